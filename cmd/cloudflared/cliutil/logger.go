@@ -41,6 +41,36 @@ func ConfigureLoggingFlags(shouldHide bool) []cli.Flag {
 			EnvVars: []string{"TUNNEL_LOGDIRECTORY"},
 			Hidden:  shouldHide,
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    logger.LogOutputFlag,
+			Usage:   "Also send application log to this destination: syslog://host[:port] or syslog+udp://host[:port], syslog+tcp://host[:port], syslog+unix:///path (syslog:// for the local syslog), journald:// or eventlog:// (the Windows Event Log, for the events of the tunnel and errors). Can be repeated.",
+			EnvVars: []string{"TUNNEL_LOG_OUTPUT"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    logger.LogSampleFlag,
+			Usage:   "Limit the identical events of a level logged every minute, as LEVEL=FIRST:THEREAFTER: the first FIRST are logged, then one in THEREAFTER with the number of events that weren't, e.g. error=10:100. Can be repeated for each level.",
+			EnvVars: []string{"TUNNEL_LOG_SAMPLE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    logger.LogMaxSizeFlag,
+			Usage:   "Rotate the application log, and the access log, once it grows past this many megabytes. Rotation is enabled by any of the log-max-* flags, and defaults to 100 megabytes then.",
+			EnvVars: []string{"TUNNEL_LOG_MAX_SIZE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    logger.LogMaxAgeFlag,
+			Usage:   "Delete rotated log files older than this many days. 0 keeps them regardless of their age.",
+			EnvVars: []string{"TUNNEL_LOG_MAX_AGE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    logger.LogMaxBackupsFlag,
+			Usage:   "Keep at most this many rotated log files. 0 keeps them all, within log-max-age.",
+			EnvVars: []string{"TUNNEL_LOG_MAX_BACKUPS"},
+			Hidden:  shouldHide,
+		}),
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:    "trace-output",
 			Usage:   "Name of trace output file, generated when cloudflared stops.",
@@ -3,10 +3,14 @@ package tunnel
 import (
 	"crypto/tls"
 	"fmt"
+	"math"
 	mathRand "math/rand"
 	"net"
 	"net/netip"
+	"net/url"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +20,7 @@ import (
 	"github.com/urfave/cli/v2"
 	"github.com/urfave/cli/v2/altsrc"
 	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/http/httpproxy"
 
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
 	"github.com/cloudflare/cloudflared/config"
@@ -24,9 +29,12 @@ import (
 	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
 	"github.com/cloudflare/cloudflared/features"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/orchestration"
+	"github.com/cloudflare/cloudflared/proxy"
 	"github.com/cloudflare/cloudflared/supervisor"
 	"github.com/cloudflare/cloudflared/tlsconfig"
+	"github.com/cloudflare/cloudflared/tracing"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 )
 
@@ -132,17 +140,62 @@ func prepareTunnelConfig(
 	tags = append(tags, tunnelpogs.Tag{Name: "ID", Value: clientID.String()})
 
 	transportProtocol := c.String("protocol")
-	needPQ := c.Bool("post-quantum")
+	pqMode, err := parsePQMode(c.String("post-quantum-mode"))
+	if err != nil {
+		return nil, nil, err
+	}
+	needPQ := c.Bool("post-quantum") && pqMode == supervisor.PQModeStrict
+	if FipsEnabled || c.Bool("fips") {
+		// Restricts the edge TLS configs below, as well as the origin ones once the ingress rules start
+		tlsconfig.EnableFIPS()
+	}
+	if c.Bool("post-quantum") && tlsconfig.FIPSEnabled() {
+		return nil, nil, fmt.Errorf("post-quantum not supported in FIPS mode")
+	}
+	if !c.Bool("post-quantum") {
+		pqMode = ""
+	}
 	if needPQ {
-		if FipsEnabled {
-			return nil, nil, fmt.Errorf("post-quantum not supported in FIPS mode")
-		}
 		// Error if the user tries to force a non-quic transport protocol
 		if transportProtocol != connection.AutoSelectFlag && transportProtocol != connection.QUIC.String() {
 			return nil, nil, fmt.Errorf("post-quantum is only supported with the quic transport")
 		}
 		transportProtocol = connection.QUIC.String()
 	}
+	edgeProxyURL, err := parseEdgeProxyURL(c.String("proxy-url"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.IsSet("edge-socks5") {
+		socksURL, err := parseEdgeSOCKS5(c.String("edge-socks5"))
+		if err != nil {
+			return nil, nil, err
+		}
+		if edgeProxyURL != nil {
+			log.Warn().Msgf("Connecting to the edge through SOCKS5 proxy %s rather than proxy %s", socksURL.Redacted(), edgeProxyURL.Redacted())
+		}
+		edgeProxyURL = socksURL
+	}
+	var (
+		edgeProxy     func(*url.URL) (*url.URL, error)
+		edgeProxyName string
+	)
+	if edgeProxyURL != nil {
+		edgeProxy = func(*url.URL) (*url.URL, error) { return edgeProxyURL, nil }
+		edgeProxyName = edgeProxyURL.Redacted()
+	} else if edgeProxy = edgeProxyFromEnvironment(); edgeProxy != nil {
+		edgeProxyName = "from HTTPS_PROXY"
+	}
+	if edgeProxy != nil {
+		// QUIC runs over UDP, which can't be tunneled through these proxies
+		if transportProtocol == connection.QUIC.String() {
+			log.Warn().Msgf("Not connecting to the edge through proxy %s, which only supports the http2 transport", edgeProxyName)
+			edgeProxy = nil
+		} else {
+			log.Info().Msgf("Connecting to the edge through proxy %s over the http2 transport", edgeProxyName)
+			transportProtocol = connection.HTTP2.String()
+		}
+	}
 
 	clientFeatures := dedup(append(c.StringSlice("features"), features.DefaultFeatures...))
 	if needPQ {
@@ -160,12 +213,33 @@ func prepareTunnelConfig(
 		return nil, nil, err
 	}
 
-	protocolSelector, err := connection.NewProtocolSelector(transportProtocol, namedTunnel.Credentials.AccountTag, c.IsSet(TunnelTokenFlag), c.Bool("post-quantum"), edgediscovery.ProtocolPercentage, connection.ResolveTTL, log)
+	edgeResolver, err := edgediscovery.NewResolver(c.String("edge-discovery-resolver"))
+	if err != nil {
+		return nil, nil, err
+	}
+	resolveJitter, err := parseJitter("protocol-resolve-jitter", c.Float64("protocol-resolve-jitter"))
+	if err != nil {
+		return nil, nil, err
+	}
+	retryJitter, err := parseJitter("retry-jitter", c.Float64("retry-jitter"))
+	if err != nil {
+		return nil, nil, err
+	}
+	protocolSelector, err := connection.NewProtocolSelector(transportProtocol, namedTunnel.Credentials.AccountTag, c.IsSet(TunnelTokenFlag), needPQ, edgediscovery.ProtocolPercentageFetcher(edgeResolver), connection.ResolveTTL, resolveJitter, log)
 	if err != nil {
 		return nil, nil, err
 	}
 	log.Info().Msgf("Initial protocol %s", protocolSelector.Current())
 
+	edgeCertPins, err := tlsconfig.ParseSPKIPins(c.StringSlice("edge-cert-pin"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid value for edge-cert-pin")
+	}
+	// Shared by every connection, so that reconnecting resumes the TLS session of a previous connection
+	var edgeSessionCache tls.ClientSessionCache
+	if size := c.Int("edge-tls-session-cache-size"); size > 0 {
+		edgeSessionCache = tls.NewLRUClientSessionCache(size)
+	}
 	edgeTLSConfigs := make(map[connection.Protocol]*tls.Config, len(connection.ProtocolList))
 	for _, p := range connection.ProtocolList {
 		tlsSettings := p.TLSSettings()
@@ -179,6 +253,11 @@ func prepareTunnelConfig(
 		if len(tlsSettings.NextProtos) > 0 {
 			edgeTLSConfig.NextProtos = tlsSettings.NextProtos
 		}
+		tlsconfig.PinSPKI(edgeTLSConfig, edgeCertPins)
+		edgeTLSConfig.ClientSessionCache = edgeSessionCache
+		if err := tlsconfig.EnforceFIPS(edgeTLSConfig); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to create TLS config to connect with edge")
+		}
 		edgeTLSConfigs[p] = edgeTLSConfig
 	}
 
@@ -190,6 +269,22 @@ func prepareTunnelConfig(
 	if err != nil {
 		return nil, nil, err
 	}
+	if c.IsSet("edge") && c.IsSet("edge-addrs-file") {
+		return nil, nil, errors.New("--edge and --edge-addrs-file can't be used together")
+	}
+	edgeSRVRecord := allregions.SRVRecord{
+		Service: c.String("edge-srv-service"),
+		Proto:   c.String("edge-srv-proto"),
+		Name:    c.String("edge-srv-name"),
+	}
+	edgeIPFilter, err := allregions.ParseIPFilter(c.StringSlice("edge-ip-allow"), c.StringSlice("edge-ip-deny"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid edge-ip-allow or edge-ip-deny")
+	}
+	haSpread, err := parseHASpreadPolicy(c.String("ha-spread"))
+	if err != nil {
+		return nil, nil, err
+	}
 	edgeBindAddr, err := parseConfigBindAddress(c.String("edge-bind-address"))
 	if err != nil {
 		return nil, nil, err
@@ -204,14 +299,32 @@ func prepareTunnelConfig(
 	}
 
 	var pqKexIdx int
-	if needPQ {
+	if pqMode != "" {
 		pqKexIdx = mathRand.Intn(len(supervisor.PQKexes))
 		log.Info().Msgf(
-			"Using experimental hybrid post-quantum key agreement %s",
+			"Using experimental hybrid post-quantum key agreement %s (%s)",
 			supervisor.PQKexNames[supervisor.PQKexes[pqKexIdx]],
+			pqMode,
 		)
 	}
 
+	muxerConfig, err := parseMuxerConfig(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	edgeDSCP, err := parseEdgeDSCP(c.Int("edge-dscp"))
+	if err != nil {
+		return nil, nil, err
+	}
+	edgeDialTimeouts := edgediscovery.DialTimeouts{
+		Dial:         c.Duration("edge-dial-timeout"),
+		TLSHandshake: c.Duration("edge-tls-handshake-timeout"),
+		KeepAlive:    c.Duration("edge-tcp-keepalive"),
+	}
+	if edgeDialTimeouts.KeepAlive == 0 {
+		edgeDialTimeouts.KeepAlive = -1
+	}
+
 	tunnelConfig := &supervisor.TunnelConfig{
 		GracePeriod:     gracePeriod,
 		ReplaceExisting: c.Bool("force"),
@@ -231,14 +344,42 @@ func prepareTunnelConfig(
 		Observer:        observer,
 		ReportedVersion: info.Version(),
 		// Note TUN-3758 , we use Int because UInt is not supported with altsrc
-		Retries:            uint(c.Int("retries")),
-		RunFromTerminal:    isRunningFromTerminal(),
-		NamedTunnel:        namedTunnel,
-		ProtocolSelector:   protocolSelector,
-		EdgeTLSConfigs:     edgeTLSConfigs,
-		NeedPQ:             needPQ,
-		PQKexIdx:           pqKexIdx,
-		MaxEdgeAddrRetries: uint8(c.Int("max-edge-addr-retries")),
+		Retries:                   uint(c.Int("retries")),
+		RunFromTerminal:           isRunningFromTerminal(),
+		NamedTunnel:               namedTunnel,
+		ProtocolSelector:          protocolSelector,
+		EdgeTLSConfigs:            edgeTLSConfigs,
+		NeedPQ:                    needPQ,
+		PQMode:                    pqMode,
+		PQKexIdx:                  pqKexIdx,
+		MaxEdgeAddrRetries:        uint8(c.Int("max-edge-addr-retries")),
+		PreflightDNSCheck:         c.Bool("preflight-dns-check"),
+		RegistrationTimeout:       c.Duration("registration-timeout"),
+		MaxAddrsPerAttemptCycle:   c.Int("max-edge-addrs-per-attempt-cycle"),
+		RecoveryConcurrency:       c.Int("recovery-concurrency"),
+		MaxConnectionAge:          c.Duration("max-conn-age"),
+		MaxRestarts:               c.Int("max-restarts"),
+		MaxRestartsWindow:         c.Duration("max-restarts-window"),
+		EdgeAddrsFile:             c.String("edge-addrs-file"),
+		EdgeResolver:              edgeResolver,
+		EdgeSRVRecord:             edgeSRVRecord,
+		EdgeIPFilter:              edgeIPFilter,
+		EdgeAddrsCacheFile:        c.String("edge-addrs-cache"),
+		EdgeProxy:                 edgeProxy,
+		EdgeDialTimeouts:          edgeDialTimeouts,
+		EdgeDSCP:                  edgeDSCP,
+		MuxerConfig:               muxerConfig,
+		EdgeRefreshInterval:       c.Duration("edge-discovery-refresh-interval"),
+		EdgeLatencyProbeInterval:  c.Duration("edge-latency-probe-interval"),
+		EdgeQuarantineThreshold:   c.Int("edge-addr-quarantine-threshold"),
+		EdgeQuarantineCooldown:    c.Duration("edge-addr-quarantine-cooldown"),
+		HASpread:                  haSpread,
+		RetryBaseDelay:            c.Duration("retry-base-delay"),
+		RetryMaxDelay:             c.Duration("retry-max-delay"),
+		RetryJitter:               retryJitter,
+		RegistrationInterval:      c.Duration("registration-interval"),
+		LogSuccessfulConnections:  c.Bool("log-successful-connections"),
+		InterleaveAddressFamilies: c.Bool("interleave-edge-ip-versions"),
 	}
 	packetConfig, err := newPacketConfig(c, log)
 	if err != nil {
@@ -246,9 +387,27 @@ func prepareTunnelConfig(
 	} else {
 		tunnelConfig.PacketConfig = packetConfig
 	}
+	if listen := c.String("control-plane-listen"); listen != "" {
+		controlPlaneTLS, err := controlPlaneTLSConfig(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		tunnelConfig.ControlPlaneListen = listen
+		tunnelConfig.ControlPlaneTLS = controlPlaneTLS
+	}
+	bandwidthLimit := c.Int("bandwidth-limit")
+	if bandwidthLimit < 0 {
+		return nil, nil, fmt.Errorf("invalid value for bandwidth-limit: %d, expected a number of bytes per second", bandwidthLimit)
+	}
+	accessLog, err := parseAccessLog(c)
+	if err != nil {
+		return nil, nil, err
+	}
 	orchestratorConfig := &orchestration.Config{
 		Ingress:            &ingressRules,
 		WarpRouting:        ingress.NewWarpRoutingConfig(&cfg.WarpRouting),
+		BandwidthLimit:     uint64(bandwidthLimit),
+		AccessLog:          accessLog,
 		ConfigurationFlags: parseConfigFlags(c),
 	}
 	return tunnelConfig, orchestratorConfig, nil
@@ -266,6 +425,28 @@ func parseConfigFlags(c *cli.Context) map[string]string {
 	return result
 }
 
+// controlPlaneTLSConfig is the TLS config securing the control plane, which requires client certificates
+// when control-plane-client-ca is set.
+func controlPlaneTLSConfig(c *cli.Context) (*tls.Config, error) {
+	cert, key := c.String("control-plane-tls-cert"), c.String("control-plane-tls-key")
+	if cert == "" || key == "" {
+		return nil, errors.New("control-plane-tls-cert and control-plane-tls-key are required to serve the control plane")
+	}
+	params := &tlsconfig.TLSParameters{
+		Cert:       cert,
+		Key:        key,
+		MinVersion: tls.VersionTLS12,
+	}
+	if clientCA := c.String("control-plane-client-ca"); clientCA != "" {
+		params.ClientCAs = []string{clientCA}
+	}
+	tlsConfig, err := tlsconfig.GetConfig(params)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid control plane TLS configuration")
+	}
+	return tlsConfig, nil
+}
+
 func gracePeriod(c *cli.Context) (time.Duration, error) {
 	period := c.Duration("grace-period")
 	if period > connection.MaxGracePeriod {
@@ -312,6 +493,161 @@ func parseConfigIPVersion(version string) (v allregions.ConfigIPVersion, err err
 	return
 }
 
+// parsePQMode returns how to use the post-quantum key agreements from the value of post-quantum-mode, strict
+// if it's empty.
+func parsePQMode(mode string) (supervisor.PQMode, error) {
+	switch m := supervisor.PQMode(mode); m {
+	case "":
+		return supervisor.PQModeStrict, nil
+	case supervisor.PQModeStrict, supervisor.PQModePrefer:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid value for post-quantum-mode: %s", mode)
+	}
+}
+
+// parseHASpreadPolicy returns how to place the HA connections across edge regions from the value of ha-spread
+func parseHASpreadPolicy(policy string) (supervisor.HASpreadPolicy, error) {
+	switch p := supervisor.HASpreadPolicy(policy); p {
+	case supervisor.HASpreadNone, supervisor.HASpreadRegion:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid value for ha-spread: %s", policy)
+	}
+}
+
+// parseJitter returns the value of a flag giving the fraction of a delay that is randomized, which must be
+// between 0 and 1.
+func parseJitter(flag string, jitter float64) (float64, error) {
+	if jitter < 0 || jitter > 1 {
+		return 0, fmt.Errorf("invalid value for %s: %v, expected a fraction between 0 and 1", flag, jitter)
+	}
+	return jitter, nil
+}
+
+// parseEdgeProxyURL returns the HTTP(S) proxy to connect to the edge through from the value of proxy-url, nil if
+// it's empty.
+func parseEdgeProxyURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for proxy-url: %w", err)
+	}
+	if (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid value for proxy-url: %s, expected http://host:port or https://host:port", proxyURL.Redacted())
+	}
+	return proxyURL, nil
+}
+
+// edgeProxyFromEnvironment returns the proxy of HTTPS_PROXY for the edge addresses NO_PROXY doesn't exclude, nil if
+// HTTPS_PROXY isn't set.
+func edgeProxyFromEnvironment() func(*url.URL) (*url.URL, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if proxyConfig.HTTPSProxy == "" {
+		return nil
+	}
+	return proxyConfig.ProxyFunc()
+}
+
+// parseEdgeSOCKS5 returns the SOCKS5 proxy to connect to the edge through from the value of edge-socks5,
+// [user:password@]host:port.
+func parseEdgeSOCKS5(addr string) (*url.URL, error) {
+	proxyURL, err := url.Parse("socks5://" + addr)
+	if err != nil || proxyURL.Port() == "" || proxyURL.Path != "" {
+		return nil, fmt.Errorf("invalid value for edge-socks5, expected [user:password@]host:port")
+	}
+	return proxyURL, nil
+}
+
+// parseMuxerConfig returns the flow control windows and the concurrent streams of http2 connections from the
+// values of stream-window-size, max-stream-window-size, connection-window-size and max-concurrent-streams.
+func parseMuxerConfig(c *cli.Context) (connection.MuxerConfig, error) {
+	var sizes [3]uint32
+	for i, flag := range []string{"stream-window-size", "max-stream-window-size", "connection-window-size"} {
+		size := c.Int(flag)
+		if size < 0 || size > math.MaxInt32 {
+			return connection.MuxerConfig{}, fmt.Errorf("invalid value for %s: %d, expected a value between 0 and %d", flag, size, math.MaxInt32)
+		}
+		sizes[i] = uint32(size)
+	}
+	maxStreams := c.Int("max-concurrent-streams")
+	if maxStreams < 0 || int64(maxStreams) > math.MaxUint32 {
+		return connection.MuxerConfig{}, fmt.Errorf("invalid value for max-concurrent-streams: %d", maxStreams)
+	}
+	return connection.MuxerConfig{
+		StreamWindowSize:     sizes[0],
+		MaxStreamWindowSize:  sizes[1],
+		ConnectionWindowSize: sizes[2],
+		MaxConcurrentStreams: uint32(maxStreams),
+	}, nil
+}
+
+// parseAccessLog returns the access log written to access-logfile, or nil if it isn't set.
+func parseAccessLog(c *cli.Context) (*proxy.AccessLog, error) {
+	path := c.String("access-logfile")
+	if path == "" {
+		return nil, nil
+	}
+	format := c.String("access-log-format")
+	if err := proxy.ValidateAccessLogFormat(format); err != nil {
+		return nil, errors.Wrap(err, "invalid value for access-log-format")
+	}
+	file, err := logger.NewFileWriter(path, logger.RotationConfigFromContext(c))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open access-logfile")
+	}
+	return proxy.NewAccessLog(file, format)
+}
+
+// parseLatencyBuckets returns the buckets of the request latency histograms from the values of
+// metrics-latency-buckets, which can each be a comma separated list.
+func parseLatencyBuckets(values []string) ([]float64, error) {
+	var buckets []float64
+	for _, value := range values {
+		for _, bucket := range strings.Split(value, ",") {
+			seconds, err := strconv.ParseFloat(strings.TrimSpace(bucket), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for metrics-latency-buckets: %s, expected a number of seconds", bucket)
+			}
+			buckets = append(buckets, seconds)
+		}
+	}
+	return buckets, nil
+}
+
+// parseOTLPConfig returns the configuration of the export of spans to an OpenTelemetry collector.
+func parseOTLPConfig(c *cli.Context) (tracing.OTLPConfig, error) {
+	cfg := tracing.OTLPConfig{
+		Endpoint:    c.String("otlp-traces-endpoint"),
+		Headers:     make(map[string]string),
+		SampleRatio: c.Float64("otlp-traces-sample-ratio"),
+	}
+	if u, err := url.Parse(cfg.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return tracing.OTLPConfig{}, fmt.Errorf("invalid value for otlp-traces-endpoint: %s, expected an http or https URL", cfg.Endpoint)
+	}
+	for _, header := range c.StringSlice("otlp-traces-header") {
+		name, value, ok := strings.Cut(header, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return tracing.OTLPConfig{}, fmt.Errorf("invalid value for otlp-traces-header: %s, expected NAME=VALUE", header)
+		}
+		cfg.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return cfg, nil
+}
+
+// parseEdgeDSCP returns the DSCP to mark the packets of the edge connections with from the value of edge-dscp.
+func parseEdgeDSCP(dscp int) (uint8, error) {
+	if dscp < 0 || dscp > edgediscovery.MaxDSCP {
+		return 0, fmt.Errorf("invalid value for edge-dscp: %d, expected a value between 0 and %d", dscp, edgediscovery.MaxDSCP)
+	}
+	if dscp != 0 && !edgediscovery.DSCPSupported {
+		return 0, fmt.Errorf("edge-dscp isn't supported on %s", runtime.GOOS)
+	}
+	return uint8(dscp), nil
+}
+
 func parseConfigBindAddress(ipstr string) (net.IP, error) {
 	// Unspecified - it's fine
 	if ipstr == "" {
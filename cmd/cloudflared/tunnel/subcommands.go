@@ -139,6 +139,13 @@ var (
 		EnvVars: []string{"TUNNEL_POST_QUANTUM"},
 		Hidden:  FipsEnabled,
 	})
+	postQuantumModeFlag = altsrc.NewStringFlag(&cli.StringFlag{
+		Name:    "post-quantum-mode",
+		Usage:   "How --post-quantum uses the hybrid post-quantum key agreements. {strict, prefer} With strict, connections only use them, over the quic transport. With prefer, they're offered first over either transport, and connections whose post-quantum handshake fails fall back to classical key agreements.",
+		EnvVars: []string{"TUNNEL_POST_QUANTUM_MODE"},
+		Value:   "strict",
+		Hidden:  FipsEnabled,
+	})
 	sortInfoByFlag = &cli.StringFlag{
 		Name:    "sort-by",
 		Value:   "createdAt",
@@ -611,6 +618,7 @@ func buildRunCommand() *cli.Command {
 		credentialsFileFlag,
 		credentialsContentsFlag,
 		postQuantumFlag,
+		postQuantumModeFlag,
 		selectProtocolFlag,
 		featuresFlag,
 		tunnelTokenFlag,
@@ -175,12 +175,128 @@ func ValidateUrl(c *cli.Context, allowURLFromArgs bool) (*url.URL, error) {
 }
 
 type UnvalidatedIngressRule struct {
-	Hostname      string              `json:"hostname,omitempty"`
-	Path          string              `json:"path,omitempty"`
-	Service       string              `json:"service,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Service  string `json:"service,omitempty"`
+	// Services are the origins requests are balanced between, when service is a list rather than a single origin.
+	Services []string `yaml:"-" json:"-"`
+	// LoadBalancingPolicy is how requests are balanced between Services.
+	LoadBalancingPolicy string `yaml:"loadBalancingPolicy" json:"loadBalancingPolicy,omitempty"`
+	// HealthCheck enables active health checks of Services, so that requests only go to healthy ones.
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck" json:"healthCheck,omitempty"`
+	// SessionAffinity sends the requests of a client to the same origin of Services.
+	SessionAffinity *SessionAffinityConfig `yaml:"sessionAffinity" json:"sessionAffinity,omitempty"`
+	// Canary sends part of the requests to a canary origin rather than to Service.
+	Canary *CanaryConfig `yaml:"canary" json:"canary,omitempty"`
+	// Mirror is an HTTP origin requests are duplicated to, without waiting for its responses.
+	Mirror string `yaml:"mirror" json:"mirror,omitempty"`
+	// Maintenance serves a static 503 page rather than proxying requests to Service, while it's being upgraded.
+	Maintenance   *MaintenanceConfig  `yaml:"maintenance" json:"maintenance,omitempty"`
 	OriginRequest OriginRequestConfig `yaml:"originRequest" json:"originRequest"`
 }
 
+// MaintenanceConfig configures the maintenance mode of an ingress rule.
+type MaintenanceConfig struct {
+	// Enabled serves the maintenance page rather than proxying requests to the service of the rule
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Path of the HTML page served, a generic page by default
+	Page string `yaml:"page" json:"page,omitempty"`
+}
+
+// CanaryConfig configures the canary origin of an ingress rule, for gradual rollouts.
+type CanaryConfig struct {
+	// Service is the canary origin, an HTTP origin like the service of the rule
+	Service string `yaml:"service" json:"service"`
+	// Percentage of the requests sent to the canary origin
+	Weight uint `yaml:"weight" json:"weight"`
+	// Request header overriding the weight, requests with it set to true go to the canary origin and requests with
+	// it set to false to the service of the rule
+	Header string `yaml:"header" json:"header,omitempty"`
+	// Request cookie overriding the weight like Header
+	Cookie string `yaml:"cookie" json:"cookie,omitempty"`
+}
+
+// SessionAffinityConfig configures how the requests of a client stick to one of the origins of an ingress rule.
+type SessionAffinityConfig struct {
+	// Type is cookie, to pin clients to an origin with a cookie set by cloudflared, or client_ip, to pick the
+	// origin by consistent hashing of the client IP
+	Type string `yaml:"type" json:"type"`
+	// Name of the cookie, cloudflared_origin by default
+	CookieName string `yaml:"cookieName" json:"cookieName,omitempty"`
+}
+
+// HealthCheckConfig configures the active health checks of the origins of an ingress rule.
+type HealthCheckConfig struct {
+	// Path requested from the origins, healthy origins respond with a status below 400
+	Path string `yaml:"path" json:"path,omitempty"`
+	// Time between two checks of an origin
+	Interval *CustomDuration `yaml:"interval" json:"interval,omitempty"`
+	// Timeout of a check
+	Timeout *CustomDuration `yaml:"timeout" json:"timeout,omitempty"`
+	// Consecutive successful checks after which an unhealthy origin is healthy again
+	HealthyThreshold *uint `yaml:"healthyThreshold" json:"healthyThreshold,omitempty"`
+	// Consecutive failed checks after which a healthy origin is unhealthy
+	UnhealthyThreshold *uint `yaml:"unhealthyThreshold" json:"unhealthyThreshold,omitempty"`
+}
+
+// ingressRuleFields has the fields of UnvalidatedIngressRule without its custom (un)marshalling.
+type ingressRuleFields UnvalidatedIngressRule
+
+// UnmarshalYAML decodes service into Services if it's a list, and into Service otherwise.
+func (r *UnvalidatedIngressRule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			if value.Content[i].Value != "service" || value.Content[i+1].Kind != yaml.SequenceNode {
+				continue
+			}
+			if err := value.Content[i+1].Decode(&r.Services); err != nil {
+				return err
+			}
+			fields := *value
+			fields.Content = append(append([]*yaml.Node{}, value.Content[:i]...), value.Content[i+2:]...)
+			return fields.Decode((*ingressRuleFields)(r))
+		}
+	}
+	return value.Decode((*ingressRuleFields)(r))
+}
+
+// UnmarshalJSON decodes service into Services if it's a list, and into Service otherwise.
+func (r *UnvalidatedIngressRule) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		*ingressRuleFields
+		Service json.RawMessage `json:"service,omitempty"`
+	}{
+		ingressRuleFields: (*ingressRuleFields)(r),
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw.Service) == 0 {
+		return nil
+	}
+	if raw.Service[0] == '[' {
+		return json.Unmarshal(raw.Service, &r.Services)
+	}
+	return json.Unmarshal(raw.Service, &r.Service)
+}
+
+// MarshalJSON encodes Services as service if they're set.
+func (r UnvalidatedIngressRule) MarshalJSON() ([]byte, error) {
+	var service interface{}
+	if len(r.Services) > 0 {
+		service = r.Services
+	} else if r.Service != "" {
+		service = r.Service
+	}
+	return json.Marshal(struct {
+		ingressRuleFields
+		Service interface{} `json:"service,omitempty"`
+	}{
+		ingressRuleFields: ingressRuleFields(r),
+		Service:           service,
+	})
+}
+
 // OriginRequestConfig is a set of optional fields that users may set to
 // customize how cloudflared sends requests to origin services. It is used to set
 // up general config that apply to all rules, and also, specific per-rule
@@ -208,6 +324,11 @@ type OriginRequestConfig struct {
 	// Path to the CA for the certificate of your origin.
 	// This option should be used only if your certificate is not signed by Cloudflare.
 	CAPool *string `yaml:"caPool" json:"caPool,omitempty"`
+	// Certificate presented to origins that require mutual TLS, either a path to a PEM file or inline PEM.
+	ClientCert *string `yaml:"clientCert" json:"clientCert,omitempty"`
+	// Private key of ClientCert, either a path to a PEM file or inline PEM. It can only be set locally, it's never
+	// serialized to JSON, e.g. when migrating the configuration to a remotely managed one.
+	ClientKey *string `yaml:"clientKey" json:"-"`
 	// Disables TLS verification of the certificate presented by your origin.
 	// Will allow any certificate from the origin to be accepted.
 	// Note: The connection from your machine to Cloudflare's Edge is still encrypted.
@@ -227,6 +348,56 @@ type OriginRequestConfig struct {
 	IPRules []IngressIPRule `yaml:"ipRules" json:"ipRules,omitempty"`
 	// Attempt to connect to origin with HTTP/2
 	Http2Origin *bool `yaml:"http2Origin" json:"http2Origin,omitempty"`
+	// Timeout after which a TCP stream to the origin is closed if no data went either way
+	TCPIdleTimeout *CustomDuration `yaml:"tcpIdleTimeout" json:"tcpIdleTimeout,omitempty"`
+	// Timeout for receiving the response headers of the origin once the request was sent, 0 means no timeout
+	ResponseHeaderTimeout *CustomDuration `yaml:"responseHeaderTimeout" json:"responseHeaderTimeout,omitempty"`
+	// Size in bytes of the largest request body sent to the origin, larger ones are rejected with a 413
+	MaxRequestBodySize *int64 `yaml:"maxRequestBodySize" json:"maxRequestBodySize,omitempty"`
+	// Version of the PROXY protocol header, v1 or v2, sent to the origin when connecting to it
+	ProxyProtocol *string `yaml:"proxyProtocol" json:"proxyProtocol,omitempty"`
+	// Prefix removed from the path of the requests sent to the origin
+	StripPrefix *string `yaml:"stripPrefix" json:"stripPrefix,omitempty"`
+	// Path of the requests sent to the origin, which can refer to the capture groups of the rule path, e.g. $1 or ${name}
+	RewritePath *string `yaml:"rewritePath" json:"rewritePath,omitempty"`
+	// Headers added to, set on and removed from the requests sent to the origin
+	RequestHeaders *HeadersConfig `yaml:"requestHeaders" json:"requestHeaders,omitempty"`
+	// Headers added to, set on and removed from the responses of the origin
+	ResponseHeaders *HeadersConfig `yaml:"responseHeaders" json:"responseHeaders,omitempty"`
+	// Strips or renames the headers Cloudflare adds to the requests sent to the origin
+	CloudflareHeaders *CloudflareHeadersConfig `yaml:"cloudflareHeaders" json:"cloudflareHeaders,omitempty"`
+	// Stops sending requests to the origin for a while once it keeps failing
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuitBreaker" json:"circuitBreaker,omitempty"`
+	// Retries the idempotent requests that fail to reach the origin or get a 502 or 503 response
+	Retry *RetryConfig `yaml:"retry" json:"retry,omitempty"`
+	// Caps the number of requests in flight to the origin
+	ConcurrencyLimit *ConcurrencyLimitConfig `yaml:"concurrencyLimit" json:"concurrencyLimit,omitempty"`
+	// Rate limits the requests to the origin
+	RateLimit *RateLimitConfig `yaml:"rateLimit" json:"rateLimit,omitempty"`
+	// Denies the requests matching filters before they reach the origin
+	Filter *FilterConfig `yaml:"filter" json:"filter,omitempty"`
+	// Serves a custom error page rather than a generic 502 when the origin can't be reached
+	ErrorPage *ErrorPageConfig `yaml:"errorPage" json:"errorPage,omitempty"`
+	// Only lets the requests of clients in these CIDRs through, as seen in the Cf-Connecting-Ip header
+	AllowIPs []string `yaml:"allowIPs" json:"allowIPs,omitempty"`
+	// Denies the requests of clients in these CIDRs, as seen in the Cf-Connecting-Ip header
+	DenyIPs []string `yaml:"denyIPs" json:"denyIPs,omitempty"`
+	// Configures the file server of fileserver: services
+	FileServer *FileServerConfig `yaml:"fileServer" json:"fileServer,omitempty"`
+	// Configures the response of http_status: services
+	StatusResponse *StatusResponseConfig `yaml:"statusResponse" json:"statusResponse,omitempty"`
+	// Caches the responses to GET and HEAD requests in memory for a short while
+	Cache *CacheConfig `yaml:"cache" json:"cache,omitempty"`
+	// Caps the throughput of the traffic to and from the origin
+	BandwidthLimit *BandwidthLimitConfig `yaml:"bandwidthLimit" json:"bandwidthLimit,omitempty"`
+	// Sends every write of the response body to the edge right away, e.g. for Server-Sent Events
+	DisableResponseBuffering *bool `yaml:"disableResponseBuffering" json:"disableResponseBuffering,omitempty"`
+	// Longest time the writes of the response body are buffered before they're sent to the edge
+	FlushInterval *CustomDuration `yaml:"flushInterval" json:"flushInterval,omitempty"`
+	// Reads the responses of the origin into memory before sending them to the edge
+	ResponseBuffer *ResponseBufferConfig `yaml:"responseBuffer" json:"responseBuffer,omitempty"`
+	// Leaves the requests matching the rule out of the access log
+	DisableAccessLog *bool `yaml:"disableAccessLog" json:"disableAccessLog,omitempty"`
 	// Access holds all access related configs
 	Access *AccessConfig `yaml:"access" json:"access,omitempty"`
 }
@@ -242,6 +413,193 @@ type AccessConfig struct {
 	AudTag []string `yaml:"audTag" json:"audTag"`
 }
 
+// HeadersConfig configures the headers of the requests sent to an origin, or of its responses. Header values can
+// refer to the ${host}, ${client_ip}, ${cf_ray} and ${path} of the request, where path is the one sent to the
+// origin. Headers are removed, then set, then added.
+type HeadersConfig struct {
+	// Add adds a value to headers, keeping the values they already have.
+	Add map[string]string `yaml:"add" json:"add,omitempty"`
+
+	// Set replaces the values of headers.
+	Set map[string]string `yaml:"set" json:"set,omitempty"`
+
+	// Remove removes headers.
+	Remove []string `yaml:"remove" json:"remove,omitempty"`
+}
+
+// CloudflareHeadersConfig configures how the headers Cloudflare adds to requests, Cf-* and CDN-Loop, are sent to
+// the origin, for origins that misbehave when they see them. It's applied after the RequestHeaders.
+type CloudflareHeadersConfig struct {
+	// Strip removes the headers.
+	Strip bool `yaml:"strip" json:"strip"`
+
+	// Keep lists the headers that aren't removed by Strip.
+	Keep []string `yaml:"keep" json:"keep,omitempty"`
+
+	// Rename sends headers under another name, e.g. Cf-Connecting-Ip as X-Real-Ip.
+	Rename map[string]string `yaml:"rename" json:"rename,omitempty"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker of an origin.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive requests that fail to reach the origin, or that get a 5xx
+	// response, after which the circuit opens. The circuit breaker is disabled when it's 0.
+	FailureThreshold uint `yaml:"failureThreshold" json:"failureThreshold"`
+
+	// Cooldown is how long requests are short-circuited once the circuit opens, 30s by default.
+	Cooldown CustomDuration `yaml:"cooldown" json:"cooldown"`
+
+	// ResponseStatus is the status of the responses to short-circuited requests, 503 by default.
+	ResponseStatus int `yaml:"responseStatus" json:"responseStatus,omitempty"`
+}
+
+// RetryConfig configures how the requests to an origin are retried.
+type RetryConfig struct {
+	// MaxAttempts is the number of times a request is sent to the origin at most, including the first attempt.
+	// Requests aren't retried when it's 0 or 1.
+	MaxAttempts uint `yaml:"maxAttempts" json:"maxAttempts"`
+
+	// Backoff is the delay before the first retry, 100ms by default. It doubles with every retry.
+	Backoff CustomDuration `yaml:"backoff" json:"backoff"`
+
+	// Methods are the methods of the requests that are retried, GET, HEAD, OPTIONS, PUT, DELETE and TRACE by
+	// default. Requests with a body are never retried.
+	Methods []string `yaml:"methods" json:"methods,omitempty"`
+
+	// StatusCodes are the statuses of the responses that are retried, 502 and 503 by default. Requests that fail
+	// to connect to the origin are always retried.
+	StatusCodes []int `yaml:"statusCodes" json:"statusCodes,omitempty"`
+}
+
+// ConcurrencyLimitConfig configures how many requests can be in flight to an origin at once.
+type ConcurrencyLimitConfig struct {
+	// MaxRequests is the number of requests that can be in flight to the origin at once. There's no limit when
+	// it's 0.
+	MaxRequests uint `yaml:"maxRequests" json:"maxRequests"`
+
+	// QueueTimeout is how long a request over the limit waits for another one to complete before it's answered
+	// with a 503. Requests over the limit are answered right away when it's 0.
+	QueueTimeout CustomDuration `yaml:"queueTimeout" json:"queueTimeout"`
+
+	// MaxQueued is the number of requests over the limit that can wait at once, further ones are answered with a
+	// 503 right away. There's no limit when it's 0.
+	MaxQueued uint `yaml:"maxQueued" json:"maxQueued"`
+
+	// RetryAfter is how long clients are told to wait before retrying the requests answered with a 503, 1s by
+	// default. It's rounded up to the second.
+	RetryAfter CustomDuration `yaml:"retryAfter" json:"retryAfter"`
+}
+
+// RateLimitConfig configures the rate limiting of the requests to an origin, enforced with a token bucket.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the rate at which requests are let through in the long run. There's no limit when it's 0.
+	RequestsPerSecond float64 `yaml:"requestsPerSecond" json:"requestsPerSecond"`
+
+	// Burst is the number of requests that can be let through at once, RequestsPerSecond rounded up by default.
+	Burst uint `yaml:"burst" json:"burst"`
+
+	// PerClientIP rate limits the requests of each client IP, as seen in the Cf-Connecting-Ip header, separately.
+	PerClientIP bool `yaml:"perClientIP" json:"perClientIP"`
+}
+
+// FilterConfig denies the requests that match any of its filters, e.g. scanners looking for well known
+// vulnerabilities, before they reach the origin. Patterns are regular expressions, matching part of the value unless
+// they're anchored.
+type FilterConfig struct {
+	// DenyMethods lists the methods that are denied, e.g. TRACE.
+	DenyMethods []string `yaml:"denyMethods" json:"denyMethods,omitempty"`
+
+	// DenyPaths lists the patterns of the paths that are denied, e.g. ^/wp-admin, once stripPrefix and rewritePath
+	// were applied.
+	DenyPaths []string `yaml:"denyPaths" json:"denyPaths,omitempty"`
+
+	// DenyHeaders maps headers to the pattern of the values that are denied.
+	DenyHeaders map[string]string `yaml:"denyHeaders" json:"denyHeaders,omitempty"`
+
+	// DenyUserAgents lists the patterns of the user agents that are denied, e.g. (?i)sqlmap.
+	DenyUserAgents []string `yaml:"denyUserAgents" json:"denyUserAgents,omitempty"`
+
+	// ResponseStatus is the status of the responses to denied requests, 403 by default.
+	ResponseStatus int `yaml:"responseStatus" json:"responseStatus"`
+}
+
+// ErrorPageConfig configures the response served when the origin can't be reached. Its status is 504 when the
+// origin timed out, and 502 otherwise.
+type ErrorPageConfig struct {
+	// Body is the inline body of the response. ${error_code} is replaced by why the origin couldn't be reached:
+	// connection_refused, timeout, tls or unreachable. ${host}, ${client_ip}, ${cf_ray} and ${path} are replaced
+	// like in requestHeaders.
+	Body string `yaml:"body" json:"body,omitempty"`
+
+	// File is the path of a file whose content is the body, when Body isn't set.
+	File string `yaml:"file" json:"file,omitempty"`
+
+	// ContentType of the body, e.g. application/json. It's text/html by default.
+	ContentType string `yaml:"contentType" json:"contentType,omitempty"`
+}
+
+// FileServerConfig configures how a fileserver: service serves the files of its directory.
+type FileServerConfig struct {
+	// ListDirectories serves a listing of the directories that don't have an index file, rather than a 404.
+	ListDirectories bool `yaml:"listDirectories" json:"listDirectories"`
+
+	// IndexFiles are the files served for a directory, the first one that exists is. It's index.html by default.
+	IndexFiles []string `yaml:"indexFiles" json:"indexFiles,omitempty"`
+
+	// CacheControl is the Cache-Control header of the responses, e.g. public, max-age=3600. There's none by default.
+	CacheControl string `yaml:"cacheControl" json:"cacheControl,omitempty"`
+
+	// DisableRanges ignores Range headers, so that files are always served whole.
+	DisableRanges bool `yaml:"disableRanges" json:"disableRanges"`
+
+	// ServeDotFiles serves the files and directories whose name starts with a dot, such as .git or .env, which
+	// aren't served or listed by default.
+	ServeDotFiles bool `yaml:"serveDotFiles" json:"serveDotFiles"`
+}
+
+// StatusResponseConfig configures the response of an http_status: service beyond its status, e.g. to serve a
+// robots.txt or a health endpoint from cloudflared itself.
+type StatusResponseConfig struct {
+	// Body is the inline body of the response.
+	Body string `yaml:"body" json:"body,omitempty"`
+
+	// File is the path of a file whose content is the body, when Body isn't set.
+	File string `yaml:"file" json:"file,omitempty"`
+
+	// Headers of the response. Content-Type is detected from the body when it isn't set.
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+}
+
+// CacheConfig configures the in-memory cache of the responses of an origin, so that bursts of identical requests
+// don't all reach it. Only responses to GET and HEAD requests without credentials, with a cacheable status and
+// without Set-Cookie or Cache-Control preventing shared caching are cached.
+type CacheConfig struct {
+	// TTL is how long responses are cached. There's no cache when it's 0.
+	TTL CustomDuration `yaml:"ttl" json:"ttl"`
+
+	// MaxSize is the total size of the cached bodies in bytes, 10MB by default. The least recently used responses
+	// are evicted first.
+	MaxSize int64 `yaml:"maxSize" json:"maxSize"`
+}
+
+// BandwidthLimitConfig caps the throughput of the traffic of an ingress rule, across all its requests and streams,
+// so that one hostname can't saturate the uplink shared with the others.
+type BandwidthLimitConfig struct {
+	// UploadBytesPerSecond caps the bytes sent to the origin per second. There's no limit when it's 0.
+	UploadBytesPerSecond uint64 `yaml:"uploadBytesPerSecond" json:"uploadBytesPerSecond"`
+
+	// DownloadBytesPerSecond caps the bytes received from the origin per second. There's no limit when it's 0.
+	DownloadBytesPerSecond uint64 `yaml:"downloadBytesPerSecond" json:"downloadBytesPerSecond"`
+}
+
+// ResponseBufferConfig configures how the responses of an origin are buffered, so that the connections to the origin
+// are released as soon as it responded rather than once slow clients received the response.
+type ResponseBufferConfig struct {
+	// MaxSize is the size in bytes of the largest response body that's buffered. Larger bodies are buffered up to
+	// MaxSize, then streamed from the origin. Responses aren't buffered when it's 0.
+	MaxSize int64 `yaml:"maxSize" json:"maxSize"`
+}
+
 type IngressIPRule struct {
 	Prefix *string `yaml:"prefix" json:"prefix"`
 	Ports  []int   `yaml:"ports" json:"ports"`
@@ -145,7 +145,11 @@ var rawJsonConfig = []byte(`
 			"allow": true
 		}
 	],
-	"http2Origin": true
+	"http2Origin": true,
+	"tcpIdleTimeout": 300,
+	"responseHeaderTimeout": 15,
+	"allowIPs": ["10.0.0.0/8"],
+	"denyIPs": ["10.1.0.0/16"]
 }
 `)
 
@@ -193,6 +197,10 @@ func assertConfig(
 	assert.Equal(t, uint(9000), *config.ProxyPort)
 	assert.Equal(t, "socks", *config.ProxyType)
 	assert.Equal(t, true, *config.Http2Origin)
+	assert.Equal(t, time.Minute*5, config.TCPIdleTimeout.Duration)
+	assert.Equal(t, time.Second*15, config.ResponseHeaderTimeout.Duration)
+	assert.Equal(t, []string{"10.0.0.0/8"}, config.AllowIPs)
+	assert.Equal(t, []string{"10.1.0.0/16"}, config.DenyIPs)
 
 	privateV4 := "10.0.0.0/8"
 	privateV6 := "fc00::/7"
@@ -218,3 +226,33 @@ func assertConfig(
 
 	require.Equal(t, config2, config)
 }
+
+func TestUnmarshalIngressRuleServices(t *testing.T) {
+	rawYAML := `
+- hostname: tunnel1.example.com
+  service: https://localhost:8000
+- hostname: tunnel2.example.com
+  service:
+    - http://localhost:8001
+    - http://localhost:8002
+  loadBalancingPolicy: least_connections
+  originRequest:
+    noTLSVerify: true
+`
+	var rules []UnvalidatedIngressRule
+	require.NoError(t, yaml.Unmarshal([]byte(rawYAML), &rules))
+	require.Len(t, rules, 2)
+	assert.Equal(t, "https://localhost:8000", rules[0].Service)
+	assert.Empty(t, rules[0].Services)
+	assert.Equal(t, "tunnel2.example.com", rules[1].Hostname)
+	assert.Empty(t, rules[1].Service)
+	assert.Equal(t, []string{"http://localhost:8001", "http://localhost:8002"}, rules[1].Services)
+	assert.Equal(t, "least_connections", rules[1].LoadBalancingPolicy)
+	assert.True(t, *rules[1].OriginRequest.NoTLSVerify)
+
+	rawJSON, err := json.Marshal(rules)
+	require.NoError(t, err)
+	var fromJSON []UnvalidatedIngressRule
+	require.NoError(t, json.Unmarshal(rawJSON, &fromJSON))
+	assert.Equal(t, rules, fromJSON)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/rs/zerolog"
 
+	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/management"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 )
@@ -26,9 +27,14 @@ type controlStream struct {
 
 	newRPCClientFunc RPCClientFunc
 
-	gracefulShutdownC <-chan struct{}
-	gracePeriod       time.Duration
-	stoppedGracefully bool
+	gracefulShutdownC   <-chan struct{}
+	gracePeriod         time.Duration
+	registrationTimeout time.Duration
+	stoppedGracefully   bool
+
+	// createdAt is right before the connection is established, to report how long it took to set up
+	createdAt    time.Time
+	logConnected bool
 }
 
 // ControlStreamHandler registers connections with origintunneld and initiates graceful shutdown.
@@ -54,6 +60,8 @@ func NewControlStream(
 	gracefulShutdownC <-chan struct{},
 	gracePeriod time.Duration,
 	protocol Protocol,
+	registrationTimeout time.Duration,
+	logConnected bool,
 ) ControlStreamHandler {
 	if newRPCClientFunc == nil {
 		newRPCClientFunc = newRegistrationRPCClient
@@ -68,6 +76,9 @@ func NewControlStream(
 		gracefulShutdownC:     gracefulShutdownC,
 		gracePeriod:           gracePeriod,
 		protocol:              protocol,
+		registrationTimeout:   registrationTimeout,
+		createdAt:             time.Now(),
+		logConnected:          logConnected,
 	}
 }
 
@@ -79,13 +90,13 @@ func (c *controlStream) ServeControlStream(
 ) error {
 	rpcClient := c.newRPCClientFunc(ctx, rw, c.observer.log)
 
-	registrationDetails, err := rpcClient.RegisterConnection(ctx, c.namedTunnelProperties, connOptions, c.connIndex, c.edgeAddress, c.observer)
+	registrationDetails, err := c.registerConnection(ctx, rpcClient, connOptions)
 	if err != nil {
 		rpcClient.Close()
 		return err
 	}
 
-	c.observer.logConnected(registrationDetails.UUID, c.connIndex, registrationDetails.Location, c.edgeAddress, c.protocol)
+	c.observer.logConnected(registrationDetails.UUID, c.connIndex, registrationDetails.Location, c.edgeAddress, c.protocol, time.Since(c.createdAt), c.logConnected)
 	c.observer.sendConnectedEvent(c.connIndex, c.protocol, registrationDetails.Location)
 	c.connectedFuse.Connected()
 
@@ -104,6 +115,25 @@ func (c *controlStream) ServeControlStream(
 	return nil
 }
 
+// registerConnection registers the connection with the edge, bounded by the registration timeout if one is set.
+func (c *controlStream) registerConnection(
+	ctx context.Context,
+	rpcClient NamedTunnelRPCClient,
+	connOptions *tunnelpogs.ConnectionOptions,
+) (*tunnelpogs.ConnectionDetails, error) {
+	if c.registrationTimeout <= 0 {
+		return rpcClient.RegisterConnection(ctx, c.namedTunnelProperties, connOptions, c.connIndex, c.edgeAddress, c.observer)
+	}
+
+	registrationCtx, cancel := context.WithTimeout(ctx, c.registrationTimeout)
+	defer cancel()
+	registrationDetails, err := rpcClient.RegisterConnection(registrationCtx, c.namedTunnelProperties, connOptions, c.connIndex, c.edgeAddress, c.observer)
+	if err != nil && ctx.Err() == nil && registrationCtx.Err() == context.DeadlineExceeded {
+		return nil, RegistrationTimeoutError{Timeout: c.registrationTimeout}
+	}
+	return registrationDetails, err
+}
+
 func (c *controlStream) waitForUnregister(ctx context.Context, rpcClient NamedTunnelRPCClient) {
 	// wait for connection termination or start of graceful shutdown
 	defer rpcClient.Close()
@@ -120,6 +150,7 @@ func (c *controlStream) waitForUnregister(ctx context.Context, rpcClient NamedTu
 		Int(management.EventTypeKey, int(management.Cloudflared)).
 		Uint8(LogFieldConnIndex, c.connIndex).
 		IPAddr(LogFieldIPAddress, c.edgeAddress).
+		Int(logger.LogFieldEventID, logger.EventIDTunnelUnregistered).
 		Msg("Unregistered tunnel connection")
 }
 
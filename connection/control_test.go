@@ -0,0 +1,134 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+)
+
+// hangingRPCClient never completes registration until the context is done.
+type hangingRPCClient struct {
+	mockNamedTunnelRPCClient
+}
+
+func (hangingRPCClient) RegisterConnection(
+	ctx context.Context,
+	properties *NamedTunnelProperties,
+	options *tunnelpogs.ConnectionOptions,
+	connIndex uint8,
+	edgeAddress net.IP,
+	observer *Observer,
+) (*tunnelpogs.ConnectionDetails, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRegistrationTimeout(t *testing.T) {
+	const registrationTimeout = 50 * time.Millisecond
+	obs := NewObserver(&log, &log)
+	controlStream := NewControlStream(
+		obs,
+		mockConnectedFuse{},
+		&NamedTunnelProperties{},
+		0,
+		nil,
+		func(context.Context, io.ReadWriteCloser, *zerolog.Logger) NamedTunnelRPCClient {
+			return hangingRPCClient{}
+		},
+		nil,
+		time.Second,
+		HTTP2,
+		registrationTimeout,
+		true,
+	)
+
+	start := time.Now()
+	err := controlStream.ServeControlStream(context.Background(), nil, &tunnelpogs.ConnectionOptions{}, nil)
+	assert.Equal(t, RegistrationTimeoutError{Timeout: registrationTimeout}, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRegistrationCancelledIsNotTimeout(t *testing.T) {
+	obs := NewObserver(&log, &log)
+	controlStream := NewControlStream(
+		obs,
+		mockConnectedFuse{},
+		&NamedTunnelProperties{},
+		0,
+		nil,
+		func(context.Context, io.ReadWriteCloser, *zerolog.Logger) NamedTunnelRPCClient {
+			return hangingRPCClient{}
+		},
+		nil,
+		time.Second,
+		HTTP2,
+		time.Minute,
+		true,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := controlStream.ServeControlStream(ctx, nil, &tunnelpogs.ConnectionOptions{}, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestLogSuccessfulConnection(t *testing.T) {
+	for _, logSuccess := range []bool{true, false} {
+		var logOutput bytes.Buffer
+		log := zerolog.New(&logOutput)
+		obs := NewObserver(&log, &log)
+		controlStream := NewControlStream(
+			obs,
+			mockConnectedFuse{},
+			&NamedTunnelProperties{},
+			1,
+			net.ParseIP("198.41.200.13"),
+			func(context.Context, io.ReadWriteCloser, *zerolog.Logger) NamedTunnelRPCClient {
+				return mockNamedTunnelRPCClient{
+					registered:   make(chan struct{}),
+					unregistered: make(chan struct{}),
+				}
+			},
+			nil,
+			time.Second,
+			QUIC,
+			0,
+			logSuccess,
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := controlStream.ServeControlStream(ctx, nil, &tunnelpogs.ConnectionOptions{}, nil)
+		require.NoError(t, err)
+
+		var registered map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(logOutput.String()), "\n") {
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			if entry["message"] == "Registered tunnel connection" {
+				registered = entry
+			}
+		}
+		if !logSuccess {
+			assert.Nil(t, registered)
+			continue
+		}
+		require.NotNil(t, registered)
+		assert.Equal(t, "info", registered["level"])
+		assert.EqualValues(t, 1, registered[LogFieldConnIndex])
+		assert.Equal(t, "198.41.200.13", registered[LogFieldIPAddress])
+		assert.Equal(t, "LIS", registered[LogFieldLocation])
+		assert.Equal(t, QUIC.String(), registered[LogFieldProtocol])
+		assert.Contains(t, registered, LogFieldSetupDuration)
+	}
+}
@@ -1,6 +1,9 @@
 package connection
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/cloudflare/cloudflared/edgediscovery"
 	"github.com/cloudflare/cloudflared/h2mux"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
@@ -18,6 +21,15 @@ func (e DupConnRegisterTunnelError) Error() string {
 	return "already connected to this server, trying another address"
 }
 
+// RegistrationTimeoutError is returned when the edge didn't answer the connection registration in time
+type RegistrationTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e RegistrationTimeoutError) Error() string {
+	return fmt.Sprintf("connection registration did not complete within %s", e.Timeout)
+}
+
 // Dial to edge server with quic failed
 type EdgeQuicDialError struct {
 	Cause error
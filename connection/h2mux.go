@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"golang.org/x/net/http2"
 
 	"github.com/cloudflare/cloudflared/h2mux"
 )
@@ -17,6 +18,14 @@ type MuxerConfig struct {
 	MaxHeartbeats      uint64
 	CompressionSetting h2mux.CompressionSetting
 	MetricsUpdateFreq  time.Duration
+	// StreamWindowSize is the initial flow control window of each stream, which may grow up to
+	// MaxStreamWindowSize. Zero means the default of each transport.
+	StreamWindowSize    uint32
+	MaxStreamWindowSize uint32
+	// ConnectionWindowSize is the flow control window of the whole http2 connection. Zero means the default.
+	ConnectionWindowSize uint32
+	// MaxConcurrentStreams bounds how many http2 streams the edge may open at once. Zero means no bound.
+	MaxConcurrentStreams uint32
 }
 
 func (mc *MuxerConfig) H2MuxerConfig(h h2mux.MuxedStreamHandler, log *zerolog.Logger) *h2mux.MuxerConfig {
@@ -28,5 +37,24 @@ func (mc *MuxerConfig) H2MuxerConfig(h h2mux.MuxedStreamHandler, log *zerolog.Lo
 		MaxHeartbeats:      mc.MaxHeartbeats,
 		Log:                log,
 		CompressionQuality: mc.CompressionSetting,
+		DefaultWindowSize:  mc.StreamWindowSize,
+		MaxWindowSize:      mc.MaxStreamWindowSize,
 	}
 }
+
+// HTTP2Server returns the server of http2 connections. Its streams don't grow their flow control window, so
+// they're given the larger of StreamWindowSize and MaxStreamWindowSize.
+func (mc *MuxerConfig) HTTP2Server() *http2.Server {
+	server := &http2.Server{
+		MaxConcurrentStreams:         mc.MaxConcurrentStreams,
+		MaxUploadBufferPerStream:     int32(mc.StreamWindowSize),
+		MaxUploadBufferPerConnection: int32(mc.ConnectionWindowSize),
+	}
+	if mc.MaxStreamWindowSize > mc.StreamWindowSize {
+		server.MaxUploadBufferPerStream = int32(mc.MaxStreamWindowSize)
+	}
+	if server.MaxConcurrentStreams == 0 {
+		server.MaxConcurrentStreams = MaxConcurrentStreams
+	}
+	return server
+}
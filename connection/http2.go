@@ -16,6 +16,7 @@ import (
 	"github.com/rs/zerolog"
 	"golang.org/x/net/http2"
 
+	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/tracing"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 )
@@ -57,14 +58,13 @@ func NewHTTP2Connection(
 	connOptions *tunnelpogs.ConnectionOptions,
 	observer *Observer,
 	connIndex uint8,
+	muxerConfig *MuxerConfig,
 	controlStreamHandler ControlStreamHandler,
 	log *zerolog.Logger,
 ) *HTTP2Connection {
 	return &HTTP2Connection{
-		conn: conn,
-		server: &http2.Server{
-			MaxConcurrentStreams: MaxConcurrentStreams,
-		},
+		conn:                 newMeteredConn(conn, observer.metrics, connIndex),
+		server:               muxerConfig.HTTP2Server(),
 		orchestrator:         orchestrator,
 		connOptions:          connOptions,
 		observer:             observer,
@@ -81,6 +81,7 @@ func (c *HTTP2Connection) Serve(ctx context.Context) error {
 		<-ctx.Done()
 		c.close()
 	}()
+	go reportRTT(ctx, c.conn, c.observer.metrics, c.connIndex)
 	c.server.ServeConn(c.conn, &http2.ServeConnOpts{
 		Context: ctx,
 		Handler: c,
@@ -92,7 +93,7 @@ func (c *HTTP2Connection) Serve(ctx context.Context) error {
 	case c.controlStreamErr != nil:
 		return c.controlStreamErr
 	default:
-		c.observer.log.Info().Uint8(LogFieldConnIndex, c.connIndex).Msg("Lost connection with the edge")
+		c.observer.log.Info().Uint8(LogFieldConnIndex, c.connIndex).Int(logger.LogFieldEventID, logger.EventIDTunnelDisconnected).Msg("Lost connection with the edge")
 		return errEdgeConnectionClosed
 	}
 }
@@ -129,6 +130,7 @@ func (c *HTTP2Connection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	case TypeWebsocket, TypeHTTP:
 		stripWebsocketUpgradeHeader(r)
+		c.observer.metrics.requestServed(c.connIndex)
 		// Check for tracing on request
 		tr := tracing.NewTracedHTTPRequest(r, c.connIndex, c.log)
 		if err := originProxy.ProxyHTTP(respWriter, tr, connType == TypeWebsocket); err != nil {
@@ -136,6 +138,7 @@ func (c *HTTP2Connection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 	case TypeTCP:
+		c.observer.metrics.requestServed(c.connIndex)
 		host, err := getRequestHost(r)
 		if err != nil {
 			requestErr = fmt.Errorf(`cloudflared received a warp-routing request with an empty host value: %w`, err)
@@ -362,6 +365,14 @@ func (rp *http2RespWriter) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
+// Flush sends the response written so far to the edge.
+func (rp *http2RespWriter) Flush() {
+	if rp.hijacked() {
+		return
+	}
+	rp.flusher.Flush()
+}
+
 func (rp *http2RespWriter) Close() error {
 	return nil
 }
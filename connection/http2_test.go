@@ -44,6 +44,8 @@ func newTestHTTP2Connection() (*HTTP2Connection, net.Conn) {
 		nil,
 		1*time.Second,
 		HTTP2,
+		0,
+		true,
 	)
 	return NewHTTP2Connection(
 		cfdConn,
@@ -52,6 +54,7 @@ func newTestHTTP2Connection() (*HTTP2Connection, net.Conn) {
 		&pogs.ConnectionOptions{},
 		obs,
 		connIndex,
+		&MuxerConfig{},
 		controlStream,
 		&log,
 	), edgeConn
@@ -368,6 +371,8 @@ func TestServeControlStream(t *testing.T) {
 		nil,
 		1*time.Second,
 		HTTP2,
+		0,
+		true,
 	)
 	http2Conn.controlStreamHandler = controlStream
 
@@ -420,6 +425,8 @@ func TestFailRegistration(t *testing.T) {
 		nil,
 		1*time.Second,
 		HTTP2,
+		0,
+		true,
 	)
 	http2Conn.controlStreamHandler = controlStream
 
@@ -468,6 +475,8 @@ func TestGracefulShutdownHTTP2(t *testing.T) {
 		shutdownC,
 		1*time.Second,
 		HTTP2,
+		0,
+		true,
 	)
 
 	http2Conn.controlStreamHandler = controlStream
@@ -578,3 +587,20 @@ func BenchmarkServeHTTPLargeFile(b *testing.B) {
 
 	benchmarkServeHTTP(b, test)
 }
+
+func TestMuxerConfigHTTP2Server(t *testing.T) {
+	server := (&MuxerConfig{}).HTTP2Server()
+	assert.Equal(t, uint32(MaxConcurrentStreams), server.MaxConcurrentStreams)
+	assert.Zero(t, server.MaxUploadBufferPerStream)
+	assert.Zero(t, server.MaxUploadBufferPerConnection)
+
+	server = (&MuxerConfig{
+		StreamWindowSize:     1 << 20,
+		MaxStreamWindowSize:  1 << 24,
+		ConnectionWindowSize: 1 << 26,
+		MaxConcurrentStreams: 100,
+	}).HTTP2Server()
+	assert.Equal(t, uint32(100), server.MaxConcurrentStreams)
+	assert.Equal(t, int32(1<<24), server.MaxUploadBufferPerStream)
+	assert.Equal(t, int32(1<<26), server.MaxUploadBufferPerConnection)
+}
@@ -0,0 +1,83 @@
+package connection
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// rttReportInterval is how often the RTT of http2 connections is read from the kernel.
+const rttReportInterval = 10 * time.Second
+
+// meteredConn records the bytes read from and written to a connection with the edge in the per connection
+// metrics.
+type meteredConn struct {
+	net.Conn
+	metrics   *tunnelMetrics
+	connIndex uint8
+}
+
+// meteredTLSConn is a meteredConn that still exposes the TLS connection state, which the http2 server
+// checks the negotiated TLS version of.
+type meteredTLSConn struct {
+	*meteredConn
+	tlsConn *tls.Conn
+}
+
+func newMeteredConn(conn net.Conn, metrics *tunnelMetrics, connIndex uint8) net.Conn {
+	metered := &meteredConn{Conn: conn, metrics: metrics, connIndex: connIndex}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		return &meteredTLSConn{meteredConn: metered, tlsConn: tlsConn}
+	}
+	return metered
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.metrics.bytesReceived(c.connIndex, n)
+	}
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.metrics.bytesSent(c.connIndex, n)
+	}
+	return n, err
+}
+
+func (c *meteredTLSConn) ConnectionState() tls.ConnectionState {
+	return c.tlsConn.ConnectionState()
+}
+
+// reportRTT records the RTT the kernel measured on the TCP connection underneath conn every rttReportInterval,
+// until ctx is done. It does nothing if conn isn't a TCP connection, e.g. because it goes through a proxy, or
+// the RTT can't be read on this platform.
+func reportRTT(ctx context.Context, conn net.Conn, metrics *tunnelMetrics, connIndex uint8) {
+	if metered, ok := conn.(*meteredTLSConn); ok {
+		conn = metered.tlsConn.NetConn()
+	} else if metered, ok := conn.(*meteredConn); ok {
+		conn = metered.Conn
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(rttReportInterval)
+	defer ticker.Stop()
+	for {
+		rtt, err := tcpRTT(tcpConn)
+		if err != nil {
+			return
+		}
+		metrics.rttUpdated(connIndex, rtt)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
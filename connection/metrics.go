@@ -2,6 +2,7 @@ package connection
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -59,6 +60,30 @@ type tunnelMetrics struct {
 	userHostnamesCounts *prometheus.CounterVec
 
 	localConfigMetrics *localConfigMetrics
+
+	connInfo          *prometheus.GaugeVec
+	connRequests      *prometheus.CounterVec
+	connReconnects    *prometheus.CounterVec
+	connSentBytes     *prometheus.CounterVec
+	connReceivedBytes *prometheus.CounterVec
+	connRTT           *prometheus.GaugeVec
+	// totalSentBytes and totalReceivedBytes count the bytes of all connections
+	totalSentBytes     atomic.Uint64
+	totalReceivedBytes atomic.Uint64
+	// connLabelsLock is a mutex for connLabels
+	connLabelsLock sync.Mutex
+	// connLabels stores the protocol and edge location each connection registered with last, by index
+	connLabels map[uint8]connLabels
+}
+
+// connLabels are the labels of the per connection metrics, other than the connection index.
+type connLabels struct {
+	protocol string
+	location string
+}
+
+func (l connLabels) values(connIndex uint8) []string {
+	return []string{uint8ToString(connIndex), l.protocol, l.location}
 }
 
 func newLocalConfigMetrics() *localConfigMetrics {
@@ -415,6 +440,74 @@ func initTunnelMetrics() *tunnelMetrics {
 	)
 	prometheus.MustRegister(registerSuccess)
 
+	// Until a connection registers, its metrics are labeled with an empty protocol and edge location
+	connLabelNames := []string{"conn_index", "protocol", "edge_location"}
+	connInfo := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "connection_info",
+			Help:      "Protocol and edge location of each registered connection, always 1",
+		},
+		connLabelNames,
+	)
+	prometheus.MustRegister(connInfo)
+
+	connRequests := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "connection_requests",
+			Help:      "Count of requests proxied by each connection",
+		},
+		connLabelNames,
+	)
+	prometheus.MustRegister(connRequests)
+
+	connReconnects := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "connection_reconnects",
+			Help:      "Count of reconnections of each connection, by the protocol and edge location it was connected with last",
+		},
+		connLabelNames,
+	)
+	prometheus.MustRegister(connReconnects)
+
+	connSentBytes := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "connection_sent_bytes",
+			Help:      "Count of bytes sent to the edge by each connection",
+		},
+		connLabelNames,
+	)
+	prometheus.MustRegister(connSentBytes)
+
+	connReceivedBytes := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "connection_received_bytes",
+			Help:      "Count of bytes received from the edge by each connection",
+		},
+		connLabelNames,
+	)
+	prometheus.MustRegister(connReceivedBytes)
+
+	connRTT := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "connection_rtt",
+			Help:      "Latest RTT measured on each connection in millisec",
+		},
+		connLabelNames,
+	)
+	prometheus.MustRegister(connRTT)
+
 	return &tunnelMetrics{
 		timerRetries:        timerRetries,
 		serverLocations:     serverLocations,
@@ -426,6 +519,13 @@ func initTunnelMetrics() *tunnelMetrics {
 		rpcFail:             rpcFail,
 		userHostnamesCounts: userHostnamesCounts,
 		localConfigMetrics:  newLocalConfigMetrics(),
+		connInfo:            connInfo,
+		connRequests:        connRequests,
+		connReconnects:      connReconnects,
+		connSentBytes:       connSentBytes,
+		connReceivedBytes:   connReceivedBytes,
+		connRTT:             connRTT,
+		connLabels:          make(map[uint8]connLabels),
 	}
 }
 
@@ -445,6 +545,58 @@ func (t *tunnelMetrics) registerServerLocation(connectionID, loc string) {
 	t.oldServerLocations[connectionID] = loc
 }
 
+// registerConnection records the protocol and edge location the connection with the given index registered
+// with, which label its metrics from then on.
+func (t *tunnelMetrics) registerConnection(connIndex uint8, protocol Protocol, location string) {
+	t.connLabelsLock.Lock()
+	defer t.connLabelsLock.Unlock()
+	old := t.connLabels[connIndex]
+	t.connInfo.DeleteLabelValues(old.values(connIndex)...)
+	t.connRTT.DeleteLabelValues(old.values(connIndex)...)
+	labels := connLabels{protocol: protocol.String(), location: location}
+	t.connLabels[connIndex] = labels
+	t.connInfo.WithLabelValues(labels.values(connIndex)...).Set(1)
+}
+
+// unregisterConnection records that the connection with the given index isn't registered anymore. Its labels
+// are kept to count its reconnections.
+func (t *tunnelMetrics) unregisterConnection(connIndex uint8) {
+	t.connLabelsLock.Lock()
+	defer t.connLabelsLock.Unlock()
+	labels := t.connLabels[connIndex]
+	t.connInfo.DeleteLabelValues(labels.values(connIndex)...)
+	t.connRTT.DeleteLabelValues(labels.values(connIndex)...)
+}
+
+func (t *tunnelMetrics) reconnecting(connIndex uint8) {
+	t.unregisterConnection(connIndex)
+	t.connReconnects.WithLabelValues(t.labelsOf(connIndex)...).Inc()
+}
+
+func (t *tunnelMetrics) requestServed(connIndex uint8) {
+	t.connRequests.WithLabelValues(t.labelsOf(connIndex)...).Inc()
+}
+
+func (t *tunnelMetrics) bytesSent(connIndex uint8, n int) {
+	t.connSentBytes.WithLabelValues(t.labelsOf(connIndex)...).Add(float64(n))
+	t.totalSentBytes.Add(uint64(n))
+}
+
+func (t *tunnelMetrics) bytesReceived(connIndex uint8, n int) {
+	t.connReceivedBytes.WithLabelValues(t.labelsOf(connIndex)...).Add(float64(n))
+	t.totalReceivedBytes.Add(uint64(n))
+}
+
+func (t *tunnelMetrics) rttUpdated(connIndex uint8, rtt time.Duration) {
+	t.connRTT.WithLabelValues(t.labelsOf(connIndex)...).Set(float64(rtt) / float64(time.Millisecond))
+}
+
+func (t *tunnelMetrics) labelsOf(connIndex uint8) []string {
+	t.connLabelsLock.Lock()
+	defer t.connLabelsLock.Unlock()
+	return t.connLabels[connIndex].values(connIndex)
+}
+
 var tunnelMetricsInternal struct {
 	sync.Once
 	metrics *tunnelMetrics
@@ -3,10 +3,12 @@ package connection
 import (
 	"net"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
+	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/management"
 )
 
@@ -15,6 +17,7 @@ const (
 	LogFieldLocation          = "location"
 	LogFieldIPAddress         = "ip"
 	LogFieldProtocol          = "protocol"
+	LogFieldSetupDuration     = "setupDuration"
 	observerChannelBufferSize = 16
 )
 
@@ -46,17 +49,23 @@ func (o *Observer) RegisterSink(sink EventSink) {
 	o.addSinkChan <- sink
 }
 
-func (o *Observer) logConnected(connectionID uuid.UUID, connIndex uint8, location string, address net.IP, protocol Protocol) {
+// logConnected reports a registered connection, only logging it if logSuccess is set.
+func (o *Observer) logConnected(connectionID uuid.UUID, connIndex uint8, location string, address net.IP, protocol Protocol, setupDuration time.Duration, logSuccess bool) {
 	o.sendEvent(Event{Index: connIndex, EventType: Connected, Location: location})
-	o.log.Info().
-		Int(management.EventTypeKey, int(management.Cloudflared)).
-		Str(LogFieldConnectionID, connectionID.String()).
-		Uint8(LogFieldConnIndex, connIndex).
-		Str(LogFieldLocation, location).
-		IPAddr(LogFieldIPAddress, address).
-		Str(LogFieldProtocol, protocol.String()).
-		Msg("Registered tunnel connection")
+	if logSuccess {
+		o.log.Info().
+			Int(management.EventTypeKey, int(management.Cloudflared)).
+			Str(LogFieldConnectionID, connectionID.String()).
+			Uint8(LogFieldConnIndex, connIndex).
+			Str(LogFieldLocation, location).
+			IPAddr(LogFieldIPAddress, address).
+			Str(LogFieldProtocol, protocol.String()).
+			Dur(LogFieldSetupDuration, setupDuration).
+			Int(logger.LogFieldEventID, logger.EventIDTunnelRegistered).
+			Msg("Registered tunnel connection")
+	}
 	o.metrics.registerServerLocation(uint8ToString(connIndex), location)
+	o.metrics.registerConnection(connIndex, protocol, location)
 }
 
 func (o *Observer) sendRegisteringEvent(connIndex uint8) {
@@ -80,6 +89,7 @@ func (o *Observer) SendURL(url string) {
 
 func (o *Observer) SendReconnect(connIndex uint8) {
 	o.sendEvent(Event{Index: connIndex, EventType: Reconnecting})
+	o.metrics.reconnecting(connIndex)
 }
 
 func (o *Observer) sendUnregisteringEvent(connIndex uint8) {
@@ -88,6 +98,12 @@ func (o *Observer) sendUnregisteringEvent(connIndex uint8) {
 
 func (o *Observer) SendDisconnect(connIndex uint8) {
 	o.sendEvent(Event{Index: connIndex, EventType: Disconnected})
+	o.metrics.unregisterConnection(connIndex)
+}
+
+// BytesTransferred returns how many bytes all connections of the process sent to and received from the edge.
+func (o *Observer) BytesTransferred() (sent, received uint64) {
+	return o.metrics.totalSentBytes.Load(), o.metrics.totalReceivedBytes.Load()
 }
 
 func (o *Observer) sendEvent(e Event) {
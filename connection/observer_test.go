@@ -1,13 +1,19 @@
 package connection
 
 import (
+	"context"
+	"io"
+	"net"
+	"runtime"
 	"strconv"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/quic-go/quic-go/logging"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,6 +68,45 @@ func TestRegisterServerLocation(t *testing.T) {
 
 }
 
+func TestConnectionMetricLabels(t *testing.T) {
+	observer := NewObserver(&log, &log)
+	const connIndex = 200
+	countOf := func(metric *prometheus.CounterVec, protocol, location string) float64 {
+		m := &dto.Metric{}
+		assert.NoError(t, metric.WithLabelValues(strconv.Itoa(connIndex), protocol, location).Write(m))
+		return m.Counter.GetValue()
+	}
+	infoCount := func() int {
+		metrics := make(chan prometheus.Metric, 256)
+		observer.metrics.connInfo.Collect(metrics)
+		return len(metrics)
+	}
+	// The metrics are registered once per process, so only their changes are asserted
+	before := infoCount()
+	quicRequests := countOf(observer.metrics.connRequests, "quic", "LHR")
+	http2Requests := countOf(observer.metrics.connRequests, "http2", "AMS")
+	quicReconnects := countOf(observer.metrics.connReconnects, "quic", "LHR")
+
+	observer.logConnected(uuid.New(), connIndex, "LHR", nil, QUIC, time.Second, false)
+	observer.metrics.requestServed(connIndex)
+	observer.metrics.requestServed(connIndex)
+	assert.Equal(t, quicRequests+2, countOf(observer.metrics.connRequests, "quic", "LHR"))
+	assert.Equal(t, before+1, infoCount())
+
+	observer.SendReconnect(connIndex)
+	assert.Equal(t, quicReconnects+1, countOf(observer.metrics.connReconnects, "quic", "LHR"))
+	assert.Equal(t, before, infoCount())
+
+	observer.logConnected(uuid.New(), connIndex, "AMS", nil, HTTP2, time.Second, false)
+	observer.metrics.requestServed(connIndex)
+	assert.Equal(t, http2Requests+1, countOf(observer.metrics.connRequests, "http2", "AMS"))
+	assert.Equal(t, quicRequests+2, countOf(observer.metrics.connRequests, "quic", "LHR"))
+	assert.Equal(t, before+1, infoCount())
+
+	observer.SendDisconnect(connIndex)
+	assert.Equal(t, before, infoCount())
+}
+
 func TestObserverEventsDontBlock(t *testing.T) {
 	observer := NewObserver(&log, &log)
 	var mu sync.Mutex
@@ -102,3 +147,93 @@ func (s *eventCollectorSink) assertSawEvent(t *testing.T, event Event) {
 	defer s.mu.Unlock()
 	assert.Contains(t, s.observedEvents, event)
 }
+
+func TestConnectionBytesAndRTTMetrics(t *testing.T) {
+	observer := NewObserver(&log, &log)
+	const connIndex = 201
+	valueOf := func(collector prometheus.Collector, protocol, location string) float64 {
+		m := &dto.Metric{}
+		switch metric := collector.(type) {
+		case *prometheus.CounterVec:
+			assert.NoError(t, metric.WithLabelValues(strconv.Itoa(connIndex), protocol, location).Write(m))
+			return m.Counter.GetValue()
+		case *prometheus.GaugeVec:
+			assert.NoError(t, metric.WithLabelValues(strconv.Itoa(connIndex), protocol, location).Write(m))
+			return m.Gauge.GetValue()
+		}
+		return 0
+	}
+	rttCount := func() int {
+		metrics := make(chan prometheus.Metric, 256)
+		observer.metrics.connRTT.Collect(metrics)
+		return len(metrics)
+	}
+	// The metrics are registered once per process, so only their changes are asserted
+	quicSent := valueOf(observer.metrics.connSentBytes, "quic", "LHR")
+	quicReceived := valueOf(observer.metrics.connReceivedBytes, "quic", "LHR")
+	http2Sent := valueOf(observer.metrics.connSentBytes, "http2", "AMS")
+	http2Received := valueOf(observer.metrics.connReceivedBytes, "http2", "AMS")
+	totalSent, totalReceived := observer.BytesTransferred()
+	observer.metrics.connRTT.Reset()
+
+	observer.logConnected(uuid.New(), connIndex, "LHR", nil, QUIC, time.Second, false)
+	tracer := &metricsTracer{metrics: observer.metrics, connIndex: connIndex}
+	tracer.SentShortHeaderPacket(nil, 100, nil, nil)
+	tracer.SentLongHeaderPacket(nil, 20, nil, nil)
+	tracer.ReceivedShortHeaderPacket(nil, 50, nil)
+	rttStats := &logging.RTTStats{}
+	rttStats.UpdateRTT(30*time.Millisecond, 0, time.Now())
+	tracer.UpdatedMetrics(rttStats, 0, 0, 0)
+	assert.Equal(t, quicSent+120, valueOf(observer.metrics.connSentBytes, "quic", "LHR"))
+	assert.Equal(t, quicReceived+50, valueOf(observer.metrics.connReceivedBytes, "quic", "LHR"))
+	assert.Equal(t, 30.0, valueOf(observer.metrics.connRTT, "quic", "LHR"))
+
+	// The RTT of the previous registration isn't reported anymore
+	observer.SendReconnect(connIndex)
+	assert.Equal(t, 0, rttCount())
+
+	observer.logConnected(uuid.New(), connIndex, "AMS", nil, HTTP2, time.Second, false)
+	edgeConn, originConn := net.Pipe()
+	defer edgeConn.Close()
+	conn := newMeteredConn(originConn, observer.metrics, connIndex)
+	go func() {
+		_, _ = edgeConn.Write([]byte("request"))
+		_, _ = io.ReadFull(edgeConn, make([]byte, len("response")))
+	}()
+	_, err := io.ReadFull(conn, make([]byte, len("request")))
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("response"))
+	assert.NoError(t, err)
+	assert.Equal(t, http2Sent+float64(len("response")), valueOf(observer.metrics.connSentBytes, "http2", "AMS"))
+	assert.Equal(t, http2Received+float64(len("request")), valueOf(observer.metrics.connReceivedBytes, "http2", "AMS"))
+	sent, received := observer.BytesTransferred()
+	assert.Equal(t, totalSent+120+uint64(len("response")), sent)
+	assert.Equal(t, totalReceived+50+uint64(len("request")), received)
+
+	observer.SendDisconnect(connIndex)
+	assert.Equal(t, 0, rttCount())
+}
+
+func TestReportRTTOfTCPConnection(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the RTT of TCP connections is only read on Linux")
+	}
+	observer := NewObserver(&log, &log)
+	const connIndex = 202
+	observer.metrics.connRTT.Reset()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	tcpConn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer tcpConn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The RTT is reported right away, then every rttReportInterval until ctx is done
+	reportRTT(ctx, newMeteredConn(tcpConn, observer.metrics, connIndex), observer.metrics, connIndex)
+	metrics := make(chan prometheus.Metric, 256)
+	observer.metrics.connRTT.Collect(metrics)
+	assert.Len(t, metrics, 1)
+}
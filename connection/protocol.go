@@ -3,6 +3,7 @@ package connection
 import (
 	"fmt"
 	"hash/fnv"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -114,7 +115,10 @@ type remoteProtocolSelector struct {
 	fetchFunc       edgediscovery.PercentageFetcher
 	refreshAfter    time.Time
 	ttl             time.Duration
-	log             *zerolog.Logger
+	// ttlJitter is the fraction of ttl each refresh interval is randomly shortened or lengthened by, so that
+	// instances started together don't resolve in lockstep.
+	ttlJitter float64
+	log       *zerolog.Logger
 }
 
 func newRemoteProtocolSelector(
@@ -123,17 +127,29 @@ func newRemoteProtocolSelector(
 	switchThreshold int32,
 	fetchFunc edgediscovery.PercentageFetcher,
 	ttl time.Duration,
+	ttlJitter float64,
 	log *zerolog.Logger,
 ) *remoteProtocolSelector {
-	return &remoteProtocolSelector{
+	s := &remoteProtocolSelector{
 		current:         current,
 		protocolPool:    protocolPool,
 		switchThreshold: switchThreshold,
 		fetchFunc:       fetchFunc,
-		refreshAfter:    time.Now().Add(ttl),
 		ttl:             ttl,
+		ttlJitter:       ttlJitter,
 		log:             log,
 	}
+	s.refreshAfter = time.Now().Add(s.refreshInterval())
+	return s
+}
+
+// refreshInterval returns the ttl, shortened or lengthened by a random amount of up to ttlJitter of it.
+func (s *remoteProtocolSelector) refreshInterval() time.Duration {
+	maxJitter := int64(float64(s.ttl) * s.ttlJitter)
+	if maxJitter <= 0 {
+		return s.ttl
+	}
+	return s.ttl + time.Duration(rand.Int63n(2*maxJitter+1)-maxJitter)
 }
 
 func (s *remoteProtocolSelector) Current() Protocol {
@@ -150,7 +166,7 @@ func (s *remoteProtocolSelector) Current() Protocol {
 	}
 	s.current = protocol
 
-	s.refreshAfter = time.Now().Add(s.ttl)
+	s.refreshAfter = time.Now().Add(s.refreshInterval())
 	return s.current
 }
 
@@ -209,6 +225,7 @@ func NewProtocolSelector(
 	needPQ bool,
 	protocolFetcher edgediscovery.PercentageFetcher,
 	resolveTTL time.Duration,
+	resolveTTLJitter float64,
 	log *zerolog.Logger,
 ) (ProtocolSelector, error) {
 	// With --post-quantum, we force quic
@@ -243,7 +260,7 @@ func NewProtocolSelector(
 		if tunnelTokenProvided {
 			return newDefaultProtocolSelector(QUIC), nil
 		}
-		return newRemoteProtocolSelector(fetchedProtocol, ProtocolList, threshold, protocolFetcher, resolveTTL, log), nil
+		return newRemoteProtocolSelector(fetchedProtocol, ProtocolList, threshold, protocolFetcher, resolveTTL, resolveTTLJitter, log), nil
 	}
 
 	return nil, fmt.Errorf("Unknown protocol %s, %s", protocolFlag, AvailableProtocolFlagMessage)
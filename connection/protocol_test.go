@@ -3,6 +3,7 @@ package connection
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -87,7 +88,7 @@ func TestNewProtocolSelector(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			selector, err := NewProtocolSelector(test.protocol, testAccountTag, test.tunnelTokenProvided, test.needPQ, fetcher.fetch(), ResolveTTL, &log)
+			selector, err := NewProtocolSelector(test.protocol, testAccountTag, test.tunnelTokenProvided, test.needPQ, fetcher.fetch(), ResolveTTL, 0, &log)
 			if test.wantErr {
 				assert.Error(t, err, fmt.Sprintf("test %s failed", test.name))
 			} else {
@@ -105,7 +106,7 @@ func TestNewProtocolSelector(t *testing.T) {
 
 func TestAutoProtocolSelectorRefresh(t *testing.T) {
 	fetcher := dynamicMockFetcher{}
-	selector, err := NewProtocolSelector(AutoSelectFlag, testAccountTag, false, false, fetcher.fetch(), testNoTTL, &log)
+	selector, err := NewProtocolSelector(AutoSelectFlag, testAccountTag, false, false, fetcher.fetch(), testNoTTL, 0, &log)
 	assert.NoError(t, err)
 	assert.Equal(t, QUIC, selector.Current())
 
@@ -132,10 +133,37 @@ func TestAutoProtocolSelectorRefresh(t *testing.T) {
 	assert.Equal(t, QUIC, selector.Current())
 }
 
+func TestAutoProtocolSelectorJitteredRefresh(t *testing.T) {
+	const (
+		ttl       = time.Hour
+		ttlJitter = 0.2
+	)
+	fetcher := dynamicMockFetcher{}
+	selector, err := NewProtocolSelector(AutoSelectFlag, testAccountTag, false, false, fetcher.fetch(), ttl, ttlJitter, &log)
+	assert.NoError(t, err)
+	remoteSelector := selector.(*remoteProtocolSelector)
+
+	minInterval := time.Duration(float64(ttl) * (1 - ttlJitter))
+	maxInterval := time.Duration(float64(ttl) * (1 + ttlJitter))
+	intervals := make(map[time.Duration]struct{})
+	for i := 0; i < 20; i++ {
+		// expire the current interval so that Current re-resolves
+		remoteSelector.refreshAfter = time.Now().Add(-time.Second)
+		before := time.Now()
+		selector.Current()
+		interval := remoteSelector.refreshAfter.Sub(before)
+
+		assert.GreaterOrEqual(t, interval, minInterval)
+		assert.LessOrEqual(t, interval, maxInterval+time.Second)
+		intervals[interval.Truncate(time.Second)] = struct{}{}
+	}
+	assert.Greater(t, len(intervals), 1, "re-resolution interval isn't jittered")
+}
+
 func TestHTTP2ProtocolSelectorRefresh(t *testing.T) {
 	fetcher := dynamicMockFetcher{}
 	// Since the user chooses http2 on purpose, we always stick to it.
-	selector, err := NewProtocolSelector(HTTP2.String(), testAccountTag, false, false, fetcher.fetch(), testNoTTL, &log)
+	selector, err := NewProtocolSelector(HTTP2.String(), testAccountTag, false, false, fetcher.fetch(), testNoTTL, 0, &log)
 	assert.NoError(t, err)
 	assert.Equal(t, HTTP2, selector.Current())
 
@@ -164,7 +192,7 @@ func TestHTTP2ProtocolSelectorRefresh(t *testing.T) {
 
 func TestAutoProtocolSelectorNoRefreshWithToken(t *testing.T) {
 	fetcher := dynamicMockFetcher{}
-	selector, err := NewProtocolSelector(AutoSelectFlag, testAccountTag, true, false, fetcher.fetch(), testNoTTL, &log)
+	selector, err := NewProtocolSelector(AutoSelectFlag, testAccountTag, true, false, fetcher.fetch(), testNoTTL, 0, &log)
 	assert.NoError(t, err)
 	assert.Equal(t, QUIC, selector.Current())
 
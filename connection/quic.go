@@ -18,12 +18,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/cloudflare/cloudflared/datagramsession"
+	"github.com/cloudflare/cloudflared/edgediscovery"
 	"github.com/cloudflare/cloudflared/ingress"
 	"github.com/cloudflare/cloudflared/management"
 	"github.com/cloudflare/cloudflared/packet"
@@ -62,6 +64,7 @@ type QUICConnection struct {
 	packetRouter         *ingress.PacketRouter
 	controlStreamHandler ControlStreamHandler
 	connOptions          *tunnelpogs.ConnectionOptions
+	observer             *Observer
 	connIndex            uint8
 }
 
@@ -71,10 +74,12 @@ func NewQUICConnection(
 	quicConfig *quic.Config,
 	edgeAddr net.Addr,
 	localAddr net.IP,
+	dscp uint8,
 	connIndex uint8,
 	tlsConfig *tls.Config,
 	orchestrator Orchestrator,
 	connOptions *tunnelpogs.ConnectionOptions,
+	observer *Observer,
 	controlStreamHandler ControlStreamHandler,
 	logger *zerolog.Logger,
 	packetRouterConfig *ingress.GlobalRouterConfig,
@@ -83,7 +88,14 @@ func NewQUICConnection(
 	if err != nil {
 		return nil, err
 	}
+	if dscp != 0 {
+		if err := edgediscovery.SetDSCP(udpConn, dscp); err != nil {
+			udpConn.Close()
+			return nil, err
+		}
+	}
 
+	quicConfig = withMetricsTracer(quicConfig, observer.metrics, connIndex)
 	session, err := quic.Dial(ctx, udpConn, edgeAddr, tlsConfig, quicConfig)
 	if err != nil {
 		// close the udp server socket in case of error connecting to the edge
@@ -111,6 +123,7 @@ func NewQUICConnection(
 		packetRouter:         packetRouter,
 		controlStreamHandler: controlStreamHandler,
 		connOptions:          connOptions,
+		observer:             observer,
 		connIndex:            connIndex,
 	}, nil
 }
@@ -261,6 +274,7 @@ func (q *QUICConnection) dispatchRequest(ctx context.Context, stream *quicpogs.R
 	if err != nil {
 		return err, false
 	}
+	q.observer.metrics.requestServed(q.connIndex)
 
 	switch request.Type {
 	case quicpogs.ConnectionTypeHTTP, quicpogs.ConnectionTypeWebsocket:
@@ -640,3 +654,48 @@ func (w *wrapCloseableConnQuicConnection) CloseWithError(errorCode quic.Applicat
 
 	return err
 }
+
+// withMetricsTracer returns a copy of quicConfig that also traces the bytes and RTT of the connection into
+// the per connection metrics.
+func withMetricsTracer(quicConfig *quic.Config, metrics *tunnelMetrics, connIndex uint8) *quic.Config {
+	quicConfig = quicConfig.Clone()
+	tracer := quicConfig.Tracer
+	quicConfig.Tracer = func(ctx context.Context, p logging.Perspective, connID quic.ConnectionID) logging.ConnectionTracer {
+		metricsTracer := &metricsTracer{metrics: metrics, connIndex: connIndex}
+		if tracer == nil {
+			return metricsTracer
+		}
+		return logging.NewMultiplexedConnectionTracer(tracer(ctx, p, connID), metricsTracer)
+	}
+	return quicConfig
+}
+
+// metricsTracer records the bytes and RTT of a QUIC connection in the per connection metrics.
+type metricsTracer struct {
+	logging.NullConnectionTracer
+	metrics   *tunnelMetrics
+	connIndex uint8
+}
+
+func (t *metricsTracer) SentLongHeaderPacket(_ *logging.ExtendedHeader, size logging.ByteCount, _ *logging.AckFrame, _ []logging.Frame) {
+	t.metrics.bytesSent(t.connIndex, int(size))
+}
+
+func (t *metricsTracer) SentShortHeaderPacket(_ *logging.ShortHeader, size logging.ByteCount, _ *logging.AckFrame, _ []logging.Frame) {
+	t.metrics.bytesSent(t.connIndex, int(size))
+}
+
+func (t *metricsTracer) ReceivedLongHeaderPacket(_ *logging.ExtendedHeader, size logging.ByteCount, _ []logging.Frame) {
+	t.metrics.bytesReceived(t.connIndex, int(size))
+}
+
+func (t *metricsTracer) ReceivedShortHeaderPacket(_ *logging.ShortHeader, size logging.ByteCount, _ []logging.Frame) {
+	t.metrics.bytesReceived(t.connIndex, int(size))
+}
+
+func (t *metricsTracer) UpdatedMetrics(rttStats *logging.RTTStats, _, _ logging.ByteCount, _ int) {
+	// The latest RTT is 0 until it was measured
+	if rtt := rttStats.LatestRTT(); rtt > 0 {
+		t.metrics.rttUpdated(t.connIndex, rtt)
+	}
+}
@@ -718,10 +718,12 @@ func testQUICConnection(udpListenerAddr net.Addr, t *testing.T, index uint8) *QU
 		testQUICConfig,
 		udpListenerAddr,
 		nil,
+		0,
 		index,
 		tlsClientConfig,
 		&mockOrchestrator{originProxy: &mockOriginProxyWithRequest{}},
 		&tunnelpogs.ConnectionOptions{},
+		NewObserver(&log, &log),
 		fakeControlStream{},
 		&log,
 		nil,
@@ -0,0 +1,31 @@
+//go:build linux
+
+package connection
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpRTT returns the smoothed RTT the kernel measured on the TCP connection.
+func tcpRTT(conn *net.TCPConn) (time.Duration, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		info    *unix.TCPInfo
+		infoErr error
+	)
+	if err := rawConn.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return 0, err
+	}
+	if infoErr != nil {
+		return 0, infoErr
+	}
+	return time.Duration(info.Rtt) * time.Microsecond, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package connection
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+func tcpRTT(conn *net.TCPConn) (time.Duration, error) {
+	return 0, fmt.Errorf("reading the RTT of TCP connections isn't supported on this platform")
+}
@@ -1,5 +1,7 @@
 package allregions
 
+import "time"
+
 // Region contains cloudflared edge addresses. The edge is partitioned into several regions for
 // redundancy purposes.
 type AddrSet map[*EdgeAddr]UsedBy
@@ -37,6 +39,45 @@ func (a AddrSet) GetUnusedIP(excluding *EdgeAddr) *EdgeAddr {
 	return nil
 }
 
+// GetLowestLatencyUnusedIP returns the unused address with the lowest latency in this region, excluding
+// the given one and the avoided ones. Addresses with a known latency are preferred, and a random one is
+// returned if none has. Returns nil if all addresses are in use or avoided.
+func (a AddrSet) GetLowestLatencyUnusedIP(excluding *EdgeAddr, latencies map[*EdgeAddr]time.Duration, avoiding map[*EdgeAddr]struct{}) *EdgeAddr {
+	var lowest *EdgeAddr
+	var lowestLatency time.Duration
+	for addr, usedby := range a {
+		if usedby.Used || addr == excluding {
+			continue
+		}
+		if _, ok := avoiding[addr]; ok {
+			continue
+		}
+		latency, ok := latencies[addr]
+		if !ok {
+			if lowest == nil {
+				lowest = addr
+			}
+			continue
+		}
+		if _, lowestKnown := latencies[lowest]; !lowestKnown || latency < lowestLatency {
+			lowest = addr
+			lowestLatency = latency
+		}
+	}
+	return lowest
+}
+
+// GetUnusedIPWithVersion returns a random unused address of the given IP version in this region.
+// Returns nil if all addresses of that version are in use.
+func (a AddrSet) GetUnusedIPWithVersion(version EdgeIPVersion) *EdgeAddr {
+	for addr, usedby := range a {
+		if !usedby.Used && addr.IPVersion == version {
+			return addr
+		}
+	}
+	return nil
+}
+
 // Use the address, assigning it to a proxy connection.
 func (a AddrSet) Use(addr *EdgeAddr, connID int) {
 	if addr == nil {
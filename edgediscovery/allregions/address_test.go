@@ -3,6 +3,7 @@ package allregions
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestAddrSet_AddrUsedBy(t *testing.T) {
@@ -169,6 +170,90 @@ func TestAddrSet_GetUnusedIP(t *testing.T) {
 	}
 }
 
+func TestAddrSet_GetLowestLatencyUnusedIP(t *testing.T) {
+	latencies := map[*EdgeAddr]time.Duration{
+		&addr0: 30 * time.Millisecond,
+		&addr1: 20 * time.Millisecond,
+		&addr2: 10 * time.Millisecond,
+	}
+	tests := []struct {
+		name      string
+		addrSet   AddrSet
+		excluding *EdgeAddr
+		latencies map[*EdgeAddr]time.Duration
+		avoiding  map[*EdgeAddr]struct{}
+		want      *EdgeAddr
+	}{
+		{
+			name: "lowest latency unused",
+			addrSet: AddrSet{
+				&addr0: Unused(),
+				&addr1: Unused(),
+				&addr2: InUse(2),
+				&addr3: Unused(),
+			},
+			latencies: latencies,
+			want:      &addr1,
+		},
+		{
+			name: "lowest latency excluded",
+			addrSet: AddrSet{
+				&addr0: Unused(),
+				&addr1: Unused(),
+				&addr3: Unused(),
+			},
+			excluding: &addr1,
+			latencies: latencies,
+			want:      &addr0,
+		},
+		{
+			name: "lowest latency avoided",
+			addrSet: AddrSet{
+				&addr0: Unused(),
+				&addr1: Unused(),
+				&addr3: Unused(),
+			},
+			latencies: latencies,
+			avoiding:  map[*EdgeAddr]struct{}{&addr1: {}, &addr3: {}},
+			want:      &addr0,
+		},
+		{
+			name: "unknown latency last",
+			addrSet: AddrSet{
+				&addr0: InUse(0),
+				&addr1: InUse(1),
+				&addr3: Unused(),
+			},
+			latencies: latencies,
+			want:      &addr3,
+		},
+		{
+			name: "no latencies",
+			addrSet: AddrSet{
+				&addr0: InUse(0),
+				&addr1: Unused(),
+			},
+			want: &addr1,
+		},
+		{
+			name: "all in use",
+			addrSet: AddrSet{
+				&addr0: InUse(0),
+				&addr1: InUse(1),
+			},
+			latencies: latencies,
+			want:      nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.addrSet.GetLowestLatencyUnusedIP(tt.excluding, tt.latencies, tt.avoiding); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AddrSet.GetLowestLatencyUnusedIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestAddrSet_GiveBack(t *testing.T) {
 	type args struct {
 		addr *EdgeAddr
@@ -25,6 +25,9 @@ const (
 	dotServerAddr = "1.1.1.1:853"
 	dotTimeout    = 15 * time.Second
 
+	// Bounds each lookup made with a resolver given for edge discovery
+	resolverTimeout = 15 * time.Second
+
 	logFieldAddress = "address"
 )
 
@@ -83,6 +86,14 @@ type EdgeAddr struct {
 	IPVersion EdgeIPVersion
 }
 
+// DNSRecord is a DNS record that edge discovery resolved to build the edge address pool.
+// Note that the resolver doesn't expose the TTLs of the records.
+type DNSRecord struct {
+	Name  string
+	Type  string
+	Value string
+}
+
 // If the call to net.LookupSRV fails, try to fall back to DoT from Cloudflare directly.
 //
 // Note: Instead of DoT, we could also have used DoH. Either of these:
@@ -108,38 +119,88 @@ var friendlyDNSErrorLines = []string{
 	`     https://developers.cloudflare.com/1.1.1.1/setting-up-1.1.1.1/`,
 }
 
-// EdgeDiscovery implements HA service discovery lookup.
-func edgeDiscovery(log *zerolog.Logger, srvService string) ([][]*EdgeAddr, error) {
+// SRVRecord names the SRV record the edge is discovered from, _<Service>._<Proto>.<Name>. Fields left empty
+// default to the record of the Cloudflare edge, e.g. so that staging or isolated environments only need to
+// override some of them.
+type SRVRecord struct {
+	Service string
+	Proto   string
+	Name    string
+}
+
+// withDefaults fills the empty fields of r with those of the record of the Cloudflare edge.
+func (r SRVRecord) withDefaults() SRVRecord {
+	if r.Service == "" {
+		r.Service = srvService
+	}
+	if r.Proto == "" {
+		r.Proto = srvProto
+	}
+	if r.Name == "" {
+		r.Name = srvName
+	}
+	return r
+}
+
+// String returns the domain name of the record.
+func (r SRVRecord) String() string {
+	return "_" + r.Service + "._" + r.Proto + "." + r.Name
+}
+
+// EdgeDiscovery implements HA service discovery lookup. Along with the addresses, it returns the DNS
+// records they were resolved from. The records are looked up with resolver, or the system resolver if nil.
+func edgeDiscovery(log *zerolog.Logger, srv SRVRecord, resolver *net.Resolver) ([][]*EdgeAddr, []DNSRecord, error) {
 	logger := log.With().Int(management.EventTypeKey, int(management.Cloudflared)).Logger()
+	domain := srv.String()
 	logger.Debug().
 		Int(management.EventTypeKey, int(management.Cloudflared)).
-		Str("domain", "_"+srvService+"._"+srvProto+"."+srvName).
+		Str("domain", domain).
 		Msg("edge discovery: looking up edge SRV record")
 
-	_, addrs, err := netLookupSRV(srvService, srvProto, srvName)
+	lookupSRV, lookupIP := netLookupSRV, netLookupIP
+	if resolver != nil {
+		lookupSRV, lookupIP = resolverLookupSRV(resolver), resolverLookupIP(resolver)
+	}
+
+	_, addrs, err := lookupSRV(srv.Service, srv.Proto, srv.Name)
 	if err != nil {
-		_, fallbackAddrs, fallbackErr := fallbackLookupSRV(srvService, srvProto, srvName)
+		_, fallbackAddrs, fallbackErr := fallbackLookupSRV(srv.Service, srv.Proto, srv.Name)
 		if fallbackErr != nil || len(fallbackAddrs) == 0 {
 			// use the original DNS error `err` in messages, not `fallbackErr`
 			logger.Err(err).Msg("edge discovery: error looking up Cloudflare edge IPs: the DNS query failed")
 			for _, s := range friendlyDNSErrorLines {
 				logger.Error().Msg(s)
 			}
-			return nil, errors.Wrapf(err, "Could not lookup srv records on _%v._%v.%v", srvService, srvProto, srvName)
+			return nil, nil, errors.Wrapf(err, "Could not lookup srv records on %v", domain)
 		}
 		// Accept the fallback results and keep going
 		addrs = fallbackAddrs
 	}
 
-	var resolvedAddrPerCNAME [][]*EdgeAddr
+	var (
+		resolvedAddrPerCNAME [][]*EdgeAddr
+		records              []DNSRecord
+	)
+	for _, addr := range addrs {
+		records = append(records, DNSRecord{
+			Name:  domain,
+			Type:  "SRV",
+			Value: fmt.Sprintf("%d %d %d %s", addr.Priority, addr.Weight, addr.Port, addr.Target),
+		})
+	}
 	for _, addr := range addrs {
-		edgeAddrs, err := resolveSRV(addr)
+		edgeAddrs, err := resolveSRV(addr, lookupIP)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		logAddrs := make([]string, len(edgeAddrs))
 		for i, e := range edgeAddrs {
 			logAddrs[i] = e.UDP.IP.String()
+			recordType := "A"
+			if e.IPVersion == V6 {
+				recordType = "AAAA"
+			}
+			records = append(records, DNSRecord{Name: addr.Target, Type: recordType, Value: logAddrs[i]})
 		}
 		logger.Debug().
 			Strs("addresses", logAddrs).
@@ -147,7 +208,7 @@ func edgeDiscovery(log *zerolog.Logger, srvService string) ([][]*EdgeAddr, error
 		resolvedAddrPerCNAME = append(resolvedAddrPerCNAME, edgeAddrs)
 	}
 
-	return resolvedAddrPerCNAME, nil
+	return resolvedAddrPerCNAME, records, nil
 }
 
 func lookupSRVWithDOT(srvService string, srvProto string, srvName string) (cname string, addrs []*net.SRV, err error) {
@@ -169,8 +230,26 @@ func lookupSRVWithDOT(srvService string, srvProto string, srvName string) (cname
 	return r.LookupSRV(ctx, srvService, srvProto, srvName)
 }
 
-func resolveSRV(srv *net.SRV) ([]*EdgeAddr, error) {
-	ips, err := netLookupIP(srv.Target)
+// resolverLookupSRV adapts resolver to the signature of net.LookupSRV.
+func resolverLookupSRV(resolver *net.Resolver) func(string, string, string) (string, []*net.SRV, error) {
+	return func(service, proto, name string) (string, []*net.SRV, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), resolverTimeout)
+		defer cancel()
+		return resolver.LookupSRV(ctx, service, proto, name)
+	}
+}
+
+// resolverLookupIP adapts resolver to the signature of net.LookupIP.
+func resolverLookupIP(resolver *net.Resolver) func(string) ([]net.IP, error) {
+	return func(host string) ([]net.IP, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), resolverTimeout)
+		defer cancel()
+		return resolver.LookupIP(ctx, "ip", host)
+	}
+}
+
+func resolveSRV(srv *net.SRV, lookupIP func(string) ([]net.IP, error)) ([]*EdgeAddr, error) {
+	ips, err := lookupIP(srv.Target)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Couldn't resolve SRV record %v", srv)
 	}
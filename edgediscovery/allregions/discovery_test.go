@@ -2,6 +2,7 @@ package allregions
 
 import (
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/rs/zerolog"
@@ -25,7 +26,7 @@ func TestEdgeDiscovery(t *testing.T) {
 	}
 
 	l := zerolog.Nop()
-	addrLists, err := edgeDiscovery(&l, "")
+	addrLists, _, err := edgeDiscovery(&l, SRVRecord{}.withDefaults(), nil)
 	assert.NoError(t, err)
 	actualAddrSet := map[string]bool{}
 	for _, addrs := range addrLists {
@@ -36,3 +37,65 @@ func TestEdgeDiscovery(t *testing.T) {
 
 	assert.Equal(t, expectedAddrSet, actualAddrSet)
 }
+
+func TestResolveEdgeDNSRecords(t *testing.T) {
+	mockAddrs := newMockAddrs(7844, 2, 2)
+	netLookupSRV = mockNetLookupSRV(mockAddrs)
+	netLookupIP = mockNetLookupIP(mockAddrs)
+
+	var expected []DNSRecord
+	for srv, addrs := range mockAddrs.addrMap {
+		expected = append(expected, DNSRecord{
+			Name:  "_v2-origintunneld._tcp.argotunnel.com",
+			Type:  "SRV",
+			Value: fmt.Sprintf("0 0 7844 %s", srv.Target),
+		})
+		for _, addr := range addrs {
+			expected = append(expected, DNSRecord{Name: srv.Target, Type: "A", Value: addr.TCP.IP.String()})
+		}
+	}
+
+	l := zerolog.Nop()
+	regions, err := ResolveEdge(&l, "", Auto, SRVRecord{}, nil, IPFilter{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, regions.DNSRecords())
+}
+
+func TestResolveEdgeRegion(t *testing.T) {
+	mockAddrs := newMockAddrs(7844, 2, 2)
+	netLookupIP = mockNetLookupIP(mockAddrs)
+	var lookedUp []string
+	lookupSRV := mockNetLookupSRV(mockAddrs)
+	netLookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookedUp = append(lookedUp, service)
+		return lookupSRV(service, proto, name)
+	}
+
+	l := zerolog.Nop()
+	_, err := ResolveEdge(&l, "us", Auto, SRVRecord{}, nil, IPFilter{})
+	assert.NoError(t, err)
+	_, err = ResolveEdge(&l, "", Auto, SRVRecord{}, nil, IPFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"us-v2-origintunneld", "v2-origintunneld"}, lookedUp)
+}
+
+func TestResolveEdgeSRVRecord(t *testing.T) {
+	mockAddrs := newMockAddrs(7844, 2, 2)
+	netLookupIP = mockNetLookupIP(mockAddrs)
+	var lookedUp []string
+	lookupSRV := mockNetLookupSRV(mockAddrs)
+	netLookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookedUp = append(lookedUp, "_"+service+"._"+proto+"."+name)
+		return lookupSRV(service, proto, name)
+	}
+
+	l := zerolog.Nop()
+	_, err := ResolveEdge(&l, "", Auto, SRVRecord{Service: "staging-origintunneld", Name: "example.com"}, nil, IPFilter{})
+	assert.NoError(t, err)
+	_, err = ResolveEdge(&l, "us", Auto, SRVRecord{Proto: "udp"}, nil, IPFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"_staging-origintunneld._tcp.example.com",
+		"_us-v2-origintunneld._udp.argotunnel.com",
+	}, lookedUp)
+}
@@ -0,0 +1,88 @@
+package allregions
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPFilter restricts the discovered edge addresses to those whose IP is in one of the Allow networks, if
+// any, and in none of the Deny networks. The zero value allows every address.
+type IPFilter struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// ParseIPFilter parses the CIDRs of the allowed and denied networks. A plain IP is a network of only that IP.
+func ParseIPFilter(allow, deny []string) (IPFilter, error) {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return IPFilter{}, err
+	}
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return IPFilter{}, err
+	}
+	return IPFilter{Allow: allowNets, Deny: denyNets}, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %s", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// IsZero reports whether the filter allows every address.
+func (f IPFilter) IsZero() bool {
+	return len(f.Allow) == 0 && len(f.Deny) == 0
+}
+
+// Allows reports whether the filter allows dialing ip.
+func (f IPFilter) Allows(ip net.IP) bool {
+	for _, ipNet := range f.Deny {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, ipNet := range f.Allow {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the addresses the filter allows.
+func (f IPFilter) filter(addrs []*EdgeAddr) []*EdgeAddr {
+	if f.IsZero() {
+		return addrs
+	}
+	allowed := make([]*EdgeAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if f.Allows(addr.TCP.IP) {
+			allowed = append(allowed, addr)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,72 @@
+package allregions
+
+import (
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPFilter(t *testing.T) {
+	filter, err := ParseIPFilter([]string{"198.41.192.0/24", "2606:4700:a0::/48"}, []string{"198.41.192.7", "2606:4700:a0::1"})
+	require.NoError(t, err)
+	assert.Equal(t, "198.41.192.7/32", filter.Deny[0].String())
+	assert.Equal(t, "2606:4700:a0::1/128", filter.Deny[1].String())
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{ip: "198.41.192.1", allowed: true},
+		{ip: "198.41.192.7", allowed: false},
+		{ip: "198.41.200.1", allowed: false},
+		{ip: "2606:4700:a0::2", allowed: true},
+		{ip: "2606:4700:a0::1", allowed: false},
+		{ip: "2606:4700:a8::1", allowed: false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.allowed, filter.Allows(net.ParseIP(test.ip)), test.ip)
+	}
+
+	_, err = ParseIPFilter([]string{"198.41.192.0/33"}, nil)
+	assert.Error(t, err)
+	_, err = ParseIPFilter(nil, []string{"not an IP"})
+	assert.Error(t, err)
+}
+
+func TestIPFilterZeroAllowsAll(t *testing.T) {
+	filter, err := ParseIPFilter(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, filter.IsZero())
+	assert.True(t, filter.Allows(net.ParseIP("198.41.192.1")))
+	assert.Equal(t, v4Addrs, filter.filter(v4Addrs))
+}
+
+func TestIPFilterDenyOnly(t *testing.T) {
+	filter, err := ParseIPFilter(nil, []string{"123.4.5.0/31"})
+	require.NoError(t, err)
+	assert.Equal(t, []*EdgeAddr{&addr2, &addr3}, filter.filter(v4Addrs))
+}
+
+func TestResolveEdgeIPFilter(t *testing.T) {
+	mockAddrs := newMockAddrs(7844, 2, 4)
+	netLookupSRV = mockNetLookupSRV(mockAddrs)
+	netLookupIP = mockNetLookupIP(mockAddrs)
+	l := zerolog.Nop()
+
+	filter, err := ParseIPFilter([]string{"10.0.0.0/16"}, []string{"10.0.0.0", "10.0.1.0/30"})
+	require.NoError(t, err)
+	regions, err := ResolveEdge(&l, "", Auto, SRVRecord{}, nil, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 3, regions.AvailableAddrs())
+	for _, addr := range regions.Addrs() {
+		assert.True(t, filter.Allows(addr.TCP.IP), addr.TCP.IP)
+	}
+
+	filter, err = ParseIPFilter([]string{"192.168.0.0/16"}, nil)
+	require.NoError(t, err)
+	_, err = ResolveEdge(&l, "", Auto, SRVRecord{}, nil, filter)
+	assert.Error(t, err)
+}
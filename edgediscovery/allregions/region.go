@@ -87,6 +87,16 @@ func (r Region) AvailableAddrs() int {
 	return r.active.AvailableAddrs()
 }
 
+// NumAddrs counts how many addresses this region contains, used or not.
+func (r Region) NumAddrs() int {
+	return len(r.primary) + len(r.secondary)
+}
+
+// UsedAddrs counts how many addresses of this region are used by a connection.
+func (r Region) UsedAddrs() int {
+	return r.NumAddrs() - r.primary.AvailableAddrs() - r.secondary.AvailableAddrs()
+}
+
 // AssignAnyAddress returns a random unused address in this region now
 // assigned to the connID excluding the provided EdgeAddr.
 // Returns nil if all addresses are in use for the region.
@@ -98,6 +108,75 @@ func (r Region) AssignAnyAddress(connID int, excluding *EdgeAddr) *EdgeAddr {
 	return nil
 }
 
+// AssignLowestLatencyAddress returns the unused address with the lowest latency in this region now
+// assigned to the connID, excluding the provided EdgeAddr and the avoided ones. Like AssignAnyAddress, it
+// returns a random unused address if no latency is known, and nil if all addresses are in use or avoided.
+func (r Region) AssignLowestLatencyAddress(connID int, excluding *EdgeAddr, latencies map[*EdgeAddr]time.Duration, avoiding map[*EdgeAddr]struct{}) *EdgeAddr {
+	if addr := r.active.GetLowestLatencyUnusedIP(excluding, latencies, avoiding); addr != nil {
+		r.active.Use(addr, connID)
+		return addr
+	}
+	return nil
+}
+
+// UsedBy returns which connection uses the address, if it's an address of this region.
+func (r Region) UsedBy(addr *EdgeAddr) (UsedBy, bool) {
+	for _, set := range []AddrSet{r.primary, r.secondary} {
+		if usedBy, ok := set[addr]; ok {
+			return usedBy, true
+		}
+	}
+	return UsedBy{}, false
+}
+
+// Addrs returns all addresses of this region, used or not.
+func (r Region) Addrs() []*EdgeAddr {
+	addrs := make([]*EdgeAddr, 0, r.NumAddrs())
+	for _, set := range []AddrSet{r.primary, r.secondary} {
+		for addr := range set {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// AssignAnyAddressWithIPVersion returns a random unused address of the given IP version in this region,
+// now assigned to the connID. Unlike AssignAnyAddress, both the primary and secondary sets are considered.
+// Returns nil if all addresses of that version are in use for the region.
+func (r Region) AssignAnyAddressWithIPVersion(connID int, version EdgeIPVersion) *EdgeAddr {
+	for _, set := range []AddrSet{r.primary, r.secondary} {
+		if addr := set.GetUnusedIPWithVersion(version); addr != nil {
+			set.Use(addr, connID)
+			return addr
+		}
+	}
+	return nil
+}
+
+// PeekUnusedAddrWithIPVersion returns a random unused address of the given IP version in this region
+// without assigning it. Like AssignAnyAddressWithIPVersion, both the primary and secondary sets are considered.
+// Returns nil if all addresses of that version are in use for the region.
+func (r Region) PeekUnusedAddrWithIPVersion(version EdgeIPVersion) *EdgeAddr {
+	for _, set := range []AddrSet{r.primary, r.secondary} {
+		if addr := set.GetUnusedIPWithVersion(version); addr != nil {
+			return addr
+		}
+	}
+	return nil
+}
+
+// Use assigns the address to the connID if it's an unused address of this region.
+// Returns true if the address was assigned.
+func (r Region) Use(addr *EdgeAddr, connID int) bool {
+	for _, set := range []AddrSet{r.primary, r.secondary} {
+		if usedBy, ok := set[addr]; ok && !usedBy.Used {
+			set.Use(addr, connID)
+			return true
+		}
+	}
+	return false
+}
+
 // GetAnyAddress returns an arbitrary address from the region.
 func (r Region) GetAnyAddress() *EdgeAddr {
 	return r.active.GetAnyAddress()
@@ -3,6 +3,8 @@ package allregions
 import (
 	"fmt"
 	"math/rand"
+	"net"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -12,24 +14,44 @@ import (
 type Regions struct {
 	region1 Region
 	region2 Region
+	// DNS records the regions were resolved from, if any
+	dnsRecords []DNSRecord
+	// latencies measured to the addresses, if any, to prefer the lowest-latency ones
+	latencies map[*EdgeAddr]time.Duration
+	// quarantined addresses, only handed out once no other address is left
+	quarantined map[*EdgeAddr]struct{}
 }
 
 // ------------------------------------
 // Constructors
 // ------------------------------------
 
-// ResolveEdge resolves the Cloudflare edge, returning all regions discovered.
-func ResolveEdge(log *zerolog.Logger, region string, overrideIPVersion ConfigIPVersion) (*Regions, error) {
-	edgeAddrs, err := edgeDiscovery(log, getRegionalServiceName(region))
+// ResolveEdge resolves the Cloudflare edge from the srv record, returning all regions discovered. The edge is
+// resolved with resolver, or the system resolver if nil. Only the addresses ipFilter allows are kept.
+func ResolveEdge(log *zerolog.Logger, region string, overrideIPVersion ConfigIPVersion, srv SRVRecord, resolver *net.Resolver, ipFilter IPFilter) (*Regions, error) {
+	edgeAddrs, dnsRecords, err := edgeDiscovery(log, RegionalSRVRecord(region, srv), resolver)
 	if err != nil {
 		return nil, err
 	}
 	if len(edgeAddrs) < 2 {
 		return nil, fmt.Errorf("expected at least 2 Cloudflare Regions regions, but SRV only returned %v", len(edgeAddrs))
 	}
+	if !ipFilter.IsZero() {
+		discovered, allowed := 0, 0
+		for i, addrs := range edgeAddrs {
+			discovered += len(addrs)
+			edgeAddrs[i] = ipFilter.filter(addrs)
+			allowed += len(edgeAddrs[i])
+		}
+		if allowed == 0 {
+			return nil, fmt.Errorf("none of the %d Cloudflare edge addresses discovered is allowed by the edge IP filters", discovered)
+		}
+		log.Debug().Msgf("edge discovery: %d of the %d edge addresses discovered are allowed by the edge IP filters", allowed, discovered)
+	}
 	return &Regions{
-		region1: NewRegion(edgeAddrs[0], overrideIPVersion),
-		region2: NewRegion(edgeAddrs[1], overrideIPVersion),
+		region1:    NewRegion(edgeAddrs[0], overrideIPVersion),
+		region2:    NewRegion(edgeAddrs[1], overrideIPVersion),
+		dnsRecords: dnsRecords,
 	}, nil
 }
 
@@ -92,31 +114,127 @@ func (rs *Regions) GetUnusedAddr(excluding *EdgeAddr, connID int) *EdgeAddr {
 	if rs.region1.AvailableAddrs() == rs.region2.AvailableAddrs() {
 		regions := []Region{rs.region1, rs.region2}
 		firstChoice := rand.Intn(2)
-		return getAddrs(excluding, connID, &regions[firstChoice], &regions[1-firstChoice])
+		return rs.getAddrs(excluding, connID, &regions[firstChoice], &regions[1-firstChoice])
 	}
 
 	if rs.region1.AvailableAddrs() > rs.region2.AvailableAddrs() {
-		return getAddrs(excluding, connID, &rs.region1, &rs.region2)
+		return rs.getAddrs(excluding, connID, &rs.region1, &rs.region2)
 	}
 
-	return getAddrs(excluding, connID, &rs.region2, &rs.region1)
+	return rs.getAddrs(excluding, connID, &rs.region2, &rs.region1)
 }
 
-// getAddrs tries to grab address form `first` region, then `second` region
-// this is an unrolled loop over 2 element array
-func getAddrs(excluding *EdgeAddr, connID int, first *Region, second *Region) *EdgeAddr {
-	addr := first.AssignAnyAddress(connID, excluding)
-	if addr != nil {
+// GetUnusedAddrSpread gets an unused addr from the edge, excluding the given addr, like GetUnusedAddr.
+// Rather than balancing the available addrs, it prefers the region the fewest connections use so that
+// connections are placed across both regions.
+func (rs *Regions) GetUnusedAddrSpread(excluding *EdgeAddr, connID int) *EdgeAddr {
+	used1, used2 := rs.region1.UsedAddrs(), rs.region2.UsedAddrs()
+	if used1 == used2 {
+		return rs.GetUnusedAddr(excluding, connID)
+	}
+	if used1 < used2 {
+		return rs.getAddrs(excluding, connID, &rs.region1, &rs.region2)
+	}
+	return rs.getAddrs(excluding, connID, &rs.region2, &rs.region1)
+}
+
+// GetUnusedAddrWithIPVersion gets an unused addr of the given IP version from the edge. Prefer the region
+// with the most available addrs so addresses are used evenly across both regions.
+func (rs *Regions) GetUnusedAddrWithIPVersion(connID int, version EdgeIPVersion) *EdgeAddr {
+	first, second := &rs.region1, &rs.region2
+	if rs.region2.AvailableAddrs() > rs.region1.AvailableAddrs() {
+		first, second = second, first
+	}
+	if addr := first.AssignAnyAddressWithIPVersion(connID, version); addr != nil {
 		return addr
 	}
-	addr = second.AssignAnyAddress(connID, excluding)
-	if addr != nil {
+	return second.AssignAnyAddressWithIPVersion(connID, version)
+}
+
+// PeekUnusedAddrWithIPVersion returns an unused addr of the given IP version from the edge without assigning it.
+func (rs *Regions) PeekUnusedAddrWithIPVersion(version EdgeIPVersion) *EdgeAddr {
+	if addr := rs.region1.PeekUnusedAddrWithIPVersion(version); addr != nil {
 		return addr
 	}
+	return rs.region2.PeekUnusedAddrWithIPVersion(version)
+}
 
+// Use assigns the addr to the connID if it's unused. Returns true if the addr was assigned.
+func (rs *Regions) Use(addr *EdgeAddr, connID int) bool {
+	return rs.region1.Use(addr, connID) || rs.region2.Use(addr, connID)
+}
+
+// getAddrs tries to grab address form `first` region, then `second` region, preferring the lowest-latency
+// address of the region. Quarantined addresses are only grabbed if neither region has another address left.
+func (rs *Regions) getAddrs(excluding *EdgeAddr, connID int, first *Region, second *Region) *EdgeAddr {
+	for _, avoiding := range []map[*EdgeAddr]struct{}{rs.quarantined, nil} {
+		if addr := first.AssignLowestLatencyAddress(connID, excluding, rs.latencies, avoiding); addr != nil {
+			return addr
+		}
+		if addr := second.AssignLowestLatencyAddress(connID, excluding, rs.latencies, avoiding); addr != nil {
+			return addr
+		}
+	}
 	return nil
 }
 
+// Addrs returns all edge addresses, used or not.
+func (rs *Regions) Addrs() []*EdgeAddr {
+	return append(rs.region1.Addrs(), rs.region2.Addrs()...)
+}
+
+// UsedBy returns which connection uses the address, if it's an address of this edge.
+func (rs *Regions) UsedBy(addr *EdgeAddr) (UsedBy, bool) {
+	if usedBy, ok := rs.region1.UsedBy(addr); ok {
+		return usedBy, true
+	}
+	return rs.region2.UsedBy(addr)
+}
+
+// SetLatencies sets the latencies measured to the edge addresses, so that unused addresses are handed out
+// lowest latency first within each region. Addresses without a known latency are handed out last.
+func (rs *Regions) SetLatencies(latencies map[*EdgeAddr]time.Duration) {
+	rs.latencies = latencies
+}
+
+// Latencies returns the latencies measured to the edge addresses, if any.
+func (rs *Regions) Latencies() map[*EdgeAddr]time.Duration {
+	return rs.latencies
+}
+
+// SetQuarantined sets the edge addresses to only hand out once no other address is left, e.g. because they
+// failed repeatedly.
+func (rs *Regions) SetQuarantined(quarantined map[*EdgeAddr]struct{}) {
+	rs.quarantined = quarantined
+}
+
+// NumAddrs returns how many edge addresses there are, used or not.
+func (rs *Regions) NumAddrs() int {
+	return rs.region1.NumAddrs() + rs.region2.NumAddrs()
+}
+
+// UsedAddrsPerRegion returns how many edge addresses are used in each region.
+func (rs *Regions) UsedAddrsPerRegion() []int {
+	return []int{rs.region1.UsedAddrs(), rs.region2.UsedAddrs()}
+}
+
+// AvailableAddrsPerRegion returns how many edge addresses aren't used in each region.
+func (rs *Regions) AvailableAddrsPerRegion() []int {
+	return []int{rs.region1.AvailableAddrs(), rs.region2.AvailableAddrs()}
+}
+
+// RegionUsedBy returns the index of the region of the address used by the given connection, or -1 if
+// the connection isn't using an address.
+func (rs *Regions) RegionUsedBy(connID int) int {
+	if rs.region1.AddrUsedBy(connID) != nil {
+		return 0
+	}
+	if rs.region2.AddrUsedBy(connID) != nil {
+		return 1
+	}
+	return -1
+}
+
 // AvailableAddrs returns how many edge addresses aren't used.
 func (rs *Regions) AvailableAddrs() int {
 	return rs.region1.AvailableAddrs() + rs.region2.AvailableAddrs()
@@ -131,11 +249,24 @@ func (rs *Regions) GiveBack(addr *EdgeAddr, hasConnectivityError bool) bool {
 	return rs.region2.GiveBack(addr, hasConnectivityError)
 }
 
+// DNSRecords returns the DNS records the regions were resolved from. It is empty for static edges.
+func (rs *Regions) DNSRecords() []DNSRecord {
+	return rs.dnsRecords
+}
+
+// RegionalSRVRecord returns the SRV record the edge of region is discovered from, the global edge if region is empty.
+// The empty fields of srv default to the record of the Cloudflare edge.
+func RegionalSRVRecord(region string, srv SRVRecord) SRVRecord {
+	srv = srv.withDefaults()
+	srv.Service = getRegionalServiceName(srv.Service, region)
+	return srv
+}
+
 // Return regionalized service name if `region` isn't empty, otherwise return the global service name for origintunneld
-func getRegionalServiceName(region string) string {
+func getRegionalServiceName(service, region string) string {
 	if region != "" {
-		return region + "-" + srvService // Example: `us-v2-origintunneld`
+		return region + "-" + service // Example: `us-v2-origintunneld`
 	}
 
-	return srvService // Global service is just `v2-origintunneld`
+	return service // Global service is just `v2-origintunneld`
 }
@@ -212,6 +212,30 @@ func TestRegions_GetUnusedAddr_Excluding_Region2(t *testing.T) {
 	}
 }
 
+func TestRegions_GetUnusedAddrSpread(t *testing.T) {
+	rs := Regions{
+		region1: NewRegion(v4Addrs, Auto),
+		region2: NewRegion(v6Addrs[:2], Auto),
+	}
+
+	// Both regions are unused, so the larger region is preferred like GetUnusedAddr does
+	rs.GetUnusedAddrSpread(nil, 0)
+	assert.Equal(t, 0, rs.RegionUsedBy(0))
+	// The larger region still has the most available addrs, but the other one is unused
+	rs.GetUnusedAddrSpread(nil, 1)
+	assert.Equal(t, 1, rs.RegionUsedBy(1))
+	assert.Equal(t, []int{1, 1}, rs.UsedAddrsPerRegion())
+	assert.Equal(t, []int{3, 1}, rs.AvailableAddrsPerRegion())
+
+	rs.GetUnusedAddrSpread(nil, 2)
+	rs.GetUnusedAddrSpread(nil, 3)
+	assert.Equal(t, []int{2, 2}, rs.UsedAddrsPerRegion())
+	// Once a region is full, the other one is used
+	rs.GetUnusedAddrSpread(nil, 4)
+	assert.Equal(t, 0, rs.RegionUsedBy(4))
+	assert.Equal(t, -1, rs.RegionUsedBy(5))
+}
+
 func TestNewNoResolveBalancesRegions(t *testing.T) {
 	type args struct {
 		addrs []*EdgeAddr
@@ -239,12 +263,12 @@ func TestNewNoResolveBalancesRegions(t *testing.T) {
 
 func TestGetRegionalServiceName(t *testing.T) {
 	// Empty region should just go to origintunneld
-	globalServiceName := getRegionalServiceName("")
+	globalServiceName := getRegionalServiceName(srvService, "")
 	assert.Equal(t, srvService, globalServiceName)
 
 	// Non-empty region should go to the regional origintunneld variant
 	for _, region := range []string{"us", "pt", "am"} {
-		regionalServiceName := getRegionalServiceName(region)
+		regionalServiceName := getRegionalServiceName(srvService, region)
 		assert.Equal(t, region+"-"+srvService, regionalServiceName)
 	}
 }
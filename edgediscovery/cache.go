@@ -0,0 +1,122 @@
+package edgediscovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+const (
+	// Bounds how many of the edge addresses connections registered with most recently are cached
+	maxCachedAddrs = 16
+
+	addrsCacheHeader = "# Edge addresses cloudflared registered connections with most recently, used when the edge can't be discovered\n"
+)
+
+// ResolveEdgeOrCached is ResolveEdge, but the edge addresses connections register with are cached in
+// cacheFile. When the edge can't be discovered, it's seeded with the cached addresses instead, so that
+// connections can be established until it's discovered again, see RediscoverSeeded. An empty cacheFile
+// disables the cache.
+func ResolveEdgeOrCached(cacheFile string, log *zerolog.Logger, region string, edgeIpVersion allregions.ConfigIPVersion, srv allregions.SRVRecord, resolver *net.Resolver, ipFilter allregions.IPFilter) (*Edge, error) {
+	return resolveEdgeOrCached(cacheFile, log, discoverFunc(log, region, edgeIpVersion, srv, resolver, ipFilter))
+}
+
+func resolveEdgeOrCached(cacheFile string, log *zerolog.Logger, discover func() (*allregions.Regions, error)) (*Edge, error) {
+	regions, err := discover()
+	seeded := false
+	if err != nil {
+		if cacheFile == "" {
+			return new(Edge), err
+		}
+		cached, cacheErr := ReadEdgeAddrsFile(cacheFile)
+		if cacheErr != nil || len(cached) == 0 {
+			return new(Edge), err
+		}
+		var seedErr error
+		if regions, seedErr = allregions.StaticEdge(cached, log); seedErr != nil {
+			return new(Edge), err
+		}
+		log.Warn().Err(err).Int("addresses", regions.AvailableAddrs()).Str("path", cacheFile).
+			Msg("edge discovery: failed to discover the edge, using the cached edge addresses until it's discovered")
+		seeded = true
+	}
+	edge := newEdge(log, regions)
+	edge.discover = discover
+	edge.seeded = seeded
+	edge.cacheFile = cacheFile
+	return edge, nil
+}
+
+// RediscoverSeeded discovers the edge again each interval while it's seeded with cached addresses, until
+// it's discovered or ctx is done.
+func (ed *Edge) RediscoverSeeded(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ed.isSeeded() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ed.Refresh(); err != nil {
+				ed.log.Debug().Err(err).Msg("edge discovery: still failing to discover the edge, keeping the cached edge addresses")
+			}
+		}
+	}
+}
+
+func (ed *Edge) isSeeded() bool {
+	ed.Lock()
+	defer ed.Unlock()
+	return ed.seeded
+}
+
+// RecordRegistered records that a connection registered with the edge at addr, and caches the addresses
+// connections registered with most recently if the edge has a cache file.
+func (ed *Edge) RecordRegistered(addr *allregions.EdgeAddr) {
+	ed.Lock()
+	registered := addr.TCP.String()
+	addrs := []string{registered}
+	for _, a := range ed.registeredAddrs {
+		if a != registered && len(addrs) < maxCachedAddrs {
+			addrs = append(addrs, a)
+		}
+	}
+	ed.registeredAddrs = addrs
+	cacheFile := ed.cacheFile
+	ed.Unlock()
+
+	if cacheFile == "" {
+		return
+	}
+	ed.cacheMu.Lock()
+	defer ed.cacheMu.Unlock()
+	if err := writeAddrsCache(cacheFile, addrs); err != nil {
+		ed.log.Warn().Err(err).Str("path", cacheFile).Msg("edge discovery: failed to cache the edge addresses")
+	}
+}
+
+// writeAddrsCache writes addrs to path in the format of ReadEdgeAddrsFile. The file is replaced atomically,
+// so that it's never read half written.
+func writeAddrsCache(path string, addrs []string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = fmt.Fprintf(tmp, "%s%s\n", addrsCacheHeader, strings.Join(addrs, "\n"))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,100 @@
+package edgediscovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+func TestRecordRegisteredCachesAddrs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge-addrs-cache")
+	edge, err := StaticEdge(&testLogger, []string{"127.0.0.1:7844", "127.0.0.2:7844"})
+	require.NoError(t, err)
+	edge.cacheFile = path
+
+	addr1, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	addr2, err := edge.GetAddr(1)
+	require.NoError(t, err)
+	edge.RecordRegistered(addr1)
+	edge.RecordRegistered(addr2)
+	edge.RecordRegistered(addr1)
+
+	cached, err := ReadEdgeAddrsFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{addr1.TCP.String(), addr2.TCP.String()}, cached, "most recently registered address should come first")
+}
+
+func TestRecordRegisteredBoundsCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge-addrs-cache")
+	edge, err := StaticEdge(&testLogger, []string{"127.0.0.1:7844"})
+	require.NoError(t, err)
+	edge.cacheFile = path
+
+	addrs := allregions.ResolveAddrs([]string{"127.0.0.1:7844"}, &testLogger)
+	for i := 0; i < maxCachedAddrs+4; i++ {
+		addr := *addrs[0]
+		addr.TCP = &net.TCPAddr{IP: addr.TCP.IP, Port: 7000 + i}
+		edge.RecordRegistered(&addr)
+	}
+	cached, err := ReadEdgeAddrsFile(path)
+	require.NoError(t, err)
+	assert.Len(t, cached, maxCachedAddrs)
+	assert.Equal(t, fmt.Sprintf("127.0.0.1:%d", 7000+maxCachedAddrs+3), cached[0])
+}
+
+func TestResolveEdgeOrCached(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge-addrs-cache")
+	var (
+		mu          sync.Mutex
+		discoverErr = fmt.Errorf("no such host")
+	)
+	discover := func() (*allregions.Regions, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if discoverErr != nil {
+			return nil, discoverErr
+		}
+		return allregions.NewNoResolve(allregions.ResolveAddrs([]string{"127.0.0.3:7844", "127.0.0.4:7844", "127.0.0.5:7844"}, &testLogger)), nil
+	}
+
+	// Nothing is cached yet
+	_, err := resolveEdgeOrCached(path, &testLogger, discover)
+	assert.Error(t, err)
+	// The cache is disabled
+	_, err = resolveEdgeOrCached("", &testLogger, discover)
+	assert.Error(t, err)
+
+	require.NoError(t, writeAddrsCache(path, []string{"127.0.0.1:7844", "127.0.0.2:7844"}))
+	edge, err := resolveEdgeOrCached(path, &testLogger, discover)
+	require.NoError(t, err)
+	assert.True(t, edge.isSeeded())
+	assert.Equal(t, 2, edge.AvailableAddrs())
+
+	// The edge keeps being discovered until it succeeds
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		edge.RediscoverSeeded(ctx, time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, edge.isSeeded())
+	mu.Lock()
+	discoverErr = nil
+	mu.Unlock()
+	<-done
+	require.NoError(t, ctx.Err(), "edge should have been discovered")
+	assert.False(t, edge.isSeeded())
+	assert.Equal(t, 3, edge.AvailableAddrs())
+}
@@ -4,34 +4,66 @@ import (
 	"context"
 	"crypto/tls"
 	"net"
+	"net/url"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-// DialEdgeWithH2Mux makes a TLS connection to a Cloudflare edge node
+// DialTimeouts bounds the steps of connecting to a Cloudflare edge node.
+type DialTimeouts struct {
+	// Dial bounds establishing the TCP connection, to the proxy and through it if there's one.
+	Dial time.Duration
+	// TLSHandshake bounds the TLS handshake once the TCP connection is established.
+	TLSHandshake time.Duration
+	// KeepAlive is the interval between TCP keepalive probes. Zero means the default of 15 seconds, and a
+	// negative value disables them.
+	KeepAlive time.Duration
+}
+
+// DialEdgeWithH2Mux makes a TLS connection to a Cloudflare edge node, through the HTTP(S) or SOCKS5 proxy at
+// proxyURL if it's not nil. Its packets are marked with dscp unless it's 0.
 func DialEdge(
 	ctx context.Context,
-	timeout time.Duration,
+	timeouts DialTimeouts,
 	tlsConfig *tls.Config,
 	edgeTCPAddr *net.TCPAddr,
 	localIP net.IP,
+	dscp uint8,
+	proxyURL *url.URL,
 ) (net.Conn, error) {
 	// Inherit from parent context so we can cancel (Ctrl-C) while dialing
-	dialCtx, dialCancel := context.WithTimeout(ctx, timeout)
+	dialCtx, dialCancel := context.WithTimeout(ctx, timeouts.Dial)
 	defer dialCancel()
 
-	dialer := net.Dialer{}
+	dialer := net.Dialer{KeepAlive: timeouts.KeepAlive}
 	if localIP != nil {
 		dialer.LocalAddr = &net.TCPAddr{IP: localIP, Port: 0}
 	}
-	edgeConn, err := dialer.DialContext(dialCtx, "tcp", edgeTCPAddr.String())
-	if err != nil {
-		return nil, newDialError(err, "DialContext error")
+	if dscp != 0 {
+		dialer.Control = func(_, _ string, rawConn syscall.RawConn) error {
+			return setDSCP(rawConn, dscp)
+		}
+	}
+	var (
+		edgeConn net.Conn
+		err      error
+	)
+	if proxyURL != nil {
+		edgeConn, err = dialThroughProxy(dialCtx, &dialer, proxyURL, edgeTCPAddr.String())
+		if err != nil {
+			return nil, newDialError(err, "proxy dial error")
+		}
+	} else {
+		edgeConn, err = dialer.DialContext(dialCtx, "tcp", edgeTCPAddr.String())
+		if err != nil {
+			return nil, newDialError(err, "DialContext error")
+		}
 	}
 
 	tlsEdgeConn := tls.Client(edgeConn, tlsConfig)
-	tlsEdgeConn.SetDeadline(time.Now().Add(timeout))
+	tlsEdgeConn.SetDeadline(time.Now().Add(timeouts.TLSHandshake))
 
 	if err = tlsEdgeConn.Handshake(); err != nil {
 		return nil, newDialError(err, "TLS handshake with edge error")
@@ -41,6 +73,79 @@ func DialEdge(
 	return tlsEdgeConn, nil
 }
 
+// happyEyeballsDelay is how long DialEdgeHappyEyeballs waits for the preferred address to connect before also
+// dialing the fallback address. This is the connection attempt delay RFC 8305 recommends.
+var happyEyeballsDelay = 250 * time.Millisecond
+
+// DialEdgeHappyEyeballs makes a TLS connection to a Cloudflare edge node over whichever of the preferred and
+// fallback addresses connects first, like RFC 8305 does for dual-stack hosts: the fallback address is dialed
+// once the preferred one failed, or hasn't connected within happyEyeballsDelay. It returns the address the
+// connection was established with.
+func DialEdgeHappyEyeballs(
+	ctx context.Context,
+	timeouts DialTimeouts,
+	tlsConfig *tls.Config,
+	preferred *net.TCPAddr,
+	fallback *net.TCPAddr,
+	localIP net.IP,
+	dscp uint8,
+) (net.Conn, *net.TCPAddr, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn net.Conn
+		addr *net.TCPAddr
+		err  error
+	}
+	results := make(chan dialResult, 2)
+	dial := func(addr *net.TCPAddr) {
+		conn, err := DialEdge(ctx, timeouts, tlsConfig, addr, localIP, dscp, nil)
+		results <- dialResult{conn: conn, addr: addr, err: err}
+	}
+	go dial(preferred)
+	pending := 1
+
+	fallbackTimer := time.NewTimer(happyEyeballsDelay)
+	defer fallbackTimer.Stop()
+	fallbackStarted := false
+	startFallback := func() {
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			go dial(fallback)
+		}
+	}
+
+	var firstErr error
+	for {
+		select {
+		case <-fallbackTimer.C:
+			startFallback()
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if pending > 0 {
+					// The losing dial may still complete its TLS handshake, which isn't cancellable
+					go func() {
+						if loser := <-results; loser.err == nil {
+							_ = loser.conn.Close()
+						}
+					}()
+				}
+				return result.conn, result.addr, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			startFallback()
+			if pending == 0 {
+				return nil, nil, firstErr
+			}
+		}
+	}
+}
+
 // DialError is an error returned from DialEdge
 type DialError struct {
 	cause error
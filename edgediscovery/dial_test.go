@@ -0,0 +1,229 @@
+package edgediscovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/socks"
+)
+
+func newTestTLSServer(t *testing.T) (*net.TCPAddr, *tls.Config) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	tlsConfig.ServerName = "example.com"
+	return server.Listener.Addr().(*net.TCPAddr), tlsConfig
+}
+
+func testDialTimeouts(timeout time.Duration) DialTimeouts {
+	return DialTimeouts{Dial: timeout, TLSHandshake: timeout}
+}
+
+// newBlackholeAddr returns an address that accepts TCP connections, but never completes a TLS handshake.
+func newBlackholeAddr(t *testing.T) *net.TCPAddr {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	return listener.Addr().(*net.TCPAddr)
+}
+
+// newRefusingAddr returns an address nothing listens on.
+func newRefusingAddr(t *testing.T) *net.TCPAddr {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().(*net.TCPAddr)
+	require.NoError(t, listener.Close())
+	return addr
+}
+
+func TestDialEdgeHappyEyeballsPrefersPreferredAddr(t *testing.T) {
+	preferred, tlsConfig := newTestTLSServer(t)
+	fallback, _ := newTestTLSServer(t)
+
+	conn, addr, err := DialEdgeHappyEyeballs(context.Background(), testDialTimeouts(time.Second), tlsConfig, preferred, fallback, nil, 0)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, preferred, addr)
+}
+
+func TestDialEdgeHappyEyeballsRacesFallbackAddr(t *testing.T) {
+	happyEyeballsDelay = 10 * time.Millisecond
+	defer func() { happyEyeballsDelay = 250 * time.Millisecond }()
+	fallback, tlsConfig := newTestTLSServer(t)
+
+	conn, addr, err := DialEdgeHappyEyeballs(context.Background(), testDialTimeouts(5*time.Second), tlsConfig, newBlackholeAddr(t), fallback, nil, 0)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, fallback, addr)
+}
+
+func TestDialEdgeHappyEyeballsFallsBackWithoutDelay(t *testing.T) {
+	happyEyeballsDelay = time.Minute
+	defer func() { happyEyeballsDelay = 250 * time.Millisecond }()
+	fallback, tlsConfig := newTestTLSServer(t)
+
+	start := time.Now()
+	conn, addr, err := DialEdgeHappyEyeballs(context.Background(), testDialTimeouts(5*time.Second), tlsConfig, newRefusingAddr(t), fallback, nil, 0)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, fallback, addr)
+	assert.Less(t, time.Since(start), happyEyeballsDelay)
+}
+
+func TestDialEdgeHappyEyeballsBothFail(t *testing.T) {
+	_, tlsConfig := newTestTLSServer(t)
+
+	_, _, err := DialEdgeHappyEyeballs(context.Background(), testDialTimeouts(time.Second), tlsConfig, newRefusingAddr(t), newRefusingAddr(t), nil, 0)
+	require.Error(t, err)
+	assert.IsType(t, DialError{}, err)
+}
+
+// newTestProxy returns the URL of an HTTP proxy that tunnels CONNECT requests with the given credentials, and
+// records the targets it connected to.
+func newTestProxy(t *testing.T, username, password string) (*url.URL, *[]string) {
+	var targets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodConnect, r.Method)
+		user, pass, ok := parseProxyAuthorization(r.Header.Get("Proxy-Authorization"))
+		if !ok || user != username || pass != password {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		targets = append(targets, r.Host)
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		require.NoError(t, err)
+		go func() {
+			_, _ = io.Copy(target, conn)
+			_ = target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+		_ = conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	proxyURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	proxyURL.User = url.UserPassword(username, password)
+	return proxyURL, &targets
+}
+
+func parseProxyAuthorization(header string) (string, string, bool) {
+	r := http.Request{Header: http.Header{"Authorization": []string{header}}}
+	return r.BasicAuth()
+}
+
+func TestDialEdgeThroughProxy(t *testing.T) {
+	edge, tlsConfig := newTestTLSServer(t)
+	proxyURL, targets := newTestProxy(t, "user", "secret")
+
+	conn, err := DialEdge(context.Background(), testDialTimeouts(time.Second), tlsConfig, edge, nil, 0, proxyURL)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, []string{edge.String()}, *targets)
+}
+
+func TestDialEdgeThroughProxyRefused(t *testing.T) {
+	edge, tlsConfig := newTestTLSServer(t)
+	proxyURL, targets := newTestProxy(t, "user", "secret")
+	proxyURL.User = url.UserPassword("user", "wrong")
+
+	_, err := DialEdge(context.Background(), testDialTimeouts(time.Second), tlsConfig, edge, nil, 0, proxyURL)
+	require.Error(t, err)
+	assert.IsType(t, DialError{}, err)
+	assert.Contains(t, err.Error(), "407")
+	assert.Empty(t, *targets)
+}
+
+// newTestSOCKS5Proxy returns the URL of a SOCKS5 proxy.
+func newTestSOCKS5Proxy(t *testing.T) *url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	server := socks.NewConnectionHandler(socks.NewRequestHandler(socks.NewNetDialer(), nil))
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = server.Serve(conn)
+			}()
+		}
+	}()
+	return &url.URL{Scheme: "socks5", Host: listener.Addr().String()}
+}
+
+func TestDialEdgeThroughSOCKS5Proxy(t *testing.T) {
+	edge, tlsConfig := newTestTLSServer(t)
+	proxyURL := newTestSOCKS5Proxy(t)
+
+	conn, err := DialEdge(context.Background(), testDialTimeouts(time.Second), tlsConfig, edge, nil, 0, proxyURL)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Credentials are offered, but the proxy doesn't require them
+	proxyURL.User = url.UserPassword("user", "secret")
+	conn, err = DialEdge(context.Background(), testDialTimeouts(time.Second), tlsConfig, edge, nil, 0, proxyURL)
+	require.NoError(t, err)
+	defer conn.Close()
+}
+
+func TestDialEdgeThroughSOCKS5ProxyUnreachable(t *testing.T) {
+	edge, tlsConfig := newTestTLSServer(t)
+	proxyURL := &url.URL{Scheme: "socks5", Host: newRefusingAddr(t).String()}
+
+	_, err := DialEdge(context.Background(), testDialTimeouts(time.Second), tlsConfig, edge, nil, 0, proxyURL)
+	require.Error(t, err)
+	assert.IsType(t, DialError{}, err)
+}
+
+func TestDialEdgeResumesSession(t *testing.T) {
+	addr, tlsConfig := newTestTLSServer(t)
+	tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+
+	dial := func() bool {
+		conn, err := DialEdge(context.Background(), testDialTimeouts(time.Second), tlsConfig, addr, nil, 0, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		// The session ticket is sent after the handshake, read a response to receive it
+		req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		require.NoError(t, err)
+		require.NoError(t, req.Write(conn))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return conn.(*tls.Conn).ConnectionState().DidResume
+	}
+
+	assert.False(t, dial())
+	assert.True(t, dial())
+}
+
+func TestDialEdgeTLSHandshakeTimeout(t *testing.T) {
+	_, tlsConfig := newTestTLSServer(t)
+	timeouts := DialTimeouts{Dial: 5 * time.Second, TLSHandshake: 50 * time.Millisecond}
+
+	start := time.Now()
+	_, err := DialEdge(context.Background(), timeouts, tlsConfig, newBlackholeAddr(t), nil, 0, nil)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
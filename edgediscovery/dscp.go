@@ -0,0 +1,34 @@
+package edgediscovery
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// MaxDSCP is the highest Differentiated Services Code Point, which is 6 bits long.
+const MaxDSCP = 63
+
+// SetDSCP marks the packets sent over conn with the Differentiated Services Code Point dscp (RFC 2474), so
+// that networks can apply QoS policies to them. It's set in the traffic class of IPv6 packets, as well as in
+// the TOS of IPv4 ones.
+func SetDSCP(conn syscall.Conn, dscp uint8) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return setDSCP(rawConn, dscp)
+}
+
+func setDSCP(rawConn syscall.RawConn, dscp uint8) error {
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = setTOS(fd, int(dscp)<<2)
+	}); err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return errors.Wrapf(sockErr, "failed to set DSCP %d", dscp)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+//go:build linux
+
+package edgediscovery
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func getTOS(t *testing.T, conn syscall.Conn) int {
+	rawConn, err := conn.SyscallConn()
+	require.NoError(t, err)
+	var (
+		tos     int
+		sockErr error
+	)
+	require.NoError(t, rawConn.Control(func(fd uintptr) {
+		tos, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+	}))
+	require.NoError(t, sockErr)
+	return tos
+}
+
+func TestSetDSCP(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, SetDSCP(conn, 46))
+	assert.Equal(t, 46<<2, getTOS(t, conn))
+}
+
+func TestDialEdgeDSCP(t *testing.T) {
+	addr, tlsConfig := newTestTLSServer(t)
+
+	conn, err := DialEdge(context.Background(), testDialTimeouts(time.Second), tlsConfig, addr, nil, 10, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, 10<<2, getTOS(t, conn.(*tls.Conn).NetConn().(*net.TCPConn)))
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package edgediscovery
+
+import "golang.org/x/sys/unix"
+
+// DSCPSupported reports whether SetDSCP can mark packets on this platform.
+const DSCPSupported = true
+
+// setTOS sets the TOS of IPv4 packets and the traffic class of IPv6 packets sent over the socket fd. Only one
+// of them applies to sockets that aren't dual-stack, so it only fails if neither can be set.
+func setTOS(fd uintptr, tos int) error {
+	err4 := unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	err6 := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+	if err4 != nil && err6 != nil {
+		return err4
+	}
+	return nil
+}
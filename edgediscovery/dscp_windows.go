@@ -0,0 +1,13 @@
+//go:build windows
+
+package edgediscovery
+
+import "fmt"
+
+// DSCPSupported reports whether SetDSCP can mark packets on this platform. Windows ignores the TOS sockets
+// set, DSCP values are applied with QoS policies instead.
+const DSCPSupported = false
+
+func setTOS(fd uintptr, tos int) error {
+	return fmt.Errorf("DSCP marking isn't supported on Windows, use a QoS policy instead")
+}
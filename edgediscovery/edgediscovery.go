@@ -1,7 +1,9 @@
 package edgediscovery
 
 import (
+	"net"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -12,8 +14,14 @@ import (
 const (
 	LogFieldConnIndex = "connIndex"
 	LogFieldIPAddress = "ip"
+
+	// Bounds how many attempt outcomes are kept to compute setup success rates
+	maxOutcomeHistory = 1000
 )
 
+// Redeclare time functions so they can be overridden in tests.
+var timeNow = time.Now
+
 var errNoAddressesLeft = ErrNoAddressesLeft{}
 
 type ErrNoAddressesLeft struct{}
@@ -27,6 +35,43 @@ type Edge struct {
 	regions *allregions.Regions
 	sync.Mutex
 	log *zerolog.Logger
+	// discover resolves the edge again to refresh its addresses, nil for static edges
+	discover func() (*allregions.Regions, error)
+	// seeded is set while the addresses are the cached ones, because the edge couldn't be discovered;
+	// protected by the Mutex
+	seeded bool
+	// registeredAddrs are the addresses connections registered with most recently, most recent first, which
+	// are cached in cacheFile if set; protected by the Mutex. cacheMu serializes writes to cacheFile.
+	registeredAddrs []string
+	cacheFile       string
+	cacheMu         sync.Mutex
+	// spreadRegions places connections in the region the fewest connections use; protected by the Mutex
+	spreadRegions bool
+	// health of the addresses connections were attempted against, and the quarantined ones, which the
+	// regions share; protected by the Mutex
+	health              map[*allregions.EdgeAddr]*addrHealth
+	quarantined         map[*allregions.EdgeAddr]struct{}
+	quarantineThreshold int
+	quarantineCooldown  time.Duration
+	// addrStats counts connection attempts by edge IP; protected by the Mutex
+	addrStats map[string]*AddrStats
+	// outcomes of the most recent connection attempts, oldest first; protected by the Mutex
+	outcomes []attemptOutcome
+	// triedAddrs are the distinct addresses each connection attempted since it last connected, by connection
+	// index; protected by the Mutex
+	triedAddrs map[int]map[*allregions.EdgeAddr]struct{}
+}
+
+type attemptOutcome struct {
+	at        time.Time
+	connected bool
+}
+
+// AddrStats counts the connection establishment attempts made against an edge address, and their outcomes.
+type AddrStats struct {
+	Attempts  uint64
+	Successes uint64
+	Failures  uint64
 }
 
 // ------------------------------------
@@ -34,16 +79,23 @@ type Edge struct {
 // ------------------------------------
 
 // ResolveEdge runs the initial discovery of the Cloudflare edge, finding Addrs that can be allocated
-// to connections.
-func ResolveEdge(log *zerolog.Logger, region string, edgeIpVersion allregions.ConfigIPVersion) (*Edge, error) {
-	regions, err := allregions.ResolveEdge(log, region, edgeIpVersion)
+// to connections. The edge is resolved from the srv record with resolver, or the system resolver if nil, and
+// only the Addrs ipFilter allows are kept.
+func ResolveEdge(log *zerolog.Logger, region string, edgeIpVersion allregions.ConfigIPVersion, srv allregions.SRVRecord, resolver *net.Resolver, ipFilter allregions.IPFilter) (*Edge, error) {
+	discover := discoverFunc(log, region, edgeIpVersion, srv, resolver, ipFilter)
+	regions, err := discover()
 	if err != nil {
 		return new(Edge), err
 	}
-	return &Edge{
-		log:     log,
-		regions: regions,
-	}, nil
+	edge := newEdge(log, regions)
+	edge.discover = discover
+	return edge, nil
+}
+
+func discoverFunc(log *zerolog.Logger, region string, edgeIpVersion allregions.ConfigIPVersion, srv allregions.SRVRecord, resolver *net.Resolver, ipFilter allregions.IPFilter) func() (*allregions.Regions, error) {
+	return func() (*allregions.Regions, error) {
+		return allregions.ResolveEdge(log, region, edgeIpVersion, srv, resolver, ipFilter)
+	}
 }
 
 // StaticEdge creates a list of edge addresses from the list of hostnames. Mainly used for testing connectivity.
@@ -52,10 +104,19 @@ func StaticEdge(log *zerolog.Logger, hostnames []string) (*Edge, error) {
 	if err != nil {
 		return new(Edge), err
 	}
+	return newEdge(log, regions), nil
+}
+
+func newEdge(log *zerolog.Logger, regions *allregions.Regions) *Edge {
+	quarantined := make(map[*allregions.EdgeAddr]struct{})
+	regions.SetQuarantined(quarantined)
 	return &Edge{
-		log:     log,
-		regions: regions,
-	}, nil
+		log:         log,
+		regions:     regions,
+		addrStats:   make(map[string]*AddrStats),
+		health:      make(map[*allregions.EdgeAddr]*addrHealth),
+		quarantined: quarantined,
+	}
 }
 
 // ------------------------------------
@@ -89,7 +150,7 @@ func (ed *Edge) GetAddr(connIndex int) (*allregions.EdgeAddr, error) {
 	}
 
 	// Otherwise, give it an unused one
-	addr := ed.regions.GetUnusedAddr(nil, connIndex)
+	addr := ed.getUnusedAddr(nil, connIndex)
 	if addr == nil {
 		log.Debug().Msg("edge discovery: no addresses left in pool to give proxy connection")
 		return nil, errNoAddressesLeft
@@ -98,8 +159,33 @@ func (ed *Edge) GetAddr(connIndex int) (*allregions.EdgeAddr, error) {
 	return addr, nil
 }
 
-// GetDifferentAddr gives back the proxy connection's edge Addr and uses a new one.
-func (ed *Edge) GetDifferentAddr(connIndex int, hasConnectivityError bool) (*allregions.EdgeAddr, error) {
+// GetAddrWithIPVersion gives this proxy connection an edge Addr of the given IP version. Prefer Addrs this
+// connection has already used.
+func (ed *Edge) GetAddrWithIPVersion(connIndex int, version allregions.EdgeIPVersion) (*allregions.EdgeAddr, error) {
+	log := ed.log.With().
+		Int(LogFieldConnIndex, connIndex).
+		Int(management.EventTypeKey, int(management.Cloudflared)).
+		Logger()
+	ed.Lock()
+	defer ed.Unlock()
+
+	if addr := ed.regions.AddrUsedBy(connIndex); addr != nil {
+		return addr, nil
+	}
+
+	addr := ed.regions.GetUnusedAddrWithIPVersion(connIndex, version)
+	if addr == nil {
+		log.Debug().Msgf("edge discovery: no IPv%s addresses left in pool to give proxy connection", version)
+		return nil, errNoAddressesLeft
+	}
+	log.Debug().IPAddr(LogFieldIPAddress, addr.UDP.IP).Msg("edge discovery: giving new address to connection")
+	return addr, nil
+}
+
+// GetDifferentAddr gives back the proxy connection's edge Addr and uses a new one. If the pool only has the
+// address the connection was using, there's no alternative to rotate to, so that address is returned again
+// with noAlternative set rather than failing the connection.
+func (ed *Edge) GetDifferentAddr(connIndex int, hasConnectivityError bool) (addr *allregions.EdgeAddr, noAlternative bool, err error) {
 	log := ed.log.With().
 		Int(LogFieldConnIndex, connIndex).
 		Int(management.EventTypeKey, int(management.Cloudflared)).
@@ -111,17 +197,92 @@ func (ed *Edge) GetDifferentAddr(connIndex int, hasConnectivityError bool) (*all
 	if oldAddr != nil {
 		ed.regions.GiveBack(oldAddr, hasConnectivityError)
 	}
-	addr := ed.regions.GetUnusedAddr(oldAddr, connIndex)
+	addr = ed.getUnusedAddr(oldAddr, connIndex)
+	if addr == nil && oldAddr != nil && ed.regions.NumAddrs() == 1 {
+		log.Debug().
+			IPAddr(LogFieldIPAddress, oldAddr.UDP.IP).
+			Msg("edge discovery: no alternative to the only address in the pool, giving it back to connection")
+		return ed.regions.GetUnusedAddr(nil, connIndex), true, nil
+	}
 	if addr == nil {
 		log.Debug().Msg("edge discovery: no addresses left in pool to give proxy connection")
 		// note: if oldAddr were not nil, it will become available on the next iteration
-		return nil, errNoAddressesLeft
+		return nil, false, errNoAddressesLeft
 	}
 	log.Debug().
 		IPAddr(LogFieldIPAddress, addr.UDP.IP).
 		Int("available", ed.regions.AvailableAddrs()).
 		Msg("edge discovery: giving new address to connection")
-	return addr, nil
+	return addr, false, nil
+}
+
+// getUnusedAddr gives the connection an unused Addr other than excluding, spreading connections across
+// regions if asked to.
+func (ed *Edge) getUnusedAddr(excluding *allregions.EdgeAddr, connIndex int) *allregions.EdgeAddr {
+	ed.releaseQuarantinedAddrs()
+	if ed.spreadRegions {
+		return ed.regions.GetUnusedAddrSpread(excluding, connIndex)
+	}
+	return ed.regions.GetUnusedAddr(excluding, connIndex)
+}
+
+// SpreadAcrossRegions makes the edge give new connections Addrs of the region the fewest connections use,
+// rather than balancing the Addrs left in each region.
+func (ed *Edge) SpreadAcrossRegions() {
+	ed.Lock()
+	defer ed.Unlock()
+	ed.spreadRegions = true
+}
+
+// RegionPlacement returns how many connections use an Addr of each region, and how many Addrs are left
+// in each region.
+func (ed *Edge) RegionPlacement() (connections, available []int) {
+	ed.Lock()
+	defer ed.Unlock()
+	return ed.regions.UsedAddrsPerRegion(), ed.regions.AvailableAddrsPerRegion()
+}
+
+// RegionUsedBy returns the index of the region of the Addr the connection uses, or -1 if it has none.
+func (ed *Edge) RegionUsedBy(connIndex int) int {
+	ed.Lock()
+	defer ed.Unlock()
+	return ed.regions.RegionUsedBy(connIndex)
+}
+
+// GetFallbackAddr returns an unused Addr of the other IP version than the one the proxy connection uses,
+// without giving it to the connection, so that both can be raced. Returns nil if the connection has no
+// Addr or there is no unused Addr of the other IP version, e.g. because only one IP version is used.
+func (ed *Edge) GetFallbackAddr(connIndex int) *allregions.EdgeAddr {
+	ed.Lock()
+	defer ed.Unlock()
+	addr := ed.regions.AddrUsedBy(connIndex)
+	if addr == nil {
+		return nil
+	}
+	version := allregions.V6
+	if addr.IPVersion == allregions.V6 {
+		version = allregions.V4
+	}
+	return ed.regions.PeekUnusedAddrWithIPVersion(version)
+}
+
+// SwitchAddr gives back the proxy connection's Addr and gives it the provided one instead, e.g. because that
+// one won a race against it. Returns false, leaving the connection on its Addr, if the provided Addr has been
+// given to another connection in the meantime.
+func (ed *Edge) SwitchAddr(connIndex int, addr *allregions.EdgeAddr) bool {
+	ed.Lock()
+	defer ed.Unlock()
+	oldAddr := ed.regions.AddrUsedBy(connIndex)
+	if oldAddr != nil {
+		ed.regions.GiveBack(oldAddr, false)
+	}
+	if ed.regions.Use(addr, connIndex) {
+		return true
+	}
+	if oldAddr != nil {
+		ed.regions.Use(oldAddr, connIndex)
+	}
+	return false
 }
 
 // AvailableAddrs returns how many unused addresses there are left.
@@ -142,3 +303,118 @@ func (ed *Edge) GiveBack(addr *allregions.EdgeAddr, hasConnectivityError bool) b
 		Msg("edge discovery: gave back address to the pool")
 	return ed.regions.GiveBack(addr, hasConnectivityError)
 }
+
+// ReleaseAddr gives back the address used by a connection that is gone for good, so that other
+// connections can use it.
+func (ed *Edge) ReleaseAddr(connIndex int) {
+	ed.Lock()
+	defer ed.Unlock()
+	if addr := ed.regions.AddrUsedBy(connIndex); addr != nil {
+		ed.regions.GiveBack(addr, false)
+	}
+}
+
+// DNSRecords returns the DNS records the edge address pool was resolved from.
+func (ed *Edge) DNSRecords() []allregions.DNSRecord {
+	ed.Lock()
+	defer ed.Unlock()
+	return append([]allregions.DNSRecord(nil), ed.regions.DNSRecords()...)
+}
+
+// RecordAttempt counts an attempt to establish a connection with the given address.
+func (ed *Edge) RecordAttempt(addr *allregions.EdgeAddr) {
+	ed.Lock()
+	defer ed.Unlock()
+	ed.statsFor(addr).Attempts++
+	addrAttempts.WithLabelValues(addrLabel(addr)).Inc()
+	if usedBy, ok := ed.regions.UsedBy(addr); ok && usedBy.Used {
+		if ed.triedAddrs == nil {
+			ed.triedAddrs = make(map[int]map[*allregions.EdgeAddr]struct{})
+		}
+		if ed.triedAddrs[usedBy.ConnID] == nil {
+			ed.triedAddrs[usedBy.ConnID] = make(map[*allregions.EdgeAddr]struct{})
+		}
+		ed.triedAddrs[usedBy.ConnID][addr] = struct{}{}
+	}
+}
+
+// RecordOutcome counts the result of an attempt previously recorded with RecordAttempt.
+func (ed *Edge) RecordOutcome(addr *allregions.EdgeAddr, connected bool) {
+	ed.Lock()
+	defer ed.Unlock()
+	stats := ed.statsFor(addr)
+	ed.outcomes = append(ed.outcomes, attemptOutcome{at: timeNow(), connected: connected})
+	if len(ed.outcomes) > maxOutcomeHistory {
+		ed.outcomes = ed.outcomes[len(ed.outcomes)-maxOutcomeHistory:]
+	}
+	ed.recordHealth(addr, connected)
+	if connected {
+		stats.Successes++
+		addrSuccesses.WithLabelValues(addrLabel(addr)).Inc()
+		if usedBy, ok := ed.regions.UsedBy(addr); ok && usedBy.Used {
+			delete(ed.triedAddrs, usedBy.ConnID)
+		}
+	} else {
+		stats.Failures++
+		addrFailures.WithLabelValues(addrLabel(addr)).Inc()
+	}
+}
+
+// AddrStats returns a snapshot of the connection attempt counters, keyed by edge IP.
+func (ed *Edge) AddrStats() map[string]AddrStats {
+	ed.Lock()
+	defer ed.Unlock()
+	snapshot := make(map[string]AddrStats, len(ed.addrStats))
+	for addr, stats := range ed.addrStats {
+		snapshot[addr] = *stats
+	}
+	return snapshot
+}
+
+// TriedAddrs counts the distinct addresses the connection attempted with RecordAttempt since it last connected,
+// or since ResetTriedAddrs.
+func (ed *Edge) TriedAddrs(connIndex int) int {
+	ed.Lock()
+	defer ed.Unlock()
+	return len(ed.triedAddrs[connIndex])
+}
+
+// ResetTriedAddrs forgets the addresses the connection attempted, so that TriedAddrs counts from zero again.
+func (ed *Edge) ResetTriedAddrs(connIndex int) {
+	ed.Lock()
+	defer ed.Unlock()
+	delete(ed.triedAddrs, connIndex)
+}
+
+// OutcomesSince counts the attempt outcomes recorded with RecordOutcome since the given time.
+// Only the most recent outcomes are kept, so older ones may not be counted.
+func (ed *Edge) OutcomesSince(since time.Time) (successes, failures uint64) {
+	ed.Lock()
+	defer ed.Unlock()
+	for i := len(ed.outcomes) - 1; i >= 0 && !ed.outcomes[i].at.Before(since); i-- {
+		if ed.outcomes[i].connected {
+			successes++
+		} else {
+			failures++
+		}
+	}
+	return successes, failures
+}
+
+// statsFor must be called with the lock held.
+func (ed *Edge) statsFor(addr *allregions.EdgeAddr) *AddrStats {
+	if ed.addrStats == nil {
+		ed.addrStats = make(map[string]*AddrStats)
+	}
+	label := addrLabel(addr)
+	stats, ok := ed.addrStats[label]
+	if !ok {
+		stats = &AddrStats{}
+		ed.addrStats[label] = stats
+	}
+	return stats
+}
+
+func addrLabel(addr *allregions.EdgeAddr) string {
+	return addr.UDP.IP.String()
+}
@@ -3,9 +3,11 @@ package edgediscovery
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
 )
@@ -179,13 +181,13 @@ func TestOnePerRegion(t *testing.T) {
 	assert.NotNil(t, a1)
 
 	// if the first address is bad, get the second one
-	a2, err := edge.GetDifferentAddr(connID, false)
+	a2, _, err := edge.GetDifferentAddr(connID, false)
 	assert.NoError(t, err)
 	assert.NotNil(t, a2)
 	assert.NotEqual(t, a1, a2)
 
 	// now that second one is bad, get the first one again
-	a3, err := edge.GetDifferentAddr(connID, false)
+	a3, _, err := edge.GetDifferentAddr(connID, false)
 	assert.NoError(t, err)
 	assert.Equal(t, a1, a3)
 }
@@ -200,14 +202,12 @@ func TestOnlyOneAddrLeft(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, addr)
 
-	// If that edge address is "bad", there's no alternative address.
-	_, err = edge.GetDifferentAddr(connID, false)
-	assert.Error(t, err)
-
-	// previously bad address should become available again on next iteration.
-	addr, err = edge.GetDifferentAddr(connID, false)
+	// If that edge address is "bad", there's no alternative address, so the connection keeps using it.
+	sameAddr, noAlternative, err := edge.GetDifferentAddr(connID, false)
 	assert.NoError(t, err)
-	assert.NotNil(t, addr)
+	assert.True(t, noAlternative)
+	assert.Equal(t, addr, sameAddr)
+	assert.Equal(t, 0, edge.AvailableAddrs())
 }
 
 func TestNoAddrsLeft(t *testing.T) {
@@ -247,17 +247,174 @@ func TestGetDifferentAddr(t *testing.T) {
 	assert.Equal(t, 3, edge.AvailableAddrs())
 
 	// If the same connection requests another address, it should get the same one.
-	addr2, err := edge.GetDifferentAddr(connID, false)
+	addr2, noAlternative, err := edge.GetDifferentAddr(connID, false)
 	assert.NoError(t, err)
+	assert.False(t, noAlternative)
 	assert.NotEqual(t, addr, addr2)
 	assert.Equal(t, 3, edge.AvailableAddrs())
 }
 
+func TestAddrStats(t *testing.T) {
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1, &addr2})
+
+	outcomes := []struct {
+		addr      *allregions.EdgeAddr
+		connected bool
+	}{
+		{&addr0, false},
+		{&addr0, false},
+		{&addr0, false},
+		{&addr1, false},
+		{&addr1, true},
+		{&addr0, true},
+	}
+	for _, o := range outcomes {
+		edge.RecordAttempt(o.addr)
+		edge.RecordOutcome(o.addr, o.connected)
+	}
+	// An attempt that is still in flight is only counted as an attempt
+	edge.RecordAttempt(&addr2)
+
+	stats := edge.AddrStats()
+	assert.Equal(t, map[string]AddrStats{
+		"123.4.5.0": {Attempts: 4, Successes: 1, Failures: 3},
+		"123.4.5.1": {Attempts: 2, Successes: 1, Failures: 1},
+		"123.4.5.2": {Attempts: 1},
+	}, stats)
+}
+
+func TestTriedAddrs(t *testing.T) {
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1, &addr2})
+
+	addr, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	edge.RecordAttempt(addr)
+	edge.RecordOutcome(addr, false)
+	// Trying the same address again doesn't count
+	edge.RecordAttempt(addr)
+	edge.RecordOutcome(addr, false)
+	addr, _, err = edge.GetDifferentAddr(0, true)
+	require.NoError(t, err)
+	edge.RecordAttempt(addr)
+	assert.Equal(t, 2, edge.TriedAddrs(0))
+	assert.Zero(t, edge.TriedAddrs(1))
+
+	// Connecting forgets the tried addresses
+	edge.RecordOutcome(addr, true)
+	assert.Zero(t, edge.TriedAddrs(0))
+
+	edge.RecordAttempt(addr)
+	assert.Equal(t, 1, edge.TriedAddrs(0))
+	edge.ResetTriedAddrs(0)
+	assert.Zero(t, edge.TriedAddrs(0))
+}
+
 // MockEdge creates a Cloudflare Edge from arbitrary TCP addresses. Used for testing.
 func MockEdge(log *zerolog.Logger, addrs []*allregions.EdgeAddr) *Edge {
-	regions := allregions.NewNoResolve(addrs)
-	return &Edge{
-		log:     log,
-		regions: regions,
+	return newEdge(log, allregions.NewNoResolve(addrs))
+}
+
+func TestOutcomesSince(t *testing.T) {
+	originalTimeNow := timeNow
+	defer func() { timeNow = originalTimeNow }()
+	start := time.Now()
+	currentTime := start
+	timeNow = func() time.Time { return currentTime }
+
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1})
+	outcomes := []struct {
+		after     time.Duration
+		connected bool
+	}{
+		{0, false},
+		{time.Minute, false},
+		{2 * time.Minute, true},
+		{3 * time.Minute, false},
+		{4 * time.Minute, true},
+		{5 * time.Minute, true},
+	}
+	for _, o := range outcomes {
+		currentTime = start.Add(o.after)
+		edge.RecordAttempt(&addr0)
+		edge.RecordOutcome(&addr0, o.connected)
 	}
+
+	successes, failures := edge.OutcomesSince(start)
+	assert.Equal(t, uint64(3), successes)
+	assert.Equal(t, uint64(3), failures)
+
+	successes, failures = edge.OutcomesSince(start.Add(3 * time.Minute))
+	assert.Equal(t, uint64(2), successes)
+	assert.Equal(t, uint64(1), failures)
+
+	successes, failures = edge.OutcomesSince(start.Add(time.Hour))
+	assert.Zero(t, successes)
+	assert.Zero(t, failures)
+}
+
+func TestReleaseAddr(t *testing.T) {
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1})
+
+	const connID = 0
+	_, err := edge.GetAddr(connID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, edge.AvailableAddrs())
+
+	edge.ReleaseAddr(connID)
+	assert.Equal(t, 2, edge.AvailableAddrs())
+
+	// releasing a connection without an address is a no-op
+	edge.ReleaseAddr(connID)
+	assert.Equal(t, 2, edge.AvailableAddrs())
+}
+
+func TestGetFallbackAddr(t *testing.T) {
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr4})
+
+	const connID = 0
+	assert.Nil(t, edge.GetFallbackAddr(connID), "connection without an address has no fallback")
+	addr, err := edge.GetAddrWithIPVersion(connID, allregions.V4)
+	require.NoError(t, err)
+
+	fallback := edge.GetFallbackAddr(connID)
+	assert.Equal(t, &addr4, fallback)
+	// the fallback address isn't given to the connection until it switches to it
+	assert.Equal(t, 1, edge.AvailableAddrs())
+
+	assert.True(t, edge.SwitchAddr(connID, fallback))
+	current, err := edge.GetAddr(connID)
+	require.NoError(t, err)
+	assert.Equal(t, fallback, current)
+	assert.Equal(t, 1, edge.AvailableAddrs())
+
+	// another connection took the address in the meantime
+	const otherConnID = 1
+	_, err = edge.GetAddr(otherConnID)
+	require.NoError(t, err)
+	assert.False(t, edge.SwitchAddr(connID, addr))
+	current, err = edge.GetAddr(connID)
+	require.NoError(t, err)
+	assert.Equal(t, fallback, current)
+}
+
+func TestSpreadAcrossRegions(t *testing.T) {
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1, &addr2, &addr3})
+	edge.SpreadAcrossRegions()
+
+	_, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	_, err = edge.GetAddr(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, edge.RegionUsedBy(0), edge.RegionUsedBy(1))
+	assert.Equal(t, -1, edge.RegionUsedBy(2))
+
+	connections, available := edge.RegionPlacement()
+	assert.Equal(t, []int{1, 1}, connections)
+	assert.Equal(t, []int{1, 1}, available)
+
+	// Rotating keeps the connection in the region no other connection uses
+	region := edge.RegionUsedBy(1)
+	_, _, err = edge.GetDifferentAddr(1, false)
+	require.NoError(t, err)
+	assert.Equal(t, region, edge.RegionUsedBy(1))
 }
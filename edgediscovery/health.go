@@ -0,0 +1,77 @@
+package edgediscovery
+
+import (
+	"time"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+// addrHealth tracks the recent connection attempts against an edge address.
+type addrHealth struct {
+	// consecutive failed attempts since the last successful one
+	consecutiveFailures int
+	quarantinedUntil    time.Time
+}
+
+// QuarantineFailingAddrs makes the edge quarantine addresses that failed to connect threshold times in a
+// row: they're only given to connections once no other address is left, until cooldown passed. A zero
+// threshold disables the quarantine.
+func (ed *Edge) QuarantineFailingAddrs(threshold int, cooldown time.Duration) {
+	ed.Lock()
+	defer ed.Unlock()
+	ed.quarantineThreshold = threshold
+	ed.quarantineCooldown = cooldown
+}
+
+// IsQuarantined returns whether the address is quarantined because it failed repeatedly.
+func (ed *Edge) IsQuarantined(addr *allregions.EdgeAddr) bool {
+	ed.Lock()
+	defer ed.Unlock()
+	ed.releaseQuarantinedAddrs()
+	_, ok := ed.quarantined[addr]
+	return ok
+}
+
+// recordHealth updates the health of the address with the outcome of an attempt against it, quarantining
+// it if it failed too many times in a row. Must be called with the lock held.
+func (ed *Edge) recordHealth(addr *allregions.EdgeAddr, connected bool) {
+	if ed.quarantineThreshold <= 0 {
+		return
+	}
+	health, ok := ed.health[addr]
+	if !ok {
+		health = &addrHealth{}
+		ed.health[addr] = health
+	}
+	if connected {
+		health.consecutiveFailures = 0
+		return
+	}
+	health.consecutiveFailures++
+	if health.consecutiveFailures < ed.quarantineThreshold {
+		return
+	}
+	health.consecutiveFailures = 0
+	health.quarantinedUntil = timeNow().Add(ed.quarantineCooldown)
+	if _, ok := ed.quarantined[addr]; !ok {
+		ed.quarantined[addr] = struct{}{}
+		quarantinedAddrs.Inc()
+	}
+	ed.log.Warn().
+		IPAddr(LogFieldIPAddress, addr.UDP.IP).
+		Msgf("edge discovery: address failed %d times in a row, avoiding it for %s", ed.quarantineThreshold, ed.quarantineCooldown)
+}
+
+// releaseQuarantinedAddrs ends the quarantine of the addresses whose cooldown passed. Must be called with the
+// lock held.
+func (ed *Edge) releaseQuarantinedAddrs() {
+	now := timeNow()
+	for addr := range ed.quarantined {
+		if now.Before(ed.health[addr].quarantinedUntil) {
+			continue
+		}
+		delete(ed.quarantined, addr)
+		quarantinedAddrs.Dec()
+		ed.log.Debug().IPAddr(LogFieldIPAddress, addr.UDP.IP).Msg("edge discovery: address quarantine ended")
+	}
+}
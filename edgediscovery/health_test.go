@@ -0,0 +1,55 @@
+package edgediscovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+func TestQuarantineFailingAddrs(t *testing.T) {
+	currentTime := time.Now()
+	originalTimeNow := timeNow
+	defer func() { timeNow = originalTimeNow }()
+	timeNow = func() time.Time { return currentTime }
+
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1})
+	edge.QuarantineFailingAddrs(2, time.Minute)
+
+	// A success in between resets the failures
+	edge.RecordOutcome(&addr0, false)
+	edge.RecordOutcome(&addr0, true)
+	edge.RecordOutcome(&addr0, false)
+	assert.False(t, edge.IsQuarantined(&addr0))
+
+	edge.RecordOutcome(&addr0, false)
+	assert.True(t, edge.IsQuarantined(&addr0))
+	assert.False(t, edge.IsQuarantined(&addr1))
+
+	// The healthy address is given first, then the quarantined one since no other is left
+	for i := 0; i < 10; i++ {
+		addr, err := edge.GetAddr(0)
+		require.NoError(t, err)
+		assert.Equal(t, &addr1, addr)
+		edge.ReleaseAddr(0)
+	}
+	_, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	addr, err := edge.GetAddr(1)
+	require.NoError(t, err)
+	assert.Equal(t, &addr0, addr)
+
+	currentTime = currentTime.Add(time.Minute)
+	assert.False(t, edge.IsQuarantined(&addr0))
+}
+
+func TestQuarantineDisabled(t *testing.T) {
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1})
+	for i := 0; i < 10; i++ {
+		edge.RecordOutcome(&addr0, false)
+	}
+	assert.False(t, edge.IsQuarantined(&addr0))
+}
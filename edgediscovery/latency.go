@@ -0,0 +1,78 @@
+package edgediscovery
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+// probeLatency measures the round trip time to addr by connecting to it over TCP. It's a variable so that
+// tests can replace it.
+var probeLatency = func(ctx context.Context, addr *allregions.EdgeAddr, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr.TCP.String())
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	_ = conn.Close()
+	return rtt, nil
+}
+
+// ProbeLatencies measures the round trip time to every edge Addr, so that connections are given the Addrs
+// with the lowest latency of each region first. Addrs that can't be reached within timeout are given last.
+func (ed *Edge) ProbeLatencies(ctx context.Context, timeout time.Duration) {
+	ed.Lock()
+	addrs := ed.regions.Addrs()
+	ed.Unlock()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		latencies = make(map[*allregions.EdgeAddr]time.Duration, len(addrs))
+	)
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr *allregions.EdgeAddr) {
+			defer wg.Done()
+			rtt, err := probeLatency(ctx, addr, timeout)
+			if err != nil {
+				ed.log.Debug().Err(err).IPAddr(LogFieldIPAddress, addr.UDP.IP).Msg("edge discovery: failed to probe latency")
+				addrLatency.DeleteLabelValues(addrLabel(addr))
+				return
+			}
+			addrLatency.WithLabelValues(addrLabel(addr)).Set(rtt.Seconds())
+			mu.Lock()
+			latencies[addr] = rtt
+			mu.Unlock()
+		}(addr)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	ed.Lock()
+	defer ed.Unlock()
+	ed.regions.SetLatencies(latencies)
+}
+
+// RunLatencyProbes probes the latency to every edge Addr each interval, until ctx is done.
+func (ed *Edge) RunLatencyProbes(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ed.ProbeLatencies(ctx, timeout)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package edgediscovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+func TestProbeLatencies(t *testing.T) {
+	latencies := map[*allregions.EdgeAddr]time.Duration{
+		&addr0: 50 * time.Millisecond,
+		&addr1: 5 * time.Millisecond,
+		&addr2: 10 * time.Millisecond,
+	}
+	originalProbe := probeLatency
+	defer func() { probeLatency = originalProbe }()
+	probeLatency = func(_ context.Context, addr *allregions.EdgeAddr, _ time.Duration) (time.Duration, error) {
+		if latency, ok := latencies[addr]; ok {
+			return latency, nil
+		}
+		return 0, errors.New("unreachable")
+	}
+
+	// The first region has addr0 and addr2, the second one addr1 and addr3
+	edge := MockEdge(&testLogger, []*allregions.EdgeAddr{&addr0, &addr1, &addr2, &addr3})
+	edge.ProbeLatencies(context.Background(), time.Second)
+
+	// Each connection is given the lowest-latency address of its region
+	addr, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	other, err := edge.GetAddr(1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*allregions.EdgeAddr{&addr1, &addr2}, []*allregions.EdgeAddr{addr, other})
+
+	// Refreshed latencies apply to addresses given afterwards
+	latencies[&addr0] = time.Millisecond
+	edge.ProbeLatencies(context.Background(), time.Second)
+	edge.ReleaseAddr(0)
+	edge.ReleaseAddr(1)
+	addr, err = edge.GetAddr(0)
+	require.NoError(t, err)
+	other, err = edge.GetAddr(1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*allregions.EdgeAddr{&addr0, &addr1}, []*allregions.EdgeAddr{addr, other})
+}
+
+func TestProbeLatencyConnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	addr := &allregions.EdgeAddr{TCP: listener.Addr().(*net.TCPAddr)}
+	rtt, err := probeLatency(context.Background(), addr, time.Second)
+	require.NoError(t, err)
+	assert.Greater(t, rtt, time.Duration(0))
+
+	listener.Close()
+	_, err = probeLatency(context.Background(), addr, time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package edgediscovery
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	MetricsNamespace = "cloudflared"
+	MetricsSubsystem = "edge_discovery"
+)
+
+var (
+	addrAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "address_attempts",
+			Help:      "Count of connection establishment attempts by edge address",
+		},
+		[]string{"address"},
+	)
+	addrSuccesses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "address_successes",
+			Help:      "Count of successfully established connections by edge address",
+		},
+		[]string{"address"},
+	)
+	addrFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "address_failures",
+			Help:      "Count of failed connection establishment attempts by edge address",
+		},
+		[]string{"address"},
+	)
+	quarantinedAddrs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "quarantined_addresses",
+			Help:      "Number of edge addresses avoided because they failed repeatedly",
+		},
+	)
+	addrLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "address_rtt_seconds",
+			Help:      "Round trip time last measured to each edge address",
+		},
+		[]string{"address"},
+	)
+	refreshFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "refresh_failures",
+			Help:      "Count of failures to resolve the edge again to refresh its addresses",
+		},
+	)
+	addrsAdded = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "addresses_added",
+			Help:      "Count of edge addresses added when the edge addresses were refreshed or reloaded",
+		},
+	)
+	addrsRemoved = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "addresses_removed",
+			Help:      "Count of edge addresses removed when the edge addresses were refreshed or reloaded",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		addrAttempts,
+		addrSuccesses,
+		addrFailures,
+		addrLatency,
+		quarantinedAddrs,
+		refreshFailures,
+		addrsAdded,
+		addrsRemoved,
+	)
+}
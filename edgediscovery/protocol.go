@@ -1,6 +1,7 @@
 package edgediscovery
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -38,7 +39,23 @@ func (p ProtocolPercents) GetPercentage(protocol string) int32 {
 
 // ProtocolPercentage returns the ratio of protocols and a specification ratio for their selection.
 func ProtocolPercentage() (ProtocolPercents, error) {
-	records, err := net.LookupTXT(protocolRecord)
+	return parseProtocolRecords(net.LookupTXT(protocolRecord))
+}
+
+// ProtocolPercentageFetcher returns a PercentageFetcher that looks the ratio of protocols up with resolver,
+// or the system resolver if nil.
+func ProtocolPercentageFetcher(resolver *net.Resolver) PercentageFetcher {
+	if resolver == nil {
+		return ProtocolPercentage
+	}
+	return func() (ProtocolPercents, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), resolverTimeout)
+		defer cancel()
+		return parseProtocolRecords(resolver.LookupTXT(ctx, protocolRecord))
+	}
+}
+
+func parseProtocolRecords(records []string, err error) (ProtocolPercents, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,99 @@
+package edgediscovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// dialThroughProxy connects to target through the proxy at proxyURL, authenticated with the user info of
+// proxyURL, if any. The proxy is a SOCKS5 proxy if the scheme is socks5, and an HTTP(S) proxy otherwise.
+func dialThroughProxy(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, target string) (net.Conn, error) {
+	if proxyURL.Scheme == "socks5" {
+		return dialSOCKS5(ctx, dialer, proxyURL, target)
+	}
+	return dialHTTPConnect(ctx, dialer, proxyURL, target)
+}
+
+// dialSOCKS5 connects to target through the SOCKS5 proxy at proxyURL.
+func dialSOCKS5(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, target string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth = &proxy.Auth{User: user.Username(), Password: password}
+	}
+	socksDialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return socksDialer.(proxy.ContextDialer).DialContext(ctx, "tcp", target)
+}
+
+// dialHTTPConnect connects to target through the HTTP(S) proxy at proxyURL with a CONNECT request.
+func dialHTTPConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, target string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("TLS handshake with proxy %s failed: %w", proxyURL.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to read the response of proxy %s: %w", proxyURL.Host, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", proxyURL.Host, target, resp.Status)
+	}
+	// The edge only speaks once the TLS handshake started, so nothing should follow the response
+	if reader.Buffered() > 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %s sent unexpected data after connecting to %s", proxyURL.Host, target)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
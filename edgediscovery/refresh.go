@@ -0,0 +1,100 @@
+package edgediscovery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+// Refresh resolves the edge again, and replaces its addresses with the ones discovered. Connections keep
+// the address they use if it's still discovered. The others keep running, and are given one of the new
+// addresses once they reconnect. The edge is left as is if it can't be resolved.
+func (ed *Edge) Refresh() error {
+	if ed.discover == nil {
+		return fmt.Errorf("static edge addresses can't be refreshed")
+	}
+	regions, err := ed.discover()
+	if err != nil {
+		refreshFailures.Inc()
+		return err
+	}
+
+	ed.Lock()
+	defer ed.Unlock()
+	added, removed := ed.replaceRegions(regions)
+	ed.seeded = false
+	if added > 0 || removed > 0 {
+		ed.log.Info().Int("added", added).Int("removed", removed).Msg("edge discovery: refreshed the edge addresses")
+	} else {
+		ed.log.Debug().Msg("edge discovery: refreshed the edge addresses, which didn't change")
+	}
+	return nil
+}
+
+// RunRefresh resolves the edge again each interval, until ctx is done.
+func (ed *Edge) RunRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ed.Refresh(); err != nil {
+				ed.log.Err(err).Msg("edge discovery: failed to refresh the edge addresses, keeping the current ones")
+			}
+		}
+	}
+}
+
+// replaceRegions replaces the edge addresses with those of regions, returning how many addresses were
+// added and removed. What's known about the addresses that are kept, whether they're used, their health
+// and latency, carries over. The caller must hold the Mutex.
+func (ed *Edge) replaceRegions(regions *allregions.Regions) (added, removed int) {
+	oldAddrs := make(map[string]*allregions.EdgeAddr)
+	for _, addr := range ed.regions.Addrs() {
+		oldAddrs[addr.TCP.String()] = addr
+	}
+	oldLatencies := ed.regions.Latencies()
+	health := make(map[*allregions.EdgeAddr]*addrHealth)
+	quarantined := make(map[*allregions.EdgeAddr]struct{})
+	latencies := make(map[*allregions.EdgeAddr]time.Duration)
+	for _, addr := range regions.Addrs() {
+		oldAddr, ok := oldAddrs[addr.TCP.String()]
+		if !ok {
+			added++
+			continue
+		}
+		delete(oldAddrs, addr.TCP.String())
+		if usedBy, _ := ed.regions.UsedBy(oldAddr); usedBy.Used {
+			regions.Use(addr, usedBy.ConnID)
+		}
+		if h, ok := ed.health[oldAddr]; ok {
+			health[addr] = h
+		}
+		if _, ok := ed.quarantined[oldAddr]; ok {
+			quarantined[addr] = struct{}{}
+		}
+		if rtt, ok := oldLatencies[oldAddr]; ok {
+			latencies[addr] = rtt
+		}
+	}
+	removed = len(oldAddrs)
+	for _, addr := range oldAddrs {
+		addrLatency.DeleteLabelValues(addrLabel(addr))
+	}
+	addrsAdded.Add(float64(added))
+	addrsRemoved.Add(float64(removed))
+
+	quarantinedAddrs.Sub(float64(len(ed.quarantined) - len(quarantined)))
+	regions.SetQuarantined(quarantined)
+	if len(latencies) > 0 {
+		regions.SetLatencies(latencies)
+	}
+	ed.regions = regions
+	ed.health = health
+	ed.quarantined = quarantined
+	return added, removed
+}
@@ -0,0 +1,66 @@
+package edgediscovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+func getCounterValue(t *testing.T, counter prometheus.Counter) float64 {
+	var m = &dto.Metric{}
+	require.NoError(t, counter.Write(m))
+	return m.Counter.GetValue()
+}
+
+func TestRefresh(t *testing.T) {
+	hostnames := []string{"127.0.0.1:7844", "127.0.0.2:7844"}
+	var discoverErr error
+	discover := func() (*allregions.Regions, error) {
+		if discoverErr != nil {
+			return nil, discoverErr
+		}
+		return allregions.NewNoResolve(allregions.ResolveAddrs(hostnames, &testLogger)), nil
+	}
+	regions, err := discover()
+	require.NoError(t, err)
+	edge := newEdge(&testLogger, regions)
+	edge.discover = discover
+
+	kept, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	other, _, err := edge.GetDifferentAddr(1, false)
+	require.NoError(t, err)
+	require.NotEqual(t, kept.TCP.String(), other.TCP.String())
+
+	added, removed := getCounterValue(t, addrsAdded), getCounterValue(t, addrsRemoved)
+	hostnames = []string{kept.TCP.String(), "127.0.0.3:7844", "127.0.0.4:7844"}
+	require.NoError(t, edge.Refresh())
+	assert.Equal(t, added+2, getCounterValue(t, addrsAdded))
+	assert.Equal(t, removed+1, getCounterValue(t, addrsRemoved))
+
+	addr, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	assert.Equal(t, kept.TCP.String(), addr.TCP.String(), "connection should keep an address that is still discovered")
+	addr, err = edge.GetAddr(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, other.TCP.String(), addr.TCP.String(), "removed address shouldn't be given anymore")
+	assert.Equal(t, 1, edge.AvailableAddrs())
+
+	failures := getCounterValue(t, refreshFailures)
+	discoverErr = fmt.Errorf("no such host")
+	assert.Error(t, edge.Refresh())
+	assert.Equal(t, failures+1, getCounterValue(t, refreshFailures))
+	assert.Equal(t, 1, edge.AvailableAddrs(), "edge should be left as is")
+}
+
+func TestRefreshStaticEdge(t *testing.T) {
+	edge, err := StaticEdge(&testLogger, []string{"127.0.0.1:7844"})
+	require.NoError(t, err)
+	assert.Error(t, edge.Refresh())
+}
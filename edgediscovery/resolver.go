@@ -0,0 +1,203 @@
+package edgediscovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultDNSPort  = "53"
+	defaultDoTPort  = "853"
+	dohMediaType    = "application/dns-message"
+	resolverTimeout = 15 * time.Second
+)
+
+// Transport of the DNS over HTTPS requests, a variable so that tests can trust their server
+var dohTransport = http.DefaultTransport
+
+// NewResolver returns the resolver to discover the edge with, querying only the server at address, rather
+// than the system resolver. An empty address returns nil, meaning the system resolver.
+// The address is one of:
+//   - host[:port] for plain DNS, on port 53 by default
+//   - tls://host[:port] for DNS over TLS, on port 853 by default
+//   - https://host[:port]/path for DNS over HTTPS. The host of the URL is resolved with the system resolver,
+//     unless it's an IP.
+func NewResolver(address string) (*net.Resolver, error) {
+	if address == "" {
+		return nil, nil
+	}
+	switch {
+	case strings.HasPrefix(address, "https://"):
+		endpoint, err := url.Parse(address)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid DNS over HTTPS resolver %s", address)
+		}
+		client := &http.Client{Transport: dohTransport, Timeout: resolverTimeout}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return &dohConn{ctx: ctx, client: client, endpoint: endpoint.String()}, nil
+			},
+		}, nil
+	case strings.HasPrefix(address, "tls://"):
+		server, host, err := resolverAddr(strings.TrimPrefix(address, "tls://"), defaultDoTPort)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig := &tls.Config{ServerName: host}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				conn, err := dialer.DialContext(ctx, "tcp", server)
+				if err != nil {
+					return nil, err
+				}
+				return tls.Client(conn, tlsConfig), nil
+			},
+		}, nil
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("unsupported resolver %s, expected host:port, tls://host:port or https://host/path", address)
+	default:
+		server, _, err := resolverAddr(address, defaultDNSPort)
+		if err != nil {
+			return nil, err
+		}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}, nil
+	}
+}
+
+// resolverAddr adds defaultPort to address if it has none, returning it along with its host.
+func resolverAddr(address, defaultPort string) (string, string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = strings.Trim(address, "[]"), defaultPort
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid resolver %s", address)
+	}
+	return net.JoinHostPort(host, port), host, nil
+}
+
+// dohConn is a net.Conn that exchanges DNS messages with a DNS over HTTPS endpoint (RFC 8484). The Go
+// resolver writes each query to it prefixed with its length, as it does over TCP, and reads the answer back
+// the same way.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+	deadline time.Time
+
+	query  bytes.Buffer
+	answer bytes.Reader
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.query.Write(b)
+	msg := c.query.Bytes()
+	if len(msg) < 2 {
+		return len(b), nil
+	}
+	end := 2 + int(binary.BigEndian.Uint16(msg))
+	if len(msg) < end {
+		return len(b), nil
+	}
+	answer, err := c.exchange(msg[2:end])
+	c.query.Reset()
+	if err != nil {
+		return 0, err
+	}
+	framed := make([]byte, 2+len(answer))
+	binary.BigEndian.PutUint16(framed, uint16(len(answer)))
+	copy(framed[2:], answer)
+	c.answer.Reset(framed)
+	return len(b), nil
+}
+
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS over HTTPS resolver %s returned %s", c.endpoint, resp.Status)
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 0xffff+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read DNS over HTTPS answer")
+	}
+	if len(answer) > 0xffff {
+		return nil, fmt.Errorf("DNS over HTTPS resolver %s returned an answer that's too large", c.endpoint)
+	}
+	return answer, nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	return c.answer.Read(b)
+}
+
+func (c *dohConn) Close() error {
+	return nil
+}
+
+func (c *dohConn) LocalAddr() net.Addr {
+	return dohAddr(c.endpoint)
+}
+
+func (c *dohConn) RemoteAddr() net.Addr {
+	return dohAddr(c.endpoint)
+}
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *dohConn) SetWriteDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+type dohAddr string
+
+func (a dohAddr) Network() string {
+	return "https"
+}
+
+func (a dohAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,134 @@
+package edgediscovery
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDNSHandler answers the SRV record of the edge and the TXT record of the protocol percentages.
+func testDNSHandler(t *testing.T) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, query *dns.Msg) {
+		answer := new(dns.Msg)
+		answer.SetReply(query)
+		q := query.Question[0]
+		switch q.Qtype {
+		case dns.TypeSRV:
+			answer.Answer = append(answer.Answer, &dns.SRV{
+				Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 60},
+				Port:   7844,
+				Target: "region1.v2.argotunnel.com.",
+			})
+		case dns.TypeTXT:
+			answer.Answer = append(answer.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+				Txt: []string{`[{"protocol":"quic","percentage":100}]`},
+			})
+		}
+		assert.NoError(t, w.WriteMsg(answer))
+	}
+}
+
+func assertResolves(t *testing.T, resolver *net.Resolver) {
+	_, srvs, err := resolver.LookupSRV(context.Background(), "v2-origintunneld", "tcp", "argotunnel.com")
+	require.NoError(t, err)
+	require.Len(t, srvs, 1)
+	assert.Equal(t, "region1.v2.argotunnel.com.", srvs[0].Target)
+	assert.Equal(t, uint16(7844), srvs[0].Port)
+
+	percents, err := ProtocolPercentageFetcher(resolver)()
+	require.NoError(t, err)
+	assert.Equal(t, int32(100), percents.GetPercentage("quic"))
+}
+
+func TestNewResolverDNS(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &dns.Server{PacketConn: conn, Handler: testDNSHandler(t)}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	defer server.Shutdown()
+
+	resolver, err := NewResolver(conn.LocalAddr().String())
+	require.NoError(t, err)
+	assertResolves(t, resolver)
+}
+
+func TestNewResolverDoH(t *testing.T) {
+	handler := testDNSHandler(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, dohMediaType, r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		query := new(dns.Msg)
+		require.NoError(t, query.Unpack(body))
+		handler(&dohResponseWriter{w: w}, query)
+	}))
+	defer server.Close()
+	defer func(transport http.RoundTripper) { dohTransport = transport }(dohTransport)
+	dohTransport = server.Client().Transport
+
+	resolver, err := NewResolver(server.URL + "/dns-query")
+	require.NoError(t, err)
+	assertResolves(t, resolver)
+}
+
+func TestNewResolver(t *testing.T) {
+	resolver, err := NewResolver("")
+	assert.NoError(t, err)
+	assert.Nil(t, resolver)
+
+	for _, address := range []string{"1.1.1.1", "1.1.1.1:5353", "2606:4700:4700::1111", "[2606:4700:4700::1111]:53", "tls://one.one.one.one", "https://1.1.1.1/dns-query"} {
+		resolver, err := NewResolver(address)
+		assert.NoError(t, err, address)
+		assert.NotNil(t, resolver, address)
+	}
+	for _, address := range []string{"udp://1.1.1.1", "tls://", "https://"} {
+		_, err := NewResolver(address)
+		assert.Error(t, err, address)
+	}
+}
+
+func TestResolverAddr(t *testing.T) {
+	tests := []struct {
+		address string
+		server  string
+		host    string
+	}{
+		{address: "1.1.1.1", server: "1.1.1.1:53", host: "1.1.1.1"},
+		{address: "1.1.1.1:5353", server: "1.1.1.1:5353", host: "1.1.1.1"},
+		{address: "2606:4700:4700::1111", server: "[2606:4700:4700::1111]:53", host: "2606:4700:4700::1111"},
+		{address: "one.one.one.one", server: "one.one.one.one:53", host: "one.one.one.one"},
+	}
+	for _, test := range tests {
+		server, host, err := resolverAddr(test.address, defaultDNSPort)
+		assert.NoError(t, err)
+		assert.Equal(t, test.server, server)
+		assert.Equal(t, test.host, host)
+	}
+}
+
+// dohResponseWriter writes the DNS answers of a handler as the body of a DNS over HTTPS response.
+type dohResponseWriter struct {
+	dns.ResponseWriter
+	w http.ResponseWriter
+}
+
+func (rw *dohResponseWriter) WriteMsg(msg *dns.Msg) error {
+	packed, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	rw.w.Header().Set("Content-Type", dohMediaType)
+	_, err = rw.w.Write(packed)
+	return err
+}
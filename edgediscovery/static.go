@@ -0,0 +1,98 @@
+package edgediscovery
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+	"github.com/cloudflare/cloudflared/watcher"
+)
+
+// ReadEdgeAddrsFile reads the host:port edge addresses listed in a file, one per line. Empty lines and
+// lines starting with # are ignored.
+func ReadEdgeAddrsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var addrs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// StaticEdgeFromFile creates a list of edge addresses from the hostnames listed in a file, see ReadEdgeAddrsFile.
+func StaticEdgeFromFile(log *zerolog.Logger, path string) (*Edge, error) {
+	hostnames, err := ReadEdgeAddrsFile(path)
+	if err != nil {
+		return new(Edge), err
+	}
+	return StaticEdge(log, hostnames)
+}
+
+// ReplaceStaticAddrs replaces the edge addresses with the given hostnames. Connections keep the address they
+// use if it's still listed. The others keep running, and are given one of the new addresses once they
+// reconnect. The edge is left as is if none of the hostnames resolves.
+func (ed *Edge) ReplaceStaticAddrs(hostnames []string) error {
+	resolved := allregions.ResolveAddrs(hostnames, ed.log)
+	if len(resolved) == 0 {
+		return fmt.Errorf("failed to resolve any edge address")
+	}
+	regions := allregions.NewNoResolve(resolved)
+
+	ed.Lock()
+	defer ed.Unlock()
+	ed.replaceRegions(regions)
+	ed.log.Info().Int("addresses", len(resolved)).Msg("edge discovery: replaced the static edge addresses")
+	return nil
+}
+
+// WatchAddrsFile replaces the edge addresses with the ones listed in the file each time it's written to,
+// until ctx is done.
+func (ed *Edge) WatchAddrsFile(ctx context.Context, notifier watcher.Notifier, path string) error {
+	if err := notifier.Add(path); err != nil {
+		return err
+	}
+	go notifier.Start(&addrsFileWatcher{edge: ed, path: path})
+	go func() {
+		<-ctx.Done()
+		notifier.Shutdown()
+	}()
+	return nil
+}
+
+// addrsFileWatcher reloads the edge addresses from a file when it changes.
+type addrsFileWatcher struct {
+	edge *Edge
+	path string
+}
+
+func (w *addrsFileWatcher) WatcherItemDidChange(string) {
+	hostnames, err := ReadEdgeAddrsFile(w.path)
+	if err == nil {
+		err = w.edge.ReplaceStaticAddrs(hostnames)
+	}
+	if err != nil {
+		w.edge.log.Err(err).Str("path", w.path).Msg("edge discovery: failed to reload the edge addresses file")
+	}
+}
+
+func (w *addrsFileWatcher) WatcherDidError(err error) {
+	w.edge.log.Err(err).Str("path", w.path).Msg("edge discovery: edge addresses file watcher encountered an error")
+}
@@ -0,0 +1,96 @@
+package edgediscovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/watcher"
+)
+
+type mockFileWatcher struct {
+	path     string
+	notifier watcher.Notification
+	ready    chan struct{}
+}
+
+func (w *mockFileWatcher) Start(n watcher.Notification) {
+	w.notifier = n
+	w.ready <- struct{}{}
+}
+
+func (w *mockFileWatcher) Add(path string) error {
+	w.path = path
+	return nil
+}
+
+func (w *mockFileWatcher) Shutdown() {}
+
+func (w *mockFileWatcher) TriggerChange() {
+	w.notifier.WatcherItemDidChange(w.path)
+}
+
+func writeEdgeAddrsFile(t *testing.T, path string, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestReadEdgeAddrsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge-addrs")
+	writeEdgeAddrsFile(t, path, "# allowed edge addresses\n127.0.0.1:7844\n\n  127.0.0.2:7844  \n[::1]:7844\n")
+
+	addrs, err := ReadEdgeAddrsFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:7844", "127.0.0.2:7844", "[::1]:7844"}, addrs)
+
+	_, err = ReadEdgeAddrsFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestReplaceStaticAddrs(t *testing.T) {
+	edge, err := StaticEdge(&testLogger, []string{"127.0.0.1:7844", "127.0.0.2:7844"})
+	require.NoError(t, err)
+	kept, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	other, _, err := edge.GetDifferentAddr(1, false)
+	require.NoError(t, err)
+	require.NotEqual(t, kept.TCP.String(), other.TCP.String())
+
+	require.NoError(t, edge.ReplaceStaticAddrs([]string{kept.TCP.String(), "127.0.0.3:7844", "127.0.0.4:7844"}))
+	addr, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	assert.Equal(t, kept.TCP.String(), addr.TCP.String(), "connection should keep an address that is still listed")
+	addr, err = edge.GetAddr(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, other.TCP.String(), addr.TCP.String(), "removed address shouldn't be given anymore")
+	assert.Equal(t, 1, edge.AvailableAddrs())
+
+	assert.Error(t, edge.ReplaceStaticAddrs([]string{"not an address"}))
+	assert.Equal(t, 1, edge.AvailableAddrs(), "edge should be left as is")
+}
+
+func TestWatchAddrsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edge-addrs")
+	writeEdgeAddrsFile(t, path, "127.0.0.1:7844\n")
+	edge, err := StaticEdgeFromFile(&testLogger, path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, edge.AvailableAddrs())
+
+	w := &mockFileWatcher{ready: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, edge.WatchAddrsFile(ctx, w, path))
+	<-w.ready
+
+	writeEdgeAddrsFile(t, path, "127.0.0.1:7844\n127.0.0.2:7844\n127.0.0.3:7844\n")
+	w.TriggerChange()
+	assert.Equal(t, 3, edge.AvailableAddrs())
+
+	// An unreadable file leaves the addresses as they are
+	require.NoError(t, os.Remove(path))
+	w.TriggerChange()
+	assert.Equal(t, 3, edge.AvailableAddrs())
+}
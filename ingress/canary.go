@@ -0,0 +1,77 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+// CanaryService is an OriginService splitting the requests of a rule between a primary and a canary HTTP origin.
+type CanaryService struct {
+	primary *httpService
+	canary  *httpService
+	raw     config.CanaryConfig
+}
+
+func newCanaryService(primary, canary *httpService, cfg config.CanaryConfig) (*CanaryService, error) {
+	if cfg.Weight > 100 {
+		return nil, fmt.Errorf("canary weight %d isn't a percentage", cfg.Weight)
+	}
+	return &CanaryService{
+		primary: primary,
+		canary:  canary,
+		raw:     cfg,
+	}, nil
+}
+
+// Canary returns the configuration of the canary origin.
+func (s *CanaryService) Canary() config.CanaryConfig {
+	return s.raw
+}
+
+// String returns the primary origin, the canary one is part of Canary.
+func (s *CanaryService) String() string {
+	return s.primary.String()
+}
+
+func (s *CanaryService) start(log *zerolog.Logger, shutdownC <-chan struct{}, cfg OriginRequestConfig) error {
+	if err := s.primary.start(log, shutdownC, cfg); err != nil {
+		return err
+	}
+	return s.canary.start(log, shutdownC, cfg)
+}
+
+func (s *CanaryService) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *CanaryService) RoundTrip(req *http.Request) (*http.Response, error) {
+	if s.toCanary(req) {
+		return s.canary.RoundTrip(req)
+	}
+	return s.primary.RoundTrip(req)
+}
+
+// toCanary returns true if req goes to the canary origin. The header, then the cookie, override the weight when
+// they're set to a boolean.
+func (s *CanaryService) toCanary(req *http.Request) bool {
+	if s.raw.Header != "" {
+		if canary, err := strconv.ParseBool(req.Header.Get(s.raw.Header)); err == nil {
+			return canary
+		}
+	}
+	if s.raw.Cookie != "" {
+		if cookie, err := req.Cookie(s.raw.Cookie); err == nil {
+			if canary, err := strconv.ParseBool(cookie.Value); err == nil {
+				return canary
+			}
+		}
+	}
+	return uint(rand.Intn(100)) < s.raw.Weight
+}
@@ -0,0 +1,109 @@
+package ingress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestParseCanaryService(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - hostname: app.example.com
+   service: http://localhost:8000
+   canary:
+     service: http://localhost:8001
+     weight: 5
+     cookie: canary
+ - service: http_status:404
+`))
+	require.NoError(t, err)
+	canary, ok := ing.Rules[0].Service.(*CanaryService)
+	require.True(t, ok)
+	assert.Equal(t, "http://localhost:8000", canary.String())
+	assert.Equal(t, config.CanaryConfig{Service: "http://localhost:8001", Weight: 5, Cookie: "canary"}, canary.Canary())
+
+	for _, rawYAML := range []string{`
+ingress:
+ - service: http://localhost:8000
+   canary:
+     service: tcp://localhost:8001
+`, `
+ingress:
+ - service: http://localhost:8000
+   canary:
+     service: http://localhost:8001
+     weight: 101
+`, `
+ingress:
+ - service: http_status:404
+   canary:
+     service: http://localhost:8001
+`, `
+ingress:
+ - service:
+     - http://localhost:8000
+     - http://localhost:8001
+   canary:
+     service: http://localhost:8002
+`} {
+		_, err := ParseIngress(MustReadIngress(rawYAML))
+		assert.Error(t, err, rawYAML)
+	}
+}
+
+func newTestCanaryService(t *testing.T, cfg config.CanaryConfig) *CanaryService {
+	origin := func(name string) *httpService {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}))
+		t.Cleanup(server.Close)
+		return &httpService{url: MustParseURL(t, server.URL)}
+	}
+	canary, err := newCanaryService(origin("primary"), origin("canary"), cfg)
+	require.NoError(t, err)
+	require.NoError(t, canary.start(testLogger, make(chan struct{}), originRequestFromConfig(config.OriginRequestConfig{})))
+	return canary
+}
+
+func TestCanaryServiceWeight(t *testing.T) {
+	for weight, expected := range map[uint]string{0: "primary", 100: "canary"} {
+		canary := newTestCanaryService(t, config.CanaryConfig{Weight: weight})
+		for i := 0; i < 10; i++ {
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			require.NoError(t, err)
+			resp, err := canary.RoundTrip(req)
+			require.NoError(t, err)
+			assert.Equal(t, expected, readOrigin(t, resp))
+		}
+	}
+}
+
+func TestCanaryServiceOverride(t *testing.T) {
+	canary := newTestCanaryService(t, config.CanaryConfig{Weight: 50, Header: "X-Canary", Cookie: "canary"})
+	roundTrip := func(header, cookie string) string {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("X-Canary", header)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "canary", Value: cookie})
+		}
+		resp, err := canary.RoundTrip(req)
+		require.NoError(t, err)
+		return readOrigin(t, resp)
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "canary", roundTrip("true", ""))
+		assert.Equal(t, "primary", roundTrip("false", "true"))
+		assert.Equal(t, "canary", roundTrip("", "1"))
+		assert.Equal(t, "primary", roundTrip("", "0"))
+	}
+}
@@ -123,6 +123,8 @@ func originRequestFromSingleRule(c *cli.Context) OriginRequestConfig {
 	var httpHostHeader string
 	var originServerName string
 	var caPool string
+	var clientCert string
+	var clientKey string
 	var noTLSVerify bool
 	var disableChunkedEncoding bool
 	var bastionMode bool
@@ -157,6 +159,12 @@ func originRequestFromSingleRule(c *cli.Context) OriginRequestConfig {
 	if flag := tlsconfig.OriginCAPoolFlag; c.IsSet(flag) {
 		caPool = c.String(flag)
 	}
+	if flag := tlsconfig.OriginClientCertFlag; c.IsSet(flag) {
+		clientCert = c.String(flag)
+	}
+	if flag := tlsconfig.OriginClientKeyFlag; c.IsSet(flag) {
+		clientKey = c.String(flag)
+	}
 	if flag := NoTLSVerifyFlag; c.IsSet(flag) {
 		noTLSVerify = c.Bool(flag)
 	}
@@ -190,6 +198,8 @@ func originRequestFromSingleRule(c *cli.Context) OriginRequestConfig {
 		HTTPHostHeader:         httpHostHeader,
 		OriginServerName:       originServerName,
 		CAPool:                 caPool,
+		ClientCert:             clientCert,
+		ClientKey:              clientKey,
 		NoTLSVerify:            noTLSVerify,
 		DisableChunkedEncoding: disableChunkedEncoding,
 		BastionMode:            bastionMode,
@@ -236,6 +246,12 @@ func originRequestFromConfig(c config.OriginRequestConfig) OriginRequestConfig {
 	if c.CAPool != nil {
 		out.CAPool = *c.CAPool
 	}
+	if c.ClientCert != nil {
+		out.ClientCert = *c.ClientCert
+	}
+	if c.ClientKey != nil {
+		out.ClientKey = *c.ClientKey
+	}
 	if c.NoTLSVerify != nil {
 		out.NoTLSVerify = *c.NoTLSVerify
 	}
@@ -265,6 +281,81 @@ func originRequestFromConfig(c config.OriginRequestConfig) OriginRequestConfig {
 	if c.Http2Origin != nil {
 		out.Http2Origin = *c.Http2Origin
 	}
+	if c.TCPIdleTimeout != nil {
+		out.TCPIdleTimeout = *c.TCPIdleTimeout
+	}
+	if c.ResponseHeaderTimeout != nil {
+		out.ResponseHeaderTimeout = *c.ResponseHeaderTimeout
+	}
+	if c.MaxRequestBodySize != nil {
+		out.MaxRequestBodySize = *c.MaxRequestBodySize
+	}
+	if c.ProxyProtocol != nil {
+		out.ProxyProtocol = *c.ProxyProtocol
+	}
+	if c.StripPrefix != nil {
+		out.StripPrefix = *c.StripPrefix
+	}
+	if c.RewritePath != nil {
+		out.RewritePath = *c.RewritePath
+	}
+	if c.RequestHeaders != nil {
+		out.RequestHeaders = *c.RequestHeaders
+	}
+	if c.ResponseHeaders != nil {
+		out.ResponseHeaders = *c.ResponseHeaders
+	}
+	if c.CloudflareHeaders != nil {
+		out.CloudflareHeaders = *c.CloudflareHeaders
+	}
+	if c.CircuitBreaker != nil {
+		out.CircuitBreaker = *c.CircuitBreaker
+	}
+	if c.Retry != nil {
+		out.Retry = *c.Retry
+	}
+	if c.ConcurrencyLimit != nil {
+		out.ConcurrencyLimit = *c.ConcurrencyLimit
+	}
+	if c.RateLimit != nil {
+		out.RateLimit = *c.RateLimit
+	}
+	if c.Filter != nil {
+		out.Filter = *c.Filter
+	}
+	if c.ErrorPage != nil {
+		out.ErrorPage = *c.ErrorPage
+	}
+	if len(c.AllowIPs) > 0 {
+		out.AllowIPs = c.AllowIPs
+	}
+	if len(c.DenyIPs) > 0 {
+		out.DenyIPs = c.DenyIPs
+	}
+	if c.FileServer != nil {
+		out.FileServer = *c.FileServer
+	}
+	if c.StatusResponse != nil {
+		out.StatusResponse = *c.StatusResponse
+	}
+	if c.Cache != nil {
+		out.Cache = *c.Cache
+	}
+	if c.BandwidthLimit != nil {
+		out.BandwidthLimit = *c.BandwidthLimit
+	}
+	if c.DisableResponseBuffering != nil {
+		out.DisableResponseBuffering = *c.DisableResponseBuffering
+	}
+	if c.FlushInterval != nil {
+		out.FlushInterval = *c.FlushInterval
+	}
+	if c.ResponseBuffer != nil {
+		out.ResponseBuffer = *c.ResponseBuffer
+	}
+	if c.DisableAccessLog != nil {
+		out.DisableAccessLog = *c.DisableAccessLog
+	}
 	if c.Access != nil {
 		out.Access = *c.Access
 	}
@@ -294,6 +385,11 @@ type OriginRequestConfig struct {
 	// Path to the CA for the certificate of your origin.
 	// This option should be used only if your certificate is not signed by Cloudflare.
 	CAPool string `yaml:"caPool" json:"caPool"`
+	// Certificate presented to origins that require mutual TLS, either a path to a PEM file or inline PEM.
+	ClientCert string `yaml:"clientCert" json:"clientCert"`
+	// Private key of ClientCert, either a path to a PEM file or inline PEM. It's never serialized to JSON, so that
+	// it isn't served by /config.
+	ClientKey string `yaml:"clientKey" json:"-"`
 	// Disables TLS verification of the certificate presented by your origin.
 	// Will allow any certificate from the origin to be accepted.
 	// Note: The connection from your machine to Cloudflare's Edge is still encrypted.
@@ -313,6 +409,60 @@ type OriginRequestConfig struct {
 	IPRules []ipaccess.Rule `yaml:"ipRules" json:"ipRules"`
 	// Attempt to connect to origin with HTTP/2
 	Http2Origin bool `yaml:"http2Origin" json:"http2Origin"`
+	// Timeout after which a TCP stream to the origin is closed if no data went either way, 0 means no timeout
+	TCPIdleTimeout config.CustomDuration `yaml:"tcpIdleTimeout" json:"tcpIdleTimeout"`
+	// Timeout for receiving the response headers of the origin once the request was sent, 0 means no timeout
+	ResponseHeaderTimeout config.CustomDuration `yaml:"responseHeaderTimeout" json:"responseHeaderTimeout"`
+	// Size in bytes of the largest request body sent to the origin, 0 means no limit
+	MaxRequestBodySize int64 `yaml:"maxRequestBodySize" json:"maxRequestBodySize"`
+	// Version of the PROXY protocol header, v1 or v2, carrying the client address sent to the origin when connecting
+	// to it. Connections to HTTP origins aren't reused when it's set, since they're specific to a client.
+	ProxyProtocol string `yaml:"proxyProtocol" json:"proxyProtocol"`
+	// Prefix removed from the path of the requests sent to the origin
+	StripPrefix string `yaml:"stripPrefix" json:"stripPrefix"`
+	// Path of the requests sent to the origin, which can refer to the capture groups of the rule path, e.g. $1 or
+	// ${name}. StripPrefix is ignored if it's set.
+	RewritePath string `yaml:"rewritePath" json:"rewritePath"`
+	// Headers added to, set on and removed from the requests sent to the origin
+	RequestHeaders config.HeadersConfig `yaml:"requestHeaders" json:"requestHeaders"`
+	// Headers added to, set on and removed from the responses of the origin
+	ResponseHeaders config.HeadersConfig `yaml:"responseHeaders" json:"responseHeaders"`
+	// Strips or renames the headers Cloudflare adds to the requests sent to the origin
+	CloudflareHeaders config.CloudflareHeadersConfig `yaml:"cloudflareHeaders" json:"cloudflareHeaders"`
+	// Short-circuits the requests to the origin for a while after consecutive failures
+	CircuitBreaker config.CircuitBreakerConfig `yaml:"circuitBreaker" json:"circuitBreaker"`
+	// Retries idempotent requests that fail to reach the origin or get a retryable status
+	Retry config.RetryConfig `yaml:"retry" json:"retry"`
+	// Caps the number of requests in flight to the origin
+	ConcurrencyLimit config.ConcurrencyLimitConfig `yaml:"concurrencyLimit" json:"concurrencyLimit"`
+	// Rate limits the requests to the origin
+	RateLimit config.RateLimitConfig `yaml:"rateLimit" json:"rateLimit"`
+	// Denies the requests matching filters before they reach the origin
+	Filter config.FilterConfig `yaml:"filter" json:"filter"`
+	// Serves a custom error page rather than a generic 502 when the origin can't be reached
+	ErrorPage config.ErrorPageConfig `yaml:"errorPage" json:"errorPage"`
+	// Only lets the requests of clients in these CIDRs through
+	AllowIPs []string `yaml:"allowIPs" json:"allowIPs"`
+	// Denies the requests of clients in these CIDRs
+	DenyIPs []string `yaml:"denyIPs" json:"denyIPs"`
+	// Configures the file server of fileserver: services
+	FileServer config.FileServerConfig `yaml:"fileServer" json:"fileServer"`
+	// Configures the response of http_status: services
+	StatusResponse config.StatusResponseConfig `yaml:"statusResponse" json:"statusResponse"`
+	// Caches the responses to GET and HEAD requests in memory for a short while
+	Cache config.CacheConfig `yaml:"cache" json:"cache"`
+	// Caps the throughput of the traffic to and from the origin
+	BandwidthLimit config.BandwidthLimitConfig `yaml:"bandwidthLimit" json:"bandwidthLimit"`
+	// Sends every write of the response body to the edge right away, rather than when the buffer fills, e.g. for
+	// Server-Sent Events. Responses that are known to stream, like text/event-stream ones, are never buffered.
+	DisableResponseBuffering bool `yaml:"disableResponseBuffering" json:"disableResponseBuffering"`
+	// Longest time the writes of the response body are buffered before they're sent to the edge, 0 means until the
+	// buffer fills
+	FlushInterval config.CustomDuration `yaml:"flushInterval" json:"flushInterval"`
+	// Reads the responses of the origin into memory before sending them to the edge
+	ResponseBuffer config.ResponseBufferConfig `yaml:"responseBuffer" json:"responseBuffer"`
+	// Leaves the requests matching the rule out of the access log, e.g. for health checks
+	DisableAccessLog bool `yaml:"disableAccessLog" json:"disableAccessLog"`
 
 	// Access holds all access related configs
 	Access config.AccessConfig `yaml:"access" json:"access,omitempty"`
@@ -372,6 +522,18 @@ func (defaults *OriginRequestConfig) setCAPool(overrides config.OriginRequestCon
 	}
 }
 
+func (defaults *OriginRequestConfig) setClientCert(overrides config.OriginRequestConfig) {
+	if val := overrides.ClientCert; val != nil {
+		defaults.ClientCert = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setClientKey(overrides config.OriginRequestConfig) {
+	if val := overrides.ClientKey; val != nil {
+		defaults.ClientKey = *val
+	}
+}
+
 func (defaults *OriginRequestConfig) setNoTLSVerify(overrides config.OriginRequestConfig) {
 	if val := overrides.NoTLSVerify; val != nil {
 		defaults.NoTLSVerify = *val
@@ -427,6 +589,156 @@ func (defaults *OriginRequestConfig) setHttp2Origin(overrides config.OriginReque
 	}
 }
 
+func (defaults *OriginRequestConfig) setTCPIdleTimeout(overrides config.OriginRequestConfig) {
+	if val := overrides.TCPIdleTimeout; val != nil {
+		defaults.TCPIdleTimeout = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setResponseHeaderTimeout(overrides config.OriginRequestConfig) {
+	if val := overrides.ResponseHeaderTimeout; val != nil {
+		defaults.ResponseHeaderTimeout = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setMaxRequestBodySize(overrides config.OriginRequestConfig) {
+	if val := overrides.MaxRequestBodySize; val != nil {
+		defaults.MaxRequestBodySize = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setProxyProtocol(overrides config.OriginRequestConfig) {
+	if val := overrides.ProxyProtocol; val != nil {
+		defaults.ProxyProtocol = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setStripPrefix(overrides config.OriginRequestConfig) {
+	if val := overrides.StripPrefix; val != nil {
+		defaults.StripPrefix = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRewritePath(overrides config.OriginRequestConfig) {
+	if val := overrides.RewritePath; val != nil {
+		defaults.RewritePath = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRequestHeaders(overrides config.OriginRequestConfig) {
+	if val := overrides.RequestHeaders; val != nil {
+		defaults.RequestHeaders = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setResponseHeaders(overrides config.OriginRequestConfig) {
+	if val := overrides.ResponseHeaders; val != nil {
+		defaults.ResponseHeaders = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setCloudflareHeaders(overrides config.OriginRequestConfig) {
+	if val := overrides.CloudflareHeaders; val != nil {
+		defaults.CloudflareHeaders = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setCircuitBreaker(overrides config.OriginRequestConfig) {
+	if val := overrides.CircuitBreaker; val != nil {
+		defaults.CircuitBreaker = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRetry(overrides config.OriginRequestConfig) {
+	if val := overrides.Retry; val != nil {
+		defaults.Retry = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setConcurrencyLimit(overrides config.OriginRequestConfig) {
+	if val := overrides.ConcurrencyLimit; val != nil {
+		defaults.ConcurrencyLimit = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRateLimit(overrides config.OriginRequestConfig) {
+	if val := overrides.RateLimit; val != nil {
+		defaults.RateLimit = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setFilter(overrides config.OriginRequestConfig) {
+	if val := overrides.Filter; val != nil {
+		defaults.Filter = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setErrorPage(overrides config.OriginRequestConfig) {
+	if val := overrides.ErrorPage; val != nil {
+		defaults.ErrorPage = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setAllowIPs(overrides config.OriginRequestConfig) {
+	if val := overrides.AllowIPs; len(val) > 0 {
+		defaults.AllowIPs = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setDenyIPs(overrides config.OriginRequestConfig) {
+	if val := overrides.DenyIPs; len(val) > 0 {
+		defaults.DenyIPs = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setFileServer(overrides config.OriginRequestConfig) {
+	if val := overrides.FileServer; val != nil {
+		defaults.FileServer = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setStatusResponse(overrides config.OriginRequestConfig) {
+	if val := overrides.StatusResponse; val != nil {
+		defaults.StatusResponse = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setCache(overrides config.OriginRequestConfig) {
+	if val := overrides.Cache; val != nil {
+		defaults.Cache = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setBandwidthLimit(overrides config.OriginRequestConfig) {
+	if val := overrides.BandwidthLimit; val != nil {
+		defaults.BandwidthLimit = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setDisableResponseBuffering(overrides config.OriginRequestConfig) {
+	if val := overrides.DisableResponseBuffering; val != nil {
+		defaults.DisableResponseBuffering = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setFlushInterval(overrides config.OriginRequestConfig) {
+	if val := overrides.FlushInterval; val != nil {
+		defaults.FlushInterval = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setResponseBuffer(overrides config.OriginRequestConfig) {
+	if val := overrides.ResponseBuffer; val != nil {
+		defaults.ResponseBuffer = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setDisableAccessLog(overrides config.OriginRequestConfig) {
+	if val := overrides.DisableAccessLog; val != nil {
+		defaults.DisableAccessLog = *val
+	}
+}
+
 func (defaults *OriginRequestConfig) setAccess(overrides config.OriginRequestConfig) {
 	if val := overrides.Access; val != nil {
 		defaults.Access = *val
@@ -452,6 +764,8 @@ func setConfig(defaults OriginRequestConfig, overrides config.OriginRequestConfi
 	cfg.setHTTPHostHeader(overrides)
 	cfg.setOriginServerName(overrides)
 	cfg.setCAPool(overrides)
+	cfg.setClientCert(overrides)
+	cfg.setClientKey(overrides)
 	cfg.setNoTLSVerify(overrides)
 	cfg.setDisableChunkedEncoding(overrides)
 	cfg.setBastionMode(overrides)
@@ -460,6 +774,31 @@ func setConfig(defaults OriginRequestConfig, overrides config.OriginRequestConfi
 	cfg.setProxyType(overrides)
 	cfg.setIPRules(overrides)
 	cfg.setHttp2Origin(overrides)
+	cfg.setTCPIdleTimeout(overrides)
+	cfg.setResponseHeaderTimeout(overrides)
+	cfg.setMaxRequestBodySize(overrides)
+	cfg.setProxyProtocol(overrides)
+	cfg.setStripPrefix(overrides)
+	cfg.setRewritePath(overrides)
+	cfg.setRequestHeaders(overrides)
+	cfg.setResponseHeaders(overrides)
+	cfg.setCloudflareHeaders(overrides)
+	cfg.setCircuitBreaker(overrides)
+	cfg.setRetry(overrides)
+	cfg.setConcurrencyLimit(overrides)
+	cfg.setRateLimit(overrides)
+	cfg.setFilter(overrides)
+	cfg.setErrorPage(overrides)
+	cfg.setAllowIPs(overrides)
+	cfg.setDenyIPs(overrides)
+	cfg.setFileServer(overrides)
+	cfg.setStatusResponse(overrides)
+	cfg.setCache(overrides)
+	cfg.setBandwidthLimit(overrides)
+	cfg.setDisableResponseBuffering(overrides)
+	cfg.setFlushInterval(overrides)
+	cfg.setResponseBuffer(overrides)
+	cfg.setDisableAccessLog(overrides)
 	cfg.setAccess(overrides)
 
 	return cfg
@@ -472,6 +811,24 @@ func ConvertToRawOriginConfig(c OriginRequestConfig) config.OriginRequestConfig
 	var keepAliveConnections *int
 	var keepAliveTimeout *config.CustomDuration
 	var proxyAddress *string
+	var tcpIdleTimeout *config.CustomDuration
+	var responseHeaderTimeout *config.CustomDuration
+	var maxRequestBodySize *int64
+	var requestHeaders *config.HeadersConfig
+	var responseHeaders *config.HeadersConfig
+	var cloudflareHeaders *config.CloudflareHeadersConfig
+	var circuitBreaker *config.CircuitBreakerConfig
+	var retry *config.RetryConfig
+	var concurrencyLimit *config.ConcurrencyLimitConfig
+	var rateLimit *config.RateLimitConfig
+	var filter *config.FilterConfig
+	var errorPage *config.ErrorPageConfig
+	var fileServer *config.FileServerConfig
+	var statusResponse *config.StatusResponseConfig
+	var cache *config.CacheConfig
+	var bandwidthLimit *config.BandwidthLimitConfig
+	var flushInterval *config.CustomDuration
+	var responseBuffer *config.ResponseBufferConfig
 	var access *config.AccessConfig
 
 	if c.ConnectTimeout != defaultHTTPConnectTimeout {
@@ -492,30 +849,121 @@ func ConvertToRawOriginConfig(c OriginRequestConfig) config.OriginRequestConfig
 	if c.ProxyAddress != defaultProxyAddress {
 		proxyAddress = &c.ProxyAddress
 	}
+	if c.TCPIdleTimeout.Duration != 0 {
+		tcpIdleTimeout = &c.TCPIdleTimeout
+	}
+	if c.ResponseHeaderTimeout.Duration != 0 {
+		responseHeaderTimeout = &c.ResponseHeaderTimeout
+	}
+	if c.MaxRequestBodySize != 0 {
+		maxRequestBodySize = &c.MaxRequestBodySize
+	}
+	if !isEmptyHeadersConfig(c.RequestHeaders) {
+		requestHeaders = &c.RequestHeaders
+	}
+	if !isEmptyHeadersConfig(c.ResponseHeaders) {
+		responseHeaders = &c.ResponseHeaders
+	}
+	if c.CloudflareHeaders.Strip || len(c.CloudflareHeaders.Keep) > 0 || len(c.CloudflareHeaders.Rename) > 0 {
+		cloudflareHeaders = &c.CloudflareHeaders
+	}
+	if c.CircuitBreaker.FailureThreshold != 0 {
+		circuitBreaker = &c.CircuitBreaker
+	}
+	if c.Retry.MaxAttempts != 0 {
+		retry = &c.Retry
+	}
+	if c.ConcurrencyLimit.MaxRequests != 0 {
+		concurrencyLimit = &c.ConcurrencyLimit
+	}
+	if c.RateLimit.RequestsPerSecond != 0 {
+		rateLimit = &c.RateLimit
+	}
+	if !isEmptyFilterConfig(c.Filter) {
+		filter = &c.Filter
+	}
+	if c.ErrorPage != (config.ErrorPageConfig{}) {
+		errorPage = &c.ErrorPage
+	}
+	if c.FileServer.ListDirectories || len(c.FileServer.IndexFiles) > 0 || c.FileServer.CacheControl != "" ||
+		c.FileServer.DisableRanges || c.FileServer.ServeDotFiles {
+		fileServer = &c.FileServer
+	}
+	if c.StatusResponse.Body != "" || c.StatusResponse.File != "" || len(c.StatusResponse.Headers) > 0 {
+		statusResponse = &c.StatusResponse
+	}
+	if c.Cache.TTL.Duration != 0 {
+		cache = &c.Cache
+	}
+	if c.BandwidthLimit != (config.BandwidthLimitConfig{}) {
+		bandwidthLimit = &c.BandwidthLimit
+	}
+	if c.FlushInterval.Duration != 0 {
+		flushInterval = &c.FlushInterval
+	}
+	if c.ResponseBuffer.MaxSize != 0 {
+		responseBuffer = &c.ResponseBuffer
+	}
 	if c.Access.Required {
 		access = &c.Access
 	}
 
 	return config.OriginRequestConfig{
-		ConnectTimeout:         connectTimeout,
-		TLSTimeout:             tlsTimeout,
-		TCPKeepAlive:           tcpKeepAlive,
-		NoHappyEyeballs:        defaultBoolToNil(c.NoHappyEyeballs),
-		KeepAliveConnections:   keepAliveConnections,
-		KeepAliveTimeout:       keepAliveTimeout,
-		HTTPHostHeader:         emptyStringToNil(c.HTTPHostHeader),
-		OriginServerName:       emptyStringToNil(c.OriginServerName),
-		CAPool:                 emptyStringToNil(c.CAPool),
-		NoTLSVerify:            defaultBoolToNil(c.NoTLSVerify),
-		DisableChunkedEncoding: defaultBoolToNil(c.DisableChunkedEncoding),
-		BastionMode:            defaultBoolToNil(c.BastionMode),
-		ProxyAddress:           proxyAddress,
-		ProxyPort:              zeroUIntToNil(c.ProxyPort),
-		ProxyType:              emptyStringToNil(c.ProxyType),
-		IPRules:                convertToRawIPRules(c.IPRules),
-		Http2Origin:            defaultBoolToNil(c.Http2Origin),
-		Access:                 access,
-	}
+		ConnectTimeout:           connectTimeout,
+		TLSTimeout:               tlsTimeout,
+		TCPKeepAlive:             tcpKeepAlive,
+		NoHappyEyeballs:          defaultBoolToNil(c.NoHappyEyeballs),
+		KeepAliveConnections:     keepAliveConnections,
+		KeepAliveTimeout:         keepAliveTimeout,
+		HTTPHostHeader:           emptyStringToNil(c.HTTPHostHeader),
+		OriginServerName:         emptyStringToNil(c.OriginServerName),
+		CAPool:                   emptyStringToNil(c.CAPool),
+		ClientCert:               emptyStringToNil(c.ClientCert),
+		ClientKey:                emptyStringToNil(c.ClientKey),
+		NoTLSVerify:              defaultBoolToNil(c.NoTLSVerify),
+		DisableChunkedEncoding:   defaultBoolToNil(c.DisableChunkedEncoding),
+		BastionMode:              defaultBoolToNil(c.BastionMode),
+		ProxyAddress:             proxyAddress,
+		ProxyPort:                zeroUIntToNil(c.ProxyPort),
+		ProxyType:                emptyStringToNil(c.ProxyType),
+		IPRules:                  convertToRawIPRules(c.IPRules),
+		Http2Origin:              defaultBoolToNil(c.Http2Origin),
+		TCPIdleTimeout:           tcpIdleTimeout,
+		ResponseHeaderTimeout:    responseHeaderTimeout,
+		MaxRequestBodySize:       maxRequestBodySize,
+		ProxyProtocol:            emptyStringToNil(c.ProxyProtocol),
+		StripPrefix:              emptyStringToNil(c.StripPrefix),
+		RewritePath:              emptyStringToNil(c.RewritePath),
+		RequestHeaders:           requestHeaders,
+		ResponseHeaders:          responseHeaders,
+		CloudflareHeaders:        cloudflareHeaders,
+		CircuitBreaker:           circuitBreaker,
+		Retry:                    retry,
+		ConcurrencyLimit:         concurrencyLimit,
+		RateLimit:                rateLimit,
+		Filter:                   filter,
+		ErrorPage:                errorPage,
+		AllowIPs:                 c.AllowIPs,
+		DenyIPs:                  c.DenyIPs,
+		FileServer:               fileServer,
+		StatusResponse:           statusResponse,
+		Cache:                    cache,
+		BandwidthLimit:           bandwidthLimit,
+		DisableResponseBuffering: defaultBoolToNil(c.DisableResponseBuffering),
+		FlushInterval:            flushInterval,
+		ResponseBuffer:           responseBuffer,
+		DisableAccessLog:         defaultBoolToNil(c.DisableAccessLog),
+		Access:                   access,
+	}
+}
+
+func isEmptyHeadersConfig(c config.HeadersConfig) bool {
+	return len(c.Add) == 0 && len(c.Set) == 0 && len(c.Remove) == 0
+}
+
+func isEmptyFilterConfig(c config.FilterConfig) bool {
+	return len(c.DenyMethods) == 0 && len(c.DenyPaths) == 0 && len(c.DenyHeaders) == 0 &&
+		len(c.DenyUserAgents) == 0 && c.ResponseStatus == 0
 }
 
 func convertToRawIPRules(ipRules []ipaccess.Rule) []config.IngressIPRule {
@@ -0,0 +1,119 @@
+package ingress
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	OriginErrorConnectionRefused = "connection_refused"
+	OriginErrorTimeout           = "timeout"
+	OriginErrorTLS               = "tls"
+	OriginErrorUnreachable       = "unreachable"
+
+	// OriginErrorHeader tells clients why the origin couldn't be reached in responses with an error page
+	OriginErrorHeader = "Cloudflared-Origin-Error"
+
+	errorCodeVariable       = "error_code"
+	defaultErrorContentType = "text/html; charset=utf-8"
+)
+
+// ErrorPage is the response served rather than a generic 502 when the origin of a rule can't be reached.
+type ErrorPage struct {
+	body        string
+	contentType string
+}
+
+func newErrorPage(cfg config.ErrorPageConfig) (*ErrorPage, error) {
+	page := &ErrorPage{
+		body:        cfg.Body,
+		contentType: cfg.ContentType,
+	}
+	if page.body == "" && cfg.File != "" {
+		body, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read error page")
+		}
+		page.body = string(body)
+	}
+	if page.contentType == "" {
+		page.contentType = defaultErrorContentType
+	}
+
+	var err error
+	os.Expand(page.body, func(variable string) string {
+		if _, ok := requestHeaderVariables[variable]; !ok && variable != errorCodeVariable && err == nil {
+			err = fmt.Errorf("error page refers to unknown variable %s", variable)
+		}
+		return ""
+	})
+	return page, err
+}
+
+// Response returns the status, headers and body of the error page served for req, whose origin couldn't be reached
+// because of err.
+func (p *ErrorPage) Response(req *http.Request, err error) (int, http.Header, []byte) {
+	code := OriginErrorCode(err)
+	body := []byte(os.Expand(p.body, func(variable string) string {
+		if variable == errorCodeVariable {
+			return code
+		}
+		if value, ok := requestHeaderVariables[variable]; ok {
+			return value(req)
+		}
+		return ""
+	}))
+	status := http.StatusBadGateway
+	if code == OriginErrorTimeout {
+		status = http.StatusGatewayTimeout
+	}
+	header := http.Header{
+		"Content-Type":    []string{p.contentType},
+		"Content-Length":  []string{strconv.Itoa(len(body))},
+		"Cache-Control":   []string{"no-store"},
+		OriginErrorHeader: []string{code},
+	}
+	return status, header, body
+}
+
+// OriginErrorCode returns why a request couldn't reach the origin because of err.
+func OriginErrorCode(err error) string {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return OriginErrorConnectionRefused
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return OriginErrorTimeout
+	}
+	if isTLSError(err) {
+		return OriginErrorTLS
+	}
+	return OriginErrorUnreachable
+}
+
+func isTLSError(err error) bool {
+	var (
+		recordHeaderErr tls.RecordHeaderError
+		unknownAuthErr  x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		certInvalidErr  x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordHeaderErr) || errors.As(err, &unknownAuthErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &certInvalidErr) {
+		return true
+	}
+	// TLS alerts sent by the origin aren't exported error types
+	return strings.Contains(err.Error(), "tls: ")
+}
@@ -0,0 +1,78 @@
+package ingress
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestOriginErrorCode(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	_, refusedErr := net.Dial("tcp", closedAddr)
+	require.Error(t, refusedErr)
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{err: errors.Wrap(refusedErr, "dial"), expected: OriginErrorConnectionRefused},
+		{err: fmt.Errorf("round trip: %w", context.DeadlineExceeded), expected: OriginErrorTimeout},
+		{err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, expected: OriginErrorTimeout},
+		{err: errors.Wrap(x509.UnknownAuthorityError{}, "handshake"), expected: OriginErrorTLS},
+		{err: errors.New("remote error: tls: handshake failure"), expected: OriginErrorTLS},
+		{err: errors.New("no such host"), expected: OriginErrorUnreachable},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, OriginErrorCode(test.err), test.err.Error())
+	}
+}
+
+func TestErrorPageResponse(t *testing.T) {
+	page, err := newErrorPage(config.ErrorPageConfig{
+		Body:        `{"error":"${error_code}","ray":"${cf_ray}"}`,
+		ContentType: "application/json",
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Cf-Ray", "123-LHR")
+
+	status, header, body := page.Response(req, context.DeadlineExceeded)
+	assert.Equal(t, http.StatusGatewayTimeout, status)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, OriginErrorTimeout, header.Get(OriginErrorHeader))
+	assert.Equal(t, `{"error":"timeout","ray":"123-LHR"}`, string(body))
+
+	status, header, _ = page.Response(req, errors.New("no such host"))
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.Equal(t, OriginErrorUnreachable, header.Get(OriginErrorHeader))
+}
+
+func TestNewErrorPage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "error.html")
+	require.NoError(t, os.WriteFile(file, []byte("<p>${error_code}</p>"), 0o600))
+
+	page, err := newErrorPage(config.ErrorPageConfig{File: file})
+	require.NoError(t, err)
+	assert.Equal(t, "<p>${error_code}</p>", page.body)
+	assert.Equal(t, defaultErrorContentType, page.contentType)
+
+	_, err = newErrorPage(config.ErrorPageConfig{File: filepath.Join(t.TempDir(), "missing.html")})
+	assert.Error(t, err)
+	_, err = newErrorPage(config.ErrorPageConfig{Body: "${unknown}"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const ServiceFileServerPrefix = "fileserver:"
+
+var defaultIndexFiles = []string{"index.html"}
+
+// fileServer is an OriginService serving the files of a directory, so that it can be published without running
+// a web server.
+type fileServer struct {
+	root string
+	// realRoot is the absolute path of root with its symlinks resolved, which the files served must be in
+	realRoot string
+	cfg      config.FileServerConfig
+}
+
+func (o *fileServer) String() string {
+	return ServiceFileServerPrefix + o.root
+}
+
+func (o *fileServer) start(log *zerolog.Logger, _ <-chan struct{}, cfg OriginRequestConfig) error {
+	info, err := os.Stat(o.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s isn't a directory", o.root)
+	}
+	absRoot, err := filepath.Abs(o.root)
+	if err != nil {
+		return err
+	}
+	if o.realRoot, err = filepath.EvalSymlinks(absRoot); err != nil {
+		return err
+	}
+	o.cfg = cfg.FileServer
+	if len(o.cfg.IndexFiles) == 0 {
+		o.cfg.IndexFiles = defaultIndexFiles
+	}
+	return nil
+}
+
+func (o *fileServer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+func (o *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	file, err := o.open(name)
+	if err != nil {
+		serveFileError(w, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		serveFileError(w, err)
+		return
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// Relative links of the index file and the listing are resolved from the directory. The redirect is
+			// relative too, since the path the client requested can differ once stripPrefix is applied.
+			redirectURL := url.URL{Path: path.Base(name) + "/", RawQuery: r.URL.RawQuery}
+			w.Header().Set("Location", redirectURL.String())
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+		for _, index := range o.cfg.IndexFiles {
+			indexFile, err := o.open(path.Join(name, index))
+			if err != nil {
+				continue
+			}
+			defer indexFile.Close()
+			indexInfo, err := indexFile.Stat()
+			if err != nil || indexInfo.IsDir() {
+				continue
+			}
+			o.serveFile(w, r, indexFile, indexInfo)
+			return
+		}
+		if !o.cfg.ListDirectories {
+			http.Error(w, "404 page not found", http.StatusNotFound)
+			return
+		}
+		o.serveListing(w, r, file)
+		return
+	}
+	o.serveFile(w, r, file, info)
+}
+
+// open opens the file of the cleaned URL path name. Dot files aren't served unless enabled, and neither are files
+// that symlinks lead out of the root directory to, as if they didn't exist.
+func (o *fileServer) open(name string) (*os.File, error) {
+	if !o.cfg.ServeDotFiles {
+		for _, segment := range strings.Split(name, "/") {
+			if strings.HasPrefix(segment, ".") {
+				return nil, fs.ErrNotExist
+			}
+		}
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(o.realRoot, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(o.realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fs.ErrNotExist
+	}
+	return os.Open(realPath)
+}
+
+func (o *fileServer) serveFile(w http.ResponseWriter, r *http.Request, file http.File, info fs.FileInfo) {
+	if o.cfg.CacheControl != "" {
+		w.Header().Set("Cache-Control", o.cfg.CacheControl)
+	}
+	if o.cfg.DisableRanges {
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+		w = &noRangesResponseWriter{ResponseWriter: w}
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+func (o *fileServer) serveListing(w http.ResponseWriter, r *http.Request, dir http.File) {
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		serveFileError(w, err)
+		return
+	}
+	if !o.cfg.ServeDotFiles {
+		visible := entries[:0]
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), ".") {
+				visible = append(visible, entry)
+			}
+		}
+		entries = visible
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	if o.cfg.CacheControl != "" {
+		w.Header().Set("Cache-Control", o.cfg.CacheControl)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><title>%[1]s</title></head>\n<body>\n<h1>%[1]s</h1>\n<pre>\n",
+		html.EscapeString(r.URL.Path))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		link := url.URL{Path: name}
+		_, _ = fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(name))
+	}
+	_, _ = fmt.Fprint(w, "</pre>\n</body>\n</html>\n")
+}
+
+func serveFileError(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	case os.IsPermission(err):
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// noRangesResponseWriter doesn't advertise range requests, which http.ServeContent always does.
+type noRangesResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *noRangesResponseWriter) WriteHeader(status int) {
+	w.Header().Del("Accept-Ranges")
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,168 @@
+package ingress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func newTestFileServer(t *testing.T, cfg config.FileServerConfig) *fileServer {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "index.html"), []byte("<h1>home</h1>"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "data.txt"), []byte("0123456789"), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "docs"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "a <b>.txt"), []byte("a"), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "docs", "sub"), 0o700))
+
+	server := &fileServer{root: root}
+	originCfg := originRequestFromConfig(config.OriginRequestConfig{FileServer: &cfg})
+	require.NoError(t, server.start(testLogger, make(chan struct{}), originCfg))
+	return server
+}
+
+func serveFile(server *fileServer, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://example.com"+path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+func TestFileServer(t *testing.T) {
+	server := newTestFileServer(t, config.FileServerConfig{CacheControl: "public, max-age=60"})
+
+	w := serveFile(server, http.MethodGet, "/data.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+
+	w = serveFile(server, http.MethodGet, "/data.txt", http.Header{"Range": []string{"bytes=2-4"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "234", w.Body.String())
+
+	w = serveFile(server, http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<h1>home</h1>", w.Body.String())
+
+	w = serveFile(server, http.MethodGet, "/docs", nil)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "docs/", w.Header().Get("Location"))
+
+	// Listing directories is disabled by default
+	w = serveFile(server, http.MethodGet, "/docs/", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveFile(server, http.MethodGet, "/missing.txt", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveFile(server, http.MethodGet, "/../../etc/passwd", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveFile(server, http.MethodPost, "/data.txt", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestFileServerOptions(t *testing.T) {
+	server := newTestFileServer(t, config.FileServerConfig{
+		ListDirectories: true,
+		IndexFiles:      []string{"data.txt"},
+		DisableRanges:   true,
+	})
+
+	w := serveFile(server, http.MethodGet, "/", nil)
+	assert.Equal(t, "0123456789", w.Body.String())
+
+	w = serveFile(server, http.MethodGet, "/data.txt", http.Header{"Range": []string{"bytes=2-4"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Empty(t, w.Header().Get("Accept-Ranges"))
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+
+	w = serveFile(server, http.MethodGet, "/docs/", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<a href="a%20%3Cb%3E.txt">a &lt;b&gt;.txt</a>`)
+	assert.Contains(t, w.Body.String(), `<a href="sub/">sub/</a>`)
+}
+
+// Dot files often hold secrets, such as .git or .env, so they're neither served nor listed unless enabled
+func TestFileServerDotFiles(t *testing.T) {
+	for _, serveDotFiles := range []bool{false, true} {
+		server := newTestFileServer(t, config.FileServerConfig{ListDirectories: true, ServeDotFiles: serveDotFiles})
+		require.NoError(t, os.WriteFile(filepath.Join(server.root, ".env"), []byte("SECRET=1"), 0o600))
+		require.NoError(t, os.Mkdir(filepath.Join(server.root, ".git"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(server.root, ".git", "config"), []byte("[core]"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(server.root, "docs", ".htpasswd"), []byte("user:hash"), 0o600))
+
+		expectedStatus := http.StatusNotFound
+		if serveDotFiles {
+			expectedStatus = http.StatusOK
+		}
+		for _, path := range []string{"/.env", "/.git/config", "/docs/.htpasswd", "/docs/sub/../.htpasswd"} {
+			w := serveFile(server, http.MethodGet, path, nil)
+			assert.Equal(t, expectedStatus, w.Code, path)
+		}
+
+		w := serveFile(server, http.MethodGet, "/docs/", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, serveDotFiles, strings.Contains(w.Body.String(), ".htpasswd"))
+		assert.Contains(t, w.Body.String(), "sub/")
+	}
+}
+
+// Symlinks are followed within the root directory, but not out of it
+func TestFileServerSymlinks(t *testing.T) {
+	server := newTestFileServer(t, config.FileServerConfig{})
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o600))
+
+	require.NoError(t, os.Symlink(filepath.Join(server.root, "data.txt"), filepath.Join(server.root, "inside.txt")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(server.root, "outside.txt")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(server.root, "outside")))
+	require.NoError(t, os.Symlink("../../data.txt", filepath.Join(server.root, "docs", "sub", "relative.txt")))
+
+	w := serveFile(server, http.MethodGet, "/inside.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+
+	w = serveFile(server, http.MethodGet, "/docs/sub/relative.txt", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for _, path := range []string{"/outside.txt", "/outside/secret.txt", "/outside/"} {
+		w = serveFile(server, http.MethodGet, path, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "secret")
+	}
+}
+
+func TestParseFileServer(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - service: fileserver:/var/www
+   originRequest:
+     fileServer:
+       listDirectories: true
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "fileserver:/var/www", ing.Rules[0].Service.String())
+	assert.True(t, ing.Rules[0].Config.FileServer.ListDirectories)
+
+	_, err = ParseIngress(MustReadIngress(`
+ingress:
+ - service: "fileserver:"
+`))
+	assert.Error(t, err)
+
+	server := &fileServer{root: filepath.Join(t.TempDir(), "missing")}
+	assert.Error(t, server.start(testLogger, make(chan struct{}), OriginRequestConfig{}))
+}
@@ -0,0 +1,106 @@
+package ingress
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+// requestHeaderVariables are the variables the values of requestHeaders and responseHeaders can refer to.
+var requestHeaderVariables = map[string]func(r *http.Request) string{
+	"host":      func(r *http.Request) string { return r.Host },
+	"client_ip": func(r *http.Request) string { return r.Header.Get("Cf-Connecting-Ip") },
+	"cf_ray":    func(r *http.Request) string { return r.Header.Get("Cf-Ray") },
+	"path":      func(r *http.Request) string { return r.URL.Path },
+}
+
+func validateHeaders(cfg config.HeadersConfig) error {
+	for _, values := range []map[string]string{cfg.Add, cfg.Set} {
+		for name, value := range values {
+			var err error
+			os.Expand(value, func(variable string) string {
+				if _, ok := requestHeaderVariables[variable]; !ok && err == nil {
+					err = fmt.Errorf("header %s refers to unknown variable %s", name, variable)
+				}
+				return ""
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RewriteRequestHeaders adds, sets and removes the headers of req according to the RequestHeaders of the rule
+// config, then strips and renames the Cloudflare headers according to its CloudflareHeaders.
+func (r *Rule) RewriteRequestHeaders(req *http.Request) {
+	rewriteHeaders(r.Config.RequestHeaders, req.Header, req)
+	rewriteCloudflareHeaders(r.Config.CloudflareHeaders, req.Header)
+}
+
+// RewriteResponseHeaders adds, sets and removes the headers of the response to req according to the
+// ResponseHeaders of the rule config.
+func (r *Rule) RewriteResponseHeaders(req *http.Request, header http.Header) {
+	rewriteHeaders(r.Config.ResponseHeaders, header, req)
+}
+
+// rewriteHeaders changes header according to cfg. The variables in the header values are expanded from req before
+// any header is changed.
+func rewriteHeaders(cfg config.HeadersConfig, header http.Header, req *http.Request) {
+	expand := func(value string) string {
+		return os.Expand(value, func(variable string) string {
+			if value, ok := requestHeaderVariables[variable]; ok {
+				return value(req)
+			}
+			return ""
+		})
+	}
+	set := make(map[string]string, len(cfg.Set))
+	for name, value := range cfg.Set {
+		set[name] = expand(value)
+	}
+	add := make(map[string]string, len(cfg.Add))
+	for name, value := range cfg.Add {
+		add[name] = expand(value)
+	}
+
+	for _, name := range cfg.Remove {
+		header.Del(name)
+	}
+	for name, value := range set {
+		header.Set(name, value)
+	}
+	for name, value := range add {
+		header.Add(name, value)
+	}
+}
+
+// isCloudflareHeader returns true if name, in canonical form, is one of the headers Cloudflare adds to requests.
+func isCloudflareHeader(name string) bool {
+	return strings.HasPrefix(name, "Cf-") || name == "Cdn-Loop"
+}
+
+func rewriteCloudflareHeaders(cfg config.CloudflareHeadersConfig, header http.Header) {
+	for from, to := range cfg.Rename {
+		if values := header.Values(from); len(values) > 0 {
+			header.Del(from)
+			header[http.CanonicalHeaderKey(to)] = values
+		}
+	}
+	if !cfg.Strip {
+		return
+	}
+	keep := make(map[string]struct{}, len(cfg.Keep))
+	for _, name := range cfg.Keep {
+		keep[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+	for name := range header {
+		if _, ok := keep[name]; !ok && isCloudflareHeader(name) {
+			header.Del(name)
+		}
+	}
+}
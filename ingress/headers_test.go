@@ -0,0 +1,121 @@
+package ingress
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestRewriteRequestHeaders(t *testing.T) {
+	rule := Rule{
+		Config: OriginRequestConfig{
+			RequestHeaders: config.HeadersConfig{
+				Add: map[string]string{
+					"X-Forwarded-For": "${client_ip}",
+				},
+				Set: map[string]string{
+					"X-Real-Ip":    "${client_ip}",
+					"X-Origin-Url": "https://${host}${path}",
+					"Cf-Ray":       "ray-${cf_ray}",
+				},
+				Remove: []string{"Cf-Connecting-Ip", "cookie"},
+			},
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://app.example.com/users", nil)
+	require.NoError(t, err)
+	req.Header.Set("Cf-Connecting-Ip", "192.0.2.1")
+	req.Header.Set("Cf-Ray", "123abc")
+	req.Header.Set("Cookie", "session=1")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	rule.RewriteRequestHeaders(req)
+	assert.Equal(t, http.Header{
+		"X-Forwarded-For": {"198.51.100.1", "192.0.2.1"},
+		"X-Real-Ip":       {"192.0.2.1"},
+		"X-Origin-Url":    {"https://app.example.com/users"},
+		"Cf-Ray":          {"ray-123abc"},
+	}, req.Header)
+}
+
+func TestValidateHeaders(t *testing.T) {
+	assert.NoError(t, validateHeaders(config.HeadersConfig{
+		Set: map[string]string{"X-Client": "${client_ip} $host"},
+	}))
+	assert.Error(t, validateHeaders(config.HeadersConfig{
+		Add: map[string]string{"X-Client": "${client}"},
+	}))
+}
+
+func TestRewriteResponseHeaders(t *testing.T) {
+	rule := Rule{
+		Config: OriginRequestConfig{
+			ResponseHeaders: config.HeadersConfig{
+				Set: map[string]string{
+					"Strict-Transport-Security": "max-age=31536000",
+					"Content-Location":          "${path}",
+				},
+				Remove: []string{"Server"},
+			},
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://app.example.com/users", nil)
+	require.NoError(t, err)
+	header := http.Header{
+		"Server":       {"nginx"},
+		"Content-Type": {"text/plain"},
+	}
+
+	rule.RewriteResponseHeaders(req, header)
+	assert.Equal(t, http.Header{
+		"Content-Type":              {"text/plain"},
+		"Content-Location":          {"/users"},
+		"Strict-Transport-Security": {"max-age=31536000"},
+	}, header)
+	// The request headers are left alone
+	assert.Empty(t, req.Header)
+}
+
+func TestRewriteCloudflareHeaders(t *testing.T) {
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "http://app.example.com", nil)
+		require.NoError(t, err)
+		req.Header.Set("Cf-Connecting-Ip", "192.0.2.1")
+		req.Header.Set("Cf-Ray", "123abc")
+		req.Header.Set("Cf-Ipcountry", "US")
+		req.Header.Set("Cdn-Loop", "cloudflare")
+		req.Header.Set("Accept", "*/*")
+		return req
+	}
+
+	req := newRequest()
+	rule := Rule{Config: OriginRequestConfig{
+		CloudflareHeaders: config.CloudflareHeadersConfig{
+			Strip:  true,
+			Keep:   []string{"cf-ray"},
+			Rename: map[string]string{"Cf-Connecting-Ip": "X-Real-Ip"},
+		},
+	}}
+	rule.RewriteRequestHeaders(req)
+	assert.Equal(t, http.Header{
+		"Accept":    {"*/*"},
+		"Cf-Ray":    {"123abc"},
+		"X-Real-Ip": {"192.0.2.1"},
+	}, req.Header)
+
+	// The request headers can still refer to the stripped headers
+	req = newRequest()
+	rule = Rule{Config: OriginRequestConfig{
+		RequestHeaders:    config.HeadersConfig{Set: map[string]string{"X-Forwarded-For": "${client_ip}"}},
+		CloudflareHeaders: config.CloudflareHeadersConfig{Strip: true},
+	}}
+	rule.RewriteRequestHeaders(req)
+	assert.Equal(t, http.Header{
+		"Accept":          {"*/*"},
+		"X-Forwarded-For": {"192.0.2.1"},
+	}, req.Header)
+}
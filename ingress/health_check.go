@@ -0,0 +1,177 @@
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	defaultHealthCheckPath               = "/"
+	defaultHealthCheckInterval           = 10 * time.Second
+	defaultHealthCheckTimeout            = 5 * time.Second
+	defaultHealthCheckHealthyThreshold   = 2
+	defaultHealthCheckUnhealthyThreshold = 3
+	healthCheckUserAgent                 = "cloudflared-health-check"
+	logFieldOrigin                       = "originService"
+)
+
+// healthCheck periodically requests a path from the origins of a LoadBalancedService to find out which ones are
+// healthy.
+type healthCheck struct {
+	raw config.HealthCheckConfig
+	// rule is the index of the ingress rule of the origins, which tells apart origins with the same URL
+	rule               int
+	path               string
+	interval           time.Duration
+	timeout            time.Duration
+	healthyThreshold   uint
+	unhealthyThreshold uint
+}
+
+func newHealthCheck(raw config.HealthCheckConfig, rule int) (*healthCheck, error) {
+	hc := &healthCheck{
+		raw:                raw,
+		rule:               rule,
+		path:               defaultHealthCheckPath,
+		interval:           defaultHealthCheckInterval,
+		timeout:            defaultHealthCheckTimeout,
+		healthyThreshold:   defaultHealthCheckHealthyThreshold,
+		unhealthyThreshold: defaultHealthCheckUnhealthyThreshold,
+	}
+	if raw.Path != "" {
+		if !strings.HasPrefix(raw.Path, "/") {
+			return nil, fmt.Errorf("health check path %s must start with /", raw.Path)
+		}
+		hc.path = raw.Path
+	}
+	if raw.Interval != nil {
+		if raw.Interval.Duration <= 0 {
+			return nil, fmt.Errorf("health check interval must be positive")
+		}
+		hc.interval = raw.Interval.Duration
+	}
+	if raw.Timeout != nil {
+		if raw.Timeout.Duration <= 0 {
+			return nil, fmt.Errorf("health check timeout must be positive")
+		}
+		hc.timeout = raw.Timeout.Duration
+	}
+	if raw.HealthyThreshold != nil {
+		if *raw.HealthyThreshold == 0 {
+			return nil, fmt.Errorf("health check healthyThreshold must be positive")
+		}
+		hc.healthyThreshold = *raw.HealthyThreshold
+	}
+	if raw.UnhealthyThreshold != nil {
+		if *raw.UnhealthyThreshold == 0 {
+			return nil, fmt.Errorf("health check unhealthyThreshold must be positive")
+		}
+		hc.unhealthyThreshold = *raw.UnhealthyThreshold
+	}
+	return hc, nil
+}
+
+// run checks origin every interval until ctx is done.
+func (hc *healthCheck) run(ctx context.Context, origin *balancedOrigin, log *zerolog.Logger) {
+	registerOriginHealth(hc.rule, origin)
+	defer unregisterOriginHealth(hc.rule, origin)
+
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+	for {
+		hc.observe(origin, hc.check(ctx, origin), log)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (hc *healthCheck) check(ctx context.Context, origin *balancedOrigin) error {
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+origin.url.Host+hc.path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", healthCheckUserAgent)
+	resp, err := origin.httpService.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	// Drain a bit of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("health check responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// observe updates the health of origin after a check that failed with err, or succeeded if err is nil.
+func (hc *healthCheck) observe(origin *balancedOrigin, err error, log *zerolog.Logger) {
+	if err == nil {
+		origin.failedChecks = 0
+		origin.successfulChecks++
+		if !origin.healthy.Load() && origin.successfulChecks >= hc.healthyThreshold {
+			origin.healthy.Store(true)
+			log.Info().Str(logFieldOrigin, origin.String()).Msg("Origin is healthy again")
+		}
+	} else {
+		origin.successfulChecks = 0
+		origin.failedChecks++
+		if origin.healthy.Load() && origin.failedChecks >= hc.unhealthyThreshold {
+			origin.healthy.Store(false)
+			log.Warn().Err(err).Str(logFieldOrigin, origin.String()).Msg("Origin is unhealthy, requests go to the other origins of its rule")
+		}
+	}
+	if origin.healthy.Load() {
+		originHealthy.WithLabelValues(strconv.Itoa(hc.rule), origin.String()).Set(1)
+	} else {
+		originHealthy.WithLabelValues(strconv.Itoa(hc.rule), origin.String()).Set(0)
+	}
+}
+
+// healthCheckedOrigins are the origins whose health is currently checked, with the index of their ingress rule.
+var healthCheckedOrigins = struct {
+	sync.Mutex
+	origins map[*balancedOrigin]int
+}{origins: make(map[*balancedOrigin]int)}
+
+func registerOriginHealth(rule int, origin *balancedOrigin) {
+	healthCheckedOrigins.Lock()
+	defer healthCheckedOrigins.Unlock()
+	healthCheckedOrigins.origins[origin] = rule
+}
+
+func unregisterOriginHealth(rule int, origin *balancedOrigin) {
+	healthCheckedOrigins.Lock()
+	defer healthCheckedOrigins.Unlock()
+	delete(healthCheckedOrigins.origins, origin)
+	originHealthy.DeleteLabelValues(strconv.Itoa(rule), origin.String())
+}
+
+// OriginHealth returns whether each health checked origin is healthy, by the index of its ingress rule and origin.
+func OriginHealth() map[int]map[string]bool {
+	healthCheckedOrigins.Lock()
+	defer healthCheckedOrigins.Unlock()
+	health := make(map[int]map[string]bool)
+	for origin, rule := range healthCheckedOrigins.origins {
+		if health[rule] == nil {
+			health[rule] = make(map[string]bool)
+		}
+		health[rule][origin.String()] = origin.healthy.Load()
+	}
+	return health
+}
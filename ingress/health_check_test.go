@@ -0,0 +1,123 @@
+package ingress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestNewHealthCheck(t *testing.T) {
+	hc, err := newHealthCheck(config.HealthCheckConfig{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, defaultHealthCheckPath, hc.path)
+	assert.Equal(t, defaultHealthCheckInterval, hc.interval)
+	assert.Equal(t, defaultHealthCheckTimeout, hc.timeout)
+	assert.Equal(t, uint(defaultHealthCheckHealthyThreshold), hc.healthyThreshold)
+	assert.Equal(t, uint(defaultHealthCheckUnhealthyThreshold), hc.unhealthyThreshold)
+
+	zero := uint(0)
+	for _, raw := range []config.HealthCheckConfig{
+		{Path: "health"},
+		{Interval: &config.CustomDuration{}},
+		{Timeout: &config.CustomDuration{Duration: -time.Second}},
+		{HealthyThreshold: &zero},
+		{UnhealthyThreshold: &zero},
+	} {
+		_, err := newHealthCheck(raw, 0)
+		assert.Error(t, err)
+	}
+}
+
+func TestHealthCheckFailover(t *testing.T) {
+	var primaryDown atomic.Bool
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" && primaryDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secondary"))
+	}))
+	defer secondary.Close()
+
+	threshold := uint(1)
+	hc, err := newHealthCheck(config.HealthCheckConfig{
+		Path:               "/health",
+		Interval:           &config.CustomDuration{Duration: 10 * time.Millisecond},
+		HealthyThreshold:   &threshold,
+		UnhealthyThreshold: &threshold,
+	}, 0)
+	require.NoError(t, err)
+	balanced, err := newLoadBalancedService([]*httpService{
+		{url: MustParseURL(t, primary.URL)},
+		{url: MustParseURL(t, secondary.URL)},
+	}, LoadBalancingFailover, hc, nil)
+	require.NoError(t, err)
+	shutdownC := make(chan struct{})
+	defer close(shutdownC)
+	require.NoError(t, balanced.start(testLogger, shutdownC, originRequestFromConfig(config.OriginRequestConfig{})))
+
+	assert.Equal(t, "primary", readOrigin(t, balancedRoundTrip(t, balanced)))
+	require.Eventually(t, func() bool {
+		return OriginHealth()[0][primary.URL] && OriginHealth()[0][secondary.URL]
+	}, time.Second, 10*time.Millisecond)
+
+	primaryDown.Store(true)
+	require.Eventually(t, func() bool { return !OriginHealth()[0][primary.URL] }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "secondary", readOrigin(t, balancedRoundTrip(t, balanced)))
+
+	primaryDown.Store(false)
+	require.Eventually(t, func() bool { return OriginHealth()[0][primary.URL] }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "primary", readOrigin(t, balancedRoundTrip(t, balanced)))
+}
+
+// Rules can balance between the same origins with different health checks, whose health is kept apart
+func TestHealthCheckSameOriginOfRules(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer origin.Close()
+	shutdownC := make(chan struct{})
+	defer close(shutdownC)
+
+	threshold := uint(1)
+	for rule, path := range []string{"/down", "/up"} {
+		hc, err := newHealthCheck(config.HealthCheckConfig{
+			Path:               path,
+			Interval:           &config.CustomDuration{Duration: 10 * time.Millisecond},
+			HealthyThreshold:   &threshold,
+			UnhealthyThreshold: &threshold,
+		}, rule)
+		require.NoError(t, err)
+		balanced, err := newLoadBalancedService([]*httpService{{url: MustParseURL(t, origin.URL)}}, LoadBalancingFailover, hc, nil)
+		require.NoError(t, err)
+		require.NoError(t, balanced.start(testLogger, shutdownC, originRequestFromConfig(config.OriginRequestConfig{})))
+	}
+
+	require.Eventually(t, func() bool {
+		health := OriginHealth()
+		healthy, ok := health[0][origin.URL]
+		return ok && !healthy && health[1][origin.URL]
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(0), originHealthyValue(t, "0", origin.URL))
+	assert.Equal(t, float64(1), originHealthyValue(t, "1", origin.URL))
+}
+
+func originHealthyValue(t *testing.T, rule, origin string) float64 {
+	var metric dto.Metric
+	require.NoError(t, originHealthy.WithLabelValues(rule, origin).Write(&metric))
+	return metric.GetGauge().GetValue()
+}
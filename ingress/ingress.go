@@ -31,6 +31,9 @@ const (
 	ServiceBastion     = "bastion"
 	ServiceSocksProxy  = "socks-proxy"
 	ServiceWarpRouting = "warp-routing"
+
+	// globPathPrefix marks rule paths that are glob patterns rather than regexes
+	globPathPrefix = "glob:"
 )
 
 // FindMatchingRule returns the index of the Ingress Rule which matches the given
@@ -208,6 +211,11 @@ func (ing Ingress) StartOrigins(
 		if err := rule.Service.start(log, shutdownC, rule.Config); err != nil {
 			return errors.Wrapf(err, "Error starting local service %s", rule.Service)
 		}
+		if rule.Mirror != nil {
+			if err := rule.Mirror.start(log, shutdownC, rule.Config); err != nil {
+				return errors.Wrapf(err, "Error starting mirror %s", rule.Mirror)
+			}
+		}
 	}
 	return nil
 }
@@ -265,6 +273,18 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 			}
 			srv := newStatusCode(statusCode)
 			service = &srv
+		} else if prefix := ServiceFileServerPrefix; strings.HasPrefix(r.Service, prefix) {
+			root := strings.TrimPrefix(r.Service, prefix)
+			if root == "" {
+				return Ingress{}, fmt.Errorf("Rule #%d has a fileserver service without a directory", i+1)
+			}
+			service = &fileServer{root: root}
+		} else if prefix := ServiceRedirectPrefix; strings.HasPrefix(r.Service, prefix) {
+			redirect, err := newRedirectService(strings.TrimPrefix(r.Service, prefix))
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid redirect service", i+1)
+			}
+			service = redirect
 		} else if r.Service == HelloWorldFlag || r.Service == HelloWorldService {
 			service = new(helloWorld)
 		} else if r.Service == ServiceSocksProxy {
@@ -290,19 +310,64 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 			// leave the URL field empty for now.
 			cfg.BastionMode = true
 			service = newBastionService()
-		} else {
-			// Validate URL services
-			u, err := url.Parse(r.Service)
+		} else if len(r.Services) > 0 {
+			origins := make([]*httpService, len(r.Services))
+			for j, rawService := range r.Services {
+				u, err := parseServiceURL(rawService)
+				if err != nil {
+					return Ingress{}, err
+				}
+				if !isHTTPService(u) {
+					return Ingress{}, fmt.Errorf("%s can't be load balanced, only HTTP origins can be listed in a service", rawService)
+				}
+				origins[j] = &httpService{url: u}
+			}
+			var hc *healthCheck
+			healthCheckConfig := r.HealthCheck
+			if healthCheckConfig == nil && r.LoadBalancingPolicy == LoadBalancingFailover {
+				// Failing over requires knowing when the primary origin is down
+				healthCheckConfig = &config.HealthCheckConfig{}
+			}
+			if healthCheckConfig != nil {
+				var err error
+				if hc, err = newHealthCheck(*healthCheckConfig, i); err != nil {
+					return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid healthCheck", i+1)
+				}
+			}
+			var affinity *sessionAffinity
+			if r.SessionAffinity != nil {
+				var err error
+				if affinity, err = newSessionAffinity(*r.SessionAffinity); err != nil {
+					return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid sessionAffinity", i+1)
+				}
+			}
+			balanced, err := newLoadBalancedService(origins, r.LoadBalancingPolicy, hc, affinity)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid loadBalancingPolicy", i+1)
+			}
+			service = balanced
+		} else if r.Canary != nil {
+			primary, err := parseServiceURL(r.Service)
 			if err != nil {
 				return Ingress{}, err
 			}
-
-			if u.Scheme == "" || u.Hostname() == "" {
-				return Ingress{}, fmt.Errorf("%s is an invalid address, please make sure it has a scheme and a hostname", r.Service)
+			canary, err := parseServiceURL(r.Canary.Service)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid canary service", i+1)
 			}
-
-			if u.Path != "" {
-				return Ingress{}, fmt.Errorf("%s is an invalid address, ingress rules don't support proxying to a different path on the origin service. The path will be the same as the eyeball request's path", r.Service)
+			if !isHTTPService(primary) || !isHTTPService(canary) {
+				return Ingress{}, fmt.Errorf("Rule #%d has a canary, but only HTTP origins can have one", i+1)
+			}
+			canaryService, err := newCanaryService(&httpService{url: primary}, &httpService{url: canary}, *r.Canary)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid canary", i+1)
+			}
+			service = canaryService
+		} else {
+			// Validate URL services
+			u, err := parseServiceURL(r.Service)
+			if err != nil {
+				return Ingress{}, err
 			}
 			if isHTTPService(u) {
 				service = &httpService{url: u}
@@ -310,16 +375,80 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 				service = newTCPOverWSService(u)
 			}
 		}
+		if (r.LoadBalancingPolicy != "" || r.HealthCheck != nil || r.SessionAffinity != nil) && len(r.Services) == 0 {
+			return Ingress{}, fmt.Errorf("Rule #%d sets loadBalancingPolicy, healthCheck or sessionAffinity, but its service isn't a list of origins", i+1)
+		}
+		if _, isCanary := service.(*CanaryService); r.Canary != nil && !isCanary {
+			return Ingress{}, fmt.Errorf("Rule #%d has a canary, but its service isn't a single HTTP origin", i+1)
+		}
+
+		if err := validateProxyProtocol(cfg.ProxyProtocol); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid proxyProtocol", i+1)
+		}
+		if err := validateHeaders(cfg.RequestHeaders); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has invalid requestHeaders", i+1)
+		}
+		if err := validateHeaders(cfg.ResponseHeaders); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has invalid responseHeaders", i+1)
+		}
+
+		var mirror OriginService
+		if r.Mirror != "" {
+			u, err := parseServiceURL(r.Mirror)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid mirror", i+1)
+			}
+			if _, ok := service.(HTTPOriginProxy); !ok || !isHTTPService(u) {
+				return Ingress{}, fmt.Errorf("Rule #%d has a mirror, but only requests to HTTP origins can be mirrored to HTTP origins", i+1)
+			}
+			mirror = &httpService{url: u}
+		}
+
+		var maintenance *Maintenance
+		if r.Maintenance != nil {
+			var err error
+			if maintenance, err = newMaintenance(*r.Maintenance); err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid maintenance", i+1)
+			}
+		}
+
+		var errorPage *ErrorPage
+		if cfg.ErrorPage != (config.ErrorPageConfig{}) {
+			var err error
+			if errorPage, err = newErrorPage(cfg.ErrorPage); err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid errorPage", i+1)
+			}
+		}
 
 		var handlers []middleware.Handler
-		if access := r.OriginRequest.Access; access != nil {
-			if err := validateAccessConfiguration(access); err != nil {
+		if !isEmptyFilterConfig(cfg.Filter) {
+			filter, err := middleware.NewRequestFilter(cfg.Filter)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid filter", i+1)
+			}
+			handlers = append(handlers, filter)
+		}
+		if len(cfg.AllowIPs) > 0 || len(cfg.DenyIPs) > 0 {
+			ipFilter, err := middleware.NewIPFilter(cfg.AllowIPs, cfg.DenyIPs)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has invalid allowIPs or denyIPs", i+1)
+			}
+			handlers = append(handlers, ipFilter)
+		}
+		// The access configuration is inherited from the defaults like the rest of originRequest, so that requiring
+		// Access for all the rules doesn't depend on repeating it in each of them
+		if access := cfg.Access; access.Required {
+			if err := validateAccessConfiguration(&access); err != nil {
 				return Ingress{}, err
 			}
-			if access.Required {
-				verifier := middleware.NewJWTValidator(access.TeamName, "", access.AudTag)
-				handlers = append(handlers, verifier)
+			verifier := middleware.NewJWTValidator(access.TeamName, "", access.AudTag)
+			handlers = append(handlers, verifier)
+		}
+		if rateLimit := cfg.RateLimit; rateLimit.RequestsPerSecond != 0 {
+			if rateLimit.RequestsPerSecond < 0 {
+				return Ingress{}, fmt.Errorf("Rule #%d has a negative rateLimit.requestsPerSecond", i+1)
 			}
+			handlers = append(handlers, middleware.NewRateLimiter(rateLimit.RequestsPerSecond, rateLimit.Burst, rateLimit.PerClientIP))
 		}
 
 		if err := validateHostname(r, i, len(ingress)); err != nil {
@@ -339,7 +468,11 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 		var pathRegexp *Regexp
 		if r.Path != "" {
 			var err error
-			regex, err := regexp.Compile(r.Path)
+			path := r.Path
+			if strings.HasPrefix(path, globPathPrefix) {
+				path = globToRegexp(strings.TrimPrefix(path, globPathPrefix))
+			}
+			regex, err := regexp.Compile(path)
 			if err != nil {
 				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid regex", i+1)
 			}
@@ -350,6 +483,9 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 			Hostname:         r.Hostname,
 			punycodeHostname: punycodeHostname,
 			Service:          service,
+			Mirror:           mirror,
+			Maintenance:      maintenance,
+			ErrorPage:        errorPage,
 			Path:             pathRegexp,
 			Handlers:         handlers,
 			Config:           cfg,
@@ -358,6 +494,22 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 	return Ingress{Rules: rules, Defaults: defaults}, nil
 }
 
+func parseServiceURL(rawService string) (*url.URL, error) {
+	u, err := url.Parse(rawService)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "" || u.Hostname() == "" {
+		return nil, fmt.Errorf("%s is an invalid address, please make sure it has a scheme and a hostname", rawService)
+	}
+
+	if u.Path != "" {
+		return nil, fmt.Errorf("%s is an invalid address, ingress rules don't support proxying to a different path on the origin service. The path will be the same as the eyeball request's path", rawService)
+	}
+	return u, nil
+}
+
 func validateHostname(r config.UnvalidatedIngressRule, ruleIndex, totalRules int) error {
 	// Ensure that the hostname doesn't contain port
 	_, _, err := net.SplitHostPort(r.Hostname)
@@ -395,5 +547,9 @@ func (e errRuleShouldNotBeCatchAll) Error() string {
 }
 
 func isHTTPService(url *url.URL) bool {
-	return url.Scheme == "http" || url.Scheme == "https" || url.Scheme == "ws" || url.Scheme == "wss"
+	return url.Scheme == "http" || url.Scheme == "https" || url.Scheme == "ws" || url.Scheme == "wss" || isGRPCService(url)
+}
+
+func isGRPCService(url *url.URL) bool {
+	return url.Scheme == "grpc" || url.Scheme == "grpcs"
 }
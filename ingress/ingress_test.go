@@ -117,6 +117,21 @@ ingress:
 				},
 			},
 		},
+		{
+			name: "grpc service",
+			args: args{rawYAML: `
+ingress:
+ - hostname: "*"
+   service: grpc://localhost:50051
+`},
+			want: []Rule{
+				{
+					Hostname: "*",
+					Service:  &httpService{url: MustParseURL(t, "grpc://localhost:50051")},
+					Config:   defaultConfig,
+				},
+			},
+		},
 		{
 			name: "Hostname can be omitted",
 			args: args{rawYAML: `
@@ -246,6 +261,40 @@ ingress:
 			args: args{rawYAML: `
 ingress:
  - service: http_status:8080
+`},
+			wantErr: true,
+		},
+		{
+			name: "Negative rate limit",
+			args: args{rawYAML: `
+ingress:
+ - service: https://localhost:8000
+   originRequest:
+     rateLimit:
+       requestsPerSecond: -1
+`},
+			wantErr: true,
+		},
+		{
+			name: "Invalid allowIPs",
+			args: args{rawYAML: `
+ingress:
+ - service: https://localhost:8000
+   originRequest:
+     allowIPs:
+       - 10.0.0.0/40
+`},
+			wantErr: true,
+		},
+		{
+			name: "Invalid filter pattern",
+			args: args{rawYAML: `
+ingress:
+ - service: https://localhost:8000
+   originRequest:
+     filter:
+       denyPaths:
+         - "(wp-admin"
 `},
 			wantErr: true,
 		},
@@ -732,6 +781,14 @@ func TestIsHTTPService(t *testing.T) {
 			url:    MustParseURL(t, "wss://localhost:8000"),
 			isHTTP: true,
 		},
+		{
+			url:    MustParseURL(t, "grpc://localhost:50051"),
+			isHTTP: true,
+		},
+		{
+			url:    MustParseURL(t, "grpcs://localhost:50051"),
+			isHTTP: true,
+		},
 		{
 			url:    MustParseURL(t, "tcp://localhost:9000"),
 			isHTTP: false,
@@ -827,6 +884,28 @@ func TestParseAccessConfig(t *testing.T) {
 	}
 }
 
+func TestParseAccessConfigDefaults(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+originRequest:
+  access:
+    required: true
+    teamName: team
+    audTag:
+      - aud-tag
+ingress:
+ - hostname: internal.example.com
+   service: http://localhost:8000
+ - service: http_status:404
+   originRequest:
+     access:
+       required: false
+`))
+	require.NoError(t, err)
+	require.Len(t, ing.Rules[0].Handlers, 1)
+	assert.Equal(t, "AccessJWTValidator", ing.Rules[0].Handlers[0].Name())
+	assert.Empty(t, ing.Rules[1].Handlers)
+}
+
 func MustReadIngress(s string) *config.Configuration {
 	var conf config.Configuration
 	err := yaml.Unmarshal([]byte(s), &conf)
@@ -835,3 +914,26 @@ func MustReadIngress(s string) *config.Configuration {
 	}
 	return &conf
 }
+
+func TestParseMirror(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - service: http://localhost:8000
+   mirror: http://localhost:8001
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8001", ing.Rules[0].Mirror.String())
+
+	for _, rawYAML := range []string{`
+ingress:
+ - service: tcp://localhost:22
+   mirror: http://localhost:8001
+`, `
+ingress:
+ - service: http://localhost:8000
+   mirror: tcp://localhost:22
+`} {
+		_, err := ParseIngress(MustReadIngress(rawYAML))
+		assert.Error(t, err, rawYAML)
+	}
+}
@@ -0,0 +1,219 @@
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	LoadBalancingRoundRobin       = "round_robin"
+	LoadBalancingLeastConnections = "least_connections"
+	LoadBalancingRandom           = "random"
+	LoadBalancingFailover         = "failover"
+)
+
+// LoadBalancedService is an OriginService balancing the requests of a rule between several HTTP origins.
+type LoadBalancedService struct {
+	origins     []*balancedOrigin
+	policy      string
+	healthCheck *healthCheck
+	affinity    *sessionAffinity
+	// next is the number of origins picked so far, which rotates round robin and the ties of least connections
+	next uint64
+}
+
+type balancedOrigin struct {
+	*httpService
+	activeRequests int64
+	healthy        atomic.Bool
+	// successfulChecks and failedChecks are the consecutive health check results, only used by the health check
+	successfulChecks uint
+	failedChecks     uint
+}
+
+// newLoadBalancedService balances requests between origins according to policy. Origins are health checked with
+// healthCheck unless it's nil, and clients stick to an origin with affinity unless it's nil.
+func newLoadBalancedService(origins []*httpService, policy string, healthCheck *healthCheck, affinity *sessionAffinity) (*LoadBalancedService, error) {
+	switch policy {
+	case "":
+		policy = LoadBalancingRoundRobin
+	case LoadBalancingRoundRobin, LoadBalancingLeastConnections, LoadBalancingRandom, LoadBalancingFailover:
+	default:
+		return nil, fmt.Errorf("unknown load balancing policy %s, expected one of %s, %s, %s or %s", policy,
+			LoadBalancingRoundRobin, LoadBalancingLeastConnections, LoadBalancingRandom, LoadBalancingFailover)
+	}
+	balanced := make([]*balancedOrigin, len(origins))
+	for i, origin := range origins {
+		balanced[i] = &balancedOrigin{httpService: origin}
+		balanced[i].healthy.Store(true)
+	}
+	return &LoadBalancedService{
+		origins:     balanced,
+		policy:      policy,
+		healthCheck: healthCheck,
+		affinity:    affinity,
+	}, nil
+}
+
+// Origins returns the origins the requests are balanced between.
+func (s *LoadBalancedService) Origins() []string {
+	origins := make([]string, len(s.origins))
+	for i, origin := range s.origins {
+		origins[i] = origin.String()
+	}
+	return origins
+}
+
+// Policy returns how the requests are balanced between the origins.
+func (s *LoadBalancedService) Policy() string {
+	return s.policy
+}
+
+// HealthCheck returns the health check configuration of the origins, or nil if they aren't health checked.
+func (s *LoadBalancedService) HealthCheck() *config.HealthCheckConfig {
+	if s.healthCheck == nil {
+		return nil
+	}
+	raw := s.healthCheck.raw
+	return &raw
+}
+
+// SessionAffinity returns the session affinity configuration, or nil if clients don't stick to an origin.
+func (s *LoadBalancedService) SessionAffinity() *config.SessionAffinityConfig {
+	if s.affinity == nil {
+		return nil
+	}
+	raw := s.affinity.raw
+	return &raw
+}
+
+func (s *LoadBalancedService) String() string {
+	return strings.Join(s.Origins(), ", ")
+}
+
+func (s *LoadBalancedService) start(log *zerolog.Logger, shutdownC <-chan struct{}, cfg OriginRequestConfig) error {
+	for _, origin := range s.origins {
+		if err := origin.start(log, shutdownC, cfg); err != nil {
+			return err
+		}
+	}
+	if s.healthCheck != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-shutdownC
+			cancel()
+		}()
+		for _, origin := range s.origins {
+			go s.healthCheck.run(ctx, origin, log)
+		}
+	}
+	return nil
+}
+
+func (s *LoadBalancedService) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Origins())
+}
+
+func (s *LoadBalancedService) RoundTrip(req *http.Request) (*http.Response, error) {
+	origin, pinned := s.pickFor(req)
+	atomic.AddInt64(&origin.activeRequests, 1)
+	resp, err := origin.RoundTrip(req)
+	if err != nil {
+		atomic.AddInt64(&origin.activeRequests, -1)
+		return nil, err
+	}
+	// The request is active until the proxy is done with the response body
+	body := &releasingBody{
+		ReadCloser: resp.Body,
+		release:    func() { atomic.AddInt64(&origin.activeRequests, -1) },
+	}
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok {
+		// Upgraded connections, e.g. websockets, are written to through the body
+		resp.Body = &releasingReadWriteBody{releasingBody: body, Writer: rwc}
+	} else {
+		resp.Body = body
+	}
+	if s.affinity != nil && !pinned {
+		s.affinity.stick(resp, origin)
+	}
+	return resp, nil
+}
+
+// pickFor picks the origin of req, and returns whether the client of req sticks to it.
+func (s *LoadBalancedService) pickFor(req *http.Request) (*balancedOrigin, bool) {
+	if s.affinity != nil {
+		if origin := s.affinity.pinned(req, s.available()); origin != nil {
+			return origin, true
+		}
+	}
+	return s.pick(), false
+}
+
+func (s *LoadBalancedService) pick() *balancedOrigin {
+	origins := s.available()
+	switch s.policy {
+	case LoadBalancingRandom:
+		return origins[rand.Intn(len(origins))]
+	case LoadBalancingFailover:
+		return origins[0]
+	case LoadBalancingLeastConnections:
+		start := int(atomic.AddUint64(&s.next, 1) % uint64(len(origins)))
+		least := origins[start]
+		for i := 1; i < len(origins); i++ {
+			origin := origins[(start+i)%len(origins)]
+			if atomic.LoadInt64(&origin.activeRequests) < atomic.LoadInt64(&least.activeRequests) {
+				least = origin
+			}
+		}
+		return least
+	default:
+		return origins[(atomic.AddUint64(&s.next, 1)-1)%uint64(len(origins))]
+	}
+}
+
+// available returns the healthy origins in order. All origins are returned if none is healthy, since a request
+// to an origin that failed its health checks is better than no request at all.
+func (s *LoadBalancedService) available() []*balancedOrigin {
+	if s.healthCheck == nil {
+		return s.origins
+	}
+	healthy := make([]*balancedOrigin, 0, len(s.origins))
+	for _, origin := range s.origins {
+		if origin.healthy.Load() {
+			healthy = append(healthy, origin)
+		}
+	}
+	if len(healthy) == 0 {
+		return s.origins
+	}
+	return healthy
+}
+
+// releasingBody calls release once it's closed.
+type releasingBody struct {
+	io.ReadCloser
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.releaseOnce.Do(b.release)
+	return err
+}
+
+type releasingReadWriteBody struct {
+	*releasingBody
+	io.Writer
+}
@@ -0,0 +1,135 @@
+package ingress
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestParseLoadBalancedService(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - hostname: api.example.com
+   service:
+     - http://localhost:8000
+     - https://localhost:8001
+   loadBalancingPolicy: random
+ - service: http_status:404
+`))
+	require.NoError(t, err)
+	balanced, ok := ing.Rules[0].Service.(*LoadBalancedService)
+	require.True(t, ok)
+	assert.Equal(t, []string{"http://localhost:8000", "https://localhost:8001"}, balanced.Origins())
+	assert.Equal(t, LoadBalancingRandom, balanced.Policy())
+
+	for _, rawYAML := range []string{`
+ingress:
+ - service:
+     - http://localhost:8000
+     - tcp://localhost:8001
+`, `
+ingress:
+ - service:
+     - http://localhost:8000
+   loadBalancingPolicy: fastest
+`, `
+ingress:
+ - service: http://localhost:8000
+   loadBalancingPolicy: random
+`} {
+		_, err := ParseIngress(MustReadIngress(rawYAML))
+		assert.Error(t, err, rawYAML)
+	}
+}
+
+func newTestLoadBalancedService(t *testing.T, policy string, origins int) *LoadBalancedService {
+	var services []*httpService
+	for i := 0; i < origins; i++ {
+		name := fmt.Sprintf("origin-%d", i)
+		origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}))
+		t.Cleanup(origin.Close)
+		services = append(services, &httpService{url: MustParseURL(t, origin.URL)})
+	}
+	balanced, err := newLoadBalancedService(services, policy, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, balanced.start(testLogger, make(chan struct{}), originRequestFromConfig(config.OriginRequestConfig{})))
+	return balanced
+}
+
+func balancedRoundTrip(t *testing.T, balanced *LoadBalancedService) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := balanced.RoundTrip(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func readOrigin(t *testing.T, resp *http.Response) string {
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return string(body)
+}
+
+func TestLoadBalancedServiceRoundRobin(t *testing.T) {
+	balanced := newTestLoadBalancedService(t, "", 3)
+	assert.Equal(t, LoadBalancingRoundRobin, balanced.Policy())
+
+	var origins []string
+	for i := 0; i < 6; i++ {
+		origins = append(origins, readOrigin(t, balancedRoundTrip(t, balanced)))
+	}
+	assert.Equal(t, []string{"origin-0", "origin-1", "origin-2", "origin-0", "origin-1", "origin-2"}, origins)
+}
+
+func TestLoadBalancedServiceLeastConnections(t *testing.T) {
+	balanced := newTestLoadBalancedService(t, LoadBalancingLeastConnections, 2)
+
+	// The response of the first request isn't closed, so it's still active
+	first := balancedRoundTrip(t, balanced)
+	defer first.Body.Close()
+	busyOrigin := readOrigin(t, &http.Response{Body: io.NopCloser(first.Body)})
+	for i := 0; i < 4; i++ {
+		assert.NotEqual(t, busyOrigin, readOrigin(t, balancedRoundTrip(t, balanced)))
+	}
+
+	require.NoError(t, first.Body.Close())
+	origins := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		origins[readOrigin(t, balancedRoundTrip(t, balanced))] = true
+	}
+	assert.Len(t, origins, 2)
+}
+
+func TestLoadBalancedServiceRandom(t *testing.T) {
+	balanced := newTestLoadBalancedService(t, LoadBalancingRandom, 2)
+	for i := 0; i < 4; i++ {
+		assert.Contains(t, []string{"origin-0", "origin-1"}, readOrigin(t, balancedRoundTrip(t, balanced)))
+	}
+	for _, origin := range balanced.origins {
+		assert.Zero(t, origin.activeRequests)
+	}
+}
+
+func TestLoadBalancedServiceUsesAllOriginsIfNoneIsHealthy(t *testing.T) {
+	balanced := newTestLoadBalancedService(t, LoadBalancingRoundRobin, 2)
+	balanced.healthCheck = &healthCheck{}
+	for _, origin := range balanced.origins {
+		origin.healthy.Store(false)
+	}
+	assert.Len(t, balanced.available(), 2)
+
+	balanced.origins[1].healthy.Store(true)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "origin-1", readOrigin(t, balancedRoundTrip(t, balanced)))
+	}
+}
@@ -0,0 +1,63 @@
+package ingress
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+var defaultMaintenancePage = []byte(`<!DOCTYPE html>
+<html>
+<head><title>Down for maintenance</title></head>
+<body>
+<h1>Down for maintenance</h1>
+<p>This site is being upgraded and will be back shortly.</p>
+</body>
+</html>
+`)
+
+// Maintenance is the maintenance mode of a rule, which serves a static 503 page rather than proxying requests to
+// its service while the origin is being upgraded.
+type Maintenance struct {
+	// Enabled is whether the rule is in maintenance mode according to its configuration
+	Enabled bool
+	page    []byte
+	raw     config.MaintenanceConfig
+}
+
+func newMaintenance(cfg config.MaintenanceConfig) (*Maintenance, error) {
+	m := &Maintenance{
+		Enabled: cfg.Enabled,
+		page:    defaultMaintenancePage,
+		raw:     cfg,
+	}
+	if cfg.Page != "" {
+		page, err := os.ReadFile(cfg.Page)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read maintenance page")
+		}
+		m.page = page
+	}
+	return m, nil
+}
+
+// Page returns the HTML page served in maintenance mode. Rules without maintenance configuration, which are only
+// in maintenance mode when it's enabled at runtime, serve a generic page.
+func (m *Maintenance) Page() []byte {
+	if m == nil {
+		return defaultMaintenancePage
+	}
+	return m.page
+}
+
+// Raw returns the configuration of the maintenance mode.
+func (m *Maintenance) Raw() config.MaintenanceConfig {
+	return m.raw
+}
+
+func (m *Maintenance) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.raw)
+}
@@ -0,0 +1,43 @@
+package ingress
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMaintenance(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "maintenance.html")
+	require.NoError(t, os.WriteFile(page, []byte("<h1>Back soon</h1>"), 0o600))
+
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - hostname: upgrading.example.com
+   service: http://localhost:8000
+   maintenance:
+     enabled: true
+     page: ` + page + `
+ - hostname: example.com
+   service: http://localhost:8001
+ - service: http_status:404
+`))
+	require.NoError(t, err)
+	assert.True(t, ing.Rules[0].Maintenance.Enabled)
+	assert.Equal(t, []byte("<h1>Back soon</h1>"), ing.Rules[0].Maintenance.Page())
+	assert.Equal(t, page, ing.Rules[0].Maintenance.Raw().Page)
+	// Rules without maintenance configuration serve the generic page when put in maintenance mode at runtime
+	assert.Nil(t, ing.Rules[1].Maintenance)
+	assert.Equal(t, defaultMaintenancePage, ing.Rules[1].Maintenance.Page())
+
+	_, err = ParseIngress(MustReadIngress(`
+ingress:
+ - service: http://localhost:8000
+   maintenance:
+     enabled: true
+     page: ` + filepath.Join(t.TempDir(), "missing.html") + `
+`))
+	assert.Error(t, err)
+}
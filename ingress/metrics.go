@@ -0,0 +1,26 @@
+package ingress
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	MetricsNamespace = "cloudflared"
+	MetricsSubsystem = "ingress"
+)
+
+var (
+	originHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "origin_healthy",
+			Help:      "Whether a health checked origin is healthy (1) or not (0) by ingress rule and origin",
+		},
+		[]string{"ingress_rule", "origin"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(originHealthy)
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPFilter is an implementation of Handler that filters requests by client IP, as seen in the Cf-Connecting-Ip
+// header. It enforces which clients can reach internal-only hostnames even if their Access policy is misconfigured.
+type IPFilter struct {
+	allowed []*net.IPNet
+	denied  []*net.IPNet
+}
+
+// NewIPFilter returns an IPFilter denying the requests of clients in deny, and of clients not in allow unless it's
+// empty. Entries are CIDRs or single IPs.
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	allowNets, err := parseIPNets(allow)
+	if err != nil {
+		return nil, err
+	}
+	denyNets, err := parseIPNets(deny)
+	if err != nil {
+		return nil, err
+	}
+	return &IPFilter{allowed: allowNets, denied: denyNets}, nil
+}
+
+func parseIPNets(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("%s isn't an IP or a CIDR", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func (f *IPFilter) Name() string {
+	return "IPFilter"
+}
+
+func (f *IPFilter) Handle(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	if reason := f.deny(net.ParseIP(r.Header.Get(headerKeyConnectingIP))); reason != "" {
+		return &HandleResult{
+			ShouldFilterRequest: true,
+			StatusCode:          http.StatusForbidden,
+			Reason:              reason,
+		}, nil
+	}
+	return &HandleResult{ShouldFilterRequest: false}, nil
+}
+
+// deny returns why the requests of ip are denied, or an empty string if they aren't.
+func (f *IPFilter) deny(ip net.IP) string {
+	if ip == nil {
+		if len(f.allowed) > 0 {
+			return "client IP is unknown"
+		}
+		return ""
+	}
+	if containsIP(f.denied, ip) {
+		return fmt.Sprintf("client IP %s is denied", ip)
+	}
+	if len(f.allowed) > 0 && !containsIP(f.allowed, ip) {
+		return fmt.Sprintf("client IP %s isn't allowed", ip)
+	}
+	return ""
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilter(t *testing.T) {
+	filter, err := NewIPFilter([]string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.1"}, []string{"10.1.0.0/16"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		clientIP string
+		denied   bool
+	}{
+		{clientIP: "10.2.3.4"},
+		{clientIP: "2001:db8::1"},
+		{clientIP: "192.0.2.1"},
+		{clientIP: "192.0.2.2", denied: true},
+		{clientIP: "10.1.2.3", denied: true},
+		{clientIP: "2001:db9::1", denied: true},
+		{clientIP: "", denied: true},
+		{clientIP: "not an IP", denied: true},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		req.Header.Set("Cf-Connecting-Ip", test.clientIP)
+		result, err := filter.Handle(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, test.denied, result.ShouldFilterRequest, test.clientIP)
+		if test.denied {
+			assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		}
+	}
+}
+
+func TestIPFilterDenyOnly(t *testing.T) {
+	filter, err := NewIPFilter(nil, []string{"198.51.100.0/24"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, filter.deny(parseIP(t, "198.51.100.7")))
+	assert.Empty(t, filter.deny(parseIP(t, "203.0.113.7")))
+	// Requests without a client IP aren't denied when there's no allow list
+	assert.Empty(t, filter.deny(nil))
+}
+
+func TestNewIPFilterErrors(t *testing.T) {
+	_, err := NewIPFilter([]string{"10.0.0.0/33"}, nil)
+	assert.Error(t, err)
+	_, err = NewIPFilter(nil, []string{"example.com"})
+	assert.Error(t, err)
+}
+
+func parseIP(t *testing.T, s string) net.IP {
+	ip := net.ParseIP(s)
+	require.NotNil(t, ip)
+	return ip
+}
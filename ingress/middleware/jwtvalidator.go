@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 )
@@ -57,9 +58,23 @@ func (v *JWTValidator) Handle(ctx context.Context, r *http.Request) (*HandleResu
 		}, nil
 	}
 
+	// Verify checks the signature against the certs of the team, the issuer and the expiry. Tokens failing it are
+	// forged, expired or meant for another team, so the request is denied rather than failed, unless the certs
+	// couldn't be fetched to check the signature against.
 	token, err := v.IDTokenVerifier.Verify(ctx, accessJWT)
 	if err != nil {
-		return nil, err
+		if isKeyFetchError(err) {
+			return &HandleResult{
+				ShouldFilterRequest: true,
+				StatusCode:          http.StatusServiceUnavailable,
+				Reason:              fmt.Sprintf("failed to fetch the access certs: %v", err),
+			}, nil
+		}
+		return &HandleResult{
+			ShouldFilterRequest: true,
+			StatusCode:          http.StatusForbidden,
+			Reason:              fmt.Sprintf("invalid access token: %v", err),
+		}, nil
 	}
 
 	// We want at least one audTag to match
@@ -77,3 +92,9 @@ func (v *JWTValidator) Handle(ctx context.Context, r *http.Request) (*HandleResu
 		Reason:              fmt.Sprintf("Invalid token in jwt: %v", token.Audience),
 	}, nil
 }
+
+// isKeyFetchError returns whether Verify failed because the certs couldn't be fetched. go-oidc formats rather than
+// wraps the error, so it's told apart by its message.
+func isKeyFetchError(err error) bool {
+	return strings.Contains(err.Error(), "fetching keys")
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAudTag = "aud-tag"
+
+func TestJWTValidator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	certs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "key", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	}))
+	defer certs.Close()
+	issuer := certs.URL
+	validator := NewJWTValidator("team", certs.URL, []string{testAudTag})
+
+	valid := jwt.Claims{
+		Issuer:   issuer,
+		Audience: jwt.Audience{testAudTag},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	wrongAudience := valid
+	wrongAudience.Audience = jwt.Audience{"other-aud-tag"}
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "https://other-team.cloudflareaccess.com"
+	expired := valid
+	expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		token  string
+		denied bool
+	}{
+		{name: "valid", token: signToken(t, key, valid)},
+		{name: "missing", token: "", denied: true},
+		{name: "malformed", token: "not a jwt", denied: true},
+		{name: "wrong audience", token: signToken(t, key, wrongAudience), denied: true},
+		{name: "wrong issuer", token: signToken(t, key, wrongIssuer), denied: true},
+		{name: "expired", token: signToken(t, key, expired), denied: true},
+		{name: "forged", token: signToken(t, otherKey, valid), denied: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			require.NoError(t, err)
+			if test.token != "" {
+				req.Header.Set(headerKeyAccessJWTAssertion, test.token)
+			}
+			result, err := validator.Handle(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, test.denied, result.ShouldFilterRequest, result.Reason)
+			if test.denied {
+				assert.Equal(t, http.StatusForbidden, result.StatusCode)
+			}
+		})
+	}
+}
+
+// Tokens can't be checked while the certs can't be fetched, which fails the request rather than denying it
+func TestJWTValidatorCertsUnavailable(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	certs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer certs.Close()
+	validator := NewJWTValidator("team", certs.URL, []string{testAudTag})
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set(headerKeyAccessJWTAssertion, signToken(t, key, jwt.Claims{
+		Issuer:   certs.URL,
+		Audience: jwt.Audience{testAudTag},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}))
+	result, err := validator.Handle(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, result.ShouldFilterRequest)
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode, result.Reason)
+
+	// The request is failed the same way if the certs are unreachable
+	certs.Close()
+	result, err = validator.Handle(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, result.ShouldFilterRequest)
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode, result.Reason)
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims jwt.Claims) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "key"),
+	)
+	require.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
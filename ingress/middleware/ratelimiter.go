@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	headerKeyConnectingIP = "Cf-Connecting-Ip"
+	// How often the buckets of the clients that haven't sent requests in a while are dropped
+	pruneInterval = time.Minute
+)
+
+// RateLimiter is an implementation of Handler that filters the requests over a rate, using a token bucket.
+type RateLimiter struct {
+	rate        float64
+	burst       float64
+	perClientIP bool
+	now         func() time.Time
+
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter returns a RateLimiter letting requestsPerSecond requests through in the long run, and up to
+// burst at once. burst defaults to requestsPerSecond rounded up. If perClientIP is set, the requests of each
+// client IP are limited separately.
+func NewRateLimiter(requestsPerSecond float64, burst uint, perClientIP bool) *RateLimiter {
+	if burst == 0 {
+		burst = uint(math.Ceil(requestsPerSecond))
+	}
+	return &RateLimiter{
+		rate:        requestsPerSecond,
+		burst:       float64(burst),
+		perClientIP: perClientIP,
+		now:         time.Now,
+		buckets:     make(map[string]*tokenBucket),
+	}
+}
+
+func (l *RateLimiter) Name() string {
+	return "RateLimiter"
+}
+
+func (l *RateLimiter) Handle(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	var key string
+	if l.perClientIP {
+		key = r.Header.Get(headerKeyConnectingIP)
+	}
+	if l.allow(key) {
+		return &HandleResult{ShouldFilterRequest: false}, nil
+	}
+	return &HandleResult{
+		ShouldFilterRequest: true,
+		StatusCode:          http.StatusTooManyRequests,
+		Reason:              "rate limit exceeded",
+	}, nil
+}
+
+func (l *RateLimiter) allow(key string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) >= pruneInterval {
+		l.prune(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// prune drops the buckets that have refilled, since they're the same as new ones.
+func (l *RateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(2, 3, false)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.allow(""), "burst request %d", i)
+	}
+	assert.False(t, limiter.allow(""))
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.allow(""))
+	assert.False(t, limiter.allow(""))
+
+	// The bucket doesn't fill up past the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.allow(""), "burst request %d", i)
+	}
+	assert.False(t, limiter.allow(""))
+}
+
+func TestRateLimiterDefaultBurst(t *testing.T) {
+	assert.Equal(t, float64(3), NewRateLimiter(2.5, 0, false).burst)
+	assert.Equal(t, float64(1), NewRateLimiter(0.1, 0, false).burst)
+}
+
+func TestRateLimiterPerClientIP(t *testing.T) {
+	limiter := NewRateLimiter(1, 1, true)
+	request := func(ip string) *HandleResult {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		req.Header.Set(headerKeyConnectingIP, ip)
+		result, err := limiter.Handle(context.Background(), req)
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.False(t, request("192.0.2.1").ShouldFilterRequest)
+	assert.False(t, request("192.0.2.2").ShouldFilterRequest)
+	result := request("192.0.2.1")
+	assert.True(t, result.ShouldFilterRequest)
+	assert.Equal(t, http.StatusTooManyRequests, result.StatusCode)
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(1, 1, true)
+	limiter.now = func() time.Time { return now }
+
+	limiter.allow("192.0.2.1")
+	now = now.Add(pruneInterval)
+	limiter.allow("192.0.2.2")
+	assert.Len(t, limiter.buckets, 1)
+	assert.Contains(t, limiter.buckets, "192.0.2.2")
+}
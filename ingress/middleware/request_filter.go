@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const defaultFilterStatus = http.StatusForbidden
+
+// RequestFilter is an implementation of Handler that denies the requests matching any of its filters, so that
+// obviously malicious traffic never reaches the origin.
+type RequestFilter struct {
+	methods    map[string]bool
+	paths      []*regexp.Regexp
+	headers    map[string]*regexp.Regexp
+	userAgents []*regexp.Regexp
+	status     int
+}
+
+// NewRequestFilter returns a RequestFilter denying the requests matching cfg, or an error if one of its patterns
+// isn't a valid regular expression.
+func NewRequestFilter(cfg config.FilterConfig) (*RequestFilter, error) {
+	f := &RequestFilter{
+		methods: make(map[string]bool),
+		headers: make(map[string]*regexp.Regexp),
+		status:  cfg.ResponseStatus,
+	}
+	if f.status == 0 {
+		f.status = defaultFilterStatus
+	} else if f.status < 100 || f.status > 599 {
+		return nil, fmt.Errorf("%d isn't a valid HTTP status", f.status)
+	}
+	for _, method := range cfg.DenyMethods {
+		f.methods[strings.ToUpper(method)] = true
+	}
+	var err error
+	if f.paths, err = compilePatterns(cfg.DenyPaths); err != nil {
+		return nil, errors.Wrap(err, "invalid denyPaths")
+	}
+	if f.userAgents, err = compilePatterns(cfg.DenyUserAgents); err != nil {
+		return nil, errors.Wrap(err, "invalid denyUserAgents")
+	}
+	for header, pattern := range cfg.DenyHeaders {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid denyHeaders pattern of %s", header)
+		}
+		f.headers[http.CanonicalHeaderKey(header)] = re
+	}
+	return f, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func (f *RequestFilter) Name() string {
+	return "RequestFilter"
+}
+
+func (f *RequestFilter) Handle(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	if reason := f.deny(r); reason != "" {
+		return &HandleResult{
+			ShouldFilterRequest: true,
+			StatusCode:          f.status,
+			Reason:              reason,
+		}, nil
+	}
+	return &HandleResult{ShouldFilterRequest: false}, nil
+}
+
+// deny returns why r is denied, or an empty string if it isn't.
+func (f *RequestFilter) deny(r *http.Request) string {
+	if f.methods[r.Method] {
+		return fmt.Sprintf("method %s is denied", r.Method)
+	}
+	if matchesAny(f.paths, r.URL.Path) {
+		return fmt.Sprintf("path %s is denied", r.URL.Path)
+	}
+	if userAgent := r.UserAgent(); userAgent != "" && matchesAny(f.userAgents, userAgent) {
+		return fmt.Sprintf("user agent %s is denied", userAgent)
+	}
+	for header, re := range f.headers {
+		for _, value := range r.Header.Values(header) {
+			if re.MatchString(value) {
+				return fmt.Sprintf("value of header %s is denied", header)
+			}
+		}
+	}
+	return ""
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestRequestFilter(t *testing.T) {
+	filter, err := NewRequestFilter(config.FilterConfig{
+		DenyMethods:    []string{"trace"},
+		DenyPaths:      []string{`^/wp-admin`, `\.php$`},
+		DenyHeaders:    map[string]string{"x-forwarded-host": `^internal\.`},
+		DenyUserAgents: []string{`(?i)sqlmap`},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header http.Header
+		denied bool
+	}{
+		{name: "allowed", method: http.MethodGet, path: "/index.html"},
+		{name: "denied method", method: http.MethodTrace, path: "/", denied: true},
+		{name: "denied path prefix", method: http.MethodGet, path: "/wp-admin/install", denied: true},
+		{name: "denied path suffix", method: http.MethodPost, path: "/xmlrpc.php", denied: true},
+		{name: "unanchored path", method: http.MethodGet, path: "/blog/wp-admin"},
+		{
+			name:   "denied user agent",
+			method: http.MethodGet,
+			path:   "/",
+			header: http.Header{"User-Agent": []string{"SQLMap/1.7"}},
+			denied: true,
+		},
+		{
+			name:   "denied header",
+			method: http.MethodGet,
+			path:   "/",
+			header: http.Header{"X-Forwarded-Host": []string{"example.com", "internal.example.com"}},
+			denied: true,
+		},
+		{
+			name:   "allowed header",
+			method: http.MethodGet,
+			path:   "/",
+			header: http.Header{"X-Forwarded-Host": []string{"example.com"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, "http://example.com"+test.path, nil)
+			require.NoError(t, err)
+			for name, values := range test.header {
+				req.Header[name] = values
+			}
+			result, err := filter.Handle(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, test.denied, result.ShouldFilterRequest)
+			if test.denied {
+				assert.Equal(t, http.StatusForbidden, result.StatusCode)
+				assert.NotEmpty(t, result.Reason)
+			}
+		})
+	}
+}
+
+func TestRequestFilterResponseStatus(t *testing.T) {
+	filter, err := NewRequestFilter(config.FilterConfig{DenyMethods: []string{"TRACE"}, ResponseStatus: http.StatusNotFound})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodTrace, "http://example.com", nil)
+	require.NoError(t, err)
+	result, err := filter.Handle(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+}
+
+func TestNewRequestFilterErrors(t *testing.T) {
+	for _, cfg := range []config.FilterConfig{
+		{DenyPaths: []string{"("}},
+		{DenyUserAgents: []string{"[a-"}},
+		{DenyHeaders: map[string]string{"X-Test": "*"}},
+		{ResponseStatus: 42},
+	} {
+		_, err := NewRequestFilter(cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
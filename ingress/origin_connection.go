@@ -4,6 +4,9 @@ import (
 	"context"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -79,3 +82,67 @@ func (sp *socksProxyOverWSConnection) Stream(ctx context.Context, tunnelConn io.
 
 func (sp *socksProxyOverWSConnection) Close() {
 }
+
+// idleTimeoutConn is a net.Conn that closes itself once no data was read from or written to it for timeout.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+	// lastActivity is the time of the last read or write in unix nanoseconds
+	lastActivity atomic.Int64
+	closeOnce    sync.Once
+	closed       chan struct{}
+}
+
+func newIdleTimeoutConn(conn net.Conn, timeout time.Duration) *idleTimeoutConn {
+	c := &idleTimeoutConn{
+		Conn:    conn,
+		timeout: timeout,
+		closed:  make(chan struct{}),
+	}
+	c.touch()
+	go c.closeWhenIdle()
+	return c
+}
+
+func (c *idleTimeoutConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *idleTimeoutConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *idleTimeoutConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+func (c *idleTimeoutConn) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (c *idleTimeoutConn) closeWhenIdle() {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-timer.C:
+			idle := time.Since(time.Unix(0, c.lastActivity.Load()))
+			if idle >= c.timeout {
+				_ = c.Close()
+				return
+			}
+			timer.Reset(c.timeout - idle)
+		}
+	}
+}
@@ -324,6 +324,34 @@ func echoTCPOrigin(t *testing.T, conn net.Conn) {
 	assert.NoError(t, err)
 }
 
+func TestIdleTimeoutConn(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	cfdConn, originConn := net.Pipe()
+	defer originConn.Close()
+	go func() {
+		_, _ = io.Copy(originConn, originConn)
+	}()
+
+	conn := newIdleTimeoutConn(cfdConn, timeout)
+	// Keeps the connection open past the timeout as long as data goes through
+	buf := make([]byte, len(testMessage))
+	for i := 0; i < 4; i++ {
+		time.Sleep(timeout / 2)
+		_, err := conn.Write(testMessage)
+		require.NoError(t, err)
+		_, err = io.ReadFull(conn, buf)
+		require.NoError(t, err)
+	}
+
+	select {
+	case <-conn.closed:
+	case <-time.After(timeout * 10):
+		t.Fatal("idle connection wasn't closed")
+	}
+	_, err := conn.Write(testMessage)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
 type readWriter struct {
 	w io.Writer
 	r io.Reader
@@ -1,9 +1,12 @@
 package ingress
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 )
 
 // HTTPOriginProxy can be implemented by origin services that want to proxy http requests.
@@ -32,9 +35,9 @@ func (o *httpService) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Rewrite the request URL so that it goes to the origin service.
 	req.URL.Host = o.url.Host
 	switch o.url.Scheme {
-	case "ws":
+	case "ws", "grpc":
 		req.URL.Scheme = "http"
-	case "wss":
+	case "wss", "grpcs":
 		req.URL.Scheme = "https"
 	default:
 		req.URL.Scheme = o.url.Scheme
@@ -46,7 +49,21 @@ func (o *httpService) RoundTrip(req *http.Request) (*http.Response, error) {
 		req.Header.Set("X-Forwarded-Host", req.Host)
 		req.Host = o.hostHeader
 	}
-	return o.transport.RoundTrip(req)
+	if !isGRPCService(o.url) {
+		return o.transport.RoundTrip(req)
+	}
+
+	// gRPC servers reject requests that don't declare support for trailers, which carry the gRPC status
+	req.Header.Set("TE", "trailers")
+	resp, err := o.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("gRPC origin %s responded with %s, gRPC requires HTTP/2", o.url.Host, resp.Proto)
+	}
+	return resp, nil
 }
 
 func (o *statusCode) RoundTrip(_ *http.Request) (*http.Response, error) {
@@ -56,8 +73,14 @@ func (o *statusCode) RoundTrip(_ *http.Request) (*http.Response, error) {
 	resp := &http.Response{
 		StatusCode: o.code,
 		Status:     fmt.Sprintf("%d %s", o.code, http.StatusText(o.code)),
+		Header:     o.header.Clone(),
 		Body:       new(NopReadCloser),
 	}
+	if len(o.body) > 0 {
+		resp.Body = io.NopCloser(bytes.NewReader(o.body))
+		resp.ContentLength = int64(len(o.body))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(o.body)))
+	}
 
 	return resp, nil
 }
@@ -84,6 +107,15 @@ func (o *tcpOverWSService) EstablishConnection(ctx context.Context, dest string)
 	if err != nil {
 		return nil, err
 	}
+	if o.proxyProtocol != "" {
+		if err := writeProxyProtocolHeader(ctx, conn, o.proxyProtocol); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if o.idleTimeout > 0 {
+		conn = newIdleTimeoutConn(conn, o.idleTimeout)
+	}
 	originConn := &tcpOverWSConnection{
 		conn:          conn,
 		streamHandler: o.streamHandler,
@@ -2,18 +2,32 @@ package ingress
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
 
 	"github.com/cloudflare/cloudflared/carrier"
+	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/websocket"
 )
 
@@ -186,6 +200,166 @@ func TestHTTPServiceUsesIngressRuleScheme(t *testing.T) {
 	}
 }
 
+func TestHTTPServiceH2COrigin(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		server := &http2.Server{}
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn, &http2.ServeConnOpts{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(r.Proto))
+				}),
+			})
+		}
+	}()
+
+	originURL := &url.URL{Scheme: "http", Host: listener.Addr().String()}
+	httpService := &httpService{
+		url: originURL,
+	}
+	shutdownC := make(chan struct{})
+	require.NoError(t, httpService.start(testLogger, shutdownC, OriginRequestConfig{Http2Origin: true}))
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodGet, originURL.String(), nil)
+		require.NoError(t, err)
+
+		resp, err := httpService.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, "HTTP/2.0", string(respBody))
+	}
+}
+
+func TestHTTPServiceGRPCOrigin(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		server := &http2.Server{}
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn, &http2.ServeConnOpts{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					require.Equal(t, "trailers", r.Header.Get("TE"))
+					w.Header().Set("Content-Type", "application/grpc")
+					w.Header().Set("Trailer", "Grpc-Status")
+					w.Write([]byte(r.Proto))
+					w.Header().Set("Grpc-Status", "0")
+				}),
+			})
+		}
+	}()
+
+	originURL := MustParseURL(t, "grpc://"+listener.Addr().String())
+	httpService := &httpService{
+		url: originURL,
+	}
+	shutdownC := make(chan struct{})
+	require.NoError(t, httpService.start(testLogger, shutdownC, OriginRequestConfig{}))
+
+	req, err := http.NewRequest(http.MethodPost, originURL.String(), nil)
+	require.NoError(t, err)
+
+	resp, err := httpService.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0", string(respBody))
+	require.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+}
+
+func TestHTTPServiceGRPCOriginRequiresHTTP2(t *testing.T) {
+	// httptest servers only speak HTTP/1.1 unless EnableHTTP2 is set
+	origin := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer origin.Close()
+
+	originURL := MustParseURL(t, strings.Replace(origin.URL, "https://", "grpcs://", 1))
+	httpService := &httpService{
+		url: originURL,
+	}
+	shutdownC := make(chan struct{})
+	require.NoError(t, httpService.start(testLogger, shutdownC, OriginRequestConfig{NoTLSVerify: true}))
+
+	req, err := http.NewRequest(http.MethodPost, originURL.String(), nil)
+	require.NoError(t, err)
+	_, err = httpService.RoundTrip(req)
+	require.ErrorContains(t, err, "requires HTTP/2")
+}
+
+func TestHTTPServiceClientCertificate(t *testing.T) {
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	origin.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	origin.StartTLS()
+	defer origin.Close()
+
+	originURL := MustParseURL(t, origin.URL)
+	roundTrip := func(cfg OriginRequestConfig) (*http.Response, error) {
+		httpService := &httpService{
+			url: originURL,
+		}
+		shutdownC := make(chan struct{})
+		require.NoError(t, httpService.start(testLogger, shutdownC, cfg))
+		req, err := http.NewRequest(http.MethodGet, originURL.String(), nil)
+		require.NoError(t, err)
+		return httpService.RoundTrip(req)
+	}
+
+	_, err := roundTrip(OriginRequestConfig{NoTLSVerify: true})
+	require.Error(t, err)
+
+	certPEM, keyPEM := newTestClientCertificate(t, "cloudflared")
+	resp, err := roundTrip(OriginRequestConfig{
+		NoTLSVerify: true,
+		ClientCert:  string(certPEM),
+		ClientKey:   string(keyPEM),
+	})
+	require.NoError(t, err)
+	respBody, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "cloudflared", string(respBody))
+
+	httpService := &httpService{
+		url: originURL,
+	}
+	require.Error(t, httpService.start(testLogger, make(chan struct{}), OriginRequestConfig{ClientCert: string(certPEM)}))
+}
+
+func newTestClientCertificate(t *testing.T, commonName string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
 func tcpListenRoutine(listener net.Listener, closeChan chan struct{}) {
 	go func() {
 		for {
@@ -199,3 +373,93 @@ func tcpListenRoutine(listener net.Listener, closeChan chan struct{}) {
 		}
 	}()
 }
+
+func TestHTTPServiceResponseHeaderTimeout(t *testing.T) {
+	cfg := OriginRequestConfig{
+		ResponseHeaderTimeout: config.CustomDuration{Duration: 50 * time.Millisecond},
+	}
+	unblock := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-unblock
+		}
+	}))
+	defer origin.Close()
+	defer close(unblock)
+
+	originURL, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+	httpService := &httpService{url: originURL}
+	require.NoError(t, httpService.start(testLogger, make(chan struct{}), cfg))
+
+	req, err := http.NewRequest(http.MethodGet, originURL.String(), nil)
+	require.NoError(t, err)
+	resp, err := httpService.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	req, err = http.NewRequest(http.MethodGet, originURL.String()+"/slow", nil)
+	require.NoError(t, err)
+	_, err = httpService.RoundTrip(req)
+	assert.Error(t, err)
+}
+
+func TestStatusCodeResponse(t *testing.T) {
+	robots := filepath.Join(t.TempDir(), "robots.txt")
+	require.NoError(t, os.WriteFile(robots, []byte("User-agent: *\nDisallow: /\n"), 0o600))
+
+	tests := []struct {
+		name           string
+		cfg            config.StatusResponseConfig
+		expectedBody   string
+		expectedHeader http.Header
+	}{
+		{
+			name:           "no body",
+			expectedHeader: http.Header{},
+		},
+		{
+			name:         "inline body and headers",
+			cfg:          config.StatusResponseConfig{Body: `{"status":"ok"}`, Headers: map[string]string{"content-type": "application/json", "X-Stub": "1"}},
+			expectedBody: `{"status":"ok"}`,
+			expectedHeader: http.Header{
+				"Content-Type":   []string{"application/json"},
+				"Content-Length": []string{"15"},
+				"X-Stub":         []string{"1"},
+			},
+		},
+		{
+			name:         "file body",
+			cfg:          config.StatusResponseConfig{File: robots},
+			expectedBody: "User-agent: *\nDisallow: /\n",
+			expectedHeader: http.Header{
+				"Content-Type":   []string{"text/plain; charset=utf-8"},
+				"Content-Length": []string{"26"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newStatusCode(http.StatusOK)
+			cfg := originRequestFromConfig(config.OriginRequestConfig{StatusResponse: &test.cfg})
+			require.NoError(t, service.start(testLogger, make(chan struct{}), cfg))
+
+			// Each response has its own body and headers
+			for i := 0; i < 2; i++ {
+				resp, err := service.RoundTrip(httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, test.expectedHeader, resp.Header)
+				body, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedBody, string(body))
+			}
+		})
+	}
+
+	service := newStatusCode(http.StatusOK)
+	cfg := originRequestFromConfig(config.OriginRequestConfig{
+		StatusResponse: &config.StatusResponseConfig{File: filepath.Join(t.TempDir(), "missing.txt")},
+	})
+	assert.Error(t, service.start(testLogger, make(chan struct{}), cfg))
+}
@@ -9,11 +9,13 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"golang.org/x/net/http2"
 
 	"github.com/cloudflare/cloudflared/hello"
 	"github.com/cloudflare/cloudflared/ipaccess"
@@ -74,6 +76,10 @@ type httpService struct {
 }
 
 func (o *httpService) start(log *zerolog.Logger, _ <-chan struct{}, cfg OriginRequestConfig) error {
+	if o.url != nil && isGRPCService(o.url) {
+		// gRPC only works over HTTP/2
+		cfg.Http2Origin = true
+	}
 	transport, err := newHTTPTransport(o, cfg, log)
 	if err != nil {
 		return err
@@ -118,6 +124,8 @@ type tcpOverWSService struct {
 	isBastion     bool
 	streamHandler streamHandlerFunc
 	dialer        net.Dialer
+	idleTimeout   time.Duration
+	proxyProtocol string
 }
 
 type socksProxyOverWSService struct {
@@ -185,6 +193,8 @@ func (o *tcpOverWSService) start(log *zerolog.Logger, _ <-chan struct{}, cfg Ori
 	}
 	o.dialer.Timeout = cfg.ConnectTimeout.Duration
 	o.dialer.KeepAlive = cfg.TCPKeepAlive.Duration
+	o.idleTimeout = cfg.TCPIdleTimeout.Duration
+	o.proxyProtocol = cfg.ProxyProtocol
 	return nil
 }
 
@@ -248,6 +258,9 @@ func (o helloWorld) MarshalJSON() ([]byte, error) {
 // Typical use-case is "user wants the catch-all rule to just respond 404".
 type statusCode struct {
 	code int
+	// Optional headers and body of the responses, set from the rule config when started
+	header http.Header
+	body   []byte
 
 	// Set only when the user has not defined any ingress rules
 	defaultResp bool
@@ -272,6 +285,22 @@ func (o *statusCode) start(
 	_ <-chan struct{},
 	cfg OriginRequestConfig,
 ) error {
+	resp := cfg.StatusResponse
+	o.body = []byte(resp.Body)
+	if resp.Body == "" && resp.File != "" {
+		body, err := os.ReadFile(resp.File)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read the body of %s", o)
+		}
+		o.body = body
+	}
+	o.header = make(http.Header, len(resp.Headers))
+	for name, value := range resp.Headers {
+		o.header.Set(name, value)
+	}
+	if len(o.body) > 0 && o.header.Get("Content-Type") == "" {
+		o.header.Set("Content-Type", http.DetectContentType(o.body))
+	}
 	return nil
 }
 
@@ -349,6 +378,7 @@ func newHTTPTransport(service OriginService, cfg OriginRequestConfig, log *zerol
 		MaxIdleConns:          cfg.KeepAliveConnections,
 		MaxIdleConnsPerHost:   cfg.KeepAliveConnections,
 		IdleConnTimeout:       cfg.KeepAliveTimeout.Duration,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout.Duration,
 		TLSHandshakeTimeout:   cfg.TLSTimeout.Duration,
 		ExpectContinueTimeout: 1 * time.Second,
 		TLSClientConfig:       &tls.Config{RootCAs: originCertPool, InsecureSkipVerify: cfg.NoTLSVerify},
@@ -357,6 +387,16 @@ func newHTTPTransport(service OriginService, cfg OriginRequestConfig, log *zerol
 	if _, isHelloWorld := service.(*helloWorld); !isHelloWorld && cfg.OriginServerName != "" {
 		httpTransport.TLSClientConfig.ServerName = cfg.OriginServerName
 	}
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		clientCert, err := tlsconfig.LoadOriginClientCertificate(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		httpTransport.TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	if err := tlsconfig.EnforceFIPS(httpTransport.TLSClientConfig); err != nil {
+		return nil, errors.Wrap(err, "Error configuring origin TLS")
+	}
 
 	dialer := &net.Dialer{
 		Timeout:   cfg.ConnectTimeout.Duration,
@@ -381,9 +421,59 @@ func newHTTPTransport(service OriginService, cfg OriginRequestConfig, log *zerol
 		httpTransport.DialContext = dialContext
 	}
 
+	if cfg.ProxyProtocol != "" {
+		if cfg.Http2Origin {
+			return nil, errors.New("proxyProtocol can't be used with HTTP/2 origins, whose connections are shared by clients")
+		}
+		// The PROXY protocol header is specific to the client the connection is made for
+		httpTransport.DisableKeepAlives = true
+		dial := httpTransport.DialContext
+		httpTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeProxyProtocolHeader(ctx, conn, cfg.ProxyProtocol); err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}
+	}
+
+	if cfg.Http2Origin {
+		// ForceAttemptHTTP2 only negotiates HTTP/2 over TLS, cleartext origins are sent HTTP/2 with prior knowledge
+		httpTransport.RegisterProtocol("http", newH2CRoundTripper(httpTransport.DialContext))
+	}
+
 	return &httpTransport, nil
 }
 
+// h2cRoundTripper sends requests to cleartext origins over HTTP/2 with prior knowledge (h2c), multiplexing them
+// over pooled connections. Upgrade requests, such as websockets, are left to the HTTP/1.1 transport since HTTP/2
+// can't upgrade a connection.
+type h2cRoundTripper struct {
+	transport *http2.Transport
+}
+
+func newH2CRoundTripper(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) *h2cRoundTripper {
+	return &h2cRoundTripper{
+		transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialContext(ctx, network, addr)
+			},
+		},
+	}
+}
+
+func (rt *h2cRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Upgrade") != "" {
+		return nil, http.ErrSkipAltProtocol
+	}
+	return rt.transport.RoundTrip(req)
+}
+
 // MockOriginHTTPService should only be used by other packages to mock OriginService. Set Transport to configure desired RoundTripper behavior.
 type MockOriginHTTPService struct {
 	Transport http.RoundTripper
@@ -0,0 +1,100 @@
+package ingress
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+)
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+type clientIPKey struct{}
+
+// ContextWithClientIP returns a copy of ctx carrying the IP of the client a connection to an origin is made for,
+// which is sent to origins that expect a PROXY protocol header.
+func ContextWithClientIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIPFromContext(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(clientIPKey{}).(net.IP)
+	return ip
+}
+
+func validateProxyProtocol(version string) error {
+	switch version {
+	case "", ProxyProtocolV1, ProxyProtocolV2:
+		return nil
+	default:
+		return fmt.Errorf("unknown PROXY protocol version %s, expected %s or %s", version, ProxyProtocolV1, ProxyProtocolV2)
+	}
+}
+
+// writeProxyProtocolHeader writes the PROXY protocol header of a connection from the client IP in ctx to the
+// origin conn is connected to. The client port isn't known, so it's 0. The header doesn't carry addresses if
+// either isn't known, e.g. for unix socket origins.
+func writeProxyProtocolHeader(ctx context.Context, conn net.Conn, version string) error {
+	src := clientIPFromContext(ctx)
+	dst, _ := conn.RemoteAddr().(*net.TCPAddr)
+	if dst == nil || dst.IP == nil {
+		src = nil
+	}
+	var header []byte
+	switch version {
+	case ProxyProtocolV1:
+		header = proxyProtocolV1Header(src, dst)
+	case ProxyProtocolV2:
+		header = proxyProtocolV2Header(src, dst)
+	default:
+		return validateProxyProtocol(version)
+	}
+	_, err := conn.Write(header)
+	return err
+}
+
+func proxyProtocolV1Header(src net.IP, dst *net.TCPAddr) []byte {
+	if src == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+	if src.To4() != nil && dst.IP.To4() != nil {
+		return []byte(fmt.Sprintf("PROXY TCP4 %s %s 0 %d\r\n", src, dst.IP, dst.Port))
+	}
+	return []byte(fmt.Sprintf("PROXY TCP6 %s %s 0 %d\r\n", ipv6String(src), ipv6String(dst.IP), dst.Port))
+}
+
+// ipv6String formats ip as an IPv6 address, IPv4 addresses are mapped to IPv6.
+func ipv6String(ip net.IP) string {
+	if ip.To4() != nil {
+		return "::ffff:" + ip.String()
+	}
+	return ip.String()
+}
+
+func proxyProtocolV2Header(src net.IP, dst *net.TCPAddr) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	if src == nil {
+		// LOCAL command, the origin uses the address of the connection
+		return append(header, 0x20, 0x00, 0x00, 0x00)
+	}
+
+	var addresses []byte
+	if src4, dst4 := src.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		// PROXY command over TCP over IPv4
+		header = append(header, 0x21, 0x11)
+		addresses = append(append(addresses, src4...), dst4...)
+	} else {
+		// PROXY command over TCP over IPv6
+		header = append(header, 0x21, 0x21)
+		addresses = append(append(addresses, src.To16()...), dst.IP.To16()...)
+	}
+	addresses = binary.BigEndian.AppendUint16(addresses, 0)
+	addresses = binary.BigEndian.AppendUint16(addresses, uint16(dst.Port))
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+	return append(header, addresses...)
+}
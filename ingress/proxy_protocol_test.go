@@ -0,0 +1,138 @@
+package ingress
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestProxyProtocolV1Header(t *testing.T) {
+	dst4 := &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}
+	dst6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 8080}
+
+	assert.Equal(t, "PROXY TCP4 192.0.2.1 198.51.100.1 0 443\r\n", string(proxyProtocolV1Header(net.ParseIP("192.0.2.1"), dst4)))
+	assert.Equal(t, "PROXY TCP6 2001:db8::1 2001:db8::2 0 8080\r\n", string(proxyProtocolV1Header(net.ParseIP("2001:db8::1"), dst6)))
+	assert.Equal(t, "PROXY TCP6 ::ffff:192.0.2.1 2001:db8::2 0 8080\r\n", string(proxyProtocolV1Header(net.ParseIP("192.0.2.1"), dst6)))
+	assert.Equal(t, "PROXY UNKNOWN\r\n", string(proxyProtocolV1Header(nil, dst4)))
+}
+
+func TestProxyProtocolV2Header(t *testing.T) {
+	dst4 := &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}
+	expected := append([]byte("\r\n\r\n\x00\r\nQUIT\n"),
+		0x21, 0x11, 0x00, 0x0c,
+		192, 0, 2, 1,
+		198, 51, 100, 1,
+		0x00, 0x00,
+		0x01, 0xbb,
+	)
+	assert.Equal(t, expected, proxyProtocolV2Header(net.ParseIP("192.0.2.1"), dst4))
+
+	header := proxyProtocolV2Header(net.ParseIP("2001:db8::1"), dst4)
+	assert.Equal(t, []byte{0x21, 0x21, 0x00, 0x24}, header[12:16])
+	assert.Len(t, header, 16+36)
+
+	assert.Equal(t, append([]byte("\r\n\r\n\x00\r\nQUIT\n"), 0x20, 0x00, 0x00, 0x00), proxyProtocolV2Header(nil, dst4))
+}
+
+// proxyProtocolListener reads the PROXY protocol v1 header of the connections it accepts, and sends it to headers.
+type proxyProtocolListener struct {
+	net.Listener
+	headers chan string
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	l.headers <- header
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+func TestHTTPServiceProxyProtocol(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	origin := &proxyProtocolListener{Listener: listener, headers: make(chan string, 2)}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go func() { _ = server.Serve(origin) }()
+	defer server.Close()
+
+	originURL := &url.URL{Scheme: "http", Host: listener.Addr().String()}
+	service := &httpService{url: originURL}
+	cfg := originRequestFromConfig(config.OriginRequestConfig{})
+	cfg.ProxyProtocol = ProxyProtocolV1
+	require.NoError(t, service.start(testLogger, make(chan struct{}), cfg))
+
+	for _, clientIP := range []string{"192.0.2.1", "192.0.2.2"} {
+		req, err := http.NewRequest(http.MethodGet, originURL.String(), nil)
+		require.NoError(t, err)
+		req = req.WithContext(ContextWithClientIP(req.Context(), net.ParseIP(clientIP)))
+		resp, err := service.RoundTrip(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+
+		// Each request gets its own connection, with the header of its client
+		header := <-origin.headers
+		assert.True(t, strings.HasPrefix(header, "PROXY TCP4 "+clientIP+" 127.0.0.1 0 "), header)
+	}
+
+	cfg.Http2Origin = true
+	assert.Error(t, (&httpService{url: originURL}).start(testLogger, make(chan struct{}), cfg))
+}
+
+func TestTCPOverWSServiceProxyProtocol(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	headers := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		header := make([]byte, 16+12)
+		if _, err := io.ReadFull(conn, header); err == nil {
+			headers <- header
+		}
+	}()
+
+	service := newTCPOverWSService(&url.URL{Scheme: "tcp", Host: listener.Addr().String()})
+	cfg := originRequestFromConfig(config.OriginRequestConfig{})
+	cfg.ProxyProtocol = ProxyProtocolV2
+	require.NoError(t, service.start(testLogger, make(chan struct{}), cfg))
+
+	conn, err := service.EstablishConnection(ContextWithClientIP(context.Background(), net.ParseIP("192.0.2.1")), "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	header := <-headers
+	assert.Equal(t, proxyProtocolV2Signature, header[:12])
+	assert.Equal(t, []byte{192, 0, 2, 1, 127, 0, 0, 1}, header[16:24])
+}
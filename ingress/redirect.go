@@ -0,0 +1,75 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	ServiceRedirectPrefix = "redirect://"
+
+	redirectPathPlaceholder = "{path}"
+)
+
+// redirectService is an OriginService redirecting requests to another URL, e.g. to move a hostname without
+// keeping its origin. {path} in the target is replaced by the path of the request, and the query of the request is
+// kept.
+type redirectService struct {
+	target string
+}
+
+func newRedirectService(target string) (*redirectService, error) {
+	u, err := url.Parse(strings.ReplaceAll(target, redirectPathPlaceholder, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("redirect target %s must be an absolute http or https URL", target)
+	}
+	return &redirectService{target: target}, nil
+}
+
+func (o *redirectService) String() string {
+	return ServiceRedirectPrefix + o.target
+}
+
+func (o *redirectService) start(_ *zerolog.Logger, _ <-chan struct{}, _ OriginRequestConfig) error {
+	return nil
+}
+
+func (o *redirectService) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+// RoundTrip redirects permanently with a 301, or a 308 for requests that aren't GET or HEAD so that clients keep
+// their method and body.
+func (o *redirectService) RoundTrip(req *http.Request) (*http.Response, error) {
+	code := http.StatusMovedPermanently
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	header := http.Header{}
+	header.Set("Location", o.location(req))
+	return &http.Response{
+		StatusCode: code,
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		Header:     header,
+		Body:       new(NopReadCloser),
+	}, nil
+}
+
+func (o *redirectService) location(req *http.Request) string {
+	location := strings.ReplaceAll(o.target, redirectPathPlaceholder, req.URL.EscapedPath())
+	if req.URL.RawQuery == "" {
+		return location
+	}
+	if strings.Contains(location, "?") {
+		return location + "&" + req.URL.RawQuery
+	}
+	return location + "?" + req.URL.RawQuery
+}
@@ -0,0 +1,67 @@
+package ingress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectService(t *testing.T) {
+	tests := []struct {
+		target           string
+		method           string
+		url              string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			target:           "https://example.com{path}",
+			method:           http.MethodGet,
+			url:              "http://old.example.com/a/b%20c?x=1",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "https://example.com/a/b%20c?x=1",
+		},
+		{
+			target:           "https://example.com/new{path}",
+			method:           http.MethodPost,
+			url:              "http://old.example.com/form",
+			expectedStatus:   http.StatusPermanentRedirect,
+			expectedLocation: "https://example.com/new/form",
+		},
+		{
+			target:           "https://example.com/?utm_source=old",
+			method:           http.MethodHead,
+			url:              "http://old.example.com/ignored?x=1",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "https://example.com/?utm_source=old&x=1",
+		},
+	}
+	for _, test := range tests {
+		service, err := newRedirectService(test.target)
+		require.NoError(t, err)
+		resp, err := service.RoundTrip(httptest.NewRequest(test.method, test.url, nil))
+		require.NoError(t, err)
+		assert.Equal(t, test.expectedStatus, resp.StatusCode, test.url)
+		assert.Equal(t, test.expectedLocation, resp.Header.Get("Location"), test.url)
+	}
+}
+
+func TestParseRedirectService(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - service: redirect://https://example.com{path}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "redirect://https://example.com{path}", ing.Rules[0].Service.String())
+
+	for _, service := range []string{"redirect://example.com{path}", "redirect://ftp://example.com", "redirect://"} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+ - service: ` + service + `
+`))
+		assert.Error(t, err, service)
+	}
+}
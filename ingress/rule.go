@@ -25,6 +25,15 @@ type Rule struct {
 	// address.
 	Service OriginService `json:"service"`
 
+	// Mirror is an optional HTTP origin requests are duplicated to, whose responses are discarded.
+	Mirror OriginService `json:"mirror,omitempty"`
+
+	// Maintenance is the optional maintenance mode configuration of this rule.
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+
+	// ErrorPage is served when the origin can't be reached, if the rule config has one.
+	ErrorPage *ErrorPage `json:"-"`
+
 	// Handlers is a list of functions that acts as a middleware during ProxyHTTP
 	Handlers []middleware.Handler
 
@@ -67,6 +76,53 @@ func (r *Rule) Matches(hostname, path string) bool {
 	return (hostMatch || punycodeHostMatch) && pathMatch
 }
 
+// RewritePath returns the path to send to the origin for a request to path, which must match the rule, according
+// to the RewritePath and StripPrefix of the rule config.
+func (r *Rule) RewritePath(path string) string {
+	if rewrite := r.Config.RewritePath; rewrite != "" {
+		if r.Path == nil || r.Path.Regexp == nil {
+			return rewrite
+		}
+		match := r.Path.Regexp.FindStringSubmatchIndex(path)
+		if match == nil {
+			return path
+		}
+		return string(r.Path.Regexp.ExpandString(nil, rewrite, path, match))
+	}
+	if prefix := strings.TrimSuffix(r.Config.StripPrefix, "/"); prefix != "" && strings.HasPrefix(path, prefix) {
+		// Only strip whole path segments, /app shouldn't turn /application into /lication
+		if rest := strings.TrimPrefix(path, prefix); rest == "" || strings.HasPrefix(rest, "/") {
+			path = "/" + strings.TrimPrefix(rest, "/")
+		}
+	}
+	return path
+}
+
+// globToRegexp converts a glob path pattern to a regex matching the whole path. * matches any characters but /,
+// ** matches any characters and ? matches a single character but /.
+func globToRegexp(glob string) string {
+	var out strings.Builder
+	out.WriteRune('^')
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				out.WriteString(".*")
+				i++
+			} else {
+				out.WriteString("[^/]*")
+			}
+		case '?':
+			out.WriteString("[^/]")
+		default:
+			out.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	out.WriteRune('$')
+	return out.String()
+}
+
 // Regexp adds unmarshalling from json for regexp.Regexp
 type Regexp struct {
 	*regexp.Regexp
@@ -169,6 +169,119 @@ func Test_rule_matches(t *testing.T) {
 	}
 }
 
+func TestRewritePath(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		path string
+		want string
+	}{
+		{
+			name: "No rewrite",
+			rule: Rule{Path: &Regexp{Regexp: regexp.MustCompile("^/api/")}},
+			path: "/api/users",
+			want: "/api/users",
+		},
+		{
+			name: "Numbered capture groups",
+			rule: Rule{
+				Path:   &Regexp{Regexp: regexp.MustCompile("^/api/(.*)$")},
+				Config: OriginRequestConfig{RewritePath: "/$1"},
+			},
+			path: "/api/users",
+			want: "/users",
+		},
+		{
+			name: "Named capture groups",
+			rule: Rule{
+				Path:   &Regexp{Regexp: regexp.MustCompile(`^/api/v(?P<version>[0-9]+)/(?P<rest>.*)$`)},
+				Config: OriginRequestConfig{RewritePath: "/${rest}?version=${version}"},
+			},
+			path: "/api/v2/users",
+			want: "/users?version=2",
+		},
+		{
+			name: "No path",
+			rule: Rule{Config: OriginRequestConfig{RewritePath: "/index.html"}},
+			path: "/users",
+			want: "/index.html",
+		},
+		{
+			name: "Strip prefix",
+			rule: Rule{Config: OriginRequestConfig{StripPrefix: "/app"}},
+			path: "/app/users",
+			want: "/users",
+		},
+		{
+			name: "Strip whole path",
+			rule: Rule{Config: OriginRequestConfig{StripPrefix: "/app"}},
+			path: "/app",
+			want: "/",
+		},
+		{
+			name: "Strip prefix with a trailing slash",
+			rule: Rule{Config: OriginRequestConfig{StripPrefix: "/app/"}},
+			path: "/app/users",
+			want: "/users",
+		},
+		{
+			name: "Strip prefix only strips whole segments",
+			rule: Rule{Config: OriginRequestConfig{StripPrefix: "/app"}},
+			path: "/application",
+			want: "/application",
+		},
+		{
+			name: "Rewrite takes precedence over strip prefix",
+			rule: Rule{Config: OriginRequestConfig{StripPrefix: "/app", RewritePath: "/index.html"}},
+			path: "/app/users",
+			want: "/index.html",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.rule.RewritePath(tt.path))
+		})
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob       string
+		matches    []string
+		notMatches []string
+	}{
+		{
+			glob:       "/static/*.js",
+			matches:    []string{"/static/app.js", "/static/.js"},
+			notMatches: []string{"/static/js/app.js", "/static/app.jsx", "/other/static/app.js"},
+		},
+		{
+			glob:       "/static/**",
+			matches:    []string{"/static/", "/static/js/app.js"},
+			notMatches: []string{"/static"},
+		},
+		{
+			glob:       "/v?/users",
+			matches:    []string{"/v1/users", "/vö/users"},
+			notMatches: []string{"/v10/users", "/v/users"},
+		},
+		{
+			glob:       "/a.b+c",
+			matches:    []string{"/a.b+c"},
+			notMatches: []string{"/axb+c", "/a.bbc"},
+		},
+	}
+	for _, tt := range tests {
+		regex := regexp.MustCompile(globToRegexp(tt.glob))
+		for _, path := range tt.matches {
+			require.True(t, regex.MatchString(path), "%s should match %s", tt.glob, path)
+		}
+		for _, path := range tt.notMatches {
+			require.False(t, regex.MatchString(path), "%s shouldn't match %s", tt.glob, path)
+		}
+	}
+}
+
 func TestStaticHTTPStatus(t *testing.T) {
 	o := newStatusCode(404)
 	buf := make([]byte, 100)
@@ -204,25 +317,25 @@ func TestMarshalJSON(t *testing.T) {
 		{
 			name:     "Nil",
 			path:     nil,
-			expected: `{"hostname":"example.com","path":null,"service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"access":{"teamName":"","audTag":null}}}`,
+			expected: `{"hostname":"example.com","path":null,"service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","clientCert":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"tcpIdleTimeout":0,"responseHeaderTimeout":0,"maxRequestBodySize":0,"proxyProtocol":"","stripPrefix":"","rewritePath":"","requestHeaders":{},"responseHeaders":{},"cloudflareHeaders":{"strip":false},"circuitBreaker":{"failureThreshold":0,"cooldown":0},"retry":{"maxAttempts":0,"backoff":0},"concurrencyLimit":{"maxRequests":0,"queueTimeout":0,"maxQueued":0,"retryAfter":0},"rateLimit":{"requestsPerSecond":0,"burst":0,"perClientIP":false},"filter":{"responseStatus":0},"errorPage":{},"allowIPs":null,"denyIPs":null,"fileServer":{"listDirectories":false,"disableRanges":false,"serveDotFiles":false},"statusResponse":{},"cache":{"ttl":0,"maxSize":0},"bandwidthLimit":{"uploadBytesPerSecond":0,"downloadBytesPerSecond":0},"disableResponseBuffering":false,"flushInterval":0,"responseBuffer":{"maxSize":0},"disableAccessLog":false,"access":{"teamName":"","audTag":null}}}`,
 			want:     true,
 		},
 		{
 			name:     "Nil regex",
 			path:     &Regexp{Regexp: nil},
-			expected: `{"hostname":"example.com","path":null,"service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"access":{"teamName":"","audTag":null}}}`,
+			expected: `{"hostname":"example.com","path":null,"service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","clientCert":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"tcpIdleTimeout":0,"responseHeaderTimeout":0,"maxRequestBodySize":0,"proxyProtocol":"","stripPrefix":"","rewritePath":"","requestHeaders":{},"responseHeaders":{},"cloudflareHeaders":{"strip":false},"circuitBreaker":{"failureThreshold":0,"cooldown":0},"retry":{"maxAttempts":0,"backoff":0},"concurrencyLimit":{"maxRequests":0,"queueTimeout":0,"maxQueued":0,"retryAfter":0},"rateLimit":{"requestsPerSecond":0,"burst":0,"perClientIP":false},"filter":{"responseStatus":0},"errorPage":{},"allowIPs":null,"denyIPs":null,"fileServer":{"listDirectories":false,"disableRanges":false,"serveDotFiles":false},"statusResponse":{},"cache":{"ttl":0,"maxSize":0},"bandwidthLimit":{"uploadBytesPerSecond":0,"downloadBytesPerSecond":0},"disableResponseBuffering":false,"flushInterval":0,"responseBuffer":{"maxSize":0},"disableAccessLog":false,"access":{"teamName":"","audTag":null}}}`,
 			want:     true,
 		},
 		{
 			name:     "Empty",
 			path:     &Regexp{Regexp: regexp.MustCompile("")},
-			expected: `{"hostname":"example.com","path":"","service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"access":{"teamName":"","audTag":null}}}`,
+			expected: `{"hostname":"example.com","path":"","service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","clientCert":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"tcpIdleTimeout":0,"responseHeaderTimeout":0,"maxRequestBodySize":0,"proxyProtocol":"","stripPrefix":"","rewritePath":"","requestHeaders":{},"responseHeaders":{},"cloudflareHeaders":{"strip":false},"circuitBreaker":{"failureThreshold":0,"cooldown":0},"retry":{"maxAttempts":0,"backoff":0},"concurrencyLimit":{"maxRequests":0,"queueTimeout":0,"maxQueued":0,"retryAfter":0},"rateLimit":{"requestsPerSecond":0,"burst":0,"perClientIP":false},"filter":{"responseStatus":0},"errorPage":{},"allowIPs":null,"denyIPs":null,"fileServer":{"listDirectories":false,"disableRanges":false,"serveDotFiles":false},"statusResponse":{},"cache":{"ttl":0,"maxSize":0},"bandwidthLimit":{"uploadBytesPerSecond":0,"downloadBytesPerSecond":0},"disableResponseBuffering":false,"flushInterval":0,"responseBuffer":{"maxSize":0},"disableAccessLog":false,"access":{"teamName":"","audTag":null}}}`,
 			want:     true,
 		},
 		{
 			name:     "Basic",
 			path:     &Regexp{Regexp: regexp.MustCompile("/echo")},
-			expected: `{"hostname":"example.com","path":"/echo","service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"access":{"teamName":"","audTag":null}}}`,
+			expected: `{"hostname":"example.com","path":"/echo","service":"https://localhost:8000","Handlers":null,"originRequest":{"connectTimeout":30,"tlsTimeout":10,"tcpKeepAlive":30,"noHappyEyeballs":false,"keepAliveTimeout":90,"keepAliveConnections":100,"httpHostHeader":"","originServerName":"","caPool":"","clientCert":"","noTLSVerify":false,"disableChunkedEncoding":false,"bastionMode":false,"proxyAddress":"127.0.0.1","proxyPort":0,"proxyType":"","ipRules":null,"http2Origin":false,"tcpIdleTimeout":0,"responseHeaderTimeout":0,"maxRequestBodySize":0,"proxyProtocol":"","stripPrefix":"","rewritePath":"","requestHeaders":{},"responseHeaders":{},"cloudflareHeaders":{"strip":false},"circuitBreaker":{"failureThreshold":0,"cooldown":0},"retry":{"maxAttempts":0,"backoff":0},"concurrencyLimit":{"maxRequests":0,"queueTimeout":0,"maxQueued":0,"retryAfter":0},"rateLimit":{"requestsPerSecond":0,"burst":0,"perClientIP":false},"filter":{"responseStatus":0},"errorPage":{},"allowIPs":null,"denyIPs":null,"fileServer":{"listDirectories":false,"disableRanges":false,"serveDotFiles":false},"statusResponse":{},"cache":{"ttl":0,"maxSize":0},"bandwidthLimit":{"uploadBytesPerSecond":0,"downloadBytesPerSecond":0},"disableResponseBuffering":false,"flushInterval":0,"responseBuffer":{"maxSize":0},"disableAccessLog":false,"access":{"teamName":"","audTag":null}}}`,
 			want:     true,
 		},
 	}
@@ -0,0 +1,100 @@
+package ingress
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	SessionAffinityCookie   = "cookie"
+	SessionAffinityClientIP = "client_ip"
+
+	defaultSessionAffinityCookieName = "cloudflared_origin"
+)
+
+// sessionAffinity sends the requests of a client to the same origin of a LoadBalancedService, so that stateful
+// origins don't see users bounce between them.
+type sessionAffinity struct {
+	raw        config.SessionAffinityConfig
+	cookieName string
+}
+
+func newSessionAffinity(raw config.SessionAffinityConfig) (*sessionAffinity, error) {
+	switch raw.Type {
+	case SessionAffinityCookie, SessionAffinityClientIP:
+	default:
+		return nil, fmt.Errorf("unknown session affinity type %s, expected %s or %s", raw.Type,
+			SessionAffinityCookie, SessionAffinityClientIP)
+	}
+	affinity := &sessionAffinity{
+		raw:        raw,
+		cookieName: raw.CookieName,
+	}
+	if affinity.cookieName == "" {
+		affinity.cookieName = defaultSessionAffinityCookieName
+	}
+	return affinity, nil
+}
+
+// pinned returns the origin among origins the client of req sticks to, or nil if it doesn't stick to any yet.
+func (a *sessionAffinity) pinned(req *http.Request, origins []*balancedOrigin) *balancedOrigin {
+	switch a.raw.Type {
+	case SessionAffinityCookie:
+		cookie, err := req.Cookie(a.cookieName)
+		if err != nil {
+			return nil
+		}
+		for _, origin := range origins {
+			if originToken(origin) == cookie.Value {
+				return origin
+			}
+		}
+		return nil
+	default:
+		clientIP := req.Header.Get("Cf-Connecting-Ip")
+		if clientIP == "" {
+			return nil
+		}
+		// Rendezvous hashing, so that only the clients of an origin move when it's added, removed or unhealthy
+		var (
+			pinned *balancedOrigin
+			best   uint64
+		)
+		for _, origin := range origins {
+			if score := fnvHash(clientIP + "|" + origin.String()); pinned == nil || score > best {
+				pinned, best = origin, score
+			}
+		}
+		return pinned
+	}
+}
+
+// stick makes the next requests of the client go to origin, which resp comes from.
+func (a *sessionAffinity) stick(resp *http.Response, origin *balancedOrigin) {
+	if a.raw.Type != SessionAffinityCookie {
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     a.cookieName,
+		Value:    originToken(origin),
+		Path:     "/",
+		HttpOnly: true,
+	}
+	resp.Header.Add("Set-Cookie", cookie.String())
+}
+
+// originToken identifies an origin in cookies without revealing its address. It's stable across restarts and
+// configuration changes.
+func originToken(origin *balancedOrigin) string {
+	return strconv.FormatUint(fnvHash(origin.String()), 16)
+}
+
+func fnvHash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
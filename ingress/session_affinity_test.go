@@ -0,0 +1,106 @@
+package ingress
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestParseSessionAffinity(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - service:
+     - http://localhost:8000
+     - http://localhost:8001
+   sessionAffinity:
+     type: cookie
+`))
+	require.NoError(t, err)
+	balanced, ok := ing.Rules[0].Service.(*LoadBalancedService)
+	require.True(t, ok)
+	assert.Equal(t, &config.SessionAffinityConfig{Type: SessionAffinityCookie}, balanced.SessionAffinity())
+	assert.Equal(t, defaultSessionAffinityCookieName, balanced.affinity.cookieName)
+
+	for _, rawYAML := range []string{`
+ingress:
+ - service:
+     - http://localhost:8000
+     - http://localhost:8001
+   sessionAffinity:
+     type: header
+`, `
+ingress:
+ - service: http://localhost:8000
+   sessionAffinity:
+     type: cookie
+`} {
+		_, err := ParseIngress(MustReadIngress(rawYAML))
+		assert.Error(t, err, rawYAML)
+	}
+}
+
+func newTestAffinityService(t *testing.T, affinityType string) *LoadBalancedService {
+	balanced := newTestLoadBalancedService(t, LoadBalancingRoundRobin, 3)
+	affinity, err := newSessionAffinity(config.SessionAffinityConfig{Type: affinityType, CookieName: "sticky"})
+	require.NoError(t, err)
+	balanced.affinity = affinity
+	return balanced
+}
+
+func TestSessionAffinityCookie(t *testing.T) {
+	balanced := newTestAffinityService(t, SessionAffinityCookie)
+
+	resp := balancedRoundTrip(t, balanced)
+	origin := readOrigin(t, resp)
+	cookies := resp.Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "sticky", cookies[0].Name)
+
+	for i := 0; i < 6; i++ {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		req.AddCookie(cookies[0])
+		resp, err := balanced.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, origin, readOrigin(t, resp))
+		// The cookie is only set when the client doesn't stick to an origin yet
+		assert.Empty(t, resp.Cookies())
+	}
+
+	// Clients sticking to an origin that's gone are balanced again
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: "sticky", Value: "unknown"})
+	resp, err = balanced.RoundTrip(req)
+	require.NoError(t, err)
+	_ = readOrigin(t, resp)
+	assert.Len(t, resp.Cookies(), 1)
+}
+
+func TestSessionAffinityClientIP(t *testing.T) {
+	balanced := newTestAffinityService(t, SessionAffinityClientIP)
+
+	originOf := func(clientIP string) string {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		req.Header.Set("Cf-Connecting-Ip", clientIP)
+		resp, err := balanced.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Empty(t, resp.Cookies())
+		return readOrigin(t, resp)
+	}
+
+	origins := make(map[string]bool)
+	for i := 0; i < 32; i++ {
+		clientIP := fmt.Sprintf("192.0.2.%d", i)
+		origin := originOf(clientIP)
+		assert.Equal(t, origin, originOf(clientIP))
+		origins[origin] = true
+	}
+	assert.Greater(t, len(origins), 1)
+}
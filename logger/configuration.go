@@ -2,6 +2,8 @@ package logger
 
 import (
 	"path/filepath"
+
+	"github.com/rs/zerolog"
 )
 
 var defaultConfig = createDefaultConfig()
@@ -13,6 +15,10 @@ type Config struct {
 	RollingConfig *RollingConfig // If nil, the logger will not use a rolling log
 
 	MinLevel string // debug | info | error | fatal
+
+	Outputs []string // URLs of the other destinations of the logs, e.g. syslog://logs.example.com
+
+	Sampling map[zerolog.Level]SamplingConfig // If nil, all the events of the enabled levels are logged
 }
 
 type ConsoleConfig struct {
@@ -22,6 +28,8 @@ type ConsoleConfig struct {
 type FileConfig struct {
 	Dirname  string
 	Filename string
+
+	rotation RotationConfig
 }
 
 func (fc *FileConfig) Fullpath() string {
@@ -37,6 +45,40 @@ type RollingConfig struct {
 	maxAge     int // days
 }
 
+// RotationConfig rotates a log file once it grows past MaxSize, keeping MaxBackups of the previous files for MaxAge.
+// Files aren't rotated if all are 0.
+type RotationConfig struct {
+	MaxSize    int // megabytes, 0 means 100
+	MaxAge     int // days, 0 means forever
+	MaxBackups int // files, 0 means all
+}
+
+func (rc RotationConfig) enabled() bool {
+	return rc.MaxSize > 0 || rc.MaxAge > 0 || rc.MaxBackups > 0
+}
+
+// setRotation makes the log file rotate as configured by rotation, if it enables rotation. The files of the log
+// directory always rotate, the settings of rotation override the defaults.
+func (c *Config) setRotation(rotation RotationConfig) {
+	if !rotation.enabled() {
+		return
+	}
+	if c.FileConfig != nil {
+		c.FileConfig.rotation = rotation
+	}
+	if c.RollingConfig != nil {
+		if rotation.MaxSize > 0 {
+			c.RollingConfig.maxSize = rotation.MaxSize
+		}
+		if rotation.MaxAge > 0 {
+			c.RollingConfig.maxAge = rotation.MaxAge
+		}
+		if rotation.MaxBackups > 0 {
+			c.RollingConfig.maxBackups = rotation.MaxBackups
+		}
+	}
+}
+
 func createDefaultConfig() Config {
 	const minLevel = "info"
 
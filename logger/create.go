@@ -28,6 +28,11 @@ const (
 	LogFileFlag           = "logfile"
 	LogDirectoryFlag      = "log-directory"
 	LogTransportLevelFlag = "transport-loglevel"
+	LogMaxSizeFlag        = "log-max-size"
+	LogMaxAgeFlag         = "log-max-age"
+	LogMaxBackupsFlag     = "log-max-backups"
+	LogOutputFlag         = "log-output"
+	LogSampleFlag         = "log-sample"
 
 	LogSSHDirectoryFlag = "log-directory"
 	LogSSHLevelFlag     = "log-level"
@@ -124,6 +129,19 @@ func newZerolog(loggerConfig *Config) *zerolog.Logger {
 		writers = append(writers, rollingLogger)
 	}
 
+	for _, output := range loggerConfig.Outputs {
+		outputWriter, err := newOutputWriter(output)
+		if err != nil {
+			return fallbackLogger(err)
+		}
+
+		writers = append(writers, outputWriter)
+	}
+
+	if len(loggerConfig.Sampling) > 0 {
+		writers = []io.Writer{newSamplingWriter(writers, loggerConfig.Sampling)}
+	}
+
 	var managementWriter zerolog.LevelWriter
 	if features.Contains(features.FeatureManagementLogs) {
 		managementWriter = ManagementLogger
@@ -172,14 +190,30 @@ func createFromContext(
 		logDirectory,
 		logFile,
 	)
+	loggerConfig.setRotation(RotationConfigFromContext(c))
+	loggerConfig.Outputs = c.StringSlice(LogOutputFlag)
+	sampling, samplingErr := ParseSamplingConfig(c.StringSlice(LogSampleFlag))
+	loggerConfig.Sampling = sampling
 
 	log := newZerolog(loggerConfig)
+	if samplingErr != nil {
+		log.Error().Err(samplingErr).Msgf("Failed to parse %s, logging all events", LogSampleFlag)
+	}
 	if incompatibleFlagsSet := logFile != "" && logDirectory != ""; incompatibleFlagsSet {
 		log.Error().Msgf("Your config includes values for both %s and %s, but they are incompatible. %s takes precedence.", LogFileFlag, logDirectoryFlagName, LogFileFlag)
 	}
 	return log
 }
 
+// RotationConfigFromContext returns the rotation of log files configured by the log-max-* flags.
+func RotationConfigFromContext(c *cli.Context) RotationConfig {
+	return RotationConfig{
+		MaxSize:    c.Int(LogMaxSizeFlag),
+		MaxAge:     c.Int(LogMaxAgeFlag),
+		MaxBackups: c.Int(LogMaxBackupsFlag),
+	}
+}
+
 func Create(loggerConfig *Config) *zerolog.Logger {
 	if loggerConfig == nil {
 		loggerConfig = &Config{
@@ -187,6 +221,8 @@ func Create(loggerConfig *Config) *zerolog.Logger {
 			nil,
 			nil,
 			defaultConfig.MinLevel,
+			nil,
+			nil,
 		}
 	}
 	return newZerolog(loggerConfig)
@@ -214,6 +250,10 @@ var (
 
 func createFileWriter(config FileConfig) (io.Writer, error) {
 	singleFileInit.once.Do(func() {
+		if config.rotation.enabled() {
+			singleFileInit.writer, singleFileInit.creationError = NewFileWriter(config.Fullpath(), config.rotation)
+			return
+		}
 
 		var logFile io.Writer
 		fullpath := config.Fullpath()
@@ -237,6 +277,25 @@ func createFileWriter(config FileConfig) (io.Writer, error) {
 	return singleFileInit.writer, singleFileInit.creationError
 }
 
+// NewFileWriter returns a writer appending to the file at fullpath, which is rotated as configured by rotation.
+func NewFileWriter(fullpath string, rotation RotationConfig) (io.Writer, error) {
+	dirname, filename := filepath.Split(fullpath)
+	if !rotation.enabled() {
+		return createDirFile(FileConfig{Dirname: dirname, Filename: filename})
+	}
+	if dirname != "" {
+		if err := os.MkdirAll(dirname, dirPermMode); err != nil {
+			return nil, fmt.Errorf("unable to create directories for new logfile: %s", err)
+		}
+	}
+	return &lumberjack.Logger{
+		Filename:   fullpath,
+		MaxSize:    rotation.MaxSize,
+		MaxAge:     rotation.MaxAge,
+		MaxBackups: rotation.MaxBackups,
+	}, nil
+}
+
 func createDirFile(config FileConfig) (io.Writer, error) {
 	if config.Dirname != "" {
 		err := os.MkdirAll(config.Dirname, dirPermMode)
@@ -1,12 +1,16 @@
 package logger
 
 import (
+	"bytes"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockedWriter struct {
@@ -130,3 +134,35 @@ func TestResilientMultiWriter_Management(t *testing.T) {
 		})
 	}
 }
+
+func TestNewFileWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewFileWriter(filepath.Join(dir, "logs", "cloudflared.log"), RotationConfig{MaxSize: 1})
+	require.NoError(t, err)
+	defer w.(io.Closer).Close()
+
+	line := bytes.Repeat([]byte("a"), 600*1024)
+	for i := 0; i < 2; i++ {
+		_, err = w.Write(line)
+		require.NoError(t, err)
+	}
+	// The second write rotated the file past 1 megabyte
+	files, err := os.ReadDir(filepath.Join(dir, "logs"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestSetRotation(t *testing.T) {
+	config := CreateConfig("info", DisableTerminalLog, "", "/var/log/cloudflared.log")
+	config.setRotation(RotationConfig{})
+	assert.False(t, config.FileConfig.rotation.enabled())
+
+	config.setRotation(RotationConfig{MaxAge: 7})
+	assert.Equal(t, RotationConfig{MaxAge: 7}, config.FileConfig.rotation)
+
+	// Only the settings that are set override the defaults of the log directory
+	config = CreateConfig("info", DisableTerminalLog, "/var/log/cloudflared", "")
+	config.setRotation(RotationConfig{MaxBackups: 10})
+	assert.Equal(t, defaultConfig.RollingConfig.maxSize, config.RollingConfig.maxSize)
+	assert.Equal(t, 10, config.RollingConfig.maxBackups)
+}
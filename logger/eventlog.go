@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+)
+
+// LogFieldEventID identifies the events that are written to the Windows Event Log, which administrators can filter
+// and alert on by ID.
+const LogFieldEventID = "eventID"
+
+// IDs of the events of the Windows Event Log. IDs up to 99 are used by the Windows service.
+const (
+	EventIDError = 2 // Errors that don't have a more specific ID
+
+	EventIDTunnelRegistered   = 100
+	EventIDTunnelUnregistered = 101
+	EventIDTunnelDisconnected = 102
+
+	EventIDConfigUpdated = 200
+	EventIDConfigError   = 201
+)
+
+// eventLogEvent returns the ID, level and message of the JSON event p in the Windows Event Log. Only the events that
+// have an ID, and errors, are written to it, the message is followed by the event as JSON.
+func eventLogEvent(p []byte) (id uint32, level zerolog.Level, msg string, ok bool) {
+	event := trimNewline(p)
+	var fields struct {
+		EventID uint32 `json:"eventID"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return 0, zerolog.NoLevel, "", false
+	}
+	level = eventLevel(event)
+	id = fields.EventID
+	if id == 0 {
+		if level < zerolog.ErrorLevel {
+			return 0, level, "", false
+		}
+		id = EventIDError
+	}
+	return id, level, fields.Message + "\n\n" + string(event), true
+}
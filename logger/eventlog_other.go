@@ -0,0 +1,13 @@
+//go:build !windows
+
+package logger
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+func newEventLogWriter() (io.Writer, error) {
+	return nil, fmt.Errorf("the Windows Event Log isn't available on %s", runtime.GOOS)
+}
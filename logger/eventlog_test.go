@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventLogEvent(t *testing.T) {
+	output := &bytes.Buffer{}
+	log := zerolog.New(output)
+
+	log.Info().Int(LogFieldEventID, EventIDTunnelRegistered).Uint8("connIndex", 0).Msg("Registered tunnel connection")
+	id, level, msg, ok := eventLogEvent(output.Bytes())
+	assert.True(t, ok)
+	assert.Equal(t, uint32(EventIDTunnelRegistered), id)
+	assert.Equal(t, zerolog.InfoLevel, level)
+	assert.Equal(t, "Registered tunnel connection\n\n"+
+		`{"level":"info","eventID":100,"connIndex":0,"message":"Registered tunnel connection"}`, msg)
+
+	// Errors without an ID are written with the generic one
+	output.Reset()
+	log.Error().Msg("Failed to fetch features")
+	id, level, _, ok = eventLogEvent(output.Bytes())
+	assert.True(t, ok)
+	assert.Equal(t, uint32(EventIDError), id)
+	assert.Equal(t, zerolog.ErrorLevel, level)
+
+	// Other events aren't written
+	output.Reset()
+	log.Info().Msg("Starting metrics server")
+	_, _, _, ok = eventLogEvent(output.Bytes())
+	assert.False(t, ok)
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package logger
+
+import (
+	"io"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// The source installed by the Windows service
+const eventLogSource = "Cloudflared"
+
+// eventLogWriter writes log events to the Windows Event Log.
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+func newEventLogWriter() (io.Writer, error) {
+	log, err := eventlog.Open(eventLogSource)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLogWriter{log: log}, nil
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	id, level, msg, ok := eventLogEvent(p)
+	if !ok {
+		return len(p), nil
+	}
+	var err error
+	switch {
+	case level >= zerolog.ErrorLevel:
+		err = w.log.Error(id, msg)
+	case level == zerolog.WarnLevel:
+		err = w.log.Warning(id, msg)
+	default:
+		err = w.log.Info(id, msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
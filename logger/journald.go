@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+const defaultJournaldSocket = "/run/systemd/journal/socket"
+
+// journaldWriter sends log events to journald over its native protocol, so that their fields are structured fields
+// of the journal entries, e.g. CFRAY or CONNINDEX. Events must fit in a datagram.
+type journaldWriter struct {
+	socket string
+
+	lock sync.Mutex
+	conn net.Conn
+}
+
+func newJournaldWriter(socket string) *journaldWriter {
+	return &journaldWriter{socket: socket}
+}
+
+func (w *journaldWriter) Write(p []byte) (int, error) {
+	entry := journaldEntry(trimNewline(p))
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.conn == nil {
+		conn, err := net.Dial("unixgram", w.socket)
+		if err != nil {
+			return 0, err
+		}
+		w.conn = conn
+	}
+	if _, err := w.conn.Write(entry); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// journaldEntry returns the fields of the JSON event p in the native journal protocol. The message and the level are
+// MESSAGE and PRIORITY, the other fields are named after their uppercased keys.
+func journaldEntry(p []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(p, &fields); err != nil {
+		fields = map[string]json.RawMessage{}
+	}
+	entry := &bytes.Buffer{}
+	message, ok := fields[zerolog.MessageFieldName]
+	if ok {
+		writeJournaldField(entry, "MESSAGE", journaldValue(message))
+	} else {
+		writeJournaldField(entry, "MESSAGE", string(p))
+	}
+	writeJournaldField(entry, "PRIORITY", strconv.Itoa(syslogSeverity(eventLevel(p))))
+	writeJournaldField(entry, "SYSLOG_IDENTIFIER", syslogAppName)
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		switch key {
+		case zerolog.MessageFieldName, zerolog.LevelFieldName, zerolog.TimestampFieldName:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if name := journaldFieldName(key); name != "" {
+			writeJournaldField(entry, name, journaldValue(fields[key]))
+		}
+	}
+	return entry.Bytes()
+}
+
+// journaldFieldName returns key as a journal field name, which only has uppercase letters, digits and underscores,
+// and doesn't start with an underscore or a digit. It's empty if there's no such name.
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// journaldValue returns strings unquoted, and other JSON values as they are.
+func journaldValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// writeJournaldField writes a field as NAME=value, or in the binary form if the value spans lines.
+func writeJournaldField(entry *bytes.Buffer, name, value string) {
+	entry.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		entry.WriteByte('=')
+		entry.WriteString(value)
+		entry.WriteByte('\n')
+		return
+	}
+	entry.WriteByte('\n')
+	_ = binary.Write(entry, binary.LittleEndian, uint64(len(value)))
+	entry.WriteString(value)
+	entry.WriteByte('\n')
+}
@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// LogFieldSuppressed is the number of identical events that weren't logged since the previous one that was
+	LogFieldSuppressed = "suppressed"
+
+	// Identical events are counted over this period
+	samplingPeriod = time.Minute
+	// Most distinct messages counted over a period, the events with further ones are all logged
+	maxSampledMessages = 1000
+)
+
+// SamplingConfig limits how many identical events of a level, i.e. events with the same message, are logged every
+// minute: the First ones are, then one in Thereafter, or none if it's 0.
+type SamplingConfig struct {
+	First      uint64
+	Thereafter uint64
+}
+
+// ParseSamplingConfig parses the sampling of levels given as LEVEL=FIRST:THEREAFTER, e.g. error=10:100.
+func ParseSamplingConfig(values []string) (map[zerolog.Level]SamplingConfig, error) {
+	sampling := make(map[zerolog.Level]SamplingConfig, len(values))
+	for _, value := range values {
+		levelName, counts, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log sampling %s, expected LEVEL=FIRST:THEREAFTER", value)
+		}
+		level, err := zerolog.ParseLevel(levelName)
+		if err != nil || level == zerolog.NoLevel {
+			return nil, fmt.Errorf("invalid log sampling %s, unknown level %s", value, levelName)
+		}
+		first, thereafter, ok := strings.Cut(counts, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid log sampling %s, expected LEVEL=FIRST:THEREAFTER", value)
+		}
+		var cfg SamplingConfig
+		if cfg.First, err = strconv.ParseUint(first, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid log sampling %s, FIRST isn't a number", value)
+		}
+		if cfg.Thereafter, err = strconv.ParseUint(thereafter, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid log sampling %s, THEREAFTER isn't a number", value)
+		}
+		sampling[level] = cfg
+	}
+	return sampling, nil
+}
+
+type sampleKey struct {
+	level   zerolog.Level
+	message string
+}
+
+type sampleCount struct {
+	seen       uint64
+	suppressed uint64
+}
+
+// samplingWriter drops the events of the sampled levels that repeat too often, so that failure storms, e.g. during
+// edge outages, don't flood the logs. The events that are written after some were dropped tell how many were.
+type samplingWriter struct {
+	writers  []io.Writer
+	sampling map[zerolog.Level]SamplingConfig
+	now      func() time.Time
+
+	lock        sync.Mutex
+	periodStart time.Time
+	counts      map[sampleKey]*sampleCount
+}
+
+func newSamplingWriter(writers []io.Writer, sampling map[zerolog.Level]SamplingConfig) *samplingWriter {
+	return &samplingWriter{
+		writers:  writers,
+		sampling: sampling,
+		now:      time.Now,
+		counts:   make(map[sampleKey]*sampleCount),
+	}
+}
+
+func (w *samplingWriter) Write(p []byte) (int, error) {
+	if event := w.sample(p); event != nil {
+		for _, writer := range w.writers {
+			_, _ = writer.Write(event)
+		}
+	}
+	return len(p), nil
+}
+
+// sample returns the event to write for p, which counts the events that were dropped before it, or nil if p is
+// dropped.
+func (w *samplingWriter) sample(p []byte) []byte {
+	var event struct {
+		Level   string `json:"level"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(trimNewline(p), &event); err != nil {
+		return p
+	}
+	level, err := zerolog.ParseLevel(event.Level)
+	if err != nil {
+		return p
+	}
+	cfg, ok := w.sampling[level]
+	if !ok {
+		return p
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if now := w.now(); now.Sub(w.periodStart) >= samplingPeriod {
+		w.periodStart = now
+		w.counts = make(map[sampleKey]*sampleCount)
+	}
+	key := sampleKey{level: level, message: event.Message}
+	count, ok := w.counts[key]
+	if !ok {
+		if len(w.counts) >= maxSampledMessages {
+			return p
+		}
+		count = &sampleCount{}
+		w.counts[key] = count
+	}
+	count.seen++
+	if count.seen > cfg.First && (cfg.Thereafter == 0 || (count.seen-cfg.First)%cfg.Thereafter != 0) {
+		count.suppressed++
+		return nil
+	}
+	suppressed := count.suppressed
+	count.suppressed = 0
+	if suppressed == 0 {
+		return p
+	}
+	return withSuppressed(p, suppressed)
+}
+
+// withSuppressed adds LogFieldSuppressed to the JSON event p.
+func withSuppressed(p []byte, suppressed uint64) []byte {
+	end := bytes.LastIndexByte(p, '}')
+	if end < 0 {
+		return p
+	}
+	event := make([]byte, 0, len(p)+32)
+	event = append(event, p[:end]...)
+	if end > 0 && p[end-1] != '{' {
+		event = append(event, ',')
+	}
+	event = append(event, fmt.Sprintf("%q:%d", LogFieldSuppressed, suppressed)...)
+	return append(event, p[end:]...)
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSamplingConfig(t *testing.T) {
+	sampling, err := ParseSamplingConfig([]string{"error=10:100", "warn=5:0"})
+	require.NoError(t, err)
+	assert.Equal(t, map[zerolog.Level]SamplingConfig{
+		zerolog.ErrorLevel: {First: 10, Thereafter: 100},
+		zerolog.WarnLevel:  {First: 5},
+	}, sampling)
+
+	for _, invalid := range []string{"error", "error=10", "loud=10:100", "error=ten:100", "error=10:-1"} {
+		_, err := ParseSamplingConfig([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSamplingWriter(t *testing.T) {
+	output := &bytes.Buffer{}
+	w := newSamplingWriter([]io.Writer{output}, map[zerolog.Level]SamplingConfig{
+		zerolog.ErrorLevel: {First: 2, Thereafter: 3},
+	})
+	now := time.Now()
+	w.now = func() time.Time { return now }
+	log := zerolog.New(w)
+
+	for i := 0; i < 8; i++ {
+		log.Error().Int("connIndex", i%4).Msg("Connection terminated")
+		// Other levels and messages aren't sampled
+		log.Info().Msg("Retrying connection")
+	}
+	log.Error().Msg("Failed to fetch features")
+
+	var errors []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		if event["level"] == "error" {
+			errors = append(errors, event)
+		}
+	}
+	// The first 2, then the 5th and the 8th
+	require.Len(t, errors, 5)
+	assert.NotContains(t, errors[0], LogFieldSuppressed)
+	assert.NotContains(t, errors[1], LogFieldSuppressed)
+	assert.Equal(t, float64(2), errors[2][LogFieldSuppressed])
+	assert.Equal(t, float64(2), errors[3][LogFieldSuppressed])
+	assert.Equal(t, "Failed to fetch features", errors[4]["message"])
+	assert.Equal(t, 8, strings.Count(output.String(), "Retrying connection"))
+
+	// Counts start over every period
+	now = now.Add(samplingPeriod)
+	output.Reset()
+	log.Error().Msg("Connection terminated")
+	assert.NotEmpty(t, output.String())
+}
+
+func TestSamplingWriterDropsAllAfterFirst(t *testing.T) {
+	output := &bytes.Buffer{}
+	w := newSamplingWriter([]io.Writer{output}, map[zerolog.Level]SamplingConfig{
+		zerolog.WarnLevel: {First: 1},
+	})
+	log := zerolog.New(w)
+	for i := 0; i < 10; i++ {
+		log.Warn().Msg("Slow origin")
+	}
+	assert.Equal(t, 1, strings.Count(output.String(), "Slow origin"))
+}
+
+func TestWithSuppressed(t *testing.T) {
+	assert.Equal(t, `{"level":"error","suppressed":3}`+"\n", string(withSuppressed([]byte(`{"level":"error"}`+"\n"), 3)))
+	assert.Equal(t, `{"suppressed":1}`, string(withSuppressed([]byte(`{}`), 1)))
+}
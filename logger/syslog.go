@@ -0,0 +1,214 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	syslogAppName        = "cloudflared"
+	syslogFacilityDaemon = 3
+	defaultSyslogPort    = "514"
+	defaultSyslogSocket  = "/dev/log"
+	syslogDialTimeout    = 5 * time.Second
+	syslogWriteTimeout   = 5 * time.Second
+	// Most messages waiting to be sent to a syslog server, the events logged while it's full are dropped
+	syslogQueueSize = 1000
+)
+
+var errSyslogQueueFull = errors.New("syslog queue is full")
+
+// syslogWriter sends log events to a syslog server as RFC5424 messages, whose MSG is the event as JSON. Messages are
+// sent in the background, so that logging doesn't wait on the server, e.g. while it's down and connecting times out.
+// It connects lazily, and again after a write failed, so that logging doesn't depend on the server being up.
+type syslogWriter struct {
+	network  string
+	address  string
+	hostname string
+	pid      int
+	dial     func(network, address string) (net.Conn, error)
+
+	messages chan []byte
+	// Only used by the sending goroutine
+	conn net.Conn
+}
+
+func newSyslogWriter(network, address string) *syslogWriter {
+	return startSyslogWriter(network, address, func(network, address string) (net.Conn, error) {
+		return net.DialTimeout(network, address, syslogDialTimeout)
+	})
+}
+
+func startSyslogWriter(network, address string, dial func(network, address string) (net.Conn, error)) *syslogWriter {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	w := &syslogWriter{
+		network:  network,
+		address:  address,
+		hostname: hostname,
+		pid:      os.Getpid(),
+		dial:     dial,
+		messages: make(chan []byte, syslogQueueSize),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues the message of the event p, it's dropped if the queue is full.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	select {
+	case w.messages <- w.format(p):
+		return len(p), nil
+	default:
+		return 0, errSyslogQueueFull
+	}
+}
+
+// run sends the queued messages. The messages that fail to be sent are dropped.
+func (w *syslogWriter) run() {
+	for msg := range w.messages {
+		_ = w.send(msg)
+	}
+}
+
+func (w *syslogWriter) send(msg []byte) error {
+	if w.conn == nil {
+		conn, err := w.dial(w.network, w.address)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+	_ = w.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if _, err := w.conn.Write(msg); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+// format returns the RFC5424 message of the event p. Messages sent over TCP are framed by octet counting, as in
+// RFC6587, since events can span lines.
+func (w *syslogWriter) format(p []byte) []byte {
+	event := trimNewline(p)
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s", syslogFacilityDaemon*8+syslogSeverity(eventLevel(event)),
+		time.Now().UTC().Format(time.RFC3339Nano), w.hostname, syslogAppName, w.pid, event)
+	if w.network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	return []byte(msg)
+}
+
+// syslogSeverity returns the syslog severity of a zerolog level, which journald uses as priority too.
+func syslogSeverity(level zerolog.Level) int {
+	switch level {
+	case zerolog.PanicLevel:
+		return 1 // alert
+	case zerolog.FatalLevel:
+		return 2 // critical
+	case zerolog.ErrorLevel:
+		return 3 // error
+	case zerolog.WarnLevel:
+		return 4 // warning
+	case zerolog.DebugLevel, zerolog.TraceLevel:
+		return 7 // debug
+	default:
+		return 6 // informational
+	}
+}
+
+// eventLevel returns the level of the JSON event p, or info if it doesn't have one.
+func eventLevel(p []byte) zerolog.Level {
+	var event struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(p, &event); err != nil {
+		return zerolog.InfoLevel
+	}
+	level, err := zerolog.ParseLevel(event.Level)
+	if err != nil || level == zerolog.NoLevel {
+		return zerolog.InfoLevel
+	}
+	return level
+}
+
+func trimNewline(p []byte) []byte {
+	if len(p) > 0 && p[len(p)-1] == '\n' {
+		return p[:len(p)-1]
+	}
+	return p
+}
+
+var (
+	outputWritersLock sync.Mutex
+	// The writers of the outputs, by output, so that loggers created with the same outputs share connections
+	outputWriters = make(map[string]io.Writer)
+)
+
+// newOutputWriter returns the writer of a log output, one of:
+//   - syslog://host[:port] or syslog+udp://host[:port], a syslog server over UDP
+//   - syslog+tcp://host[:port], a syslog server over TCP
+//   - syslog:// or syslog+unix:///path, the local syslog server, at /dev/log by default
+//   - journald://, the local journald
+//   - eventlog://, the Windows Event Log
+func newOutputWriter(output string) (io.Writer, error) {
+	outputWritersLock.Lock()
+	defer outputWritersLock.Unlock()
+	if w, ok := outputWriters[output]; ok {
+		return w, nil
+	}
+
+	u, err := url.Parse(output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log output %s: %s", output, err)
+	}
+	var w io.Writer
+	switch u.Scheme {
+	case "syslog", "syslog+udp", "syslog+tcp":
+		network := strings.TrimPrefix(strings.TrimPrefix(u.Scheme, "syslog"), "+")
+		if u.Host == "" {
+			if network != "" {
+				return nil, fmt.Errorf("invalid log output %s: missing syslog server address", output)
+			}
+			w = newSyslogWriter("unixgram", defaultSyslogSocket)
+			break
+		}
+		if network == "" {
+			network = "udp"
+		}
+		address := u.Host
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), defaultSyslogPort)
+		}
+		w = newSyslogWriter(network, address)
+	case "syslog+unix":
+		socket := u.Path
+		if socket == "" {
+			socket = defaultSyslogSocket
+		}
+		w = newSyslogWriter("unixgram", socket)
+	case "journald":
+		w = newJournaldWriter(defaultJournaldSocket)
+	case "eventlog":
+		if w, err = newEventLogWriter(); err != nil {
+			return nil, fmt.Errorf("invalid log output %s: %s", output, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown log output %s, expected a syslog://, syslog+udp://, syslog+tcp://, syslog+unix://, journald:// or eventlog:// URL", output)
+	}
+	outputWriters[output] = w
+	return w, nil
+}
@@ -0,0 +1,183 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rfc5424Message = regexp.MustCompile(`^<(\d+)>1 \S+ \S+ cloudflared \d+ - - (.*)$`)
+
+func TestSyslogWriterUDP(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	log := zerolog.New(newSyslogWriter("udp", server.LocalAddr().String()))
+	log.Warn().Str("cfRay", "abcdef-SJC").Msg("Connection lost")
+
+	buf := make([]byte, 1024)
+	n, _, err := server.ReadFrom(buf)
+	require.NoError(t, err)
+	matches := rfc5424Message.FindStringSubmatch(string(buf[:n]))
+	require.NotNil(t, matches, string(buf[:n]))
+	// daemon facility, warning severity
+	assert.Equal(t, strconv.Itoa(3*8+4), matches[1])
+	assert.JSONEq(t, `{"level":"warn","cfRay":"abcdef-SJC","message":"Connection lost"}`, matches[2])
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	log := zerolog.New(newSyslogWriter("tcp", listener.Addr().String()))
+	log.Error().Msg("first")
+	log.Info().Msg("second")
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for _, expected := range []struct {
+		priority int
+		message  string
+	}{
+		{3*8 + 3, `{"level":"error","message":"first"}`},
+		{3*8 + 6, `{"level":"info","message":"second"}`},
+	} {
+		// Messages are framed by octet counting
+		length, err := reader.ReadString(' ')
+		require.NoError(t, err)
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		require.NoError(t, err)
+		msg := make([]byte, n)
+		_, err = io.ReadFull(reader, msg)
+		require.NoError(t, err)
+		matches := rfc5424Message.FindStringSubmatch(string(msg))
+		require.NotNil(t, matches, string(msg))
+		assert.Equal(t, strconv.Itoa(expected.priority), matches[1])
+		assert.JSONEq(t, expected.message, matches[2])
+	}
+}
+
+func TestSyslogWriterDoesntBlock(t *testing.T) {
+	dialing := make(chan struct{})
+	server, client := net.Pipe()
+	defer server.Close()
+	w := startSyslogWriter("tcp", "logs.example.com:514", func(network, address string) (net.Conn, error) {
+		// The server is slow to connect to
+		<-dialing
+		return client, nil
+	})
+
+	log := zerolog.New(w)
+	start := time.Now()
+	// The first message is being sent, the others are queued until the queue is full
+	for i := 0; i < syslogQueueSize+10; i++ {
+		log.Info().Int("i", i).Msg("Request served")
+	}
+	assert.Less(t, time.Since(start), syslogDialTimeout)
+	_, err := w.Write([]byte(`{"level":"info","message":"dropped"}`))
+	assert.ErrorIs(t, err, errSyslogQueueFull)
+
+	close(dialing)
+	reader := bufio.NewReader(server)
+	length, err := reader.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	require.NoError(t, err)
+	msg := make([]byte, n)
+	_, err = io.ReadFull(reader, msg)
+	require.NoError(t, err)
+	matches := rfc5424Message.FindStringSubmatch(string(msg))
+	require.NotNil(t, matches, string(msg))
+	assert.JSONEq(t, `{"level":"info","i":0,"message":"Request served"}`, matches[2])
+}
+
+func TestNewOutputWriter(t *testing.T) {
+	tests := []struct {
+		output  string
+		network string
+		address string
+		wantErr bool
+	}{
+		{output: "syslog://logs.example.com", network: "udp", address: "logs.example.com:514"},
+		{output: "syslog+udp://logs.example.com:5514", network: "udp", address: "logs.example.com:5514"},
+		{output: "syslog+tcp://logs.example.com", network: "tcp", address: "logs.example.com:514"},
+		{output: "syslog://", network: "unixgram", address: "/dev/log"},
+		{output: "syslog+unix:///var/run/syslog", network: "unixgram", address: "/var/run/syslog"},
+		{output: "syslog+tcp://", wantErr: true},
+		{output: "file:///var/log/cloudflared.log", wantErr: true},
+	}
+	for _, test := range tests {
+		w, err := newOutputWriter(test.output)
+		if test.wantErr {
+			assert.Error(t, err, test.output)
+			continue
+		}
+		require.NoError(t, err, test.output)
+		syslog, ok := w.(*syslogWriter)
+		require.True(t, ok, test.output)
+		assert.Equal(t, test.network, syslog.network, test.output)
+		assert.Equal(t, test.address, syslog.address, test.output)
+	}
+
+	w, err := newOutputWriter("journald://")
+	require.NoError(t, err)
+	assert.IsType(t, &journaldWriter{}, w)
+}
+
+func TestJournaldWriter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets aren't supported on windows")
+	}
+	// Unix socket paths are short, so the socket isn't in t.TempDir()
+	dir, err := os.MkdirTemp("", "journald")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "socket")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer server.Close()
+
+	log := zerolog.New(newJournaldWriter(socket))
+	log.Error().Str("cfRay", "abcdef-SJC").Uint8("connIndex", 2).Msg("Request failed")
+
+	buf := make([]byte, 1024)
+	n, err := server.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "MESSAGE=Request failed\nPRIORITY=3\nSYSLOG_IDENTIFIER=cloudflared\nCFRAY=abcdef-SJC\nCONNINDEX=2\n",
+		string(buf[:n]))
+}
+
+func TestJournaldEntryMultilineValue(t *testing.T) {
+	entry := journaldEntry([]byte(`{"level":"info","message":"line 1\nline 2"}`))
+
+	expected := &bytes.Buffer{}
+	expected.WriteString("MESSAGE\n")
+	_ = binary.Write(expected, binary.LittleEndian, uint64(len("line 1\nline 2")))
+	expected.WriteString("line 1\nline 2\nPRIORITY=6\nSYSLOG_IDENTIFIER=cloudflared\n")
+	assert.Equal(t, expected.Bytes(), entry)
+}
+
+func TestJournaldFieldName(t *testing.T) {
+	assert.Equal(t, "CFRAY", journaldFieldName("cfRay"))
+	assert.Equal(t, "ORIGIN_SERVICE", journaldFieldName("origin-service"))
+	assert.Equal(t, "PRIVATE", journaldFieldName("_private"))
+	assert.Equal(t, "", journaldFieldName("123"))
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
@@ -14,6 +15,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"golang.org/x/net/trace"
+
+	"github.com/cloudflare/cloudflared/tlsconfig"
 )
 
 const (
@@ -25,12 +28,25 @@ type Config struct {
 	ReadyServer         *ReadyServer
 	QuickTunnelHostname string
 	Orchestrator        orchestrator
+	// Serves /maintenance, which puts ingress rules in and out of maintenance mode. Anyone who can reach the metrics
+	// server can then take hostnames offline, so it's opt-in.
+	EnableMaintenance bool
+	// Serves the profiles of the pprof package under /debug/pprof/, which expose the memory of the process
+	EnablePprof bool
+	// Serves /ha-connections, which scales the HA connections of the tunnel. Nil doesn't serve it.
+	HAConnectionsScaler haConnectionsScaler
 
 	ShutdownTimeout time.Duration
 }
 
 type orchestrator interface {
 	GetVersionedConfigJSON() ([]byte, error)
+	SetMaintenance(hostname string, enabled bool) error
+	ClearMaintenance(hostname string) error
+}
+
+type haConnectionsScaler interface {
+	ScaleHAConnections(ctx context.Context, haConnections int) error
 }
 
 func newMetricsHandler(
@@ -39,6 +55,9 @@ func newMetricsHandler(
 ) *http.ServeMux {
 	router := http.NewServeMux()
 	router.Handle("/debug/", http.DefaultServeMux)
+	if !config.EnablePprof {
+		router.Handle("/debug/pprof/", http.NotFoundHandler())
+	}
 	router.Handle("/metrics", promhttp.Handler())
 	router.HandleFunc("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = fmt.Fprintf(w, "OK\n")
@@ -60,11 +79,87 @@ func newMetricsHandler(
 			}
 			_, _ = w.Write(json)
 		})
+		if config.EnableMaintenance {
+			router.HandleFunc("/maintenance", maintenanceHandler(config.Orchestrator, log))
+		}
+	}
+	if config.HAConnectionsScaler != nil {
+		router.HandleFunc("/ha-connections", haConnectionsHandler(config.HAConnectionsScaler, log))
 	}
 
 	return router
 }
 
+// maintenanceHandler puts the ingress rule of the hostname query parameter in maintenance mode on PUT, takes it out
+// of maintenance mode with enabled=false, and reverts it to its configuration on DELETE. Hostnames without an ingress
+// rule, or without maintenance mode set on DELETE, are not found.
+func maintenanceHandler(orchestrator orchestrator, log *zerolog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		hostname := r.URL.Query().Get("hostname")
+		if hostname == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "ERR: missing hostname")
+			return
+		}
+		var err error
+		switch r.Method {
+		case http.MethodPut:
+			enabled := true
+			if raw := r.URL.Query().Get("enabled"); raw != "" {
+				if enabled, err = strconv.ParseBool(raw); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = fmt.Fprintf(w, "ERR: invalid enabled: %v", err)
+					return
+				}
+			}
+			err = orchestrator.SetMaintenance(hostname, enabled)
+		case http.MethodDelete:
+			err = orchestrator.ClearMaintenance(hostname)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, "ERR: %v", err)
+			log.Err(err).Msg("Failed to update maintenance mode")
+			return
+		}
+		_, _ = fmt.Fprintf(w, "OK\n")
+	}
+}
+
+// haConnectionsHandler scales the HA connections of the tunnel to the count query parameter on PUT. Counts the
+// tunnel can't scale to, because there aren't enough edge addresses for them, are rejected.
+func haConnectionsHandler(scaler haConnectionsScaler, log *zerolog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.Header().Set("Allow", "PUT")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		count, err := strconv.Atoi(r.URL.Query().Get("count"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "ERR: invalid count: %v", err)
+			return
+		}
+		if err := scaler.ScaleHAConnections(r.Context(), count); err != nil {
+			if r.Context().Err() != nil {
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "ERR: %v", err)
+			log.Err(err).Msg("Failed to scale HA connections")
+			return
+		}
+		log.Info().Msgf("Scaled to %d HA connections", count)
+		_, _ = fmt.Fprintf(w, "OK\n")
+	}
+}
+
 func ServeMetrics(
 	l net.Listener,
 	ctx context.Context,
@@ -123,4 +218,19 @@ func RegisterBuildInfo(buildType, buildTime, version string) {
 	)
 	prometheus.MustRegister(buildInfo)
 	buildInfo.WithLabelValues(runtime.Version(), buildType, buildTime, version).Set(1)
+
+	fipsMode := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "cloudflared",
+			Name:      "fips_mode",
+			Help:      "Whether TLS with the edge and origins is restricted to FIPS-approved settings",
+		},
+		func() float64 {
+			if tlsconfig.FIPSEnabled() {
+				return 1
+			}
+			return 0
+		},
+	)
+	prometheus.MustRegister(fipsMode)
 }
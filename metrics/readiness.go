@@ -9,6 +9,8 @@ import (
 	"github.com/rs/zerolog"
 
 	conn "github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tlsconfig"
 	"github.com/cloudflare/cloudflared/tunnelstate"
 )
 
@@ -34,6 +36,9 @@ type body struct {
 	Status           int       `json:"status"`
 	ReadyConnections uint      `json:"readyConnections"`
 	ConnectorID      uuid.UUID `json:"connectorId"`
+	FIPSMode         bool      `json:"fipsMode"`
+	// OriginHealth is whether each health checked origin is healthy, by the index of its ingress rule and origin
+	OriginHealth map[int]map[string]bool `json:"originHealth,omitempty"`
 }
 
 // ServeHTTP responds with HTTP 200 if the tunnel is connected to the edge.
@@ -44,6 +49,8 @@ func (rs *ReadyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Status:           statusCode,
 		ReadyConnections: readyConnections,
 		ConnectorID:      rs.clientID,
+		FIPSMode:         tlsconfig.FIPSEnabled(),
+		OriginHealth:     ingress.OriginHealth(),
 	}
 	msg, err := json.Marshal(body)
 	if err != nil {
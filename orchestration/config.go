@@ -5,6 +5,7 @@ import (
 
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/proxy"
 )
 
 type newRemoteConfig struct {
@@ -21,6 +22,10 @@ type newLocalConfig struct {
 type Config struct {
 	Ingress     *ingress.Ingress
 	WarpRouting ingress.WarpRoutingConfig
+	// Bytes per second proxied between the edge and origins across all requests and streams, 0 for no limit
+	BandwidthLimit uint64
+	// Logs the requests proxied to HTTP origins, nil if access logging is disabled
+	AccessLog *proxy.AccessLog
 
 	// Extra settings used to configure this instance but that are not eligible for remotely management
 	// ie. (--protocol, --loglevel, ...)
@@ -55,9 +60,28 @@ func convertToUnvalidatedIngressRules(i ingress.Ingress) []config.UnvalidatedIng
 		newRule := config.UnvalidatedIngressRule{
 			Hostname:      rule.Hostname,
 			Path:          path,
-			Service:       rule.Service.String(),
 			OriginRequest: ingress.ConvertToRawOriginConfig(rule.Config),
 		}
+		switch service := rule.Service.(type) {
+		case *ingress.LoadBalancedService:
+			newRule.Services = service.Origins()
+			newRule.LoadBalancingPolicy = service.Policy()
+			newRule.HealthCheck = service.HealthCheck()
+			newRule.SessionAffinity = service.SessionAffinity()
+		case *ingress.CanaryService:
+			canary := service.Canary()
+			newRule.Service = service.String()
+			newRule.Canary = &canary
+		default:
+			newRule.Service = rule.Service.String()
+		}
+		if rule.Mirror != nil {
+			newRule.Mirror = rule.Mirror.String()
+		}
+		if rule.Maintenance != nil {
+			raw := rule.Maintenance.Raw()
+			newRule.Maintenance = &raw
+		}
 
 		result = append(result, newRule)
 	}
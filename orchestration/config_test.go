@@ -23,7 +23,34 @@ func TestNewLocalConfig_MarshalJSON(t *testing.T) {
 		"ingress": [
 			{
 				"hostname": "tun.example.com",
-				"service": "https://localhost:8000"
+				"service": "https://localhost:8000",
+				"mirror": "http://localhost:9000",
+				"maintenance": {
+					"enabled": true
+				}
+			},
+			{
+				"hostname": "lb.example.com",
+				"service": ["http://localhost:8002", "http://localhost:8003"],
+				"loadBalancingPolicy": "least_connections",
+				"healthCheck": {
+					"path": "/health",
+					"interval": 30,
+					"unhealthyThreshold": 5
+				},
+				"sessionAffinity": {
+					"type": "cookie",
+					"cookieName": "lb"
+				}
+			},
+			{
+				"hostname": "canary.example.com",
+				"service": "http://localhost:8004",
+				"canary": {
+					"service": "http://localhost:8005",
+					"weight": 10,
+					"header": "X-Canary"
+				}
 			},
 			{
 				"hostname": "*",
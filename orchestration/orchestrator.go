@@ -13,6 +13,7 @@ import (
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/proxy"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 )
@@ -32,8 +33,12 @@ type Orchestrator struct {
 	internalRules      []ingress.Rule
 	warpRoutingEnabled atomic.Bool
 	config             *Config
-	tags               []tunnelpogs.Tag
-	log                *zerolog.Logger
+	// Rules put in or out of maintenance mode at runtime, by hostname. They're kept across configuration updates.
+	maintenanceOverrides map[string]bool
+	// Shared by the proxies of all configuration versions, so that in-flight requests of the previous ones count
+	bandwidthLimit *proxy.BandwidthLimit
+	tags           []tunnelpogs.Tag
+	log            *zerolog.Logger
 
 	// orchestrator must not handle any more updates after shutdownC is closed
 	shutdownC <-chan struct{}
@@ -46,12 +51,14 @@ func NewOrchestrator(ctx context.Context, config *Config, tags []tunnelpogs.Tag,
 		// Lowest possible version, any remote configuration will have version higher than this
 		// Starting at -1 allows a configuration migration (local to remote) to override the current configuration as it
 		// will start at version 0.
-		currentVersion: -1,
-		internalRules:  internalRules,
-		config:         config,
-		tags:           tags,
-		log:            log,
-		shutdownC:      ctx.Done(),
+		currentVersion:       -1,
+		internalRules:        internalRules,
+		config:               config,
+		maintenanceOverrides: make(map[string]bool),
+		bandwidthLimit:       proxy.NewBandwidthLimit(config.BandwidthLimit),
+		tags:                 tags,
+		log:                  log,
+		shutdownC:            ctx.Done(),
 	}
 	if err := o.updateIngress(*config.Ingress, config.WarpRouting); err != nil {
 		return nil, err
@@ -79,6 +86,7 @@ func (o *Orchestrator) UpdateConfig(version int32, config []byte) *tunnelpogs.Up
 		o.log.Err(err).
 			Int32("version", version).
 			Str("config", string(config)).
+			Int(logger.LogFieldEventID, logger.EventIDConfigError).
 			Msgf("Failed to deserialize new configuration")
 		return &tunnelpogs.UpdateConfigurationResponse{
 			LastAppliedVersion: o.currentVersion,
@@ -90,6 +98,7 @@ func (o *Orchestrator) UpdateConfig(version int32, config []byte) *tunnelpogs.Up
 		o.log.Err(err).
 			Int32("version", version).
 			Str("config", string(config)).
+			Int(logger.LogFieldEventID, logger.EventIDConfigError).
 			Msgf("Failed to update ingress")
 		return &tunnelpogs.UpdateConfigurationResponse{
 			LastAppliedVersion: o.currentVersion,
@@ -101,6 +110,7 @@ func (o *Orchestrator) UpdateConfig(version int32, config []byte) *tunnelpogs.Up
 	o.log.Info().
 		Int32("version", version).
 		Str("config", string(config)).
+		Int(logger.LogFieldEventID, logger.EventIDConfigUpdated).
 		Msg("Updated to new configuration")
 	configVersion.Set(float64(version))
 	return &tunnelpogs.UpdateConfigurationResponse{
@@ -133,6 +143,9 @@ func (o *Orchestrator) updateIngress(ingressRules ingress.Ingress, warpRouting i
 		return errors.Wrap(err, "failed to start origin")
 	}
 	proxy := proxy.NewOriginProxy(ingressRules, warpRouting, o.tags, o.log)
+	proxy.SetMaintenanceOverrides(o.maintenanceOverrides)
+	proxy.SetBandwidthLimit(o.bandwidthLimit)
+	proxy.SetAccessLog(o.config.AccessLog)
 	o.proxy.Store(proxy)
 	o.config.Ingress = &ingressRules
 	o.config.WarpRouting = warpRouting
@@ -150,6 +163,52 @@ func (o *Orchestrator) updateIngress(ingressRules ingress.Ingress, warpRouting i
 	return nil
 }
 
+// SetMaintenance puts the ingress rule of hostname in or out of maintenance mode, regardless of its configuration,
+// until ClearMaintenance is called.
+func (o *Orchestrator) SetMaintenance(hostname string, enabled bool) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if !o.hasRule(hostname) {
+		return fmt.Errorf("no ingress rule matches hostname %q", hostname)
+	}
+	o.maintenanceOverrides[hostname] = enabled
+	o.applyMaintenanceOverrides()
+	o.log.Info().Str("hostname", hostname).Bool("enabled", enabled).Msg("Set maintenance mode")
+	return nil
+}
+
+// ClearMaintenance reverts the ingress rule of hostname to the maintenance mode of its configuration.
+func (o *Orchestrator) ClearMaintenance(hostname string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if _, ok := o.maintenanceOverrides[hostname]; !ok {
+		return fmt.Errorf("maintenance mode of hostname %q isn't set", hostname)
+	}
+	delete(o.maintenanceOverrides, hostname)
+	o.applyMaintenanceOverrides()
+	o.log.Info().Str("hostname", hostname).Msg("Cleared maintenance mode")
+	return nil
+}
+
+// The caller is responsible to hold the lock
+func (o *Orchestrator) hasRule(hostname string) bool {
+	for _, rule := range o.config.Ingress.Rules {
+		if rule.Hostname == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// The caller is responsible to hold the lock
+func (o *Orchestrator) applyMaintenanceOverrides() {
+	if proxy, ok := o.proxy.Load().(*proxy.Proxy); ok {
+		proxy.SetMaintenanceOverrides(o.maintenanceOverrides)
+	}
+}
+
 // GetConfigJSON returns the current json serialization of the config as the edge understands it
 func (o *Orchestrator) GetConfigJSON() ([]byte, error) {
 	o.lock.RLock()
@@ -232,6 +232,49 @@ func TestUpdateConfiguration_WithoutIngressRule(t *testing.T) {
 	require.Len(t, orchestrator.config.Ingress.Rules, 1)
 }
 
+// TestMaintenanceOverrides makes sure rules put in maintenance mode at runtime stay in it across updates
+func TestMaintenanceOverrides(t *testing.T) {
+	const hostname = "upgrading.tunnel1.org"
+	configJSON := []byte(fmt.Sprintf(`
+{
+    "ingress": [
+        {
+            "hostname": "%s",
+            "service": "http_status:200"
+        },
+        {
+            "service": "http_status:404"
+        }
+    ]
+}
+`, hostname))
+
+	orchestrator, err := NewOrchestrator(context.Background(), &Config{Ingress: &ingress.Ingress{}}, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+	updateWithValidation(t, orchestrator, 1, configJSON)
+
+	assertStatus := func(expectedStatus int) {
+		originProxy, err := orchestrator.GetOriginProxy()
+		require.NoError(t, err)
+		resp, err := proxyHTTP(originProxy, hostname)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, expectedStatus, resp.StatusCode)
+	}
+
+	assertStatus(http.StatusOK)
+	require.NoError(t, orchestrator.SetMaintenance(hostname, true))
+	assertStatus(http.StatusServiceUnavailable)
+	require.Error(t, orchestrator.SetMaintenance("unknown.tunnel1.org", true))
+
+	updateWithValidation(t, orchestrator, 2, configJSON)
+	assertStatus(http.StatusServiceUnavailable)
+
+	require.NoError(t, orchestrator.ClearMaintenance(hostname))
+	assertStatus(http.StatusOK)
+	require.Error(t, orchestrator.ClearMaintenance(hostname))
+}
+
 // TestConcurrentUpdateAndRead makes sure orchestrator can receive updates and return origin proxy concurrently
 func TestConcurrentUpdateAndRead(t *testing.T) {
 	const (
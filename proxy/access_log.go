@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatCommon   = "common"
+	AccessLogFormatCombined = "combined"
+
+	commonLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLog writes a line per HTTP request proxied to an origin, apart from the operational log. It's shared by the
+// proxies of all configuration versions.
+type AccessLog struct {
+	format string
+	lock   sync.Mutex
+	w      io.Writer
+}
+
+// NewAccessLog returns an AccessLog writing to w in format, one of AccessLogFormatJSON, AccessLogFormatCommon or
+// AccessLogFormatCombined.
+func NewAccessLog(w io.Writer, format string) (*AccessLog, error) {
+	if err := ValidateAccessLogFormat(format); err != nil {
+		return nil, err
+	}
+	return &AccessLog{
+		format: format,
+		w:      w,
+	}, nil
+}
+
+// SetAccessLog makes the proxy write the requests of the rules that don't disable it to log. It must be called before
+// the proxy is used.
+func (p *Proxy) SetAccessLog(log *AccessLog) {
+	p.accessLog = log
+}
+
+// ValidateAccessLogFormat returns an error if format isn't a format of the access log.
+func ValidateAccessLogFormat(format string) error {
+	switch format {
+	case AccessLogFormatJSON, AccessLogFormatCommon, AccessLogFormatCombined:
+		return nil
+	default:
+		return fmt.Errorf("unknown access log format %s, expected %s, %s or %s", format,
+			AccessLogFormatJSON, AccessLogFormatCommon, AccessLogFormatCombined)
+	}
+}
+
+// accessLogEntry is a line of the access log. The request fields are captured before the request is rewritten for
+// the origin.
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	Hostname  string    `json:"hostname"`
+	ClientIP  string    `json:"clientIP,omitempty"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Duration  float64   `json:"durationSeconds"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	CFRay     string    `json:"cfRay,omitempty"`
+	RequestID string    `json:"requestID,omitempty"`
+	Rule      int       `json:"ingressRule"`
+}
+
+func newAccessLogEntry(req *http.Request, fields logFields) *accessLogEntry {
+	return &accessLogEntry{
+		Time:      time.Now(),
+		Hostname:  req.Host,
+		ClientIP:  req.Header.Get("Cf-Connecting-Ip"),
+		Method:    req.Method,
+		URI:       req.URL.RequestURI(),
+		Proto:     req.Proto,
+		Referer:   req.Referer(),
+		UserAgent: req.UserAgent(),
+		CFRay:     fields.cfRay,
+		RequestID: fields.requestID,
+		Rule:      fields.rule,
+	}
+}
+
+// log completes entry with the response, once it was sent, and writes it.
+func (l *AccessLog) log(entry *accessLogEntry, w *meteredResponseWriter) {
+	entry.Status = w.status
+	entry.Bytes = w.written
+	entry.Duration = time.Since(entry.Time).Seconds()
+
+	var line []byte
+	switch l.format {
+	case AccessLogFormatJSON:
+		var err error
+		if line, err = json.Marshal(entry); err != nil {
+			return
+		}
+	default:
+		line = []byte(entry.commonLogFormat(l.format == AccessLogFormatCombined))
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, _ = l.w.Write(append(line, '\n'))
+}
+
+// commonLogFormat formats the entry in the Common Log Format, or the Combined Log Format that adds the referer and
+// user agent.
+func (e *accessLogEntry) commonLogFormat(combined bool) string {
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	line := fmt.Sprintf("%s - - [%s] %q %d %s", orDash(e.ClientIP), e.Time.Format(commonLogTimeFormat),
+		e.Method+" "+e.URI+" "+e.Proto, e.Status, bytes)
+	if combined {
+		line += fmt.Sprintf(" %q %q", orDash(e.Referer), orDash(e.UserAgent))
+	}
+	return line
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
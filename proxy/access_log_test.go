@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+func TestNewAccessLogInvalidFormat(t *testing.T) {
+	_, err := NewAccessLog(&bytes.Buffer{}, "apache")
+	assert.Error(t, err)
+}
+
+func TestAccessLogCommonLogFormat(t *testing.T) {
+	entry := &accessLogEntry{
+		Time:      time.Date(2023, time.March, 1, 12, 30, 0, 0, time.UTC),
+		ClientIP:  "192.0.2.1",
+		Method:    http.MethodGet,
+		URI:       "/index.html?q=1",
+		Proto:     "HTTP/1.1",
+		Status:    http.StatusOK,
+		Bytes:     42,
+		UserAgent: "curl/8.0",
+	}
+	assert.Equal(t, `192.0.2.1 - - [01/Mar/2023:12:30:00 +0000] "GET /index.html?q=1 HTTP/1.1" 200 42`,
+		entry.commonLogFormat(false))
+	assert.Equal(t, `192.0.2.1 - - [01/Mar/2023:12:30:00 +0000] "GET /index.html?q=1 HTTP/1.1" 200 42 "-" "curl/8.0"`,
+		entry.commonLogFormat(true))
+
+	// Unknown client IPs and empty bodies are dashes
+	entry.ClientIP = ""
+	entry.Bytes = 0
+	assert.Equal(t, `- - - [01/Mar/2023:12:30:00 +0000] "GET /index.html?q=1 HTTP/1.1" 200 -`, entry.commonLogFormat(false))
+}
+
+func TestProxyAccessLog(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer origin.Close()
+
+	disabled := true
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress: []config.UnvalidatedIngressRule{
+			{Hostname: "health.example.com", Service: origin.URL, OriginRequest: config.OriginRequestConfig{DisableAccessLog: &disabled}},
+			{Service: origin.URL},
+		},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, ing.StartOrigins(&log, ctx.Done()))
+	proxy := NewOriginProxy(ing, noWarpRouting, testTags, &log)
+	output := &bytes.Buffer{}
+	accessLog, err := NewAccessLog(output, AccessLogFormatJSON)
+	require.NoError(t, err)
+	proxy.SetAccessLog(accessLog)
+
+	for _, host := range []string{"health.example.com", "example.com"} {
+		req, err := http.NewRequest(http.MethodGet, "http://"+host+"/path?q=1", nil)
+		require.NoError(t, err)
+		req.Header.Set("Cf-Connecting-Ip", "192.0.2.1")
+		req.Header.Set("Cf-Ray", "abcdef-SJC")
+		require.NoError(t, proxy.ProxyHTTP(newMockHTTPRespWriter(), tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	}
+
+	// Only the request of the rule that doesn't disable the access log is logged
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 1)
+	var entry accessLogEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "example.com", entry.Hostname)
+	assert.Equal(t, "192.0.2.1", entry.ClientIP)
+	assert.Equal(t, "/path?q=1", entry.URI)
+	assert.Equal(t, http.StatusOK, entry.Status)
+	assert.Equal(t, int64(5), entry.Bytes)
+	assert.Equal(t, "abcdef-SJC", entry.CFRay)
+	assert.NotEmpty(t, entry.RequestID)
+	assert.Equal(t, 1, entry.Rule)
+}
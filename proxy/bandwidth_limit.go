@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+// BandwidthLimit caps the bytes per second proxied between the edge and origins, in both directions combined and
+// across all the requests and streams of the proxies it's set on.
+type BandwidthLimit struct {
+	limiter *bandwidthLimiter
+}
+
+// NewBandwidthLimit returns a limit of bytesPerSecond, or nil if it's 0 and the bandwidth isn't limited.
+func NewBandwidthLimit(bytesPerSecond uint64) *BandwidthLimit {
+	bucket := newByteBucket(bytesPerSecond)
+	if bucket == nil {
+		return nil
+	}
+	return &BandwidthLimit{
+		limiter: &bandwidthLimiter{upload: bucket, download: bucket},
+	}
+}
+
+// SetBandwidthLimit throttles all the traffic of the proxy with limit, on top of the bandwidth limits of its rules.
+// It must be called before the proxy is used.
+func (p *Proxy) SetBandwidthLimit(limit *BandwidthLimit) {
+	if limit == nil {
+		p.globalBandwidth = nil
+		return
+	}
+	p.globalBandwidth = limit.limiter
+}
+
+// bandwidthLimiter caps the throughput of the traffic of a rule to and from its origin, across all its requests
+// and streams. Either direction is unlimited when its bucket is nil, and so are both when the limiter is nil.
+type bandwidthLimiter struct {
+	upload   *byteBucket
+	download *byteBucket
+}
+
+// newBandwidthLimiters returns the bandwidth limiters of the rules that limit their throughput, by rule number.
+func newBandwidthLimiters(ingressRules ingress.Ingress) map[int]*bandwidthLimiter {
+	limiters := make(map[int]*bandwidthLimiter)
+	for i, rule := range ingressRules.Rules {
+		cfg := rule.Config.BandwidthLimit
+		if cfg == (config.BandwidthLimitConfig{}) {
+			continue
+		}
+		limiters[i] = &bandwidthLimiter{
+			upload:   newByteBucket(cfg.UploadBytesPerSecond),
+			download: newByteBucket(cfg.DownloadBytesPerSecond),
+		}
+	}
+	return limiters
+}
+
+// uploadBody limits the rate at which body is read to be sent to the origin.
+func (l *bandwidthLimiter) uploadBody(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	if l == nil || l.upload == nil || body == nil || body == http.NoBody {
+		return body
+	}
+	return &throttledReadCloser{
+		Reader: &throttledReader{Reader: body, bucket: l.upload, ctx: ctx},
+		Closer: body,
+	}
+}
+
+// downloadWriter limits the rate at which the responses of the origin are written to w.
+func (l *bandwidthLimiter) downloadWriter(ctx context.Context, w io.Writer) io.Writer {
+	if l == nil || l.download == nil {
+		return w
+	}
+	return &throttledWriter{Writer: w, bucket: l.download, ctx: ctx}
+}
+
+// stream limits a stream between the client, read from rw, and the origin, written to rw.
+func (l *bandwidthLimiter) stream(ctx context.Context, rw io.ReadWriter) io.ReadWriter {
+	if l == nil {
+		return rw
+	}
+	var reader io.Reader = rw
+	if l.upload != nil {
+		reader = &throttledReader{Reader: rw, bucket: l.upload, ctx: ctx}
+	}
+	return &bidirectionalStream{reader: reader, writer: l.downloadWriter(ctx, rw)}
+}
+
+// byteBucket is a token bucket of bytes, allowing a second worth of bytes at once.
+type byteBucket struct {
+	rate float64
+	now  func() time.Time
+
+	lock    sync.Mutex
+	tokens  float64
+	updated time.Time
+}
+
+func newByteBucket(bytesPerSecond uint64) *byteBucket {
+	if bytesPerSecond == 0 {
+		return nil
+	}
+	return &byteBucket{
+		rate:    float64(bytesPerSecond),
+		now:     time.Now,
+		tokens:  float64(bytesPerSecond),
+		updated: time.Now(),
+	}
+}
+
+// burst is the most bytes that should be transferred at once.
+func (b *byteBucket) burst() int {
+	return int(math.Max(1, math.Min(b.rate, math.MaxInt32)))
+}
+
+// wait takes n bytes from the bucket, waiting until they were refilled if it goes into debt. It returns early with
+// an error if ctx is done.
+func (b *byteBucket) wait(ctx context.Context, n int) error {
+	b.lock.Lock()
+	now := b.now()
+	b.tokens = math.Min(b.rate, b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	b.updated = now
+	b.tokens -= float64(n)
+	debt := -b.tokens
+	b.lock.Unlock()
+	if debt <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(debt / b.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type throttledReader struct {
+	io.Reader
+	bucket *byteBucket
+	ctx    context.Context
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if burst := r.bucket.burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.Reader.Read(p)
+	if waitErr := r.bucket.wait(r.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+type throttledReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type throttledWriter struct {
+	io.Writer
+	bucket *byteBucket
+	ctx    context.Context
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	burst := w.bucket.burst()
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > burst {
+			chunk = chunk[:burst]
+		}
+		if err := w.bucket.wait(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.Writer.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
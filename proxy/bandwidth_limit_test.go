@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteBucketRefill(t *testing.T) {
+	now := time.Now()
+	bucket := newByteBucket(100)
+	bucket.now = func() time.Time { return now }
+	bucket.updated = now
+
+	// A second worth of bytes is allowed at once
+	require.NoError(t, bucket.wait(context.Background(), 100))
+	assert.Zero(t, bucket.tokens)
+
+	now = now.Add(500 * time.Millisecond)
+	require.NoError(t, bucket.wait(context.Background(), 50))
+	assert.Zero(t, bucket.tokens)
+
+	// The bucket doesn't fill beyond a second worth of bytes
+	now = now.Add(time.Hour)
+	require.NoError(t, bucket.wait(context.Background(), 0))
+	assert.Equal(t, float64(100), bucket.tokens)
+}
+
+func TestByteBucketWaitsForDebt(t *testing.T) {
+	bucket := newByteBucket(100)
+	require.NoError(t, bucket.wait(context.Background(), 100))
+
+	start := time.Now()
+	require.NoError(t, bucket.wait(context.Background(), 10))
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	// Waiting is interrupted once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, bucket.wait(ctx, 1000), context.Canceled)
+}
+
+func TestNoByteBucketWithoutLimit(t *testing.T) {
+	assert.Nil(t, newByteBucket(0))
+
+	var limiter *bandwidthLimiter
+	body := io.NopCloser(strings.NewReader("body"))
+	assert.Equal(t, body, limiter.uploadBody(context.Background(), body))
+	var w bytes.Buffer
+	assert.Equal(t, &w, limiter.downloadWriter(context.Background(), &w))
+}
+
+func TestThrottledWriterChunks(t *testing.T) {
+	bucket := newByteBucket(1 << 20)
+	var out bytes.Buffer
+	w := &throttledWriter{Writer: &out, bucket: bucket, ctx: context.Background()}
+
+	n, err := w.Write(bytes.Repeat([]byte("a"), 1<<20+10))
+	require.NoError(t, err)
+	assert.Equal(t, 1<<20+10, n)
+	assert.Equal(t, 1<<20+10, out.Len())
+}
+
+func TestThrottledReader(t *testing.T) {
+	bucket := newByteBucket(4)
+	r := &throttledReader{Reader: strings.NewReader("abcdef"), bucket: bucket, ctx: context.Background()}
+
+	buf := make([]byte, 10)
+	// Reads are capped to the burst
+	n, err := r.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(buf[:n]))
+}
+
+func TestBandwidthLimitSharedAcrossDirections(t *testing.T) {
+	assert.Nil(t, NewBandwidthLimit(0))
+
+	limit := NewBandwidthLimit(100)
+	p := &Proxy{}
+	p.SetBandwidthLimit(limit)
+	require.Same(t, limit.limiter, p.globalBandwidth)
+
+	// Uploads and downloads take from the same bucket
+	body := p.globalBandwidth.uploadBody(context.Background(), io.NopCloser(strings.NewReader(strings.Repeat("a", 60))))
+	_, err := io.ReadAll(body)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	_, err = p.globalBandwidth.downloadWriter(context.Background(), &out).Write(bytes.Repeat([]byte("a"), 60))
+	require.NoError(t, err)
+	assert.Less(t, limit.limiter.download.tokens, float64(0))
+
+	p.SetBandwidthLimit(nil)
+	assert.Nil(t, p.globalBandwidth)
+}
@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+const (
+	defaultCacheMaxSize = 10 * 1000 * 1000
+	// Largest body that's cached, responses with larger ones aren't
+	maxCachedBodySize = 1 << 20
+)
+
+// Statuses cacheable by default, per RFC 9110
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// responseCache is a size bounded in-memory cache of the responses of an origin, which expire after a TTL. It's a
+// micro-cache: the TTL is expected to be short, so responses aren't revalidated.
+type responseCache struct {
+	ttl     time.Duration
+	maxSize int64
+	now     func() time.Time
+
+	lock    sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	// Most recently used first
+	lru *list.List
+}
+
+type cachedResponse struct {
+	key    string
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+func newResponseCache(cfg config.CacheConfig) *responseCache {
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultCacheMaxSize
+	}
+	return &responseCache{
+		ttl:     cfg.TTL.Duration,
+		maxSize: maxSize,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// newResponseCaches returns the response caches of the rules that enable one, by rule number.
+func newResponseCaches(ingressRules ingress.Ingress) map[int]*responseCache {
+	caches := make(map[int]*responseCache)
+	for i, rule := range ingressRules.Rules {
+		if rule.Config.Cache.TTL.Duration <= 0 {
+			continue
+		}
+		if _, ok := rule.Service.(ingress.HTTPOriginProxy); ok {
+			caches[i] = newResponseCache(rule.Config.Cache)
+		}
+	}
+	return caches
+}
+
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cached := elem.Value.(*cachedResponse)
+	if c.now().Sub(cached.stored) >= c.ttl {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return cached, true
+}
+
+func (c *responseCache) put(cached *cachedResponse) {
+	size := int64(len(cached.body))
+	if size > c.maxSize {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[cached.key]; ok {
+		c.remove(elem)
+	}
+	for c.size+size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	c.entries[cached.key] = c.lru.PushFront(cached)
+	c.size += size
+}
+
+// The caller is responsible to hold the lock
+func (c *responseCache) remove(elem *list.Element) {
+	cached := c.lru.Remove(elem).(*cachedResponse)
+	delete(c.entries, cached.key)
+	c.size -= int64(len(cached.body))
+}
+
+// cachingOrigin serves the responses of an origin from its cache when they're fresh, and caches the cacheable ones.
+type cachingOrigin struct {
+	ingress.HTTPOriginProxy
+	cache *responseCache
+	rule  string
+}
+
+func (o *cachingOrigin) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isCacheableRequest(req) {
+		return o.HTTPOriginProxy.RoundTrip(req)
+	}
+	key := cacheKey(req)
+	if cached, ok := o.cache.get(key); ok {
+		cacheHits.WithLabelValues(o.rule).Inc()
+		return cached.response(req, o.cache.now()), nil
+	}
+	cacheMisses.WithLabelValues(o.rule).Inc()
+
+	resp, err := o.HTTPOriginProxy.RoundTrip(req)
+	if err != nil || !isCacheableResponse(resp) {
+		return resp, err
+	}
+	cached := &cachedResponse{
+		key:    key,
+		status: resp.StatusCode,
+		header: resp.Header.Clone(),
+	}
+	// The body is cached once it was read completely, so that responses are streamed to the client meanwhile
+	resp.Body = &cachingBody{ReadCloser: resp.Body, onComplete: func(body []byte) {
+		cached.body = body
+		cached.stored = o.cache.now()
+		o.cache.put(cached)
+	}}
+	return resp, nil
+}
+
+func (r *cachedResponse) response(req *http.Request, now time.Time) *http.Response {
+	header := r.header.Clone()
+	header.Set("Age", strconv.Itoa(int(now.Sub(r.stored).Seconds())))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.Host + req.URL.RequestURI() + " " + req.Header.Get("Accept-Encoding")
+}
+
+// isCacheableRequest returns false for requests whose responses can be specific to the client, other than by their
+// encoding. Requests with cookies or an Access token identify the user, and origins rarely vary their responses on
+// them, so they're never served from or stored in the cache.
+func isCacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	for _, header := range []string{"Authorization", "Cookie", "Cf-Access-Jwt-Assertion", "Range"} {
+		if req.Header.Get(header) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isCacheableResponse(resp *http.Response) bool {
+	if !cacheableStatuses[resp.StatusCode] || resp.ContentLength > maxCachedBodySize {
+		return false
+	}
+	if len(resp.Header.Values("Set-Cookie")) > 0 || len(resp.Trailer) > 0 {
+		return false
+	}
+	for _, directive := range strings.Split(strings.ToLower(strings.Join(resp.Header.Values("Cache-Control"), ",")), ",") {
+		switch strings.TrimSpace(directive) {
+		case "no-store", "no-cache", "private":
+			return false
+		}
+	}
+	for _, vary := range resp.Header.Values("Vary") {
+		for _, header := range strings.Split(vary, ",") {
+			if !strings.EqualFold(strings.TrimSpace(header), "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cachingBody keeps a copy of a response body, and calls onComplete with it once it was read completely unless
+// it's larger than maxCachedBodySize.
+type cachingBody struct {
+	io.ReadCloser
+	onComplete func(body []byte)
+	captured   bytes.Buffer
+	// Set once the body was found too large, or was passed to onComplete
+	done bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done {
+		return n, err
+	}
+	if b.captured.Len()+n > maxCachedBodySize {
+		b.done = true
+		b.captured = bytes.Buffer{}
+		return n, err
+	}
+	b.captured.Write(p[:n])
+	if err == io.EOF {
+		b.done = true
+		b.onComplete(b.captured.Bytes())
+	}
+	return n, err
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestResponseCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := newResponseCache(config.CacheConfig{TTL: config.CustomDuration{Duration: time.Second}})
+	cache.now = func() time.Time { return now }
+
+	cache.put(&cachedResponse{key: "a", body: []byte("a"), stored: now})
+	_, ok := cache.get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	assert.Zero(t, cache.size)
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	cache := newResponseCache(config.CacheConfig{TTL: config.CustomDuration{Duration: time.Hour}, MaxSize: 10})
+	now := cache.now()
+
+	cache.put(&cachedResponse{key: "a", body: []byte("aaaa"), stored: now})
+	cache.put(&cachedResponse{key: "b", body: []byte("bbbb"), stored: now})
+	// a is now the most recently used
+	_, ok := cache.get("a")
+	require.True(t, ok)
+	cache.put(&cachedResponse{key: "c", body: []byte("cccc"), stored: now})
+
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	_, ok = cache.get("a")
+	assert.True(t, ok)
+	_, ok = cache.get("c")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), cache.size)
+
+	// Bodies larger than the cache aren't cached
+	cache.put(&cachedResponse{key: "d", body: []byte(strings.Repeat("d", 11)), stored: now})
+	_, ok = cache.get("d")
+	assert.False(t, ok)
+}
+
+func TestIsCacheableResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		header    http.Header
+		cacheable bool
+	}{
+		{name: "ok", status: http.StatusOK, cacheable: true},
+		{name: "not found", status: http.StatusNotFound, cacheable: true},
+		{name: "server error", status: http.StatusInternalServerError},
+		{name: "set cookie", status: http.StatusOK, header: http.Header{"Set-Cookie": []string{"session=1"}}},
+		{name: "private", status: http.StatusOK, header: http.Header{"Cache-Control": []string{"max-age=60, Private"}}},
+		{name: "no store", status: http.StatusOK, header: http.Header{"Cache-Control": []string{"no-store"}}},
+		{name: "vary encoding", status: http.StatusOK, header: http.Header{"Vary": []string{"accept-encoding"}}, cacheable: true},
+		{name: "vary cookie", status: http.StatusOK, header: http.Header{"Vary": []string{"Accept-Encoding, Cookie"}}},
+	}
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.status, Header: test.header}
+		if resp.Header == nil {
+			resp.Header = http.Header{}
+		}
+		assert.Equal(t, test.cacheable, isCacheableResponse(resp), test.name)
+	}
+}
+
+func TestCachingOrigin(t *testing.T) {
+	var originRequests int
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originRequests++
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer origin.Close()
+
+	cache := newResponseCache(config.CacheConfig{TTL: config.CustomDuration{Duration: time.Hour}})
+	caching := &cachingOrigin{HTTPOriginProxy: http.DefaultTransport, cache: cache, rule: "0"}
+	roundTrip := func(method, path string, header http.Header) string {
+		req, err := http.NewRequest(method, origin.URL+path, nil)
+		require.NoError(t, err)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		resp, err := caching.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, "/a", roundTrip(http.MethodGet, "/a", nil))
+	assert.Equal(t, "/a", roundTrip(http.MethodGet, "/a", nil))
+	assert.Equal(t, 1, originRequests)
+
+	assert.Equal(t, "/b", roundTrip(http.MethodGet, "/b", nil))
+	assert.Equal(t, 2, originRequests)
+
+	// Requests with credentials and unsafe methods always reach the origin
+	roundTrip(http.MethodGet, "/a", http.Header{"Authorization": []string{"Bearer token"}})
+	roundTrip(http.MethodPost, "/a", nil)
+	assert.Equal(t, 4, originRequests)
+}
+
+// Responses personalized by the cookies or the Access token of the user mustn't be served to other users
+func TestCachingOriginUserRequests(t *testing.T) {
+	var originRequests int
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originRequests++
+		user := "anonymous"
+		if cookie, err := r.Cookie("session"); err == nil {
+			user = cookie.Value
+		} else if token := r.Header.Get("Cf-Access-Jwt-Assertion"); token != "" {
+			user = token
+		}
+		_, _ = io.WriteString(w, "hello "+user)
+	}))
+	defer origin.Close()
+
+	cache := newResponseCache(config.CacheConfig{TTL: config.CustomDuration{Duration: time.Hour}})
+	caching := &cachingOrigin{HTTPOriginProxy: http.DefaultTransport, cache: cache, rule: "0"}
+	roundTrip := func(header http.Header) string {
+		req, err := http.NewRequest(http.MethodGet, origin.URL+"/profile", nil)
+		require.NoError(t, err)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		resp, err := caching.RoundTrip(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, "hello alice", roundTrip(http.Header{"Cookie": []string{"session=alice"}}))
+	assert.Equal(t, "hello bob", roundTrip(http.Header{"Cookie": []string{"session=bob"}}))
+	assert.Equal(t, "hello carol", roundTrip(http.Header{"Cf-Access-Jwt-Assertion": []string{"carol"}}))
+	assert.Equal(t, "hello anonymous", roundTrip(nil))
+	assert.Equal(t, 4, originRequests)
+
+	// Only the anonymous response was cached
+	assert.Equal(t, "hello anonymous", roundTrip(nil))
+	assert.Equal(t, "hello alice", roundTrip(http.Header{"Cookie": []string{"session=alice"}}))
+	assert.Equal(t, 5, originRequests)
+}
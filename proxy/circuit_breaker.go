@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+const (
+	defaultCircuitBreakerCooldown = 30 * time.Second
+	defaultCircuitBreakerStatus   = http.StatusServiceUnavailable
+)
+
+// circuitBreaker stops sending requests to an origin once FailureThreshold consecutive requests failed, so that
+// they don't pile up on an origin that's down. Requests are short-circuited for the cooldown, after which they're
+// let through again: the first success closes the circuit and the first failure opens it again.
+type circuitBreaker struct {
+	threshold uint
+	cooldown  time.Duration
+	status    int
+
+	lock      sync.Mutex
+	failures  uint
+	openUntil time.Time
+}
+
+func newCircuitBreaker(cfg config.CircuitBreakerConfig) *circuitBreaker {
+	cb := &circuitBreaker{
+		threshold: cfg.FailureThreshold,
+		cooldown:  cfg.Cooldown.Duration,
+		status:    cfg.ResponseStatus,
+	}
+	if cb.cooldown <= 0 {
+		cb.cooldown = defaultCircuitBreakerCooldown
+	}
+	if cb.status == 0 {
+		cb.status = defaultCircuitBreakerStatus
+	}
+	return cb
+}
+
+// newCircuitBreakers returns the circuit breakers of the rules that enable one, by rule number.
+func newCircuitBreakers(ingressRules ingress.Ingress) map[int]*circuitBreaker {
+	breakers := make(map[int]*circuitBreaker)
+	for i, rule := range ingressRules.Rules {
+		if rule.Config.CircuitBreaker.FailureThreshold == 0 {
+			continue
+		}
+		if _, ok := rule.Service.(ingress.HTTPOriginProxy); ok {
+			breakers[i] = newCircuitBreaker(rule.Config.CircuitBreaker)
+		}
+	}
+	return breakers
+}
+
+// allow returns false if the circuit is open and the request should be short-circuited.
+func (cb *circuitBreaker) allow() bool {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	return !time.Now().Before(cb.openUntil)
+}
+
+// observe records the outcome of a request that was sent to the origin, and returns true if it opened the circuit.
+func (cb *circuitBreaker) observe(failed bool) bool {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if !failed {
+		cb.failures = 0
+		return false
+	}
+	cb.failures++
+	if cb.failures < cb.threshold {
+		return false
+	}
+	cb.openUntil = time.Now().Add(cb.cooldown)
+	return true
+}
+
+// circuitBreakingOrigin reports the outcome of the requests to an origin to its circuit breaker. Dial errors and
+// 5xx responses count as failures.
+type circuitBreakingOrigin struct {
+	ingress.HTTPOriginProxy
+	breaker *circuitBreaker
+	rule    string
+}
+
+func (o *circuitBreakingOrigin) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := o.HTTPOriginProxy.RoundTrip(req)
+	failed := err != nil && !isRequestBodyTooLarge(err) || err == nil && resp.StatusCode >= http.StatusInternalServerError
+	if o.breaker.observe(failed) {
+		circuitBreakerOpened.WithLabelValues(o.rule).Inc()
+	}
+	return resp, err
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestNewCircuitBreakerDefaults(t *testing.T) {
+	cb := newCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 3})
+	assert.Equal(t, defaultCircuitBreakerCooldown, cb.cooldown)
+	assert.Equal(t, http.StatusServiceUnavailable, cb.status)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := newCircuitBreaker(config.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		Cooldown:         config.CustomDuration{Duration: 50 * time.Millisecond},
+	})
+
+	// Successes reset the consecutive failures
+	assert.False(t, cb.observe(true))
+	assert.False(t, cb.observe(false))
+	assert.False(t, cb.observe(true))
+	assert.True(t, cb.allow())
+
+	assert.True(t, cb.observe(true))
+	assert.False(t, cb.allow())
+
+	// After the cooldown a single failure opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, cb.allow())
+	assert.True(t, cb.observe(true))
+	assert.False(t, cb.allow())
+
+	// and a success closes it
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, cb.allow())
+	assert.False(t, cb.observe(false))
+	assert.False(t, cb.observe(true))
+	assert.True(t, cb.allow())
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+const defaultConcurrencyLimitRetryAfter = time.Second
+
+// concurrencyLimiter caps the number of requests in flight to an origin, so that traffic spikes don't overwhelm
+// small origins. Requests over the limit wait up to queueTimeout for a slot, unless the queue is full, in which
+// case they're shed right away.
+type concurrencyLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+	// queue holds the requests waiting for a slot, it's nil when their number isn't limited
+	queue      chan struct{}
+	retryAfter time.Duration
+	rule       string
+}
+
+func newConcurrencyLimiter(cfg config.ConcurrencyLimitConfig, rule string) *concurrencyLimiter {
+	l := &concurrencyLimiter{
+		slots:        make(chan struct{}, cfg.MaxRequests),
+		queueTimeout: cfg.QueueTimeout.Duration,
+		retryAfter:   cfg.RetryAfter.Duration,
+		rule:         rule,
+	}
+	if cfg.MaxQueued > 0 {
+		l.queue = make(chan struct{}, cfg.MaxQueued)
+	}
+	if l.retryAfter <= 0 {
+		l.retryAfter = defaultConcurrencyLimitRetryAfter
+	}
+	return l
+}
+
+// newConcurrencyLimiters returns the concurrency limiters of the rules that limit their requests, by rule number.
+func newConcurrencyLimiters(ingressRules ingress.Ingress) map[int]*concurrencyLimiter {
+	limiters := make(map[int]*concurrencyLimiter)
+	for i, rule := range ingressRules.Rules {
+		if rule.Config.ConcurrencyLimit.MaxRequests != 0 {
+			limiters[i] = newConcurrencyLimiter(rule.Config.ConcurrencyLimit, strconv.Itoa(i))
+		}
+	}
+	return limiters
+}
+
+// acquire returns true once the request can be sent to the origin, in which case release must be called when
+// it completes. It returns false if the queue is full, or if no slot freed up within the queue timeout or before
+// ctx is done.
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queueTimeout <= 0 {
+		return false
+	}
+	if l.queue != nil {
+		select {
+		case l.queue <- struct{}{}:
+			defer func() { <-l.queue }()
+		default:
+			return false
+		}
+	}
+	queued := queuedRequests.WithLabelValues(l.rule)
+	queued.Inc()
+	defer queued.Dec()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
+
+// retryAfterHeader returns the Retry-After header of the responses to the requests that couldn't acquire a slot.
+func (l *concurrencyLimiter) retryAfterHeader() string {
+	return strconv.Itoa(int(math.Ceil(l.retryAfter.Seconds())))
+}
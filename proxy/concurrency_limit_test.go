@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := newConcurrencyLimiter(config.ConcurrencyLimitConfig{MaxRequests: 2}, "0")
+	ctx := context.Background()
+
+	require.True(t, limiter.acquire(ctx))
+	require.True(t, limiter.acquire(ctx))
+	assert.False(t, limiter.acquire(ctx))
+
+	limiter.release()
+	assert.True(t, limiter.acquire(ctx))
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	limiter := newConcurrencyLimiter(config.ConcurrencyLimitConfig{
+		MaxRequests:  1,
+		QueueTimeout: config.CustomDuration{Duration: time.Second},
+	}, "0")
+	ctx := context.Background()
+	require.True(t, limiter.acquire(ctx))
+
+	// A queued request gets the slot of the request that completes
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		limiter.release()
+	}()
+	assert.True(t, limiter.acquire(ctx))
+
+	// and gives up when it's canceled
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.False(t, limiter.acquire(ctx))
+
+	limiter.queueTimeout = 10 * time.Millisecond
+	assert.False(t, limiter.acquire(context.Background()))
+}
+
+func TestConcurrencyLimiterMaxQueued(t *testing.T) {
+	limiter := newConcurrencyLimiter(config.ConcurrencyLimitConfig{
+		MaxRequests:  1,
+		QueueTimeout: config.CustomDuration{Duration: time.Second},
+		MaxQueued:    1,
+	}, "0")
+	ctx := context.Background()
+	require.True(t, limiter.acquire(ctx))
+
+	queued := make(chan bool)
+	go func() {
+		queued <- limiter.acquire(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(limiter.queue) == 1
+	}, time.Second, time.Millisecond)
+
+	// The queue is full, so the request is shed without waiting
+	start := time.Now()
+	assert.False(t, limiter.acquire(ctx))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	limiter.release()
+	assert.True(t, <-queued)
+	assert.Empty(t, limiter.queue)
+}
+
+func TestConcurrencyLimiterRetryAfter(t *testing.T) {
+	limiter := newConcurrencyLimiter(config.ConcurrencyLimitConfig{MaxRequests: 1}, "0")
+	assert.Equal(t, "1", limiter.retryAfterHeader())
+
+	limiter = newConcurrencyLimiter(config.ConcurrencyLimitConfig{
+		MaxRequests: 1,
+		RetryAfter:  config.CustomDuration{Duration: 1500 * time.Millisecond},
+	}, "0")
+	assert.Equal(t, "2", limiter.retryAfterHeader())
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+// flushingWriter flushes the response bodies of origins that stream them, so that they reach the edge as they're
+// written rather than once the buffer of the connection fills. It flushes after every write when latency is
+// negative, and at most latency after a write otherwise.
+type flushingWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	latency time.Duration
+
+	// Delayed flushes race with writes
+	lock         sync.Mutex
+	flushPending bool
+	timer        *time.Timer
+}
+
+// newFlushingWriter returns a writer flushing w as configured by cfg, or nil if cfg doesn't change when w is
+// flushed or w can't be flushed.
+func newFlushingWriter(w io.Writer, cfg ingress.OriginRequestConfig) *flushingWriter {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil
+	}
+	var latency time.Duration
+	switch {
+	case cfg.DisableResponseBuffering:
+		latency = -1
+	case cfg.FlushInterval.Duration > 0:
+		latency = cfg.FlushInterval.Duration
+	default:
+		return nil
+	}
+	return &flushingWriter{
+		w:       w,
+		flusher: flusher,
+		latency: latency,
+	}
+}
+
+func (fw *flushingWriter) Write(p []byte) (int, error) {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	n, err := fw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if fw.latency < 0 {
+		fw.flusher.Flush()
+		return n, nil
+	}
+	if fw.flushPending {
+		return n, nil
+	}
+	if fw.timer == nil {
+		fw.timer = time.AfterFunc(fw.latency, fw.delayedFlush)
+	} else {
+		fw.timer.Reset(fw.latency)
+	}
+	fw.flushPending = true
+	return n, nil
+}
+
+func (fw *flushingWriter) delayedFlush() {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	// stop was called since the flush was scheduled
+	if !fw.flushPending {
+		return
+	}
+	fw.flusher.Flush()
+	fw.flushPending = false
+}
+
+// stop cancels the pending flush, it must be called once the response body was written.
+func (fw *flushingWriter) stop() {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	fw.flushPending = false
+	if fw.timer != nil {
+		fw.timer.Stop()
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+type countingFlusher struct {
+	bytes.Buffer
+	flushes int32
+}
+
+func (f *countingFlusher) Flush() {
+	atomic.AddInt32(&f.flushes, 1)
+}
+
+func TestNewFlushingWriter(t *testing.T) {
+	assert.Nil(t, newFlushingWriter(&countingFlusher{}, ingress.OriginRequestConfig{}))
+	// Writers that can't be flushed are left alone
+	assert.Nil(t, newFlushingWriter(&bytes.Buffer{}, ingress.OriginRequestConfig{DisableResponseBuffering: true}))
+	assert.NotNil(t, newFlushingWriter(httptest.NewRecorder(), ingress.OriginRequestConfig{DisableResponseBuffering: true}))
+}
+
+func TestFlushingWriterUnbuffered(t *testing.T) {
+	w := &countingFlusher{}
+	fw := newFlushingWriter(w, ingress.OriginRequestConfig{DisableResponseBuffering: true})
+	require.NotNil(t, fw)
+	defer fw.stop()
+
+	for _, event := range []string{"data: a\n\n", "data: b\n\n"} {
+		_, err := fw.Write([]byte(event))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&w.flushes))
+	assert.Equal(t, "data: a\n\ndata: b\n\n", w.String())
+}
+
+func TestFlushingWriterInterval(t *testing.T) {
+	w := &countingFlusher{}
+	fw := newFlushingWriter(w, ingress.OriginRequestConfig{
+		FlushInterval: config.CustomDuration{Duration: 50 * time.Millisecond},
+	})
+	require.NotNil(t, fw)
+
+	// Writes within the interval are flushed together
+	for i := 0; i < 3; i++ {
+		_, err := fw.Write([]byte("chunk"))
+		require.NoError(t, err)
+	}
+	assert.Zero(t, atomic.LoadInt32(&w.flushes))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&w.flushes) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// No flush is pending once stopped
+	_, err := fw.Write([]byte("chunk"))
+	require.NoError(t, err)
+	fw.stop()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&w.flushes))
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+// SetMaintenanceOverrides sets the rules put in or out of maintenance mode at runtime, by hostname. They take
+// precedence over the maintenance configuration of the rules.
+func (p *Proxy) SetMaintenanceOverrides(overrides map[string]bool) {
+	copied := make(map[string]bool, len(overrides))
+	for hostname, enabled := range overrides {
+		copied[hostname] = enabled
+	}
+	p.maintenanceOverrides.Store(&copied)
+}
+
+// inMaintenance returns true if the user-defined rule ruleNum is in maintenance mode.
+func (p *Proxy) inMaintenance(rule *ingress.Rule, ruleNum int) bool {
+	if ruleNum < 0 {
+		return false
+	}
+	if overrides := p.maintenanceOverrides.Load(); overrides != nil {
+		if enabled, ok := (*overrides)[rule.Hostname]; ok {
+			return enabled
+		}
+	}
+	return rule.Maintenance != nil && rule.Maintenance.Enabled
+}
+
+func serveMaintenancePage(w connection.ResponseWriter, rule *ingress.Rule) error {
+	page := rule.Maintenance.Page()
+	header := http.Header{
+		"Content-Type":   []string{"text/html; charset=utf-8"},
+		"Content-Length": []string{strconv.Itoa(len(page))},
+		"Cache-Control":  []string{"no-store"},
+	}
+	if err := w.WriteRespHeaders(http.StatusServiceUnavailable, header); err != nil {
+		return err
+	}
+	_, err := w.Write(page)
+	return err
+}
@@ -1,9 +1,18 @@
 package proxy
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
 )
 
 // Metrics uses connection.MetricsNamespace(aka cloudflared) as namespace and connection.TunnelSubsystem
@@ -43,6 +52,144 @@ var (
 			Help:      "Count of error proxying to origin",
 		},
 	)
+	grpcResponseByStatus = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "grpc_response_by_status",
+			Help:      "Count of gRPC responses by gRPC status code",
+		},
+		[]string{"grpc_status"},
+	)
+	requestsPerRule = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "requests_per_rule",
+			Help:      "Count of requests by ingress rule, hostname and class of response status code",
+		},
+		[]string{"ingress_rule", "hostname", "status_class"},
+	)
+	// Replaced by SetLatencyBuckets
+	requestDurationPerRule = newRequestDurationPerRule(prometheus.DefBuckets)
+
+	requestBytesPerRule = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "request_bytes_per_rule",
+			Help:      "Bytes of request and response bodies proxied by ingress rule, hostname and direction",
+		},
+		[]string{"ingress_rule", "hostname", "direction"},
+	)
+	streamsPerRule = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "streams_per_rule",
+			Help:      "Count of streams proxied to stream based origins, such as tcp://, by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	concurrentStreamsPerRule = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "concurrent_streams_per_rule",
+			Help:      "Concurrent streams proxied to stream based origins by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	streamBytesPerRule = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "stream_bytes_per_rule",
+			Help:      "Bytes proxied through streams to stream based origins by ingress rule and direction",
+		},
+		[]string{"ingress_rule", "direction"},
+	)
+	circuitBreakerOpened = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "circuit_breaker_opened",
+			Help:      "Count of times the circuit breaker of an origin opened by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	shortCircuitedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "short_circuited_requests",
+			Help:      "Count of requests that weren't sent to the origin because its circuit breaker was open by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	concurrencyLimitedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "concurrency_limited_requests",
+			Help:      "Count of requests rejected because too many requests were in flight to the origin by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	queuedRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "queued_requests",
+			Help:      "Number of requests waiting for other requests to the origin to complete by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	cacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "cache_hits",
+			Help:      "Count of requests served from the response cache by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	cacheMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "cache_misses",
+			Help:      "Count of cacheable requests sent to the origin because their response wasn't cached by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	retriedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "retried_requests",
+			Help:      "Count of retries of requests to origins by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	bufferedResponseBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "buffered_response_bytes",
+			Help:      "Count of bytes of origin responses buffered before being sent to the edge by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
+	responseBufferSpillovers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "response_buffer_spillovers",
+			Help:      "Count of origin responses larger than the response buffer, streamed from the origin past it, by ingress rule",
+		},
+		[]string{"ingress_rule"},
+	)
 )
 
 func init() {
@@ -51,9 +198,58 @@ func init() {
 		concurrentRequests,
 		responseByCode,
 		requestErrors,
+		grpcResponseByStatus,
+		requestsPerRule,
+		requestDurationPerRule,
+		requestBytesPerRule,
+		streamsPerRule,
+		concurrentStreamsPerRule,
+		streamBytesPerRule,
+		circuitBreakerOpened,
+		shortCircuitedRequests,
+		retriedRequests,
+		concurrencyLimitedRequests,
+		queuedRequests,
+		cacheHits,
+		cacheMisses,
+		bufferedResponseBytes,
+		responseBufferSpillovers,
+	)
+}
+
+func newRequestDurationPerRule(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "request_duration_seconds_per_rule",
+			Help:      "Time to proxy requests until their response was sent, by ingress rule and hostname",
+			Buckets:   buckets,
+		},
+		[]string{"ingress_rule", "hostname"},
 	)
 }
 
+// SetLatencyBuckets replaces the upper bounds, in seconds, of the buckets of the request latency histograms, so that
+// they fit the latency of the origins. It must be called before requests are proxied.
+func SetLatencyBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		return errors.New("no latency buckets")
+	}
+	for i, bucket := range buckets {
+		if bucket <= 0 || i > 0 && bucket <= buckets[i-1] {
+			return fmt.Errorf("latency buckets %v aren't positive and increasing", buckets)
+		}
+	}
+	histogram := newRequestDurationPerRule(buckets)
+	prometheus.Unregister(requestDurationPerRule)
+	if err := prometheus.Register(histogram); err != nil {
+		return err
+	}
+	requestDurationPerRule = histogram
+	return nil
+}
+
 func incrementRequests() {
 	totalRequests.Inc()
 	concurrentRequests.Inc()
@@ -62,3 +258,139 @@ func incrementRequests() {
 func decrementConcurrentRequests() {
 	concurrentRequests.Dec()
 }
+
+// observeGRPCStatus counts the status of resp in grpcResponseByStatus if it's a gRPC response, once its body was
+// read. The status is sent as a trailer, or as a header by responses that only have headers.
+func observeGRPCStatus(resp *http.Response) {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc") {
+		return
+	}
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+	}
+	if status == "" {
+		status = "unknown"
+	}
+	grpcResponseByStatus.WithLabelValues(status).Inc()
+}
+
+// meteredStream counts the bytes going through a stream to a stream based origin in streamBytesPerRule.
+type meteredStream struct {
+	io.ReadWriter
+	toOrigin   prometheus.Counter
+	fromOrigin prometheus.Counter
+}
+
+func newMeteredStream(rw io.ReadWriter, rule string) *meteredStream {
+	return &meteredStream{
+		ReadWriter: rw,
+		toOrigin:   streamBytesPerRule.WithLabelValues(rule, "tunnel->origin"),
+		fromOrigin: streamBytesPerRule.WithLabelValues(rule, "origin->tunnel"),
+	}
+}
+
+func (s *meteredStream) Read(p []byte) (int, error) {
+	n, err := s.ReadWriter.Read(p)
+	s.toOrigin.Add(float64(n))
+	return n, err
+}
+
+func (s *meteredStream) Write(p []byte) (int, error) {
+	n, err := s.ReadWriter.Write(p)
+	s.fromOrigin.Add(float64(n))
+	return n, err
+}
+
+// ruleMetrics records the requests proxied through an ingress rule in requestsPerRule, requestDurationPerRule and
+// requestBytesPerRule, so that the services behind a tunnel can be told apart.
+type ruleMetrics struct {
+	rule     string
+	hostname string
+	start    time.Time
+	writer   *meteredResponseWriter
+}
+
+func newRuleMetrics(w connection.ResponseWriter, req *http.Request, rule *ingress.Rule, ruleNum int) *ruleMetrics {
+	hostname := rule.Hostname
+	if hostname == "" {
+		// The catch-all rule
+		hostname = "*"
+	}
+	m := &ruleMetrics{
+		rule:     strconv.Itoa(ruleNum),
+		hostname: hostname,
+		start:    time.Now(),
+	}
+	m.writer = &meteredResponseWriter{
+		ResponseWriter: w,
+		bytes:          requestBytesPerRule.WithLabelValues(m.rule, hostname, "origin->tunnel"),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &meteredBody{
+			ReadCloser: req.Body,
+			bytes:      requestBytesPerRule.WithLabelValues(m.rule, hostname, "tunnel->origin"),
+		}
+	}
+	return m
+}
+
+// observe records the request once it was proxied.
+func (m *ruleMetrics) observe() {
+	requestsPerRule.WithLabelValues(m.rule, m.hostname, statusClass(m.writer.status)).Inc()
+	requestDurationPerRule.WithLabelValues(m.rule, m.hostname).Observe(time.Since(m.start).Seconds())
+}
+
+// statusClass returns the class of an HTTP status code, e.g. 5xx, or error if no response was sent.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// meteredResponseWriter keeps the status of the response and counts the bytes of its body.
+type meteredResponseWriter struct {
+	connection.ResponseWriter
+	bytes   prometheus.Counter
+	status  int
+	written int64
+}
+
+func (w *meteredResponseWriter) WriteRespHeaders(status int, header http.Header) error {
+	w.status = status
+	return w.ResponseWriter.WriteRespHeaders(status, header)
+}
+
+func (w *meteredResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *meteredResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes.Add(float64(n))
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *meteredResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// meteredBody counts the bytes read from a request body.
+type meteredBody struct {
+	io.ReadCloser
+	bytes prometheus.Counter
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes.Add(float64(n))
+	return n, err
+}
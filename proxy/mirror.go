@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+const (
+	// Largest request body that's mirrored, requests with larger ones aren't
+	maxMirroredBodySize = 1 << 20
+	// Most requests in flight to the mirror of a rule, further ones aren't mirrored
+	maxMirroredRequests = 100
+	mirrorTimeout       = 30 * time.Second
+)
+
+// mirror duplicates the requests of a rule to a shadow origin, to test it under real traffic. Mirroring is best
+// effort: the shadow origin's responses are discarded, and requests aren't mirrored rather than slowing down the
+// requests to the actual origin.
+type mirror struct {
+	origin ingress.HTTPOriginProxy
+	slots  chan struct{}
+	log    *zerolog.Logger
+}
+
+// newMirrors returns the mirrors of the rules that have one, by rule number.
+func newMirrors(ingressRules ingress.Ingress, log *zerolog.Logger) map[int]*mirror {
+	mirrors := make(map[int]*mirror)
+	for i, rule := range ingressRules.Rules {
+		if origin, ok := rule.Mirror.(ingress.HTTPOriginProxy); ok {
+			mirrors[i] = &mirror{
+				origin: origin,
+				slots:  make(chan struct{}, maxMirroredRequests),
+				log:    log,
+			}
+		}
+	}
+	return mirrors
+}
+
+// mirrorRequest prepares the mirroring of req, before it's sent to the origin. The returned function sends the
+// duplicate once the request to the origin completed, since its body is captured as the origin reads it.
+func (m *mirror) mirrorRequest(req *http.Request) func() {
+	duplicate := req.Clone(context.Background())
+	if req.Body == nil || req.Body == http.NoBody {
+		m.send(duplicate)
+		return func() {}
+	}
+
+	body := &capturingBody{ReadCloser: req.Body}
+	req.Body = body
+	return func() {
+		captured, ok := body.capturedBody()
+		if !ok {
+			return
+		}
+		duplicate.Body = io.NopCloser(bytes.NewReader(captured))
+		duplicate.ContentLength = int64(len(captured))
+		m.send(duplicate)
+	}
+}
+
+func (m *mirror) send(req *http.Request) {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.log.Debug().Msg("Too many mirrored requests in flight, not mirroring request")
+		return
+	}
+	go func() {
+		defer func() { <-m.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		defer cancel()
+		resp, err := m.origin.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			m.log.Debug().Err(err).Msg("Failed to mirror request")
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+}
+
+// capturingBody keeps a copy of the first maxMirroredBodySize bytes read from a request body.
+type capturingBody struct {
+	io.ReadCloser
+	// The transport can still be reading the body once the response was received
+	lock      sync.Mutex
+	captured  bytes.Buffer
+	complete  bool
+	truncated bool
+}
+
+// capturedBody returns the body if it was read completely and isn't larger than maxMirroredBodySize.
+func (b *capturingBody) capturedBody() ([]byte, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.complete || b.truncated {
+		return nil, false
+	}
+	return b.captured.Bytes(), true
+}
+
+func (b *capturingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.truncated {
+		if b.captured.Len()+n > maxMirroredBodySize {
+			b.truncated = true
+			b.captured.Reset()
+		} else {
+			b.captured.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.complete = true
+	}
+	return n, err
+}
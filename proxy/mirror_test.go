@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+type mirroredRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+func TestProxyMirror(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("origin"))
+	}))
+	defer origin.Close()
+	mirrored := make(chan mirroredRequest, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirrored <- mirroredRequest{method: r.Method, path: r.URL.Path, body: string(body)}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Hostname: "*",
+				Service:  origin.URL,
+				Mirror:   shadow.URL,
+			},
+		},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, ing.StartOrigins(&log, ctx.Done()))
+	proxy := NewOriginProxy(ing, noWarpRouting, testTags, &log)
+
+	proxyRequest := func(method, path, body string) {
+		req, err := http.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if body == "" {
+			req.Body = http.NoBody
+		}
+		responseWriter := newMockHTTPRespWriter()
+		require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		// The response of the shadow origin is discarded
+		assert.Equal(t, http.StatusOK, responseWriter.Code)
+		assert.Equal(t, "origin", responseWriter.Body.String())
+	}
+	expectMirrored := func(expected mirroredRequest) {
+		select {
+		case req := <-mirrored:
+			assert.Equal(t, expected, req)
+		case <-time.After(time.Second):
+			t.Fatalf("request %v wasn't mirrored", expected)
+		}
+	}
+
+	proxyRequest(http.MethodGet, "/get", "")
+	expectMirrored(mirroredRequest{method: http.MethodGet, path: "/get"})
+	proxyRequest(http.MethodPost, "/post", "body")
+	expectMirrored(mirroredRequest{method: http.MethodPost, path: "/post", body: "body"})
+
+	// Bodies too large to be kept aren't mirrored
+	proxyRequest(http.MethodPost, "/large", strings.Repeat("a", maxMirroredBodySize+1))
+	select {
+	case req := <-mirrored:
+		t.Fatalf("request %s was mirrored", req.path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
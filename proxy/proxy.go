@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -20,6 +22,7 @@ import (
 	"github.com/cloudflare/cloudflared/stream"
 	"github.com/cloudflare/cloudflared/tracing"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/websocket"
 )
 
 const (
@@ -32,17 +35,30 @@ const (
 	LogFieldFlowID        = "flowID"
 	LogFieldConnIndex     = "connIndex"
 	LogFieldDestAddr      = "destAddr"
+	LogFieldRequestID     = "requestID"
 
 	trailerHeaderName = "Trailer"
 )
 
 // Proxy represents a means to Proxy between cloudflared and the origin services.
 type Proxy struct {
-	ingressRules ingress.Ingress
-	warpRouting  *ingress.WarpRoutingService
-	management   *ingress.ManagementService
-	tags         []tunnelpogs.Tag
-	log          *zerolog.Logger
+	ingressRules    ingress.Ingress
+	circuitBreakers map[int]*circuitBreaker
+	retryPolicies   map[int]*retryPolicy
+	limiters        map[int]*concurrencyLimiter
+	mirrors         map[int]*mirror
+	caches          map[int]*responseCache
+	bandwidth       map[int]*bandwidthLimiter
+	// Throttles the traffic of all rules, and of warp routing
+	globalBandwidth *bandwidthLimiter
+	// Nil if access logging is disabled
+	accessLog *AccessLog
+	// Underlying value is the rules put in or out of maintenance mode at runtime, by hostname
+	maintenanceOverrides atomic.Pointer[map[string]bool]
+	warpRouting          *ingress.WarpRoutingService
+	management           *ingress.ManagementService
+	tags                 []tunnelpogs.Tag
+	log                  *zerolog.Logger
 }
 
 // NewOriginProxy returns a new instance of the Proxy struct.
@@ -53,9 +69,15 @@ func NewOriginProxy(
 	log *zerolog.Logger,
 ) *Proxy {
 	proxy := &Proxy{
-		ingressRules: ingressRules,
-		tags:         tags,
-		log:          log,
+		ingressRules:    ingressRules,
+		circuitBreakers: newCircuitBreakers(ingressRules),
+		retryPolicies:   newRetryPolicies(ingressRules),
+		limiters:        newConcurrencyLimiters(ingressRules),
+		mirrors:         newMirrors(ingressRules, log),
+		caches:          newResponseCaches(ingressRules),
+		bandwidth:       newBandwidthLimiters(ingressRules),
+		tags:            tags,
+		log:             log,
 	}
 	if warpRouting.Enabled {
 		proxy.warpRouting = ingress.NewWarpRoutingService(warpRouting)
@@ -91,8 +113,18 @@ func (p *Proxy) ProxyHTTP(
 	defer decrementConcurrentRequests()
 
 	req := tr.Request
+	if tracing.OTLPEnabled() && !trace.SpanContextFromContext(req.Context()).IsValid() {
+		// The spans of requests that aren't traced by the edge, only exported over OTLP, need a
+		// common root, which continues the trace of the client if it sent a traceparent header
+		ctx, requestSpan := tr.Tracer().Start(tracing.ExtractTraceParent(req.Context(), req.Header), "proxy_request",
+			trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attribute.String("req-host", req.Host)))
+		defer requestSpan.End()
+		req = req.WithContext(ctx)
+		tr.Request = req
+	}
 	cfRay := connection.FindCfRayHeader(req)
 	lbProbe := connection.IsLBProbeRequest(req)
+	requestID := ensureRequestID(req)
 	p.appendTagHeaders(req)
 
 	_, ruleSpan := tr.Tracer().Start(req.Context(), "ingress_match",
@@ -100,6 +132,7 @@ func (p *Proxy) ProxyHTTP(
 	rule, ruleNum := p.ingressRules.FindMatchingRule(req.Host, req.URL.Path)
 	logFields := logFields{
 		cfRay:     cfRay,
+		requestID: requestID,
 		lbProbe:   lbProbe,
 		rule:      ruleNum,
 		connIndex: tr.ConnIndex,
@@ -107,27 +140,87 @@ func (p *Proxy) ProxyHTTP(
 	p.logRequest(req, logFields)
 	ruleSpan.SetAttributes(attribute.Int("rule-num", ruleNum))
 	ruleSpan.End()
+	metrics := newRuleMetrics(w, req, rule, ruleNum)
+	w = metrics.writer
+	if p.accessLog != nil && !rule.Config.DisableAccessLog {
+		entry := newAccessLogEntry(req, logFields)
+		defer p.accessLog.log(entry, metrics.writer)
+	}
+	defer metrics.observe()
+	if p.inMaintenance(rule, ruleNum) {
+		p.log.Debug().Int(LogFieldRule, ruleNum).Str(LogFieldCFRay, cfRay).Msg("Rule is in maintenance mode, serving maintenance page")
+		return serveMaintenancePage(w, rule)
+	}
+	if rule.Config.ProxyProtocol != "" {
+		// Origins are told the client IP in a PROXY protocol header when cloudflared connects to them
+		clientIP := net.ParseIP(req.Header.Get("Cf-Connecting-Ip"))
+		tr.Request = req.WithContext(ingress.ContextWithClientIP(req.Context(), clientIP))
+		req = tr.Request
+	}
+	if rule.Config.RewritePath != "" || rule.Config.StripPrefix != "" {
+		req.URL.Path = rule.RewritePath(req.URL.Path)
+		req.URL.RawPath = ""
+	}
 	if err, applied := p.applyIngressMiddleware(rule, req, w); err != nil {
 		if applied {
 			rule, srv := ruleField(p.ingressRules, ruleNum)
-			p.logRequestError(err, cfRay, "", rule, srv)
+			p.logRequestError(err, cfRay, requestID, "", rule, srv)
 			return nil
 		}
 		return err
 	}
 
+	if limiter, ok := p.limiters[ruleNum]; ok {
+		if !limiter.acquire(req.Context()) {
+			concurrencyLimitedRequests.WithLabelValues(strconv.Itoa(ruleNum)).Inc()
+			p.log.Debug().Int(LogFieldRule, ruleNum).Str(LogFieldCFRay, cfRay).Msg("Too many requests in flight to the origin, rejecting request")
+			return w.WriteRespHeaders(http.StatusServiceUnavailable, http.Header{"Retry-After": []string{limiter.retryAfterHeader()}})
+		}
+		defer limiter.release()
+	}
+
 	switch originProxy := rule.Service.(type) {
 	case ingress.HTTPOriginProxy:
+		rule.RewriteRequestHeaders(req)
+		if maxSize := rule.Config.MaxRequestBodySize; maxSize > 0 && !isWebsocket {
+			if req.ContentLength > maxSize {
+				p.log.Debug().Int(LogFieldRule, ruleNum).Str(LogFieldCFRay, cfRay).Msg("Request body is larger than maxRequestBodySize, rejecting request")
+				return w.WriteRespHeaders(http.StatusRequestEntityTooLarge, http.Header{})
+			}
+			if req.Body != nil && req.Body != http.NoBody {
+				// The size of chunked bodies is only known once they're read
+				req.Body = http.MaxBytesReader(nil, req.Body, maxSize)
+			}
+		}
+		req.Body = p.globalBandwidth.uploadBody(req.Context(), p.bandwidth[ruleNum].uploadBody(req.Context(), req.Body))
+		if mirror, ok := p.mirrors[ruleNum]; ok && !isWebsocket {
+			defer mirror.mirrorRequest(req)()
+		}
+		if breaker, ok := p.circuitBreakers[ruleNum]; ok {
+			if !breaker.allow() {
+				shortCircuitedRequests.WithLabelValues(strconv.Itoa(ruleNum)).Inc()
+				p.log.Debug().Int(LogFieldRule, ruleNum).Str(LogFieldCFRay, cfRay).Msg("Origin circuit breaker is open, short-circuiting request")
+				return w.WriteRespHeaders(breaker.status, http.Header{})
+			}
+			originProxy = &circuitBreakingOrigin{HTTPOriginProxy: originProxy, breaker: breaker, rule: strconv.Itoa(ruleNum)}
+		}
+		if policy, ok := p.retryPolicies[ruleNum]; ok && !isWebsocket {
+			originProxy = &retryingOrigin{HTTPOriginProxy: originProxy, policy: policy, rule: strconv.Itoa(ruleNum)}
+		}
+		if cache, ok := p.caches[ruleNum]; ok && !isWebsocket {
+			// Responses served from the cache don't count against the circuit breaker nor get retried
+			originProxy = &cachingOrigin{HTTPOriginProxy: originProxy, cache: cache, rule: strconv.Itoa(ruleNum)}
+		}
 		if err := p.proxyHTTPRequest(
 			w,
 			tr,
 			originProxy,
 			isWebsocket,
-			rule.Config.DisableChunkedEncoding,
+			rule,
 			logFields,
 		); err != nil {
 			rule, srv := ruleField(p.ingressRules, ruleNum)
-			p.logRequestError(err, cfRay, "", rule, srv)
+			p.logRequestError(err, cfRay, requestID, "", rule, srv)
 			return err
 		}
 		return nil
@@ -137,10 +230,15 @@ func (p *Proxy) ProxyHTTP(
 			return err
 		}
 
+		if isWebsocket && websocket.OffersPerMessageDeflate(req.Header) {
+			// The websocket ends in cloudflared rather than at the origin, so it compresses the messages itself
+			req = req.WithContext(websocket.ContextWithPerMessageDeflate(req.Context()))
+			tr.Request = req
+		}
 		rws := connection.NewHTTPResponseReadWriterAcker(w, req)
-		if err := p.proxyStream(tr.ToTracedContext(), rws, dest, originProxy); err != nil {
+		if err := p.proxyStream(tr.ToTracedContext(), rws, dest, originProxy, strconv.Itoa(ruleNum), p.bandwidth[ruleNum]); err != nil {
 			rule, srv := ruleField(p.ingressRules, ruleNum)
-			p.logRequestError(err, cfRay, "", rule, srv)
+			p.logRequestError(err, cfRay, requestID, "", rule, srv)
 			return err
 		}
 		return nil
@@ -171,6 +269,12 @@ func (p *Proxy) ProxyTCP(
 	defer cancel()
 
 	tracedCtx := tracing.NewTracedContext(serveCtx, req.CfTraceID, p.log)
+	if tracing.OTLPEnabled() && !trace.SpanContextFromContext(tracedCtx.Context).IsValid() {
+		var streamSpan trace.Span
+		tracedCtx.Context, streamSpan = tracedCtx.Tracer().Start(tracedCtx.Context, "proxy_stream",
+			trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attribute.String("dest", req.Dest)))
+		defer streamSpan.End()
+	}
 
 	p.log.Debug().
 		Int(management.EventTypeKey, int(management.TCP)).
@@ -179,8 +283,8 @@ func (p *Proxy) ProxyTCP(
 		Uint8(LogFieldConnIndex, req.ConnIndex).
 		Msg("tcp proxy stream started")
 
-	if err := p.proxyStream(tracedCtx, rwa, req.Dest, p.warpRouting.Proxy); err != nil {
-		p.logRequestError(err, req.CFRay, req.FlowID, "", ingress.ServiceWarpRouting)
+	if err := p.proxyStream(tracedCtx, rwa, req.Dest, p.warpRouting.Proxy, ingress.ServiceWarpRouting, nil); err != nil {
+		p.logRequestError(err, req.CFRay, "", req.FlowID, "", ingress.ServiceWarpRouting)
 		return err
 	}
 
@@ -194,6 +298,12 @@ func (p *Proxy) ProxyTCP(
 	return nil
 }
 
+// isRequestBodyTooLarge returns true if err means the request body was larger than maxRequestBodySize.
+func isRequestBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
 func ruleField(ing ingress.Ingress, ruleNum int) (ruleID string, srv string) {
 	srv = ing.Rules[ruleNum].Service.String()
 	if ing.IsSingleRule() {
@@ -208,7 +318,7 @@ func (p *Proxy) proxyHTTPRequest(
 	tr *tracing.TracedHTTPRequest,
 	httpService ingress.HTTPOriginProxy,
 	isWebsocket bool,
-	disableChunkedEncoding bool,
+	rule *ingress.Rule,
 	fields logFields,
 ) error {
 	roundTripReq := tr.Request
@@ -221,7 +331,7 @@ func (p *Proxy) proxyHTTPRequest(
 		roundTripReq.Body = nil
 	} else {
 		// Support for WSGI Servers by switching transfer encoding from chunked to gzip/deflate
-		if disableChunkedEncoding {
+		if rule.Config.DisableChunkedEncoding {
 			roundTripReq.TransferEncoding = []string{"gzip", "deflate"}
 			cLength, err := strconv.Atoi(tr.Request.Header.Get("Content-Length"))
 			if err == nil {
@@ -237,25 +347,51 @@ func (p *Proxy) proxyHTTPRequest(
 		roundTripReq.Header.Set("User-Agent", "")
 	}
 
-	_, ttfbSpan := tr.Tracer().Start(tr.Context(), "ttfb_origin")
+	ttfbCtx, ttfbSpan := tr.Tracer().Start(tr.Context(), "ttfb_origin")
+	if tracing.OTLPEnabled() {
+		tracing.InjectTraceParent(ttfbCtx, roundTripReq.Header)
+	}
 	resp, err := httpService.RoundTrip(roundTripReq)
 	if err != nil {
 		tracing.EndWithErrorStatus(ttfbSpan, err)
+		if isRequestBodyTooLarge(err) {
+			p.log.Debug().Int(LogFieldRule, fields.rule).Str(LogFieldCFRay, fields.cfRay).Msg("Request body is larger than maxRequestBodySize, rejecting request")
+			return w.WriteRespHeaders(http.StatusRequestEntityTooLarge, http.Header{})
+		}
 		if err := roundTripReq.Context().Err(); err != nil {
 			return errors.Wrap(err, "Incoming request ended abruptly")
 		}
-		return errors.Wrap(err, "Unable to reach the origin service. The service may be down or it may not be responding to traffic from cloudflared")
+		err = errors.Wrap(err, "Unable to reach the origin service. The service may be down or it may not be responding to traffic from cloudflared")
+		if rule.ErrorPage == nil {
+			return err
+		}
+		ruleName, srv := ruleField(p.ingressRules, fields.rule)
+		p.logRequestError(err, fields.cfRay, fields.requestID, "", ruleName, srv)
+		status, header, body := rule.ErrorPage.Response(tr.Request, err)
+		if err := w.WriteRespHeaders(status, header); err != nil {
+			return errors.Wrap(err, "Error writing error page headers")
+		}
+		_, err = w.Write(body)
+		return err
 	}
 
 	tracing.EndWithStatusCode(ttfbSpan, resp.StatusCode)
 	defer resp.Body.Close()
 
+	if maxSize := rule.Config.ResponseBuffer.MaxSize; maxSize > 0 {
+		if err := bufferResponse(resp, maxSize, strconv.Itoa(fields.rule)); err != nil {
+			return errors.Wrap(err, "Error reading origin response")
+		}
+	}
+
 	headers := make(http.Header, len(resp.Header))
 	// copy headers
 	for k, v := range resp.Header {
 		headers[k] = v
 	}
 
+	rule.RewriteResponseHeaders(tr.Request, headers)
+
 	// Add spans to response header (if available)
 	tr.AddSpans(headers)
 
@@ -264,6 +400,14 @@ func (p *Proxy) proxyHTTPRequest(
 		return errors.Wrap(err, "Error writing response header")
 	}
 
+	// Throttles and flushes the response body, the headers were already written
+	var download io.Writer = w
+	if fw := newFlushingWriter(w, rule.Config); fw != nil {
+		defer fw.stop()
+		download = fw
+	}
+	body := p.globalBandwidth.downloadWriter(tr.Request.Context(), p.bandwidth[fields.rule].downloadWriter(tr.Request.Context(), download))
+
 	if resp.StatusCode == http.StatusSwitchingProtocols {
 		rwc, ok := resp.Body.(io.ReadWriteCloser)
 		if !ok {
@@ -272,7 +416,7 @@ func (p *Proxy) proxyHTTPRequest(
 		defer rwc.Close()
 
 		eyeballStream := &bidirectionalStream{
-			writer: w,
+			writer: body,
 			reader: tr.Request.Body,
 		}
 
@@ -280,24 +424,28 @@ func (p *Proxy) proxyHTTPRequest(
 		return nil
 	}
 
-	if _, err = cfio.Copy(w, resp.Body); err != nil {
+	if _, err = cfio.Copy(body, resp.Body); err != nil {
 		return err
 	}
 
 	// copy trailers
 	copyTrailers(w, resp)
+	observeGRPCStatus(resp)
 
 	p.logOriginResponse(resp, fields)
 	return nil
 }
 
 // proxyStream proxies type TCP and other underlying types if the connection is defined as a stream oriented
-// ingress rule.
+// ingress rule. The stream is accounted for in the per rule stream metrics under rule, and throttled by bandwidth
+// unless it's nil.
 func (p *Proxy) proxyStream(
 	tr *tracing.TracedContext,
 	rwa connection.ReadWriteAcker,
 	dest string,
 	connectionProxy ingress.StreamBasedOriginProxy,
+	rule string,
+	bandwidth *bandwidthLimiter,
 ) error {
 	ctx := tr.Context
 	_, connectSpan := tr.Tracer().Start(ctx, "stream-connect")
@@ -315,7 +463,12 @@ func (p *Proxy) proxyStream(
 		return err
 	}
 
-	originConn.Stream(ctx, rwa, p.log)
+	streamsPerRule.WithLabelValues(rule).Inc()
+	concurrentStreams := concurrentStreamsPerRule.WithLabelValues(rule)
+	concurrentStreams.Inc()
+	defer concurrentStreams.Dec()
+
+	originConn.Stream(ctx, p.globalBandwidth.stream(ctx, bandwidth.stream(ctx, newMeteredStream(rwa, rule))), p.log)
 	return nil
 }
 
@@ -351,6 +504,7 @@ func (p *Proxy) appendTagHeaders(r *http.Request) {
 
 type logFields struct {
 	cfRay     string
+	requestID string
 	lbProbe   bool
 	rule      int
 	flowID    string
@@ -371,6 +525,9 @@ func (p *Proxy) logRequest(r *http.Request, fields logFields) {
 	if fields.cfRay != "" {
 		event = event.Str(LogFieldCFRay, fields.cfRay)
 	}
+	if fields.requestID != "" {
+		event = event.Str(LogFieldRequestID, fields.requestID)
+	}
 	if fields.lbProbe {
 		event = event.Bool(LogFieldLBProbe, fields.lbProbe)
 	}
@@ -393,6 +550,9 @@ func (p *Proxy) logOriginResponse(resp *http.Response, fields logFields) {
 	if fields.cfRay != "" {
 		event = event.Str(LogFieldCFRay, fields.cfRay)
 	}
+	if fields.requestID != "" {
+		event = event.Str(LogFieldRequestID, fields.requestID)
+	}
 	if fields.lbProbe {
 		event = event.Bool(LogFieldLBProbe, fields.lbProbe)
 	}
@@ -403,12 +563,15 @@ func (p *Proxy) logOriginResponse(resp *http.Response, fields logFields) {
 		Msgf("%s", resp.Status)
 }
 
-func (p *Proxy) logRequestError(err error, cfRay string, flowID string, rule, service string) {
+func (p *Proxy) logRequestError(err error, cfRay, requestID, flowID string, rule, service string) {
 	requestErrors.Inc()
 	log := p.log.Error().Err(err)
 	if cfRay != "" {
 		log = log.Str(LogFieldCFRay, cfRay)
 	}
+	if requestID != "" {
+		log = log.Str(LogFieldRequestID, requestID)
+	}
 	if flowID != "" {
 		log = log.Str(LogFieldFlowID, flowID).Int(management.EventTypeKey, int(management.TCP))
 	} else {
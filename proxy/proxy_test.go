@@ -12,11 +12,15 @@ import (
 	"net/http/httptest"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gobwas/ws/wsutil"
+	"github.com/google/uuid"
 	gorillaWS "github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -349,6 +353,71 @@ func TestProxyMultipleOrigins(t *testing.T) {
 	runIngressTestScenarios(t, unvalidatedIngress, tests)
 }
 
+func TestProxyPathRewrite(t *testing.T) {
+	echoPath := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer echoPath.Close()
+
+	rewritePath := "/${version}/$2"
+	stripPrefix := "/app"
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "api.example.com",
+			Path:     `^/api/v(?P<version>[0-9]+)/(.*)$`,
+			Service:  echoPath.URL,
+			OriginRequest: config.OriginRequestConfig{
+				RewritePath: &rewritePath,
+			},
+		},
+		{
+			Hostname: "app.example.com",
+			Path:     "^/app/",
+			Service:  echoPath.URL,
+			OriginRequest: config.OriginRequestConfig{
+				StripPrefix: &stripPrefix,
+			},
+		},
+		{
+			Hostname: "static.example.com",
+			Path:     "glob:/assets/**.js",
+			Service:  echoPath.URL,
+		},
+		{
+			Hostname: "*",
+			Service:  "http_status:404",
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{
+			url:            "http://api.example.com/api/v2/users/1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []byte("/2/users/1"),
+		},
+		{
+			url:            "http://api.example.com/api/users/1",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			url:            "http://app.example.com/app/users/1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []byte("/users/1"),
+		},
+		{
+			url:            "http://static.example.com/assets/js/app.js",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []byte("/assets/js/app.js"),
+		},
+		{
+			url:            "http://static.example.com/assets/app.css",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+}
+
 type MultipleIngressTest struct {
 	url            string
 	expectedStatus int
@@ -714,6 +783,49 @@ func TestConnections(t *testing.T) {
 	}
 }
 
+func TestMeteredStream(t *testing.T) {
+	const rule = "test-metered-stream"
+	rw := &bytes.Buffer{}
+	stream := newMeteredStream(rw, rule)
+
+	n, err := stream.Write([]byte("from origin"))
+	require.NoError(t, err)
+	assert.Equal(t, 11, n)
+	n, err = stream.Read(make([]byte, 4))
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	for direction, expected := range map[string]float64{"tunnel->origin": 4, "origin->tunnel": 11} {
+		m := &dto.Metric{}
+		require.NoError(t, streamBytesPerRule.WithLabelValues(rule, direction).Write(m))
+		assert.Equal(t, expected, m.Counter.GetValue(), direction)
+	}
+}
+
+func TestObserveGRPCStatus(t *testing.T) {
+	grpcStatus := func(status string) float64 {
+		m := &dto.Metric{}
+		require.NoError(t, grpcResponseByStatus.WithLabelValues(status).Write(m))
+		return m.Counter.GetValue()
+	}
+	okBefore, notFoundBefore := grpcStatus("0"), grpcStatus("5")
+
+	observeGRPCStatus(&http.Response{
+		Header:  http.Header{"Content-Type": {"application/grpc+proto"}},
+		Trailer: http.Header{"Grpc-Status": {"0"}},
+	})
+	// Trailers-only responses carry the status in the headers
+	observeGRPCStatus(&http.Response{
+		Header: http.Header{"Content-Type": {"application/grpc"}, "Grpc-Status": {"5"}},
+	})
+	observeGRPCStatus(&http.Response{
+		Header: http.Header{"Content-Type": {"text/plain"}, "Grpc-Status": {"5"}},
+	})
+
+	assert.Equal(t, okBefore+1, grpcStatus("0"))
+	assert.Equal(t, notFoundBefore+1, grpcStatus("5"))
+}
+
 type requestBody struct {
 	pw *io.PipeWriter
 	pr *io.PipeReader
@@ -977,3 +1089,437 @@ func runEchoWSService(t *testing.T, l net.Listener) {
 		require.NoError(t, err)
 	}()
 }
+
+func TestProxyCircuitBreaker(t *testing.T) {
+	var originRequests int32
+	failingOrigin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&originRequests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failingOrigin.Close()
+
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Service:  failingOrigin.URL,
+			OriginRequest: config.OriginRequestConfig{
+				CircuitBreaker: &config.CircuitBreakerConfig{
+					FailureThreshold: 2,
+					Cooldown:         config.CustomDuration{Duration: time.Hour},
+					ResponseStatus:   http.StatusTooManyRequests,
+				},
+			},
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{url: "http://example.com", expectedStatus: http.StatusBadGateway},
+		{url: "http://example.com", expectedStatus: http.StatusBadGateway},
+		{url: "http://example.com", expectedStatus: http.StatusTooManyRequests},
+		{url: "http://example.com", expectedStatus: http.StatusTooManyRequests},
+	}
+
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&originRequests))
+}
+
+func TestProxyRetry(t *testing.T) {
+	var originRequests int32
+	restartingOrigin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&originRequests, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer restartingOrigin.Close()
+
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Service:  restartingOrigin.URL,
+			OriginRequest: config.OriginRequestConfig{
+				Retry: &config.RetryConfig{
+					MaxAttempts: 2,
+					Backoff:     config.CustomDuration{Duration: time.Millisecond},
+				},
+			},
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{url: "http://example.com", expectedStatus: http.StatusOK, expectedBody: []byte("ok")},
+		{url: "http://example.com", expectedStatus: http.StatusOK, expectedBody: []byte("ok")},
+	}
+
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&originRequests))
+}
+
+func TestProxyMaxRequestBodySize(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer origin.Close()
+
+	maxSize := int64(10)
+	ingress, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Hostname: "*",
+				Service:  origin.URL,
+				OriginRequest: config.OriginRequestConfig{
+					MaxRequestBodySize: &maxSize,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, ingress.StartOrigins(&log, ctx.Done()))
+	proxy := NewOriginProxy(ingress, noWarpRouting, testTags, &log)
+
+	tests := []struct {
+		body           string
+		chunked        bool
+		expectedStatus int
+	}{
+		{body: "small", expectedStatus: http.StatusOK},
+		{body: "larger than 10 bytes", expectedStatus: http.StatusRequestEntityTooLarge},
+		{body: "small", chunked: true, expectedStatus: http.StatusOK},
+		{body: strings.Repeat("larger than 10 bytes", 1000), chunked: true, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(test.body))
+		require.NoError(t, err)
+		if test.chunked {
+			req.ContentLength = -1
+			req.Body = io.NopCloser(req.Body)
+		}
+
+		responseWriter := newMockHTTPRespWriter()
+		require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		assert.Equal(t, test.expectedStatus, responseWriter.Code)
+		if test.expectedStatus == http.StatusOK {
+			assert.Equal(t, test.body, responseWriter.Body.String())
+		}
+	}
+}
+
+func TestProxyRewriteHeaders(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "ALLOW")
+		w.Header().Set("X-Request-Client", r.Header.Get("X-Client"))
+	}))
+	defer origin.Close()
+
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Hostname: "*",
+				Service:  origin.URL,
+				OriginRequest: config.OriginRequestConfig{
+					RequestHeaders: &config.HeadersConfig{
+						Set: map[string]string{"X-Client": "${client_ip}"},
+					},
+					ResponseHeaders: &config.HeadersConfig{
+						Set: map[string]string{"X-Frame-Options": "DENY"},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, ing.StartOrigins(&log, ctx.Done()))
+	proxy := NewOriginProxy(ing, noWarpRouting, testTags, &log)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Cf-Connecting-Ip", "192.0.2.1")
+	responseWriter := newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, "DENY", responseWriter.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "192.0.2.1", responseWriter.Header().Get("X-Request-Client"))
+}
+
+func TestProxyMaintenance(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer origin.Close()
+
+	ingressRules, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Hostname:    "upgrading.example.com",
+				Service:     origin.URL,
+				Maintenance: &config.MaintenanceConfig{Enabled: true},
+			},
+			{
+				Service: origin.URL,
+			},
+		},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, ingressRules.StartOrigins(&log, ctx.Done()))
+	proxy := NewOriginProxy(ingressRules, noWarpRouting, testTags, &log)
+
+	assertResponse := func(url string, expectedStatus int, expectedBody string) {
+		responseWriter := newMockHTTPRespWriter()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		assert.Equal(t, expectedStatus, responseWriter.Code, url)
+		assert.Contains(t, responseWriter.Body.String(), expectedBody, url)
+	}
+
+	assertResponse("http://upgrading.example.com", http.StatusServiceUnavailable, "Down for maintenance")
+	assertResponse("http://example.com", http.StatusOK, "ok")
+
+	// Runtime overrides take precedence over the configuration
+	proxy.SetMaintenanceOverrides(map[string]bool{"upgrading.example.com": false, "": true})
+	assertResponse("http://upgrading.example.com", http.StatusOK, "ok")
+	assertResponse("http://example.com", http.StatusServiceUnavailable, "Down for maintenance")
+}
+
+func TestProxyErrorPage(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedOrigin := fmt.Sprintf("http://%s", listener.Addr())
+	require.NoError(t, listener.Close())
+
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Service:  closedOrigin,
+			OriginRequest: config.OriginRequestConfig{
+				ErrorPage: &config.ErrorPageConfig{Body: "origin error: ${error_code}"},
+			},
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{url: "http://example.com", expectedStatus: http.StatusBadGateway, expectedBody: []byte("origin error: connection_refused")},
+	}
+
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+}
+
+func TestProxyStatusResponse(t *testing.T) {
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Path:     "^/robots.txt$",
+			Service:  "http_status:200",
+			OriginRequest: config.OriginRequestConfig{
+				StatusResponse: &config.StatusResponseConfig{Body: "User-agent: *\nDisallow: /\n"},
+			},
+		},
+		{
+			Service: "http_status:404",
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{url: "http://example.com/robots.txt", expectedStatus: http.StatusOK, expectedBody: []byte("User-agent: *\nDisallow: /\n")},
+		{url: "http://example.com/", expectedStatus: http.StatusNotFound},
+	}
+
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+}
+
+func TestProxyCache(t *testing.T) {
+	var originRequests int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&originRequests, 1)
+		_, _ = w.Write([]byte("cached"))
+	}))
+	defer origin.Close()
+
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Service:  origin.URL,
+			OriginRequest: config.OriginRequestConfig{
+				Cache: &config.CacheConfig{TTL: config.CustomDuration{Duration: time.Hour}},
+			},
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{url: "http://example.com", expectedStatus: http.StatusOK, expectedBody: []byte("cached")},
+		{url: "http://example.com", expectedStatus: http.StatusOK, expectedBody: []byte("cached")},
+		{url: "http://example.com", expectedStatus: http.StatusOK, expectedBody: []byte("cached")},
+	}
+
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&originRequests))
+}
+
+func TestProxyBandwidthLimit(t *testing.T) {
+	body := bytes.Repeat([]byte("a"), 150)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer origin.Close()
+
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Service:  origin.URL,
+			OriginRequest: config.OriginRequestConfig{
+				BandwidthLimit: &config.BandwidthLimitConfig{DownloadBytesPerSecond: 100},
+			},
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{url: "http://example.com", expectedStatus: http.StatusOK, expectedBody: body},
+	}
+
+	start := time.Now()
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+	// The first 100 bytes are let through at once, the other 50 take half a second
+	assert.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond)
+}
+
+func TestProxyResponseBuffer(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Query().Get("body")))
+	}))
+	defer origin.Close()
+
+	unvalidatedIngress := []config.UnvalidatedIngressRule{
+		{
+			Hostname: "*",
+			Service:  origin.URL,
+			OriginRequest: config.OriginRequestConfig{
+				ResponseBuffer: &config.ResponseBufferConfig{MaxSize: 10},
+			},
+		},
+	}
+
+	tests := []MultipleIngressTest{
+		{url: "http://example.com?body=buffered", expectedStatus: http.StatusOK, expectedBody: []byte("buffered")},
+		{url: "http://example.com?body=spilled-over", expectedStatus: http.StatusOK, expectedBody: []byte("spilled-over")},
+	}
+
+	runIngressTestScenarios(t, unvalidatedIngress, tests)
+}
+
+func TestProxyRequestID(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get(RequestIDHeader)))
+	}))
+	defer origin.Close()
+
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress:  []config.UnvalidatedIngressRule{{Hostname: "*", Service: origin.URL}},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, ing.StartOrigins(&log, ctx.Done()))
+	proxy := NewOriginProxy(ing, noWarpRouting, testTags, &log)
+
+	// The ID sent by the client is propagated
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set(RequestIDHeader, "client-request-id")
+	responseWriter := newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, "client-request-id", responseWriter.Body.String())
+
+	// An ID is generated otherwise
+	req, err = http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	responseWriter = newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	_, err = uuid.Parse(responseWriter.Body.String())
+	assert.NoError(t, err)
+}
+
+func TestProxyRuleMetrics(t *testing.T) {
+	const hostname = "rule-metrics.example.com"
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer origin.Close()
+
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID: t.Name(),
+		Ingress: []config.UnvalidatedIngressRule{
+			{Hostname: hostname, Service: origin.URL},
+			{Service: "http_status:404"},
+		},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, ing.StartOrigins(&log, ctx.Done()))
+	proxy := NewOriginProxy(ing, noWarpRouting, testTags, &log)
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+hostname, strings.NewReader("hello"))
+	require.NoError(t, err)
+	responseWriter := newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, http.StatusBadGateway, responseWriter.Code)
+
+	m := &dto.Metric{}
+	require.NoError(t, requestsPerRule.WithLabelValues("0", hostname, "5xx").Write(m))
+	assert.Equal(t, float64(1), m.Counter.GetValue())
+	for direction, expected := range map[string]float64{"tunnel->origin": 5, "origin->tunnel": 5} {
+		m := &dto.Metric{}
+		require.NoError(t, requestBytesPerRule.WithLabelValues("0", hostname, direction).Write(m))
+		assert.Equal(t, expected, m.Counter.GetValue(), direction)
+	}
+	m = &dto.Metric{}
+	require.NoError(t, requestDurationPerRule.WithLabelValues("0", hostname).(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(1), m.Histogram.GetSampleCount())
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "1xx", statusClass(http.StatusSwitchingProtocols))
+	assert.Equal(t, "2xx", statusClass(http.StatusOK))
+	assert.Equal(t, "4xx", statusClass(http.StatusNotFound))
+	assert.Equal(t, "5xx", statusClass(http.StatusServiceUnavailable))
+	assert.Equal(t, "error", statusClass(0))
+}
+
+func TestSetLatencyBuckets(t *testing.T) {
+	defer func() { require.NoError(t, SetLatencyBuckets(prometheus.DefBuckets)) }()
+
+	assert.Error(t, SetLatencyBuckets(nil))
+	assert.Error(t, SetLatencyBuckets([]float64{0.1, 0.05}))
+	assert.Error(t, SetLatencyBuckets([]float64{0, 1}))
+
+	require.NoError(t, SetLatencyBuckets([]float64{0.001, 0.002}))
+	requestDurationPerRule.WithLabelValues("0", "latency-buckets.example.com").Observe(0.0015)
+	m := &dto.Metric{}
+	require.NoError(t, requestDurationPerRule.WithLabelValues("0", "latency-buckets.example.com").(prometheus.Histogram).Write(m))
+	require.Len(t, m.Histogram.Bucket, 2)
+	assert.Equal(t, 0.001, m.Histogram.Bucket[0].GetUpperBound())
+	assert.Equal(t, uint64(0), m.Histogram.Bucket[0].GetCumulativeCount())
+	assert.Equal(t, uint64(1), m.Histogram.Bucket[1].GetCumulativeCount())
+}
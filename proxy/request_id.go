@@ -0,0 +1,24 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-Id"
+	// Longest request ID that's propagated, longer ones are replaced
+	maxRequestIDLen = 128
+)
+
+// ensureRequestID returns the ID of req, generating one if the client didn't send it. The ID is sent to the origin
+// and logged, so that the logs of cloudflared and the origin can be correlated.
+func ensureRequestID(req *http.Request) string {
+	if id := req.Header.Get(RequestIDHeader); id != "" && len(id) <= maxRequestIDLen {
+		return id
+	}
+	id := uuid.New().String()
+	req.Header.Set(RequestIDHeader, id)
+	return id
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// bufferResponse reads the body of resp into memory, up to maxSize bytes, so that the connection to the origin is
+// released before the response is sent to a slow client. The rest of larger bodies, the spillover, is streamed from
+// the origin once the buffered part was sent. Upgraded connections and streaming responses aren't buffered.
+func bufferResponse(resp *http.Response, maxSize int64, rule string) error {
+	if resp.StatusCode == http.StatusSwitchingProtocols || isStreamingResponse(resp) {
+		return nil
+	}
+	if resp.ContentLength > maxSize {
+		responseBufferSpillovers.WithLabelValues(rule).Inc()
+		return nil
+	}
+
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(resp.Body, maxSize))
+	bufferedResponseBytes.WithLabelValues(rule).Add(float64(n))
+	if err != nil {
+		return err
+	}
+	if n == maxSize {
+		// The body may be exactly maxSize bytes long
+		var next [1]byte
+		switch _, err := io.ReadFull(resp.Body, next[:]); err {
+		case nil:
+			responseBufferSpillovers.WithLabelValues(rule).Inc()
+			resp.Body = &spilledBody{
+				Reader: io.MultiReader(&buf, bytes.NewReader(next[:]), resp.Body),
+				Closer: resp.Body,
+			}
+			return nil
+		case io.EOF:
+		default:
+			return err
+		}
+	}
+	// Releases the connection to the origin
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(&buf)
+	return nil
+}
+
+// isStreamingResponse returns true if the body of resp is consumed as it's written, like server-sent events and
+// gRPC streams.
+func isStreamingResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream" || strings.HasPrefix(mediaType, "application/grpc")
+}
+
+// spilledBody is the body of a response larger than the response buffer, the buffered part followed by the rest
+// of the body of the origin.
+type spilledBody struct {
+	io.Reader
+	io.Closer
+}
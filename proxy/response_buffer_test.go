@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestBufferResponse(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		contentType   string
+		buffered      bool
+	}{
+		{name: "smaller than the buffer", body: "body", contentLength: -1, buffered: true},
+		{name: "as large as the buffer", body: "0123456789", contentLength: -1, buffered: true},
+		{name: "larger than the buffer", body: "0123456789abc", contentLength: -1},
+		{name: "declared larger than the buffer", body: "0123456789abc", contentLength: 13},
+		{name: "event stream", body: "data: a\n\n", contentLength: -1, contentType: "text/event-stream; charset=utf-8"},
+		{name: "gRPC", body: "body", contentLength: -1, contentType: "application/grpc+proto"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			origin := &trackingBody{Reader: strings.NewReader(test.body)}
+			resp := &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": []string{test.contentType}},
+				ContentLength: test.contentLength,
+				Body:          origin,
+			}
+			require.NoError(t, bufferResponse(resp, 10, "0"))
+			// The connection to the origin is released once the whole body was buffered
+			assert.Equal(t, test.buffered, origin.closed)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.body, string(body))
+			require.NoError(t, resp.Body.Close())
+			assert.True(t, origin.closed)
+		})
+	}
+}
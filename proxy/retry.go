@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+const defaultRetryBackoff = 100 * time.Millisecond
+
+var (
+	defaultRetryMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodOptions,
+		http.MethodPut,
+		http.MethodDelete,
+		http.MethodTrace,
+	}
+	defaultRetryStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+)
+
+// retryPolicy decides which requests to an origin are retried, so that an origin restarting doesn't surface as
+// errors to the users.
+type retryPolicy struct {
+	maxAttempts uint
+	backoff     time.Duration
+	methods     map[string]struct{}
+	statusCodes map[int]struct{}
+}
+
+func newRetryPolicy(cfg config.RetryConfig) *retryPolicy {
+	policy := &retryPolicy{
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.Backoff.Duration,
+		methods:     make(map[string]struct{}),
+		statusCodes: make(map[int]struct{}),
+	}
+	if policy.backoff <= 0 {
+		policy.backoff = defaultRetryBackoff
+	}
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = defaultRetryMethods
+	}
+	for _, method := range methods {
+		policy.methods[strings.ToUpper(method)] = struct{}{}
+	}
+	statusCodes := cfg.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = defaultRetryStatusCodes
+	}
+	for _, status := range statusCodes {
+		policy.statusCodes[status] = struct{}{}
+	}
+	return policy
+}
+
+// newRetryPolicies returns the retry policies of the rules that retry requests, by rule number.
+func newRetryPolicies(ingressRules ingress.Ingress) map[int]*retryPolicy {
+	policies := make(map[int]*retryPolicy)
+	for i, rule := range ingressRules.Rules {
+		if rule.Config.Retry.MaxAttempts <= 1 {
+			continue
+		}
+		if _, ok := rule.Service.(ingress.HTTPOriginProxy); ok {
+			policies[i] = newRetryPolicy(rule.Config.Retry)
+		}
+	}
+	return policies
+}
+
+// retryable returns true if req can be sent again. Requests with a body aren't, since it was consumed by the
+// previous attempt.
+func (p *retryPolicy) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	_, ok := p.methods[req.Method]
+	return ok
+}
+
+func (p *retryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return isDialError(err)
+	}
+	_, ok := p.statusCodes[resp.StatusCode]
+	return ok
+}
+
+// isDialError returns true if err means the origin couldn't be connected to, in which case it didn't see the
+// request.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryingOrigin retries the requests to an origin according to its retry policy, waiting for an exponential
+// backoff between attempts.
+type retryingOrigin struct {
+	ingress.HTTPOriginProxy
+	policy *retryPolicy
+	rule   string
+}
+
+func (o *retryingOrigin) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !o.policy.retryable(req) {
+		return o.HTTPOriginProxy.RoundTrip(req)
+	}
+	backoff := o.policy.backoff
+	for attempt := uint(1); ; attempt++ {
+		resp, err := o.HTTPOriginProxy.RoundTrip(req)
+		if attempt >= o.policy.maxAttempts || !o.policy.shouldRetry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		retriedRequests.WithLabelValues(o.rule).Inc()
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+type mockStatusOrigin struct {
+	statuses []int
+	requests int
+}
+
+func (o *mockStatusOrigin) RoundTrip(*http.Request) (*http.Response, error) {
+	status := o.statuses[o.requests]
+	o.requests++
+	return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+}
+
+func TestNewRetryPolicyDefaults(t *testing.T) {
+	policy := newRetryPolicy(config.RetryConfig{MaxAttempts: 3, Methods: []string{"get"}})
+	assert.Equal(t, defaultRetryBackoff, policy.backoff)
+	assert.Equal(t, map[string]struct{}{http.MethodGet: {}}, policy.methods)
+	assert.Equal(t, map[int]struct{}{http.StatusBadGateway: {}, http.StatusServiceUnavailable: {}}, policy.statusCodes)
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := newRetryPolicy(config.RetryConfig{MaxAttempts: 3})
+
+	get, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	assert.True(t, policy.retryable(get))
+
+	post, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	require.NoError(t, err)
+	assert.False(t, policy.retryable(post))
+
+	put, err := http.NewRequest(http.MethodPut, "http://example.com", strings.NewReader("body"))
+	require.NoError(t, err)
+	assert.False(t, policy.retryable(put))
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := newRetryPolicy(config.RetryConfig{MaxAttempts: 3})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+	req, err := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String(), nil)
+	require.NoError(t, err)
+	_, err = http.DefaultTransport.RoundTrip(req)
+	require.Error(t, err)
+	assert.True(t, policy.shouldRetry(nil, err))
+	assert.False(t, policy.shouldRetry(nil, io.ErrUnexpectedEOF))
+
+	assert.True(t, policy.shouldRetry(&http.Response{StatusCode: http.StatusServiceUnavailable}, nil))
+	assert.False(t, policy.shouldRetry(&http.Response{StatusCode: http.StatusInternalServerError}, nil))
+}
+
+func TestRetryingOrigin(t *testing.T) {
+	policy := newRetryPolicy(config.RetryConfig{
+		MaxAttempts: 3,
+		Backoff:     config.CustomDuration{Duration: time.Millisecond},
+	})
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	origin := &mockStatusOrigin{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}}
+	resp, err := (&retryingOrigin{HTTPOriginProxy: origin, policy: policy}).RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, origin.requests)
+
+	// The last response is returned once the attempts run out
+	origin = &mockStatusOrigin{statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}}
+	resp, err = (&retryingOrigin{HTTPOriginProxy: origin, policy: policy}).RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, 3, origin.requests)
+
+	// Retries stop when the request is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	origin = &mockStatusOrigin{statuses: []int{http.StatusBadGateway, http.StatusOK}}
+	_, err = (&retryingOrigin{HTTPOriginProxy: origin, policy: policy}).RoundTrip(req.WithContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, origin.requests)
+}
@@ -31,6 +31,11 @@ type BackoffHandler struct {
 	RetryForever bool
 	// BaseTime sets the initial backoff period.
 	BaseTime time.Duration
+	// MaxTime caps the backoff period. The default value of 0 doesn't cap it.
+	MaxTime time.Duration
+	// MinWait is the fraction of the backoff period that is always waited, only the rest of it is
+	// randomized. The default value of 0 randomizes the whole period.
+	MinWait float64
 
 	retries       uint
 	resetDeadline time.Time
@@ -51,7 +56,7 @@ func (b BackoffHandler) GetMaxBackoffDuration(ctx context.Context) (time.Duratio
 	if b.retries >= b.MaxRetries && !b.RetryForever {
 		return time.Duration(0), false
 	}
-	maxTimeToWait := b.GetBaseTime() * 1 << (b.retries + 1)
+	maxTimeToWait := b.capTime(b.GetBaseTime() * 1 << (b.retries + 1))
 	return maxTimeToWait, true
 }
 
@@ -69,8 +74,12 @@ func (b *BackoffHandler) BackoffTimer() <-chan time.Time {
 	} else {
 		b.retries++
 	}
-	maxTimeToWait := time.Duration(b.GetBaseTime() * 1 << (b.retries))
-	timeToWait := time.Duration(rand.Int63n(maxTimeToWait.Nanoseconds()))
+	maxTimeToWait := b.capTime(b.GetBaseTime() * 1 << (b.retries))
+	minTimeToWait := time.Duration(float64(maxTimeToWait) * b.minWait())
+	timeToWait := minTimeToWait
+	if randomized := maxTimeToWait - minTimeToWait; randomized > 0 {
+		timeToWait += time.Duration(rand.Int63n(randomized.Nanoseconds()))
+	}
 	return Clock.After(timeToWait)
 }
 
@@ -104,6 +113,24 @@ func (b BackoffHandler) GetBaseTime() time.Duration {
 	return b.BaseTime
 }
 
+func (b BackoffHandler) capTime(d time.Duration) time.Duration {
+	if b.MaxTime > 0 && (d > b.MaxTime || d <= 0) {
+		return b.MaxTime
+	}
+	return d
+}
+
+func (b BackoffHandler) minWait() float64 {
+	switch {
+	case b.MinWait < 0:
+		return 0
+	case b.MinWait > 1:
+		return 1
+	default:
+		return b.MinWait
+	}
+}
+
 // Retries returns the number of retries consumed so far.
 func (b *BackoffHandler) Retries() int {
 	return int(b.retries)
@@ -145,3 +145,43 @@ func TestBackoffRetryForever(t *testing.T) {
 		t.Fatalf("backoff returned %v instead of 8 seconds on fifth retry", duration)
 	}
 }
+
+func TestBackoffMaxTime(t *testing.T) {
+	var waited time.Duration
+	Clock.After = func(d time.Duration) <-chan time.Time {
+		waited = d
+		return immediateTimeAfter(d)
+	}
+	ctx := context.Background()
+	backoff := BackoffHandler{MaxRetries: 10, BaseTime: time.Second, MaxTime: 5 * time.Second, MinWait: 1}
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, expectedWait := range expected {
+		if !backoff.Backoff(ctx) {
+			t.Fatalf("backoff failed after %d retries", i)
+		}
+		if waited != expectedWait {
+			t.Fatalf("backoff waited %s instead of %s on retry %d", waited, expectedWait, i+1)
+		}
+	}
+	if duration, ok := backoff.GetMaxBackoffDuration(ctx); !ok || duration != 5*time.Second {
+		t.Fatalf("backoff (%s) wasn't capped to 5 seconds", duration)
+	}
+}
+
+func TestBackoffMinWait(t *testing.T) {
+	var waited time.Duration
+	Clock.After = func(d time.Duration) <-chan time.Time {
+		waited = d
+		return immediateTimeAfter(d)
+	}
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		backoff := BackoffHandler{MaxRetries: 3, BaseTime: time.Second, MinWait: 0.75}
+		if !backoff.Backoff(ctx) {
+			t.Fatalf("backoff failed immediately")
+		}
+		if waited < 1500*time.Millisecond || waited >= 2*time.Second {
+			t.Fatalf("backoff waited %s, which isn't within the last quarter of 2 seconds", waited)
+		}
+	}
+}
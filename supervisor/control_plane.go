@@ -0,0 +1,97 @@
+package supervisor
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloudflare/cloudflared/controlplane"
+)
+
+// controlPlaneServer exposes the connections of the supervisor to a control plane integration over gRPC.
+type controlPlaneServer struct {
+	controlplane.UnimplementedControlPlaneServer
+	supervisor *Supervisor
+}
+
+// startControlPlane starts serving the gRPC control plane on config.ControlPlaneListen until ctx is done.
+func (s *Supervisor) startControlPlane(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.ControlPlaneListen)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for the control plane")
+	}
+	go func() {
+		if err := s.serveControlPlane(ctx, listener); err != nil {
+			s.log.Logger().Err(err).Msg("control plane terminated")
+		}
+	}()
+	return nil
+}
+
+// serveControlPlane serves the gRPC control plane on the listener until ctx is done. Connections are
+// secured with config.ControlPlaneTLS, which should require client certificates for mTLS.
+func (s *Supervisor) serveControlPlane(ctx context.Context, listener net.Listener) error {
+	var opts []grpc.ServerOption
+	if s.config.ControlPlaneTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.config.ControlPlaneTLS)))
+	}
+	server := grpc.NewServer(opts...)
+	controlplane.RegisterControlPlaneServer(server, &controlPlaneServer{supervisor: s})
+
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+	s.log.Logger().Info().Str("address", listener.Addr().String()).Msg("Serving control plane")
+	return server.Serve(listener)
+}
+
+func (c *controlPlaneServer) ListConnections(context.Context, *controlplane.ListConnectionsRequest) (*controlplane.ListConnectionsResponse, error) {
+	var resp controlplane.ListConnectionsResponse
+	for index, ci := range c.supervisor.tracker.Connections() {
+		conn := &controlplane.Connection{
+			Index:     uint32(index),
+			Connected: ci.IsConnected,
+			Protocol:  ci.Protocol.String(),
+		}
+		if !ci.ConnectedAt.IsZero() {
+			conn.ConnectedAt = timestamppb.New(ci.ConnectedAt)
+		}
+		resp.Connections = append(resp.Connections, conn)
+	}
+	sort.Slice(resp.Connections, func(i, j int) bool {
+		return resp.Connections[i].Index < resp.Connections[j].Index
+	})
+	return &resp, nil
+}
+
+func (c *controlPlaneServer) Reconnect(ctx context.Context, req *controlplane.ReconnectRequest) (*controlplane.ReconnectResponse, error) {
+	if c.supervisor.reconnectCh == nil {
+		return nil, status.Error(codes.Unavailable, "reconnecting is not supported")
+	}
+	reconnect := ReconnectSignal{Delay: req.GetDelay().AsDuration()}
+	select {
+	case c.supervisor.reconnectCh <- reconnect:
+		c.supervisor.log.Logger().Info().Msgf("Sending %+v requested by the control plane", reconnect)
+		return &controlplane.ReconnectResponse{}, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (c *controlPlaneServer) ScaleConnections(ctx context.Context, req *controlplane.ScaleConnectionsRequest) (*controlplane.ScaleConnectionsResponse, error) {
+	if err := c.supervisor.ScaleHAConnections(ctx, int(req.GetHaConnections())); err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &controlplane.ScaleConnectionsResponse{}, nil
+}
@@ -0,0 +1,100 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/controlplane"
+	"github.com/cloudflare/cloudflared/tunnelstate"
+)
+
+func newTestControlPlaneClient(t *testing.T, s *Supervisor) controlplane.ControlPlaneClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = s.serveControlPlane(ctx, listener)
+	}()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		<-served
+	})
+	return controlplane.NewControlPlaneClient(conn)
+}
+
+func TestControlPlaneListConnections(t *testing.T) {
+	s := newTestSupervisor(t, &TunnelConfig{}, nil, nil)
+	connectedAt := time.Now().Add(-time.Minute)
+	s.tracker = tunnelstate.MockedConnTracker(map[uint8]tunnelstate.ConnectionInfo{
+		1: {IsConnected: true, Protocol: connection.HTTP2, ConnectedAt: connectedAt},
+		0: {IsConnected: true, Protocol: connection.QUIC, ConnectedAt: connectedAt},
+		2: {IsConnected: false},
+	})
+	client := newTestControlPlaneClient(t, s)
+
+	resp, err := client.ListConnections(context.Background(), &controlplane.ListConnectionsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Connections, 3)
+	for i, conn := range resp.Connections {
+		assert.Equal(t, uint32(i), conn.Index)
+	}
+	assert.True(t, resp.Connections[0].Connected)
+	assert.Equal(t, connection.QUIC.String(), resp.Connections[0].Protocol)
+	assert.True(t, connectedAt.Equal(resp.Connections[0].ConnectedAt.AsTime()))
+	assert.Equal(t, connection.HTTP2.String(), resp.Connections[1].Protocol)
+	assert.False(t, resp.Connections[2].Connected)
+	assert.Nil(t, resp.Connections[2].ConnectedAt)
+}
+
+func TestControlPlaneReconnect(t *testing.T) {
+	s := newTestSupervisor(t, &TunnelConfig{}, nil, nil)
+	s.reconnectCh = make(chan ReconnectSignal, 1)
+	client := newTestControlPlaneClient(t, s)
+
+	_, err := client.Reconnect(context.Background(), &controlplane.ReconnectRequest{Delay: durationpb.New(time.Second)})
+	require.NoError(t, err)
+	select {
+	case reconnect := <-s.reconnectCh:
+		assert.Equal(t, time.Second, reconnect.Delay)
+	default:
+		t.Fatal("reconnect signal wasn't sent")
+	}
+}
+
+func TestControlPlaneScaleConnections(t *testing.T) {
+	s := newTestSupervisor(t, &TunnelConfig{}, nil, nil)
+	client := newTestControlPlaneClient(t, s)
+
+	go func() {
+		req := <-s.scaleC
+		assert.Equal(t, 3, req.haConnections)
+		req.result <- nil
+		req = <-s.scaleC
+		req.result <- fmt.Errorf("not enough edge addresses")
+	}()
+
+	_, err := client.ScaleConnections(context.Background(), &controlplane.ScaleConnectionsRequest{HaConnections: 3})
+	require.NoError(t, err)
+	_, err = client.ScaleConnections(context.Background(), &controlplane.ScaleConnectionsRequest{HaConnections: 30})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.ScaleConnections(context.Background(), &controlplane.ScaleConnectionsRequest{HaConnections: 0})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
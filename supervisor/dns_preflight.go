@@ -0,0 +1,49 @@
+package supervisor
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+const preflightDNSTimeout = 5 * time.Second
+
+// Redeclare network functions so they can be overridden in tests.
+var preflightLookupSRV = func(ctx context.Context, resolver *net.Resolver, service, proto, name string) ([]*net.SRV, error) {
+	_, addrs, err := resolver.LookupSRV(ctx, service, proto, name)
+	return addrs, err
+}
+
+// DNSUnavailableError is returned when the pre-flight DNS check fails, meaning that
+// any attempt to reach the edge would fail for reasons unrelated to the edge itself.
+type DNSUnavailableError struct {
+	cause error
+}
+
+func (e DNSUnavailableError) Error() string {
+	return e.cause.Error()
+}
+
+func (e DNSUnavailableError) Cause() error {
+	return e.cause
+}
+
+// checkDNSAvailable verifies that DNS is resolving by looking up the SRV record the edge is discovered from, with
+// the resolver edge discovery uses.
+func checkDNSAvailable(ctx context.Context, config *TunnelConfig) error {
+	resolver := config.EdgeResolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	srv := allregions.RegionalSRVRecord(config.Region, config.EdgeSRVRecord)
+	lookupCtx, cancel := context.WithTimeout(ctx, preflightDNSTimeout)
+	defer cancel()
+	if _, err := preflightLookupSRV(lookupCtx, resolver, srv.Service, srv.Proto, srv.Name); err != nil {
+		return DNSUnavailableError{cause: errors.Wrap(err, "DNS unavailable")}
+	}
+	return nil
+}
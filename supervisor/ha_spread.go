@@ -0,0 +1,82 @@
+package supervisor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// Interval between checks that the HA connections are spread across regions, a variable so that tests can
+// shorten it
+var haSpreadCheckInterval = time.Second * 30
+
+// HASpreadPolicy is how the HA connections are placed across the edge regions.
+type HASpreadPolicy string
+
+const (
+	// HASpreadNone balances the edge addresses left in each region, without regard for where the HA
+	// connections are.
+	HASpreadNone HASpreadPolicy = "none"
+	// HASpreadRegion places the HA connections across both regions, and moves one of them to the other
+	// region if they all ended up in the same one.
+	HASpreadRegion HASpreadPolicy = "region"
+)
+
+// updateRegionPlacement exports how many connections use an edge address of each region.
+func (s *Supervisor) updateRegionPlacement() {
+	connections, _ := s.edgeIPs.RegionPlacement()
+	for i, n := range connections {
+		haConnectionsPerRegion.WithLabelValues(strconv.Itoa(i + 1)).Set(float64(n))
+	}
+}
+
+// rebalanceRegions is called by the Run loop to check that the HA connections aren't all in the same
+// region. If they are, and the other region has addresses left, the running connection with the highest
+// index is closed, and started again on an address of the other region once it's closed.
+func (s *Supervisor) rebalanceRegions() {
+	haConnections := int(s.haConnections.Load())
+	if haConnections < 2 || len(s.tunnelsRebalancing) > 0 {
+		return
+	}
+	connections, available := s.edgeIPs.RegionPlacement()
+	crowded := -1
+	for i, n := range connections {
+		if n == 0 {
+			crowded = 1 - i
+			if available[i] == 0 {
+				return
+			}
+		}
+	}
+	if crowded < 0 || connections[crowded] < 2 {
+		return
+	}
+
+	for index := haConnections - 1; index >= 0; index-- {
+		if _, ok := s.tunnelsRunning[index]; !ok || s.edgeIPs.RegionUsedBy(index) != crowded {
+			continue
+		}
+		s.log.Logger().Warn().Int(connection.LogFieldConnIndex, index).
+			Msgf("All %d connections use edge region %d, moving one of them to region %d", connections[crowded], crowded+1, 2-crowded)
+		s.tunnelsRebalancing[index] = struct{}{}
+		s.tunnelStopper.stop(index)
+		return
+	}
+}
+
+// tunnelRebalanced is called by the Run loop when a tunnel returned. It returns false if the tunnel wasn't
+// closed to move it to another region, in which case it's handled like any other tunnel. Otherwise, it's
+// started again on an address of the region the fewest connections use.
+func (s *Supervisor) tunnelRebalanced(ctx context.Context, index int) bool {
+	if _, ok := s.tunnelsRebalancing[index]; !ok {
+		return false
+	}
+	delete(s.tunnelsRebalancing, index)
+	s.edgeIPs.ReleaseAddr(index)
+	s.tunnelStopper.resume(index)
+	s.tunnelsRunning[index] = struct{}{}
+	go s.startTunnel(ctx, index, s.tunnelsProtocolFallback[index], s.newConnectedTunnelSignal(index))
+	return true
+}
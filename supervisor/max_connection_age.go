@@ -0,0 +1,39 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MaxConnectionAgeError is returned when a connection lived for as long as it's allowed to, so that it's
+// re-established on a different edge address.
+type MaxConnectionAgeError struct {
+	Age time.Duration
+}
+
+func (e MaxConnectionAgeError) Error() string {
+	return fmt.Sprintf("connection reached its maximum age of %s", e.Age)
+}
+
+// maxConnectionAge returns how long the connection with the given index may live. The ages are staggered
+// across the HA connections, so that they aren't all re-established at the same time.
+func maxConnectionAge(maxAge time.Duration, connIndex uint8, haConnections int) time.Duration {
+	if haConnections <= 1 {
+		return maxAge
+	}
+	return maxAge + time.Duration(connIndex)*maxAge/time.Duration(haConnections)
+}
+
+// waitMaxConnectionAge returns a MaxConnectionAgeError once the connection lived for maxAge, or nil if the
+// context is done before that.
+func waitMaxConnectionAge(ctx context.Context, maxAge time.Duration) error {
+	timer := time.NewTimer(maxAge)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-timer.C:
+		return MaxConnectionAgeError{Age: maxAge}
+	}
+}
@@ -18,10 +18,48 @@ var (
 			Help:      "Number of active ha connections",
 		},
 	)
+	oldestConnectionAge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "oldest_connection_age_seconds",
+			Help:      "Time since the longest-lived active connection was established",
+		},
+	)
+	pqHandshakes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "post_quantum_handshakes",
+			Help:      "Count of handshakes with the edge offering only post-quantum key agreements, by outcome",
+		},
+		[]string{"outcome"},
+	)
+	haConnectionsPerRegion = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "ha_connections_per_region",
+			Help:      "Number of ha connections using an edge address of each region",
+		},
+		[]string{"region"},
+	)
+	degradedConnections = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "degraded_connections",
+			Help:      "Number of connections retried slowly because they restarted too often",
+		},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(
 		haConnections,
+		oldestConnectionAge,
+		haConnectionsPerRegion,
+		degradedConnections,
+		pqHandshakes,
 	)
 }
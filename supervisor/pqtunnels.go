@@ -29,6 +29,47 @@ var (
 	pqtWaitForMessage int
 )
 
+// PQMode is how a tunnel uses the post-quantum key agreements.
+type PQMode string
+
+const (
+	// PQModeStrict only allows the post-quantum key agreements, which only the quic transport supports.
+	PQModeStrict PQMode = "strict"
+	// PQModePrefer offers the post-quantum key agreements first over either transport, and falls back to the
+	// classical ones for a connection whose post-quantum handshake failed.
+	PQModePrefer PQMode = "prefer"
+)
+
+// pqCurvePreferences returns the post-quantum key agreements to offer, the one at kexIdx first.
+func pqCurvePreferences(kexIdx int) []tls.CurveID {
+	cs := make([]tls.CurveID, len(PQKexes))
+	copy(cs, PQKexes[:])
+
+	// It is unclear whether Kyber512 or Kyber768 will become the standard.
+	// Kyber768 is a bit bigger (and doesn't fit in one initial
+	// datagram anymore). We're enabling both, but pick randomly which
+	// one to put first. (TLS will use the first one in the list
+	// and allows a fallback to the second.)
+	cs[0], cs[kexIdx] = cs[kexIdx], cs[0]
+	return cs
+}
+
+// pqTLSConfig returns a copy of tlsConfig that only allows the post-quantum key agreements.
+func pqTLSConfig(tlsConfig *tls.Config, kexIdx int) *tls.Config {
+	pqConfig := tlsConfig.Clone()
+	pqConfig.CurvePreferences = pqCurvePreferences(kexIdx)
+	return pqConfig
+}
+
+// recordPQHandshake counts a handshake that offered only the post-quantum key agreements.
+func recordPQHandshake(err error) {
+	if err != nil {
+		pqHandshakes.WithLabelValues("failure").Inc()
+	} else {
+		pqHandshakes.WithLabelValues("success").Inc()
+	}
+}
+
 func handlePQTunnelError(rep error, config *TunnelConfig) {
 	needToMessage := false
 
@@ -0,0 +1,69 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgediscovery"
+	"github.com/cloudflare/cloudflared/tunnelstate"
+)
+
+func getPQHandshakes(t *testing.T, outcome string) float64 {
+	var m = &dto.Metric{}
+	require.NoError(t, pqHandshakes.WithLabelValues(outcome).Write(m))
+	return m.Counter.GetValue()
+}
+
+func TestPQCurvePreferences(t *testing.T) {
+	assert.Equal(t, []tls.CurveID{PQKexes[0], PQKexes[1]}, pqCurvePreferences(0))
+	assert.Equal(t, []tls.CurveID{PQKexes[1], PQKexes[0]}, pqCurvePreferences(1))
+
+	tlsConfig := &tls.Config{ServerName: "example.com"}
+	pqConfig := pqTLSConfig(tlsConfig, 1)
+	assert.Equal(t, pqCurvePreferences(1), pqConfig.CurvePreferences)
+	assert.Equal(t, "example.com", pqConfig.ServerName)
+	assert.Nil(t, tlsConfig.CurvePreferences, "shared TLS config shouldn't be modified")
+}
+
+func TestDialEdgeHTTP2PreferPQFallsBack(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	tlsConfig.ServerName = "example.com"
+
+	log := zerolog.Nop()
+	edge, err := edgediscovery.StaticEdge(&log, []string{server.Listener.Addr().String()})
+	require.NoError(t, err)
+	addr, err := edge.GetAddr(0)
+	require.NoError(t, err)
+
+	observer := connection.NewObserver(&log, &log)
+	tracker := tunnelstate.NewConnTracker(&log)
+	e := EdgeTunnelServer{
+		config: &TunnelConfig{
+			Log:            &log,
+			PQMode:         PQModePrefer,
+			EdgeTLSConfigs: map[connection.Protocol]*tls.Config{connection.HTTP2: tlsConfig},
+		},
+		edgeAddrs:       edge,
+		tracker:         tracker,
+		connAwareLogger: NewConnAwareLogger(&log, tracker, observer),
+	}
+
+	// The test server doesn't support the post-quantum key agreements, so the connection falls back to
+	// the classical ones
+	failures := getPQHandshakes(t, "failure")
+	conn, err := e.dialEdgeHTTP2(context.Background(), e.connAwareLogger, addr, 0)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, failures+1, getPQHandshakes(t, "failure"))
+}
@@ -0,0 +1,78 @@
+package supervisor
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// restartBudget detects connections stuck in a crash loop, e.g. because the origin configuration is broken.
+// A connection that restarts more than maxRestarts times within window exceeds its budget and is degraded:
+// it's retried after degradedRetryDuration rather than the regular backoff until it connects again.
+// It's only accessed by the Run loop.
+type restartBudget struct {
+	maxRestarts int
+	window      time.Duration
+	log         *zerolog.Logger
+
+	restarts map[int][]time.Time
+	degraded map[int]struct{}
+}
+
+func newRestartBudget(maxRestarts int, window time.Duration, log *zerolog.Logger) *restartBudget {
+	return &restartBudget{
+		maxRestarts: maxRestarts,
+		window:      window,
+		log:         log,
+		restarts:    make(map[int][]time.Time),
+		degraded:    make(map[int]struct{}),
+	}
+}
+
+// restart records that the connection with the given index is restarting at now, and reports whether it's
+// degraded. A zero maxRestarts disables the budget.
+func (b *restartBudget) restart(index int, now time.Time) bool {
+	if b.maxRestarts <= 0 {
+		return false
+	}
+	restarts := b.restarts[index]
+	cutoff := now.Add(-b.window)
+	for len(restarts) > 0 && !restarts[0].After(cutoff) {
+		restarts = restarts[1:]
+	}
+	restarts = append(restarts, now)
+	b.restarts[index] = restarts
+
+	if _, ok := b.degraded[index]; ok {
+		return true
+	}
+	if len(restarts) <= b.maxRestarts {
+		return false
+	}
+	b.degraded[index] = struct{}{}
+	degradedConnections.Inc()
+	b.log.Error().Int(connection.LogFieldConnIndex, index).
+		Msgf("Connection restarted %d times within %s, retrying it every %s until it connects again", len(restarts), b.window, degradedRetryDuration)
+	return true
+}
+
+// connected records that the connection with the given index connected, which ends its degraded state.
+func (b *restartBudget) connected(index int) {
+	if _, ok := b.degraded[index]; !ok {
+		return
+	}
+	delete(b.degraded, index)
+	degradedConnections.Dec()
+	b.log.Info().Int(connection.LogFieldConnIndex, index).Msg("Connection recovered, retrying it with the regular backoff again")
+}
+
+// forget drops what's known about the connection with the given index, e.g. because it was scaled down.
+func (b *restartBudget) forget(index int) {
+	if _, ok := b.degraded[index]; ok {
+		degradedConnections.Dec()
+	}
+	delete(b.degraded, index)
+	delete(b.restarts, index)
+}
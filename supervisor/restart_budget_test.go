@@ -0,0 +1,76 @@
+package supervisor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+	"github.com/cloudflare/cloudflared/signal"
+)
+
+func TestRestartBudget(t *testing.T) {
+	log := zerolog.Nop()
+	budget := newRestartBudget(2, time.Minute, &log)
+	initialDegraded := getGaugeValue(t, degradedConnections)
+	now := time.Now()
+
+	assert.False(t, budget.restart(0, now))
+	assert.False(t, budget.restart(0, now.Add(time.Second)))
+	// Restarts of other connections don't count against this one
+	assert.False(t, budget.restart(1, now.Add(time.Second)))
+	assert.True(t, budget.restart(0, now.Add(2*time.Second)))
+	assert.Equal(t, initialDegraded+1, getGaugeValue(t, degradedConnections))
+
+	// Stays degraded until it connects, even once the restarts are out of the window
+	assert.True(t, budget.restart(0, now.Add(time.Hour)))
+	budget.connected(0)
+	assert.Equal(t, initialDegraded, getGaugeValue(t, degradedConnections))
+	assert.False(t, budget.restart(0, now.Add(2*time.Hour)))
+
+	// Restarts spread over more than the window never exceed the budget
+	for i := 0; i < 10; i++ {
+		assert.False(t, budget.restart(3, now.Add(time.Duration(i)*time.Minute)))
+	}
+
+	budget.restart(2, now)
+	budget.restart(2, now)
+	assert.True(t, budget.restart(2, now))
+	budget.forget(2)
+	assert.Equal(t, initialDegraded, getGaugeValue(t, degradedConnections))
+}
+
+func TestRestartBudgetDisabled(t *testing.T) {
+	log := zerolog.Nop()
+	budget := newRestartBudget(0, time.Minute, &log)
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		assert.False(t, budget.restart(0, now))
+	}
+}
+
+func TestStartDelayedTunnelStopped(t *testing.T) {
+	edge := newTestEdge(t, 1, 0)
+	server := &mockTunnelServer{edge: edge, addrs: map[uint8]*allregions.EdgeAddr{}}
+	s := newTestSupervisor(t, &TunnelConfig{HAConnections: 1}, edge, server)
+
+	go s.startDelayedTunnel(context.Background(), 0, nil, time.Hour, signal.New(make(chan struct{})))
+	require.Eventually(t, func() bool {
+		s.tunnelStopper.mu.Lock()
+		defer s.tunnelStopper.mu.Unlock()
+		_, ok := s.tunnelStopper.cancels[0]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	s.tunnelStopper.stop(0)
+
+	select {
+	case tunnelErr := <-s.tunnelErrors:
+		assert.ErrorIs(t, tunnelErr.err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("delayed tunnel wasn't stopped")
+	}
+}
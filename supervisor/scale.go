@@ -0,0 +1,157 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudflare/cloudflared/retry"
+)
+
+// scaleRequest asks the Run loop to change the number of HA connections.
+type scaleRequest struct {
+	haConnections int
+	result        chan error
+}
+
+// tunnelStopper lets the Run loop stop tunnels that run, or are about to start, in other goroutines.
+// The zero value is ready to use.
+type tunnelStopper struct {
+	mu      sync.Mutex
+	cancels map[int]context.CancelFunc
+	stopped map[int]struct{}
+}
+
+// register records how to stop the tunnel with the given index. It returns false if the tunnel was
+// stopped before it started, in which case it shouldn't start at all.
+func (ts *tunnelStopper) register(index int, cancel context.CancelFunc) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if _, ok := ts.stopped[index]; ok {
+		return false
+	}
+	if ts.cancels == nil {
+		ts.cancels = make(map[int]context.CancelFunc)
+	}
+	ts.cancels[index] = cancel
+	return true
+}
+
+func (ts *tunnelStopper) unregister(index int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.cancels, index)
+}
+
+// stop closes the tunnel with the given index, and keeps it from starting again until it's resumed.
+func (ts *tunnelStopper) stop(index int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.stopped == nil {
+		ts.stopped = make(map[int]struct{})
+	}
+	ts.stopped[index] = struct{}{}
+	if cancel, ok := ts.cancels[index]; ok {
+		cancel()
+	}
+}
+
+// resume lets the tunnel with the given index start again.
+func (ts *tunnelStopper) resume(index int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.stopped, index)
+}
+
+// ScaleHAConnections changes how many HA connections the supervisor maintains while it runs. Scaling up starts
+// new connections, scaling down closes the connections with the highest indexes and gives their edge addresses
+// back. It returns once the change was applied, without waiting for the connections to connect or close.
+func (s *Supervisor) ScaleHAConnections(ctx context.Context, haConnections int) error {
+	if haConnections < 1 {
+		return fmt.Errorf("at least 1 HA connection is required, got %d", haConnections)
+	}
+	req := scaleRequest{haConnections: haConnections, result: make(chan error, 1)}
+	select {
+	case s.scaleC <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return <-req.result
+}
+
+// scaleTunnels is called by the Run loop to apply a scale request. It returns the tunnels that are still
+// waiting to be relaunched, and how many tunnels it started.
+func (s *Supervisor) scaleTunnels(ctx context.Context, haConnections int, tunnelsWaiting []int) ([]int, int, error) {
+	current := int(s.haConnections.Load())
+	if maxConnections := current + s.edgeIPs.AvailableAddrs(); haConnections > maxConnections {
+		return tunnelsWaiting, 0, fmt.Errorf("requested %d HA connections but there are only enough edge addresses for %d", haConnections, maxConnections)
+	}
+
+	started := 0
+	for i := current; i < haConnections; i++ {
+		if _, ok := s.tunnelsRunning[i]; ok {
+			// Still closing since it was scaled down, start it again once it's closed
+			s.tunnelsRestarting[i] = struct{}{}
+			continue
+		}
+		s.startScaledTunnel(ctx, i)
+		started++
+	}
+	for i := haConnections; i < current; i++ {
+		delete(s.tunnelsRestarting, i)
+		if _, ok := s.tunnelsRunning[i]; ok {
+			s.tunnelStopper.stop(i)
+		}
+	}
+	stillWaiting := tunnelsWaiting[:0]
+	for _, index := range tunnelsWaiting {
+		if index < haConnections {
+			stillWaiting = append(stillWaiting, index)
+		} else {
+			s.releaseTunnel(index)
+		}
+	}
+
+	s.log.Logger().Info().Msgf("Scaling HA connections from %d to %d", current, haConnections)
+	s.haConnections.Store(int32(haConnections))
+	return stillWaiting, started, nil
+}
+
+// startScaledTunnel starts a tunnel that was added by scaling up, with the protocol the first tunnel uses.
+func (s *Supervisor) startScaledTunnel(ctx context.Context, index int) {
+	s.tunnelsProtocolFallback[index] = &protocolFallback{
+		retry.BackoffHandler{MaxRetries: s.config.Retries, RetryForever: true},
+		s.tunnelsProtocolFallback[0].protocol,
+		false,
+	}
+	s.tunnelStopper.resume(index)
+	s.tunnelsRunning[index] = struct{}{}
+	go s.startTunnel(ctx, index, s.tunnelsProtocolFallback[index], s.newConnectedTunnelSignal(index))
+}
+
+// tunnelScaledDown is called by the Run loop when a tunnel returned. It returns false if the tunnel wasn't
+// stopped by scaling down, in which case it's handled like any other tunnel. Otherwise, the tunnel is started
+// again if it was scaled back up while it was closing, in which case restarted is true.
+func (s *Supervisor) tunnelScaledDown(ctx context.Context, index int) (scaledDown, restarted bool) {
+	if _, ok := s.tunnelsRestarting[index]; ok {
+		delete(s.tunnelsRestarting, index)
+		delete(s.tunnelsRebalancing, index)
+		s.startScaledTunnel(ctx, index)
+		return true, true
+	}
+	if index < int(s.haConnections.Load()) {
+		return false, false
+	}
+	delete(s.tunnelsRebalancing, index)
+	s.releaseTunnel(index)
+	return true, false
+}
+
+// releaseTunnel forgets about a tunnel that was scaled down and gives its edge address back.
+func (s *Supervisor) releaseTunnel(index int) {
+	if _, ok := s.tunnelsConnecting[index]; ok {
+		s.waitForNextTunnel(index)
+	}
+	s.edgeIPs.ReleaseAddr(index)
+	s.restartBudget.forget(index)
+}
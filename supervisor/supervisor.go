@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/quic-go/quic-go"
@@ -12,10 +13,13 @@ import (
 
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/edgediscovery"
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/orchestration"
 	"github.com/cloudflare/cloudflared/retry"
 	"github.com/cloudflare/cloudflared/signal"
 	"github.com/cloudflare/cloudflared/tunnelstate"
+	"github.com/cloudflare/cloudflared/watcher"
 )
 
 const (
@@ -23,12 +27,20 @@ const (
 	tunnelRetryDuration = time.Second * 10
 	// Interval between registering new tunnels
 	registrationInterval = time.Second
+	// Waiting time before retrying a tunnel connection that exceeded its restart budget
+	degradedRetryDuration = time.Minute * 5
 
 	subsystemRefreshAuth = "refresh_auth"
 	// Maximum exponent for 'Authenticate' exponential backoff
 	refreshAuthMaxBackoff = 10
 	// Waiting time before retrying a failed 'Authenticate' connection
 	refreshAuthRetryDuration = time.Second * 10
+	// Interval between updates of the oldest connection age metric
+	connectionAgeUpdateInterval = time.Second * 5
+	// Maximum time to wait for an edge address to answer a latency probe
+	edgeLatencyProbeTimeout = time.Second * 2
+	// Interval between attempts to discover the edge while the cached edge addresses are used
+	seededEdgeRediscoverInterval = time.Second * 30
 )
 
 // Supervisor manages non-declarative tunnels. Establishes TCP connections with the edge, and
@@ -39,15 +51,35 @@ type Supervisor struct {
 	edgeIPs                 *edgediscovery.Edge
 	edgeTunnelServer        TunnelServer
 	tunnelErrors            chan tunnelError
-	tunnelsConnecting       map[int]chan struct{}
+	tunnelsConnecting       map[int]*signal.Signal
 	tunnelsProtocolFallback map[int]*protocolFallback
 	// nextConnectedIndex and nextConnectedSignal are used to wait for all
 	// currently-connecting tunnels to finish connecting so we can reset backoff timer
 	nextConnectedIndex  int
-	nextConnectedSignal chan struct{}
+	nextConnectedSignal <-chan struct{}
 
 	log          *ConnAwareLogger
 	logTransport *zerolog.Logger
+	tracker      *tunnelstate.ConnTracker
+
+	initialTopology *initialTopologyRecorder
+	startedAt       time.Time
+
+	// scaleC receives requests to change the number of HA connections at runtime. The tunnels that are
+	// running, including the ones closing after being scaled down, the ones to start again once closed
+	// because they were scaled back up in the meantime, and the ones closing to move them to another
+	// region, are only accessed by the Run loop.
+	scaleC             chan scaleRequest
+	tunnelsRunning     map[int]struct{}
+	tunnelsRestarting  map[int]struct{}
+	tunnelsRebalancing map[int]struct{}
+	tunnelStopper      tunnelStopper
+
+	restartBudget *restartBudget
+
+	// haConnections is how many HA connections are maintained. It starts at config.HAConnections, is
+	// only changed by the Run loop, and is shared with the tunnel server, whose goroutines read it.
+	haConnections *atomic.Int32
 
 	reconnectCredentialManager *reconnectCredentialManager
 
@@ -63,14 +95,14 @@ type tunnelError struct {
 }
 
 func NewSupervisor(config *TunnelConfig, orchestrator *orchestration.Orchestrator, reconnectCh chan ReconnectSignal, gracefulShutdownC <-chan struct{}) (*Supervisor, error) {
-	isStaticEdge := len(config.EdgeAddrs) > 0
-
 	var err error
 	var edgeIPs *edgediscovery.Edge
-	if isStaticEdge { // static edge addresses
+	if config.EdgeAddrsFile != "" { // static edge addresses, reloaded when the file changes
+		edgeIPs, err = edgediscovery.StaticEdgeFromFile(config.Log, config.EdgeAddrsFile)
+	} else if len(config.EdgeAddrs) > 0 { // static edge addresses
 		edgeIPs, err = edgediscovery.StaticEdge(config.Log, config.EdgeAddrs)
 	} else {
-		edgeIPs, err = edgediscovery.ResolveEdge(config.Log, config.Region, config.EdgeIPVersion)
+		edgeIPs, err = edgediscovery.ResolveEdgeOrCached(config.EdgeAddrsCacheFile, config.Log, config.Region, config.EdgeIPVersion, config.EdgeSRVRecord, config.EdgeResolver, config.EdgeIPFilter)
 	}
 	if err != nil {
 		return nil, err
@@ -81,11 +113,25 @@ func NewSupervisor(config *TunnelConfig, orchestrator *orchestration.Orchestrato
 	tracker := tunnelstate.NewConnTracker(config.Log)
 	log := NewConnAwareLogger(config.Log, tracker, config.Observer)
 
+	if config.HASpread == HASpreadRegion {
+		edgeIPs.SpreadAcrossRegions()
+	}
+	if config.EdgeQuarantineThreshold > 0 {
+		edgeIPs.QuarantineFailingAddrs(config.EdgeQuarantineThreshold, config.EdgeQuarantineCooldown)
+	}
+
+	initialTopology := newInitialTopologyRecorder(edgeIPs, config.Log)
+	config.Observer.RegisterSink(initialTopology)
+
 	edgeAddrHandler := NewIPAddrFallback(config.MaxEdgeAddrRetries)
 	edgeBindAddr := config.EdgeBindAddr
 
+	haConnections := new(atomic.Int32)
+	haConnections.Store(int32(config.HAConnections))
+
 	edgeTunnelServer := EdgeTunnelServer{
 		config:            config,
+		haConnections:     haConnections,
 		orchestrator:      orchestrator,
 		credentialManager: reconnectCredentialManager,
 		edgeAddrs:         edgeIPs,
@@ -103,10 +149,16 @@ func NewSupervisor(config *TunnelConfig, orchestrator *orchestration.Orchestrato
 		edgeIPs:                    edgeIPs,
 		edgeTunnelServer:           &edgeTunnelServer,
 		tunnelErrors:               make(chan tunnelError),
-		tunnelsConnecting:          map[int]chan struct{}{},
+		tunnelsConnecting:          map[int]*signal.Signal{},
 		tunnelsProtocolFallback:    map[int]*protocolFallback{},
 		log:                        log,
 		logTransport:               config.LogTransport,
+		tracker:                    tracker,
+		initialTopology:            initialTopology,
+		startedAt:                  time.Now(),
+		scaleC:                     make(chan scaleRequest),
+		restartBudget:              newRestartBudget(config.MaxRestarts, config.MaxRestartsWindow, config.Log),
+		haConnections:              haConnections,
 		reconnectCredentialManager: reconnectCredentialManager,
 		reconnectCh:                reconnectCh,
 		gracefulShutdownC:          gracefulShutdownC,
@@ -129,6 +181,29 @@ func (s *Supervisor) Run(
 		}()
 	}
 
+	go s.reportConnectionMetrics(ctx)
+
+	if s.config.EdgeLatencyProbeInterval > 0 {
+		// Probe before the first connection is given an address, then keep the latencies up to date
+		s.edgeIPs.ProbeLatencies(ctx, edgeLatencyProbeTimeout)
+		go s.edgeIPs.RunLatencyProbes(ctx, s.config.EdgeLatencyProbeInterval, edgeLatencyProbeTimeout)
+	}
+
+	if s.config.EdgeAddrsFile != "" {
+		s.watchEdgeAddrsFile(ctx)
+	} else if !s.config.isStaticEdge() {
+		go s.edgeIPs.RediscoverSeeded(ctx, seededEdgeRediscoverInterval)
+		if s.config.EdgeRefreshInterval > 0 {
+			go s.edgeIPs.RunRefresh(ctx, s.config.EdgeRefreshInterval)
+		}
+	}
+
+	if s.config.ControlPlaneListen != "" {
+		if err := s.startControlPlane(ctx); err != nil {
+			return err
+		}
+	}
+
 	if err := s.initialize(ctx, connectedSignal); err != nil {
 		if err == errEarlyShutdown {
 			return nil
@@ -136,11 +211,24 @@ func (s *Supervisor) Run(
 		return err
 	}
 	var tunnelsWaiting []int
-	tunnelsActive := s.config.HAConnections
+	tunnelsActive := int(s.haConnections.Load())
+	s.tunnelsRunning = make(map[int]struct{}, tunnelsActive)
+	s.tunnelsRestarting = make(map[int]struct{})
+	s.tunnelsRebalancing = make(map[int]struct{})
+	for i := 0; i < tunnelsActive; i++ {
+		s.tunnelsRunning[i] = struct{}{}
+	}
 
-	backoff := retry.BackoffHandler{MaxRetries: s.config.Retries, BaseTime: tunnelRetryDuration, RetryForever: true}
+	backoff := s.config.retryBackoff()
 	var backoffTimer <-chan time.Time
 
+	var rebalanceC <-chan time.Time
+	if s.config.HASpread == HASpreadRegion {
+		rebalanceTicker := time.NewTicker(haSpreadCheckInterval)
+		defer rebalanceTicker.Stop()
+		rebalanceC = rebalanceTicker.C
+	}
+
 	shuttingDown := false
 	for {
 		select {
@@ -155,11 +243,23 @@ func (s *Supervisor) Run(
 		// (note that this may also be caused by context cancellation)
 		case tunnelError := <-s.tunnelErrors:
 			tunnelsActive--
-			if tunnelError.err != nil && !shuttingDown {
+			delete(s.tunnelsRunning, tunnelError.index)
+			if scaledDown, restarted := s.tunnelScaledDown(ctx, tunnelError.index); scaledDown {
+				if restarted {
+					tunnelsActive++
+				}
+				continue
+			}
+			if !shuttingDown && s.tunnelRebalanced(ctx, tunnelError.index) {
+				tunnelsActive++
+				continue
+			}
+			if !s.isCleanDisconnect(tunnelError.err) && !shuttingDown {
 				switch tunnelError.err.(type) {
-				case ReconnectSignal:
-					// For tunnels that closed with reconnect signal, we reconnect immediately
-					go s.startTunnel(ctx, tunnelError.index, s.newConnectedTunnelSignal(tunnelError.index))
+				case ReconnectSignal, MaxConnectionAgeError:
+					// For tunnels that closed with reconnect signal or reached their maximum age, we reconnect immediately
+					s.tunnelsRunning[tunnelError.index] = struct{}{}
+					go s.startTunnel(ctx, tunnelError.index, s.tunnelsProtocolFallback[tunnelError.index], s.newConnectedTunnelSignal(tunnelError.index))
 					tunnelsActive++
 					continue
 				}
@@ -167,7 +267,17 @@ func (s *Supervisor) Run(
 				if _, retry := s.tunnelsProtocolFallback[tunnelError.index].GetMaxBackoffDuration(ctx); !retry {
 					continue
 				}
-				s.log.ConnAwareLogger().Err(tunnelError.err).Int(connection.LogFieldConnIndex, tunnelError.index).Msg("Connection terminated")
+				s.log.ConnAwareLogger().Err(tunnelError.err).
+					Int(connection.LogFieldConnIndex, tunnelError.index).
+					Int(logger.LogFieldEventID, logger.EventIDTunnelDisconnected).
+					Msg("Connection terminated")
+				if s.restartBudget.restart(tunnelError.index, time.Now()) {
+					// Crash looping, retry it on its own after a long delay instead of hammering the edge
+					s.tunnelsRunning[tunnelError.index] = struct{}{}
+					go s.startDelayedTunnel(ctx, tunnelError.index, s.tunnelsProtocolFallback[tunnelError.index], degradedRetryDuration, s.newConnectedTunnelSignal(tunnelError.index))
+					tunnelsActive++
+					continue
+				}
 				tunnelsWaiting = append(tunnelsWaiting, tunnelError.index)
 				s.waitForNextTunnel(tunnelError.index)
 
@@ -182,23 +292,59 @@ func (s *Supervisor) Run(
 		// Backoff was set and its timer expired
 		case <-backoffTimer:
 			backoffTimer = nil
-			for _, index := range tunnelsWaiting {
-				go s.startTunnel(ctx, index, s.newConnectedTunnelSignal(index))
+			fallbacks := make([]*protocolFallback, len(tunnelsWaiting))
+			connectedSignals := make([]*signal.Signal, len(tunnelsWaiting))
+			for i, index := range tunnelsWaiting {
+				fallbacks[i] = s.tunnelsProtocolFallback[index]
+				connectedSignals[i] = s.newConnectedTunnelSignal(index)
+				s.tunnelsRunning[index] = struct{}{}
 			}
+			go s.relaunchTunnels(ctx, tunnelsWaiting, fallbacks, connectedSignals)
 			tunnelsActive += len(tunnelsWaiting)
 			tunnelsWaiting = nil
 		// Tunnel successfully connected
 		case <-s.nextConnectedSignal:
+			s.restartBudget.connected(s.nextConnectedIndex)
 			if !s.waitForNextTunnel(s.nextConnectedIndex) && len(tunnelsWaiting) == 0 {
 				// No more tunnels outstanding, clear backoff timer
 				backoff.SetGracePeriod()
 			}
+		case <-rebalanceC:
+			s.rebalanceRegions()
+		case req := <-s.scaleC:
+			var started int
+			var err error
+			tunnelsWaiting, started, err = s.scaleTunnels(ctx, req.haConnections, tunnelsWaiting)
+			tunnelsActive += started
+			req.result <- err
 		case <-s.gracefulShutdownC:
 			shuttingDown = true
 		}
 	}
 }
 
+// watchEdgeAddrsFile reloads the static edge addresses each time config.EdgeAddrsFile changes, until ctx is
+// done. Failing to watch the file isn't fatal, the addresses it listed at startup keep being used.
+func (s *Supervisor) watchEdgeAddrsFile(ctx context.Context) {
+	fileWatcher, err := watcher.NewFile()
+	if err == nil {
+		err = s.edgeIPs.WatchAddrsFile(ctx, fileWatcher, s.config.EdgeAddrsFile)
+	}
+	if err != nil {
+		s.log.Logger().Err(err).Str("path", s.config.EdgeAddrsFile).Msg("Failed to watch the edge addresses file, changes to it won't apply until restarted")
+	}
+}
+
+// isCleanDisconnect reports whether a connection that returned err closed as planned, in which case it's
+// not reconnected. Besides connections that returned no error, this covers connections whose context was
+// cancelled and errors config.CleanDisconnect classifies as clean.
+func (s *Supervisor) isCleanDisconnect(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return true
+	}
+	return s.config.CleanDisconnect != nil && s.config.CleanDisconnect(err)
+}
+
 // Returns nil if initialization succeeded, else the initialization error.
 // Attempts here will be made to connect one tunnel, if successful, it will
 // connect the available tunnels up to config.HAConnections.
@@ -206,18 +352,25 @@ func (s *Supervisor) initialize(
 	ctx context.Context,
 	connectedSignal *signal.Signal,
 ) error {
+	haConnections := int(s.haConnections.Load())
 	availableAddrs := s.edgeIPs.AvailableAddrs()
-	if s.config.HAConnections > availableAddrs {
-		s.log.Logger().Info().Msgf("You requested %d HA connections but I can give you at most %d.", s.config.HAConnections, availableAddrs)
-		s.config.HAConnections = availableAddrs
+	if haConnections > availableAddrs {
+		s.log.Logger().Info().Msgf("You requested %d HA connections but I can give you at most %d.", haConnections, availableAddrs)
+		haConnections = availableAddrs
+		s.haConnections.Store(int32(haConnections))
 	}
-	s.tunnelsProtocolFallback[0] = &protocolFallback{
-		retry.BackoffHandler{MaxRetries: s.config.Retries, RetryForever: true},
-		s.config.ProtocolSelector.Current(),
-		false,
+	s.initialTopology.expect(haConnections)
+	// The tunnel goroutines read tunnelsProtocolFallback, so all of its entries are added before any is started
+	for i := 0; i < haConnections; i++ {
+		s.tunnelsProtocolFallback[i] = &protocolFallback{
+			retry.BackoffHandler{MaxRetries: s.config.Retries, RetryForever: true},
+			s.config.ProtocolSelector.Current(),
+			false,
+		}
 	}
 
-	go s.startFirstTunnel(ctx, connectedSignal)
+	s.assignInitialAddr(0)
+	go s.startFirstTunnel(ctx, s.tunnelsProtocolFallback[0], connectedSignal)
 
 	// Wait for response from first tunnel before proceeding to attempt other HA edge tunnels
 	select {
@@ -232,37 +385,70 @@ func (s *Supervisor) initialize(
 	}
 
 	// At least one successful connection, so start the rest
-	for i := 1; i < s.config.HAConnections; i++ {
-		s.tunnelsProtocolFallback[i] = &protocolFallback{
-			retry.BackoffHandler{MaxRetries: s.config.Retries, RetryForever: true},
-			// Set the protocol we know the first tunnel connected with.
-			s.tunnelsProtocolFallback[0].protocol,
-			false,
-		}
-		go s.startTunnel(ctx, i, s.newConnectedTunnelSignal(i))
-		time.Sleep(registrationInterval)
+	for i := 1; i < haConnections; i++ {
+		// Set the protocol we know the first tunnel connected with.
+		s.tunnelsProtocolFallback[i].protocol = s.tunnelsProtocolFallback[0].protocol
+		s.assignInitialAddr(i)
+		go s.startTunnel(ctx, i, s.tunnelsProtocolFallback[i], s.newConnectedTunnelSignal(i))
+		time.Sleep(s.config.registrationInterval())
 	}
 	return nil
 }
 
+// assignInitialAddr pins an edge address of alternating IP version to the connection index when
+// config.InterleaveAddressFamilies is set, so that the initial HA connections are spread across
+// both address families (even indexes get IPv4, odd ones IPv6). If there is no address left of the
+// preferred version, the connection falls back to the regular address selection.
+func (s *Supervisor) assignInitialAddr(index int) {
+	if !s.config.InterleaveAddressFamilies {
+		return
+	}
+	version := allregions.V4
+	if index%2 == 1 {
+		version = allregions.V6
+	}
+	if _, err := s.edgeIPs.GetAddrWithIPVersion(index, version); err != nil {
+		s.log.Logger().Debug().Int(connection.LogFieldConnIndex, index).Msgf("No IPv%s edge address available to interleave address families", version)
+	}
+}
+
 // startTunnel starts the first tunnel connection. The resulting error will be sent on
 // s.tunnelErrors. It will send a signal via connectedSignal if registration succeed
 func (s *Supervisor) startFirstTunnel(
 	ctx context.Context,
+	fallback *protocolFallback,
 	connectedSignal *signal.Signal,
 ) {
 	var (
 		err error
 	)
 	const firstConnIndex = 0
-	isStaticEdge := len(s.config.EdgeAddrs) > 0
+	isStaticEdge := s.config.isStaticEdge()
 	defer func() {
 		s.tunnelErrors <- tunnelError{index: firstConnIndex, err: err}
 	}()
 
+	cycleBackoff := s.config.retryBackoff()
+
 	// If the first tunnel disconnects, keep restarting it.
 	for {
-		err = s.edgeTunnelServer.Serve(ctx, firstConnIndex, s.tunnelsProtocolFallback[firstConnIndex], connectedSignal)
+		// The edge forgets the addresses tried once the connection succeeds, so only failed attempts back off
+		if s.config.MaxAddrsPerAttemptCycle > 0 {
+			if tried := s.edgeIPs.TriedAddrs(firstConnIndex); tried >= s.config.MaxAddrsPerAttemptCycle {
+				s.log.Logger().Info().Msgf("Tried %d edge addresses without connecting, backing off before trying more", tried)
+				select {
+				case <-ctx.Done():
+					err = ctx.Err()
+					return
+				case <-s.gracefulShutdownC:
+					err = nil
+					return
+				case <-cycleBackoff.BackoffTimer():
+				}
+				s.edgeIPs.ResetTriedAddrs(firstConnIndex)
+			}
+		}
+		err = s.edgeTunnelServer.Serve(ctx, firstConnIndex, fallback, connectedSignal)
 		if ctx.Err() != nil {
 			return
 		}
@@ -270,7 +456,7 @@ func (s *Supervisor) startFirstTunnel(
 			return
 		}
 		// Make sure we don't continue if there is no more fallback allowed
-		if _, retry := s.tunnelsProtocolFallback[firstConnIndex].GetMaxBackoffDuration(ctx); !retry {
+		if _, retry := fallback.GetMaxBackoffDuration(ctx); !retry {
 			return
 		}
 		// Try again for Unauthorized errors because we hope them to be
@@ -285,9 +471,11 @@ func (s *Supervisor) startFirstTunnel(
 				return
 			}
 		case connection.DupConnRegisterTunnelError,
+			connection.RegistrationTimeoutError,
 			*quic.IdleTimeoutError,
 			*quic.ApplicationError,
 			edgediscovery.DialError,
+			DNSUnavailableError,
 			*connection.EdgeQuicDialError:
 			// Try again for these types of errors
 		default:
@@ -298,10 +486,25 @@ func (s *Supervisor) startFirstTunnel(
 }
 
 // startTunnel starts a new tunnel connection. The resulting error will be sent on
-// s.tunnelError as this is expected to run in a goroutine.
+// s.tunnelError as this is expected to run in a goroutine. The protocol fallback of the
+// tunnel is passed in rather than read from s.tunnelsProtocolFallback, which only the
+// supervisor's goroutine may access.
 func (s *Supervisor) startTunnel(
 	ctx context.Context,
 	index int,
+	fallback *protocolFallback,
+	connectedSignal *signal.Signal,
+) {
+	s.startDelayedTunnel(ctx, index, fallback, 0, connectedSignal)
+}
+
+// startDelayedTunnel is like startTunnel, but waits for delay before connecting. Stopping the tunnel or
+// shutting down while it waits ends it without connecting.
+func (s *Supervisor) startDelayedTunnel(
+	ctx context.Context,
+	index int,
+	fallback *protocolFallback,
+	delay time.Duration,
 	connectedSignal *signal.Signal,
 ) {
 	var (
@@ -311,15 +514,82 @@ func (s *Supervisor) startTunnel(
 		s.tunnelErrors <- tunnelError{index: index, err: err}
 	}()
 
-	err = s.edgeTunnelServer.Serve(ctx, uint8(index), s.tunnelsProtocolFallback[index], connectedSignal)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if !s.tunnelStopper.register(index, cancel) {
+		// Scaled down before it started
+		return
+	}
+	defer s.tunnelStopper.unregister(index)
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-s.gracefulShutdownC:
+			return
+		case <-timer.C:
+		}
+	}
+
+	err = s.edgeTunnelServer.Serve(ctx, uint8(index), fallback, connectedSignal)
+}
+
+// relaunchTunnels restarts the tunnels with the given indexes, letting at most config.RecoveryConcurrency
+// of them connect at the same time. Each tunnel reports back on s.tunnelErrors like startTunnel does,
+// including the ones that were never started because ctx was done.
+func (s *Supervisor) relaunchTunnels(ctx context.Context, indexes []int, fallbacks []*protocolFallback, connectedSignals []*signal.Signal) {
+	if s.config.RecoveryConcurrency <= 0 {
+		for i, index := range indexes {
+			go s.startTunnel(ctx, index, fallbacks[i], connectedSignals[i])
+		}
+		return
+	}
+
+	slots := make(chan struct{}, s.config.RecoveryConcurrency)
+	for i, index := range indexes {
+		select {
+		case <-ctx.Done():
+			s.tunnelErrors <- tunnelError{index: index, err: ctx.Err()}
+			continue
+		case slots <- struct{}{}:
+		}
+		go func(index int, fallback *protocolFallback, connectedSignal *signal.Signal) {
+			done := make(chan struct{})
+			go func() {
+				// Free the slot once the tunnel connected or gave up
+				select {
+				case <-connectedSignal.Wait():
+				case <-done:
+				}
+				<-slots
+			}()
+			s.startTunnel(ctx, index, fallback, connectedSignal)
+			close(done)
+		}(index, fallbacks[i], connectedSignals[i])
+	}
 }
 
+// newConnectedTunnelSignal returns the signal the tunnel with the given index notifies once connected.
+// If that index is still connecting, its pending signal is reused so that nobody waiting on it misses
+// the connection.
 func (s *Supervisor) newConnectedTunnelSignal(index int) *signal.Signal {
-	sig := make(chan struct{})
+	if sig, ok := s.tunnelsConnecting[index]; ok {
+		select {
+		case <-sig.Wait():
+			// The previous attempt connected already, this one needs its own signal
+		default:
+			return sig
+		}
+	}
+	sig := signal.New(make(chan struct{}))
 	s.tunnelsConnecting[index] = sig
-	s.nextConnectedSignal = sig
+	s.nextConnectedSignal = sig.Wait()
 	s.nextConnectedIndex = index
-	return signal.New(sig)
+	return sig
 }
 
 func (s *Supervisor) waitForNextTunnel(index int) bool {
@@ -327,12 +597,85 @@ func (s *Supervisor) waitForNextTunnel(index int) bool {
 	s.nextConnectedSignal = nil
 	for k, v := range s.tunnelsConnecting {
 		s.nextConnectedIndex = k
-		s.nextConnectedSignal = v
+		s.nextConnectedSignal = v.Wait()
 		return true
 	}
 	return false
 }
 
+// reportConnectionMetrics periodically updates the oldest connection age and region placement metrics
+// until ctx is done.
+func (s *Supervisor) reportConnectionMetrics(ctx context.Context) {
+	ticker := time.NewTicker(connectionAgeUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.updateOldestConnectionAge(now)
+			s.updateRegionPlacement()
+		}
+	}
+}
+
+func (s *Supervisor) updateOldestConnectionAge(now time.Time) {
+	oldest, ok := s.tracker.OldestConnectedAt()
+	if !ok {
+		oldestConnectionAge.Set(0)
+		return
+	}
+	oldestConnectionAge.Set(now.Sub(oldest).Seconds())
+}
+
+// InitialTopology returns the edge address and colo each initial HA connection registered with. It is
+// nil until all initial connections registered, and isn't changed by later reconnects.
+func (s *Supervisor) InitialTopology() []ConnTopology {
+	return s.initialTopology.Topology()
+}
+
+// SupervisorStats summarizes the connections the supervisor made since it was created.
+type SupervisorStats struct {
+	Uptime time.Duration
+	// ConnectionsEstablished counts every time a connection was established, including reconnects
+	ConnectionsEstablished uint64
+	ActiveConnections      uint
+	Reconnects             uint64
+	// SentBytes and ReceivedBytes count the bytes all connections exchanged with the edge
+	SentBytes     uint64
+	ReceivedBytes uint64
+}
+
+// Stats returns a summary of the connections the supervisor made, e.g. to back a status command.
+func (s *Supervisor) Stats() SupervisorStats {
+	established, reconnects := s.tracker.ConnectionCounts()
+	sent, received := s.config.Observer.BytesTransferred()
+	return SupervisorStats{
+		Uptime:                 time.Since(s.startedAt),
+		ConnectionsEstablished: established,
+		ActiveConnections:      s.tracker.CountActiveConns(),
+		Reconnects:             reconnects,
+		SentBytes:              sent,
+		ReceivedBytes:          received,
+	}
+}
+
+// SetupSuccessRate returns the fraction of connection attempts made within the given window that
+// succeeded. Attempts still in flight are not counted, and it is 1 if no attempt completed in the window.
+func (s *Supervisor) SetupSuccessRate(window time.Duration) float64 {
+	successes, failures := s.edgeIPs.OutcomesSince(time.Now().Add(-window))
+	if successes+failures == 0 {
+		return 1
+	}
+	return float64(successes) / float64(successes+failures)
+}
+
+// EdgeDNSRecords returns the DNS records the edge address pool was resolved from, so that operators can
+// check what DNS returned. It is empty when static edge addresses are used.
+func (s *Supervisor) EdgeDNSRecords() []allregions.DNSRecord {
+	return s.edgeIPs.DNSRecords()
+}
+
 func (s *Supervisor) unusedIPs() bool {
-	return s.edgeIPs.AvailableAddrs() > s.config.HAConnections
+	return s.edgeIPs.AvailableAddrs() > int(s.haConnections.Load())
 }
@@ -0,0 +1,647 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgediscovery"
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+	"github.com/cloudflare/cloudflared/retry"
+	"github.com/cloudflare/cloudflared/signal"
+	"github.com/cloudflare/cloudflared/tunnelstate"
+)
+
+// mockTunnelServer connects each index to the edge address handed out by the Edge,
+// and stays connected until the context is cancelled.
+type mockTunnelServer struct {
+	edge *edgediscovery.Edge
+
+	mu    sync.Mutex
+	addrs map[uint8]*allregions.EdgeAddr
+}
+
+func (m *mockTunnelServer) Serve(ctx context.Context, connIndex uint8, _ *protocolFallback, connectedSignal *signal.Signal) error {
+	addr, err := m.edge.GetAddr(int(connIndex))
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.addrs[connIndex] = addr
+	m.mu.Unlock()
+	connectedSignal.Notify()
+	<-ctx.Done()
+	return nil
+}
+
+func (m *mockTunnelServer) addrFor(connIndex uint8) *allregions.EdgeAddr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.addrs[connIndex]
+}
+
+type mockProtocolSelector struct {
+	protocol connection.Protocol
+}
+
+func (m *mockProtocolSelector) Current() connection.Protocol {
+	return m.protocol
+}
+
+func (m *mockProtocolSelector) Fallback() (connection.Protocol, bool) {
+	return m.protocol, false
+}
+
+func newTestSupervisor(t *testing.T, config *TunnelConfig, edge *edgediscovery.Edge, server TunnelServer) *Supervisor {
+	log := zerolog.Nop()
+	if config.Log == nil {
+		config.Log = &log
+	}
+	if config.Observer == nil {
+		config.Observer = connection.NewObserver(config.Log, config.Log)
+	}
+	if config.ProtocolSelector == nil {
+		config.ProtocolSelector = &mockProtocolSelector{protocol: connection.HTTP2}
+	}
+	tracker := tunnelstate.NewConnTracker(config.Log)
+	return &Supervisor{
+		haConnections:           newHAConnections(config.HAConnections),
+		config:                  config,
+		edgeIPs:                 edge,
+		edgeTunnelServer:        server,
+		tunnelErrors:            make(chan tunnelError),
+		tunnelsConnecting:       map[int]*signal.Signal{},
+		tunnelsProtocolFallback: map[int]*protocolFallback{},
+		log:                     NewConnAwareLogger(config.Log, tracker, config.Observer),
+		tracker:                 tracker,
+		initialTopology:         newInitialTopologyRecorder(edge, config.Log),
+		startedAt:               time.Now(),
+		scaleC:                  make(chan scaleRequest),
+		restartBudget:           newRestartBudget(config.MaxRestarts, config.MaxRestartsWindow, config.Log),
+		gracefulShutdownC:       make(chan struct{}),
+	}
+}
+
+func newHAConnections(haConnections int) *atomic.Int32 {
+	count := new(atomic.Int32)
+	count.Store(int32(haConnections))
+	return count
+}
+
+func newTestEdge(t *testing.T, numV4, numV6 int) *edgediscovery.Edge {
+	log := zerolog.Nop()
+	var addrs []string
+	for i := 1; i <= numV4; i++ {
+		addrs = append(addrs, fmt.Sprintf("127.0.0.%d:7844", i))
+	}
+	for i := 1; i <= numV6; i++ {
+		addrs = append(addrs, fmt.Sprintf("[::%d]:7844", i))
+	}
+	edge, err := edgediscovery.StaticEdge(&log, addrs)
+	require.NoError(t, err)
+	return edge
+}
+
+func TestInitializeInterleavesAddressFamilies(t *testing.T) {
+	const haConnections = 4
+	edge := newTestEdge(t, haConnections, haConnections)
+	server := &mockTunnelServer{edge: edge, addrs: map[uint8]*allregions.EdgeAddr{}}
+	s := newTestSupervisor(t, &TunnelConfig{
+		HAConnections:             haConnections,
+		InterleaveAddressFamilies: true,
+	}, edge, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.initialize(ctx, signal.New(make(chan struct{})))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return server.addrFor(haConnections-1) != nil
+	}, time.Second, time.Millisecond*10)
+	expected := []allregions.EdgeIPVersion{allregions.V4, allregions.V6, allregions.V4, allregions.V6}
+	for i, version := range expected {
+		addr := server.addrFor(uint8(i))
+		require.NotNil(t, addr)
+		assert.Equal(t, version, addr.IPVersion, "connection %d", i)
+	}
+
+	cancel()
+	for i := 0; i < haConnections; i++ {
+		<-s.tunnelErrors
+	}
+}
+
+func TestOldestConnectionAge(t *testing.T) {
+	s := newTestSupervisor(t, &TunnelConfig{}, nil, nil)
+	now := time.Now()
+	s.tracker = tunnelstate.MockedConnTracker(map[uint8]tunnelstate.ConnectionInfo{
+		0: {IsConnected: true, ConnectedAt: now.Add(-10 * time.Minute)},
+		1: {IsConnected: true, ConnectedAt: now.Add(-time.Hour)},
+		2: {IsConnected: true, ConnectedAt: now.Add(-time.Second)},
+		// Disconnected connections don't count, however old they are
+		3: {IsConnected: false, ConnectedAt: now.Add(-2 * time.Hour)},
+	})
+
+	s.updateOldestConnectionAge(now)
+	assert.Equal(t, time.Hour.Seconds(), getGaugeValue(t, oldestConnectionAge))
+
+	s.tracker = tunnelstate.MockedConnTracker(map[uint8]tunnelstate.ConnectionInfo{})
+	s.updateOldestConnectionAge(now)
+	assert.Equal(t, 0.0, getGaugeValue(t, oldestConnectionAge))
+}
+
+func getGaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	var m = &dto.Metric{}
+	require.NoError(t, gauge.Write(m))
+	return m.Gauge.GetValue()
+}
+
+// scriptedTunnelServer fails attempts with the scripted errors, in order, before connecting.
+type scriptedTunnelServer struct {
+	mu       sync.Mutex
+	errs     []error
+	attempts int
+}
+
+func (m *scriptedTunnelServer) Serve(ctx context.Context, connIndex uint8, _ *protocolFallback, connectedSignal *signal.Signal) error {
+	m.mu.Lock()
+	attempt := m.attempts
+	m.attempts++
+	m.mu.Unlock()
+	if attempt < len(m.errs) {
+		return m.errs[attempt]
+	}
+	connectedSignal.Notify()
+	<-ctx.Done()
+	return nil
+}
+
+func (m *scriptedTunnelServer) attemptCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attempts
+}
+
+func TestFirstTunnelRetriesRegistrationTimeout(t *testing.T) {
+	edge := newTestEdge(t, 2, 0)
+	server := &scriptedTunnelServer{
+		errs: []error{connection.RegistrationTimeoutError{Timeout: time.Second}},
+	}
+	s := newTestSupervisor(t, &TunnelConfig{
+		HAConnections:       1,
+		RegistrationTimeout: time.Second,
+	}, edge, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.initialize(ctx, signal.New(make(chan struct{})))
+	require.NoError(t, err)
+	assert.Equal(t, 2, server.attemptCount())
+
+	cancel()
+	<-s.tunnelErrors
+}
+
+// failingTunnelServer fails to dial every address it's given, rotating to a different one each time
+// like EdgeTunnelServer does on dial errors.
+type failingTunnelServer struct {
+	edge *edgediscovery.Edge
+
+	mu    sync.Mutex
+	tried []*allregions.EdgeAddr
+}
+
+func (m *failingTunnelServer) Serve(ctx context.Context, connIndex uint8, _ *protocolFallback, _ *signal.Signal) error {
+	addr, err := m.edge.GetAddr(int(connIndex))
+	if err != nil {
+		return err
+	}
+	m.edge.RecordAttempt(addr)
+	m.mu.Lock()
+	m.tried = append(m.tried, addr)
+	m.mu.Unlock()
+	m.edge.RecordOutcome(addr, false)
+	if _, _, err := m.edge.GetDifferentAddr(int(connIndex), true); err != nil {
+		return err
+	}
+	return &connection.EdgeQuicDialError{Cause: fmt.Errorf("failed to dial %s", addr.UDP)}
+}
+
+func (m *failingTunnelServer) triedAddrs() []*allregions.EdgeAddr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*allregions.EdgeAddr(nil), m.tried...)
+}
+
+func TestMaxAddrsPerAttemptCycle(t *testing.T) {
+	const maxAddrs = 3
+	backoffStarted := make(chan struct{})
+	originalAfter := retry.Clock.After
+	defer func() { retry.Clock.After = originalAfter }()
+	retry.Clock.After = func(d time.Duration) <-chan time.Time {
+		close(backoffStarted)
+		// never fire, the test is over once the backoff starts
+		return make(chan time.Time)
+	}
+
+	edge := newTestEdge(t, 20, 0)
+	server := &failingTunnelServer{edge: edge}
+	s := newTestSupervisor(t, &TunnelConfig{
+		HAConnections:           1,
+		EdgeAddrs:               []string{"static"},
+		MaxAddrsPerAttemptCycle: maxAddrs,
+	}, edge, server)
+	s.tunnelsProtocolFallback[0] = &protocolFallback{
+		retry.BackoffHandler{RetryForever: true},
+		connection.HTTP2,
+		false,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.startFirstTunnel(ctx, s.tunnelsProtocolFallback[0], signal.New(make(chan struct{})))
+
+	select {
+	case <-backoffStarted:
+	case <-time.After(time.Second):
+		t.Fatal("first tunnel didn't back off")
+	}
+	// An address given back to the pool may be tried again, only distinct addresses count
+	tried := server.triedAddrs()
+	assert.GreaterOrEqual(t, len(tried), maxAddrs)
+	distinct := map[*allregions.EdgeAddr]struct{}{}
+	for _, addr := range tried {
+		distinct[addr] = struct{}{}
+	}
+	assert.Len(t, distinct, maxAddrs)
+
+	cancel()
+	<-s.tunnelErrors
+}
+
+func TestSetupSuccessRate(t *testing.T) {
+	edge := newTestEdge(t, 2, 0)
+	s := newTestSupervisor(t, &TunnelConfig{}, edge, nil)
+	assert.Equal(t, 1.0, s.SetupSuccessRate(time.Minute))
+
+	addr, err := edge.GetAddr(0)
+	require.NoError(t, err)
+	for _, connected := range []bool{false, false, false, true} {
+		edge.RecordAttempt(addr)
+		edge.RecordOutcome(addr, connected)
+	}
+	// An attempt that is still in flight doesn't count
+	edge.RecordAttempt(addr)
+	assert.Equal(t, 0.25, s.SetupSuccessRate(time.Minute))
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1.0, s.SetupSuccessRate(time.Millisecond))
+}
+
+func TestNewConnectedTunnelSignalForConnectingIndex(t *testing.T) {
+	s := newTestSupervisor(t, &TunnelConfig{}, nil, nil)
+
+	first := s.newConnectedTunnelSignal(1)
+	other := s.newConnectedTunnelSignal(2)
+	// Index 1 is still connecting, so its pending signal is reused
+	second := s.newConnectedTunnelSignal(1)
+	assert.Same(t, first, second)
+	assert.Len(t, s.tunnelsConnecting, 2)
+
+	second.Notify()
+	first.Notify()
+	<-s.tunnelsConnecting[1].Wait()
+	assert.True(t, s.waitForNextTunnel(1))
+	assert.Equal(t, 2, s.nextConnectedIndex)
+	other.Notify()
+	<-s.nextConnectedSignal
+	assert.False(t, s.waitForNextTunnel(s.nextConnectedIndex))
+	assert.Empty(t, s.tunnelsConnecting)
+
+	// Once the previous attempt connected, a new attempt waits on a new signal
+	connected := s.newConnectedTunnelSignal(3)
+	connected.Notify()
+	reconnecting := s.newConnectedTunnelSignal(3)
+	assert.NotSame(t, connected, reconnecting)
+	assert.Len(t, s.tunnelsConnecting, 1)
+	assert.Equal(t, 3, s.nextConnectedIndex)
+	select {
+	case <-s.nextConnectedSignal:
+		t.Fatal("new attempt hasn't connected yet")
+	default:
+	}
+}
+
+// slowTunnelServer takes a while to connect, tracking how many connections are connecting at the same time.
+type slowTunnelServer struct {
+	mu            sync.Mutex
+	connecting    int
+	maxConnecting int
+	started       map[uint8]bool
+}
+
+func (m *slowTunnelServer) Serve(ctx context.Context, connIndex uint8, _ *protocolFallback, connectedSignal *signal.Signal) error {
+	m.mu.Lock()
+	m.started[connIndex] = true
+	m.connecting++
+	if m.connecting > m.maxConnecting {
+		m.maxConnecting = m.connecting
+	}
+	m.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	m.mu.Lock()
+	m.connecting--
+	m.mu.Unlock()
+	connectedSignal.Notify()
+	<-ctx.Done()
+	return nil
+}
+
+func TestRelaunchTunnelsRecoveryConcurrency(t *testing.T) {
+	const (
+		numTunnels          = 20
+		recoveryConcurrency = 3
+	)
+	server := &slowTunnelServer{started: map[uint8]bool{}}
+	s := newTestSupervisor(t, &TunnelConfig{RecoveryConcurrency: recoveryConcurrency}, nil, server)
+
+	var indexes []int
+	var connectedSignals []*signal.Signal
+	for i := 0; i < numTunnels; i++ {
+		indexes = append(indexes, i)
+		connectedSignals = append(connectedSignals, s.newConnectedTunnelSignal(i))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.relaunchTunnels(ctx, indexes, make([]*protocolFallback, numTunnels), connectedSignals)
+
+	for _, connectedSignal := range connectedSignals {
+		select {
+		case <-connectedSignal.Wait():
+		case <-time.After(5 * time.Second):
+			t.Fatal("not all tunnels were relaunched")
+		}
+	}
+	server.mu.Lock()
+	assert.Len(t, server.started, numTunnels)
+	assert.LessOrEqual(t, server.maxConnecting, recoveryConcurrency)
+	assert.Greater(t, server.maxConnecting, 1)
+	server.mu.Unlock()
+
+	cancel()
+	for i := 0; i < numTunnels; i++ {
+		<-s.tunnelErrors
+	}
+}
+
+func TestInitialTopology(t *testing.T) {
+	const haConnections = 3
+	edge := newTestEdge(t, haConnections+1, 0)
+	server := &mockTunnelServer{edge: edge, addrs: map[uint8]*allregions.EdgeAddr{}}
+	s := newTestSupervisor(t, &TunnelConfig{HAConnections: haConnections}, edge, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, s.initialize(ctx, signal.New(make(chan struct{}))))
+	require.Eventually(t, func() bool {
+		return server.addrFor(haConnections-1) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	locations := []string{"LAX", "SFO", "LAX"}
+	for i := 0; i < haConnections; i++ {
+		assert.Nil(t, s.InitialTopology(), "topology reported before all initial connections registered")
+		s.initialTopology.OnTunnelEvent(connection.Event{Index: uint8(i), EventType: connection.Connected, Location: locations[i]})
+	}
+
+	topology := s.InitialTopology()
+	require.Len(t, topology, haConnections)
+	for i, conn := range topology {
+		assert.Equal(t, uint8(i), conn.Index)
+		assert.Equal(t, server.addrFor(uint8(i)), conn.EdgeAddr)
+		assert.Equal(t, locations[i], conn.Location)
+	}
+
+	// a reconnect to a different colo doesn't change the initial topology
+	s.initialTopology.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected, Location: "SEA"})
+	assert.Equal(t, topology, s.InitialTopology())
+
+	cancel()
+	for i := 0; i < haConnections; i++ {
+		<-s.tunnelErrors
+	}
+}
+
+// closingTunnelServer connects, then immediately returns the given error.
+type closingTunnelServer struct {
+	err error
+
+	mu       sync.Mutex
+	attempts int
+}
+
+func (m *closingTunnelServer) Serve(ctx context.Context, connIndex uint8, _ *protocolFallback, connectedSignal *signal.Signal) error {
+	m.mu.Lock()
+	m.attempts++
+	m.mu.Unlock()
+	connectedSignal.Notify()
+	return m.err
+}
+
+func (m *closingTunnelServer) attemptCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.attempts
+}
+
+var errPlannedClose = errors.New("connection closed for planned drain")
+
+func TestCleanDisconnectIsNotReconnected(t *testing.T) {
+	edge := newTestEdge(t, 2, 0)
+	server := &closingTunnelServer{err: fmt.Errorf("serve: %w", errPlannedClose)}
+	s := newTestSupervisor(t, &TunnelConfig{
+		HAConnections: 1,
+		CleanDisconnect: func(err error) bool {
+			return errors.Is(err, errPlannedClose)
+		},
+	}, edge, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error)
+	go func() {
+		runErr <- s.Run(ctx, signal.New(make(chan struct{})))
+	}()
+
+	// with its only connection closed cleanly, the supervisor has nothing left to do
+	select {
+	case err := <-runErr:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor reconnected a connection that was closed as planned")
+	}
+	assert.Equal(t, 1, server.attemptCount())
+}
+
+func TestStats(t *testing.T) {
+	s := newTestSupervisor(t, &TunnelConfig{}, nil, nil)
+	s.startedAt = time.Now().Add(-time.Minute)
+
+	// registration reports a connection as connected more than once
+	for _, index := range []uint8{0, 0, 1, 1, 2} {
+		s.tracker.OnTunnelEvent(connection.Event{Index: index, EventType: connection.Connected})
+	}
+	// connection 2 reconnects, connection 1 is still reconnecting
+	s.tracker.OnTunnelEvent(connection.Event{Index: 2, EventType: connection.Reconnecting})
+	s.tracker.OnTunnelEvent(connection.Event{Index: 2, EventType: connection.Connected})
+	s.tracker.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Disconnected})
+	s.tracker.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Reconnecting})
+	// connection 3 never connected
+	s.tracker.OnTunnelEvent(connection.Event{Index: 3, EventType: connection.Reconnecting})
+
+	stats := s.Stats()
+	assert.GreaterOrEqual(t, stats.Uptime, time.Minute)
+	assert.Equal(t, uint64(4), stats.ConnectionsEstablished)
+	assert.Equal(t, uint(2), stats.ActiveConnections)
+	assert.Equal(t, uint64(1), stats.Reconnects)
+	sent, received := s.config.Observer.BytesTransferred()
+	assert.Equal(t, sent, stats.SentBytes)
+	assert.Equal(t, received, stats.ReceivedBytes)
+}
+
+func TestStartTunnelRetriesSoleAddr(t *testing.T) {
+	originalAfter := retry.Clock.After
+	defer func() { retry.Clock.After = originalAfter }()
+	retry.Clock.After = func(d time.Duration) <-chan time.Time {
+		return time.After(time.Millisecond)
+	}
+
+	// The only edge address refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	edgeAddr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	log := zerolog.Nop()
+	edge, err := edgediscovery.StaticEdge(&log, []string{edgeAddr})
+	require.NoError(t, err)
+	config := &TunnelConfig{HAConnections: 1}
+	s := newTestSupervisor(t, config, edge, nil)
+	s.edgeTunnelServer = &EdgeTunnelServer{
+		config:            config,
+		haConnections:     s.haConnections,
+		edgeAddrs:         edge,
+		edgeAddrHandler:   NewIPAddrFallback(3),
+		tracker:           s.tracker,
+		gracefulShutdownC: s.gracefulShutdownC,
+		connAwareLogger:   s.log,
+	}
+	fallback := &protocolFallback{retry.BackoffHandler{MaxRetries: 3}, connection.HTTP2, false}
+
+	for i := 0; i < 2; i++ {
+		go s.startTunnel(context.Background(), 0, fallback, signal.New(make(chan struct{})))
+		tunnelError := <-s.tunnelErrors
+		// The connection failed to dial the address, rather than running out of addresses to rotate to
+		var dialErr edgediscovery.DialError
+		require.True(t, errors.As(tunnelError.err, &dialErr), "unexpected error %v", tunnelError.err)
+
+		addr, err := edge.GetAddr(0)
+		require.NoError(t, err)
+		assert.Equal(t, edgeAddr, addr.TCP.String())
+	}
+}
+
+func TestScaleHAConnections(t *testing.T) {
+	edge := newTestEdge(t, 4, 0)
+	server := &mockTunnelServer{edge: edge, addrs: map[uint8]*allregions.EdgeAddr{}}
+	s := newTestSupervisor(t, &TunnelConfig{HAConnections: 1}, edge, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- s.Run(ctx, signal.New(make(chan struct{})))
+	}()
+
+	require.NoError(t, s.ScaleHAConnections(ctx, 3))
+	require.Eventually(t, func() bool {
+		return server.addrFor(1) != nil && server.addrFor(2) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, edge.AvailableAddrs())
+	// the tunnel goroutines read the count while the supervisor scales it
+	assert.Equal(t, int32(3), s.haConnections.Load())
+	assert.Equal(t, 1, s.config.HAConnections)
+
+	// there aren't enough edge addresses for this many connections
+	assert.Error(t, s.ScaleHAConnections(ctx, 5))
+	assert.Error(t, s.ScaleHAConnections(ctx, 0))
+
+	// scaled down connections close and give their addresses back
+	require.NoError(t, s.ScaleHAConnections(ctx, 1))
+	require.Eventually(t, func() bool {
+		return edge.AvailableAddrs() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), s.haConnections.Load())
+
+	require.NoError(t, s.ScaleHAConnections(ctx, 2))
+	require.Eventually(t, func() bool {
+		return edge.AvailableAddrs() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor didn't wait for the scaled connections to close")
+	}
+}
+
+func TestRebalanceRegions(t *testing.T) {
+	originalInterval := haSpreadCheckInterval
+	defer func() { haSpreadCheckInterval = originalInterval }()
+	haSpreadCheckInterval = 10 * time.Millisecond
+
+	// The first region has 127.0.0.1 and ::1, the second one 127.0.0.2. Interleaving address families
+	// places both connections in the first region.
+	edge := newTestEdge(t, 2, 1)
+	edge.SpreadAcrossRegions()
+	server := &mockTunnelServer{edge: edge, addrs: map[uint8]*allregions.EdgeAddr{}}
+	s := newTestSupervisor(t, &TunnelConfig{
+		HAConnections:             2,
+		HASpread:                  HASpreadRegion,
+		InterleaveAddressFamilies: true,
+		RegistrationInterval:      time.Millisecond,
+	}, edge, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+	go func() {
+		runErr <- s.Run(ctx, signal.New(make(chan struct{})))
+	}()
+
+	require.Eventually(t, func() bool {
+		addr := server.addrFor(1)
+		return addr != nil && addr.UDP.IP.Equal(net.ParseIP("127.0.0.2"))
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, server.addrFor(0).UDP.IP.Equal(net.ParseIP("127.0.0.1")))
+
+	connections, _ := edge.RegionPlacement()
+	assert.Equal(t, []int{1, 1}, connections)
+
+	cancel()
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor didn't stop")
+	}
+}
@@ -0,0 +1,106 @@
+package supervisor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgediscovery"
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+)
+
+// ConnTopology is the edge address and colo an HA connection registered with.
+type ConnTopology struct {
+	Index    uint8
+	EdgeAddr *allregions.EdgeAddr
+	Location string
+}
+
+func (c ConnTopology) String() string {
+	return fmt.Sprintf("%d: %s (%s)", c.Index, c.EdgeAddr.UDP.IP, c.Location)
+}
+
+// initialTopologyRecorder is an event sink recording where the initial HA connections registered. Once all
+// of them did, it logs the topology and stops recording, so that reconnects don't change it.
+type initialTopologyRecorder struct {
+	edge *edgediscovery.Edge
+	log  *zerolog.Logger
+
+	mu sync.RWMutex
+	// number of initial connections, only known once the supervisor initializes
+	connections int
+	recorded    map[uint8]ConnTopology
+	complete    bool
+}
+
+func newInitialTopologyRecorder(edge *edgediscovery.Edge, log *zerolog.Logger) *initialTopologyRecorder {
+	return &initialTopologyRecorder{
+		edge:     edge,
+		log:      log,
+		recorded: make(map[uint8]ConnTopology),
+	}
+}
+
+// expect sets how many initial connections there are.
+func (r *initialTopologyRecorder) expect(connections int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connections = connections
+}
+
+func (r *initialTopologyRecorder) OnTunnelEvent(e connection.Event) {
+	if e.EventType != connection.Connected {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.complete {
+		return
+	}
+	if _, ok := r.recorded[e.Index]; ok {
+		return
+	}
+	// The connection is registered, so this is the address it's using rather than a new one
+	addr, err := r.edge.GetAddr(int(e.Index))
+	if err != nil {
+		return
+	}
+	r.recorded[e.Index] = ConnTopology{Index: e.Index, EdgeAddr: addr, Location: e.Location}
+	if r.connections == 0 || len(r.recorded) < r.connections {
+		return
+	}
+	r.complete = true
+
+	topology := r.sortedTopology()
+	connections := make([]string, len(topology))
+	for i, conn := range topology {
+		connections[i] = conn.String()
+	}
+	r.log.Info().Str("topology", strings.Join(connections, ", ")).Msg("Initial connections registered")
+}
+
+// Topology returns where each initial connection registered, ordered by connection index. It is nil until
+// all initial connections registered.
+func (r *initialTopologyRecorder) Topology() []ConnTopology {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.complete {
+		return nil
+	}
+	return r.sortedTopology()
+}
+
+func (r *initialTopologyRecorder) sortedTopology() []ConnTopology {
+	topology := make([]ConnTopology, 0, len(r.recorded))
+	for _, conn := range r.recorded {
+		topology = append(topology, conn)
+	}
+	sort.Slice(topology, func(i, j int) bool {
+		return topology[i].Index < topology[j].Index
+	})
+	return topology
+}
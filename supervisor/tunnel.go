@@ -5,9 +5,11 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"net/url"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -59,9 +61,94 @@ type TunnelConfig struct {
 	MaxEdgeAddrRetries uint8
 	RunFromTerminal    bool
 
+	// PreflightDNSCheck makes each connection attempt verify DNS is resolving before dialing the edge.
+	PreflightDNSCheck bool
+	// RegistrationTimeout bounds how long registering a connection may take once the edge was dialed.
+	// Zero means registration is only bounded by the connection itself.
+	RegistrationTimeout time.Duration
+	// MaxAddrsPerAttemptCycle bounds how many distinct edge addresses the first connection tries
+	// before backing off. Zero means no bound.
+	MaxAddrsPerAttemptCycle int
+	// OriginOverrides routes the streams of the connections with the given indexes to a different origin
+	// proxy than the one of the orchestrator, e.g. to canary an origin configuration on a single connection.
+	OriginOverrides map[uint8]connection.OriginProxy
+	// RecoveryConcurrency bounds how many failed connections are relaunched at the same time once their
+	// backoff expired. Zero means no bound.
+	RecoveryConcurrency int
+	// CleanDisconnect classifies errors connections return when they're closed as planned, e.g. while
+	// draining, so that they're treated like clean shutdowns rather than reconnected after a backoff.
+	CleanDisconnect func(err error) bool
+	// MaxRestarts is how many times a connection may restart within MaxRestartsWindow before it's
+	// considered crash looping, and retried much less often until it connects again. Zero disables the limit.
+	MaxRestarts       int
+	MaxRestartsWindow time.Duration
+	// EdgeAddrsFile lists static edge addresses like EdgeAddrs, one host:port per line. They're reloaded
+	// each time the file changes.
+	EdgeAddrsFile string
+	// EdgeResolver looks up the edge SRV records and the addresses they point to, in place of the system
+	// resolver when it's set.
+	EdgeResolver *net.Resolver
+	// EdgeSRVRecord is the SRV record the edge is discovered from. Its empty fields default to the record
+	// of the Cloudflare edge.
+	EdgeSRVRecord allregions.SRVRecord
+	// EdgeIPFilter restricts the discovered edge addresses to dial, e.g. to those egress rules permit.
+	EdgeIPFilter allregions.IPFilter
+	// EdgeAddrsCacheFile caches the edge addresses connections registered with most recently. They're used
+	// when the edge can't be discovered at startup, until it's discovered again.
+	EdgeAddrsCacheFile string
+	// EdgeProxy returns the proxy the edge connection to an https URL of the edge address is tunneled through,
+	// nil to connect directly: a SOCKS5 proxy if its scheme is socks5, or an HTTP(S) proxy it CONNECTs through.
+	// Only http2 connections can be proxied.
+	EdgeProxy func(*url.URL) (*url.URL, error)
+	// EdgeDialTimeouts bounds dialing http2 connections to the edge. Its zero Dial and TLSHandshake mean the
+	// default of 15 seconds. A non-zero TLSHandshake also bounds the handshake of quic connections.
+	EdgeDialTimeouts edgediscovery.DialTimeouts
+	// EdgeDSCP marks the packets of the edge connections with this Differentiated Services Code Point, so that
+	// networks can apply QoS policies to tunnel traffic. Zero doesn't mark them.
+	EdgeDSCP uint8
+	// MuxerConfig tunes the flow control windows and the concurrent streams of http2 connections.
+	MuxerConfig connection.MuxerConfig
+	// EdgeRefreshInterval is how often the edge is discovered again, to pick up addresses that were added
+	// and stop using those that were removed. Zero only discovers the edge at startup.
+	EdgeRefreshInterval time.Duration
+	// EdgeLatencyProbeInterval is how often the latency to every edge address is measured, so that
+	// connections are given the lowest-latency addresses first. Zero disables latency probes.
+	EdgeLatencyProbeInterval time.Duration
+	// EdgeQuarantineThreshold is how many times in a row an edge address may fail to connect before it's
+	// only given to connections once no other address is left, for EdgeQuarantineCooldown. Zero disables
+	// the quarantine.
+	EdgeQuarantineThreshold int
+	EdgeQuarantineCooldown  time.Duration
+	// HASpread is how the HA connections are placed across the edge regions. Empty means HASpreadNone.
+	HASpread HASpreadPolicy
+	// RetryBaseDelay is the initial delay before relaunching failed connections, doubling with each retry up
+	// to RetryMaxDelay. Zero means the default of 10 seconds, and a zero RetryMaxDelay doesn't cap the delay.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// RetryJitter is the fraction of each retry delay that is randomized, between 0 and 1.
+	RetryJitter float64
+	// RegistrationInterval is how long to wait between starting each of the initial HA connections.
+	// Zero means the default of 1 second.
+	RegistrationInterval time.Duration
+	// MaxConnectionAge re-establishes each connection on a different edge address once it lived this long,
+	// staggered across the HA connections. Zero means connections live until they fail.
+	MaxConnectionAge time.Duration
+	// LogSuccessfulConnections logs each registered connection along with how long it took to set up.
+	// cloudflared enables it unless told otherwise.
+	LogSuccessfulConnections bool
+	// ControlPlaneListen is the address to serve the gRPC control plane on. Empty disables the control plane.
+	ControlPlaneListen string
+	// ControlPlaneTLS secures the control plane. It should require and verify client certificates.
+	ControlPlaneTLS *tls.Config
+	// InterleaveAddressFamilies alternates the initial HA connections between IPv4 and IPv6 edge addresses.
+	InterleaveAddressFamilies bool
+
 	NeedPQ bool
+	// PQMode is PQModePrefer to offer the post-quantum key agreements without requiring them, or
+	// PQModeStrict along with NeedPQ.
+	PQMode PQMode
 
-	// Index into PQKexes of post-quantum kex to use if NeedPQ is set.
+	// Index into PQKexes of post-quantum kex to use if NeedPQ is set or PQMode is PQModePrefer.
 	PQKexIdx int
 
 	NamedTunnel      *connection.NamedTunnelProperties
@@ -106,6 +193,57 @@ func (c *TunnelConfig) connectionOptions(originLocalAddr string, numPreviousAtte
 	}
 }
 
+// edgeDialTimeouts returns EdgeDialTimeouts with the defaults in place of its zero timeouts.
+func (c *TunnelConfig) edgeDialTimeouts() edgediscovery.DialTimeouts {
+	timeouts := c.EdgeDialTimeouts
+	if timeouts.Dial == 0 {
+		timeouts.Dial = dialTimeout
+	}
+	if timeouts.TLSHandshake == 0 {
+		timeouts.TLSHandshake = dialTimeout
+	}
+	return timeouts
+}
+
+// edgeProxyURL returns the proxy to connect to the edge address through, nil to connect directly.
+func (c *TunnelConfig) edgeProxyURL(addr *net.TCPAddr) (*url.URL, error) {
+	if c.EdgeProxy == nil {
+		return nil, nil
+	}
+	proxyURL, err := c.EdgeProxy(&url.URL{Scheme: "https", Host: addr.String()})
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy for edge address %s: %w", addr, err)
+	}
+	return proxyURL, nil
+}
+
+// isStaticEdge returns whether the edge addresses are given rather than discovered.
+func (c *TunnelConfig) isStaticEdge() bool {
+	return len(c.EdgeAddrs) > 0 || c.EdgeAddrsFile != ""
+}
+
+// retryBackoff returns the backoff to relaunch failed connections with.
+func (c *TunnelConfig) retryBackoff() retry.BackoffHandler {
+	baseTime := c.RetryBaseDelay
+	if baseTime == 0 {
+		baseTime = tunnelRetryDuration
+	}
+	return retry.BackoffHandler{
+		MaxRetries:   c.Retries,
+		BaseTime:     baseTime,
+		MaxTime:      c.RetryMaxDelay,
+		MinWait:      1 - c.RetryJitter,
+		RetryForever: true,
+	}
+}
+
+func (c *TunnelConfig) registrationInterval() time.Duration {
+	if c.RegistrationInterval == 0 {
+		return registrationInterval
+	}
+	return c.RegistrationInterval
+}
+
 func (c *TunnelConfig) SupportedFeatures() []string {
 	supported := []string{features.FeatureSerializedHeaders}
 	if c.NamedTunnel == nil {
@@ -114,21 +252,6 @@ func (c *TunnelConfig) SupportedFeatures() []string {
 	return supported
 }
 
-func StartTunnelDaemon(
-	ctx context.Context,
-	config *TunnelConfig,
-	orchestrator *orchestration.Orchestrator,
-	connectedSignal *signal.Signal,
-	reconnectCh chan ReconnectSignal,
-	graceShutdownC <-chan struct{},
-) error {
-	s, err := NewSupervisor(config, orchestrator, reconnectCh, graceShutdownC)
-	if err != nil {
-		return err
-	}
-	return s.Run(ctx, connectedSignal)
-}
-
 type ConnectivityError struct {
 	reachedMaxRetries bool
 }
@@ -181,6 +304,8 @@ func (f *ipAddrFallback) ShouldGetNewAddress(connIndex uint8, err error) (needsN
 	// Try the next address if it was a quic.IdleTimeoutError
 	// DupConnRegisterTunnelError needs to also receive a new ip address
 	case connection.DupConnRegisterTunnelError,
+		connection.RegistrationTimeoutError,
+		MaxConnectionAgeError,
 		*quic.IdleTimeoutError:
 		return true, nil
 	// Network problems should be retried with new address immediately and report
@@ -198,7 +323,9 @@ func (f *ipAddrFallback) ShouldGetNewAddress(connIndex uint8, err error) (needsN
 }
 
 type EdgeTunnelServer struct {
-	config            *TunnelConfig
+	config *TunnelConfig
+	// haConnections is how many HA connections the supervisor maintains, which changes when it's scaled
+	haConnections     *atomic.Int32
 	orchestrator      *orchestration.Orchestrator
 	credentialManager *reconnectCredentialManager
 	edgeAddrHandler   EdgeAddrHandler
@@ -228,6 +355,12 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 	// Ensure the above goroutine will terminate if we return without connecting
 	defer connectedFuse.Fuse(false)
 
+	if e.config.PreflightDNSCheck {
+		if err := checkDNSAvailable(ctx, e.config); err != nil {
+			return e.backoffDNSUnavailable(ctx, connIndex, protocolFallback, err)
+		}
+	}
+
 	// Fetch IP address to associated connection index
 	addr, err := e.edgeAddrs.GetAddr(int(connIndex))
 	switch err.(type) {
@@ -245,6 +378,13 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 		Logger()
 	connLog := e.connAwareLogger.ReplaceLogger(&logger)
 
+	e.edgeAddrs.RecordAttempt(addr)
+	go func() {
+		if connectedFuse.Await() {
+			e.edgeAddrs.RecordRegistered(addr)
+		}
+	}()
+
 	// Each connection to keep its own copy of protocol, because individual connections might fallback
 	// to another protocol when a particular metal doesn't support new protocol
 	// Each connection can also have it's own IP version because individual connections might fallback
@@ -258,14 +398,22 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 		protocolFallback,
 		protocolFallback.protocol,
 	)
+	if connectedFuse.Value() || err != nil {
+		e.edgeAddrs.RecordOutcome(addr, connectedFuse.Value())
+	}
 
 	// Check if the connection error was from an IP issue with the host or
 	// establishing a connection to the edge and if so, rotate the IP address.
 	shouldRotateEdgeIP, cErr := e.edgeAddrHandler.ShouldGetNewAddress(connIndex, err)
+	_, reachedMaxAge := err.(MaxConnectionAgeError)
 	if shouldRotateEdgeIP {
 		// rotate IP, but forcing internal state to assign a new IP to connection index.
-		if _, err := e.edgeAddrs.GetDifferentAddr(int(connIndex), true); err != nil {
-			return err
+		_, noAlternative, rotateErr := e.edgeAddrs.GetDifferentAddr(int(connIndex), !reachedMaxAge)
+		if rotateErr != nil {
+			return rotateErr
+		}
+		if noAlternative {
+			connLog.Logger().Debug().Msg("No other edge address to rotate to, retrying the only one")
 		}
 
 		// In addition, if it is a connectivity error, and we have exhausted the configurable maximum edge IPs to rotate,
@@ -276,6 +424,11 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 		}
 	}
 
+	if reachedMaxAge {
+		// The connection was healthy, so it's re-established right away rather than after a backoff
+		return err
+	}
+
 	// set connection has re-connecting and log the next retrying backoff
 	duration, ok := protocolFallback.GetMaxBackoffDuration(ctx)
 	if !ok {
@@ -314,6 +467,32 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 	return err
 }
 
+// backoffDNSUnavailable reports that DNS is down and waits for the backoff before giving up on this attempt,
+// instead of dialing an edge address that is bound to fail.
+func (e *EdgeTunnelServer) backoffDNSUnavailable(ctx context.Context, connIndex uint8, protocolFallback *protocolFallback, err error) error {
+	connLog := e.connAwareLogger.Logger().With().
+		Int(management.EventTypeKey, int(management.Cloudflared)).
+		Uint8(connection.LogFieldConnIndex, connIndex).
+		Logger()
+	connLog.Error().Err(err).Msg("DNS unavailable, not attempting to connect to Cloudflare edge")
+
+	duration, ok := protocolFallback.GetMaxBackoffDuration(ctx)
+	if !ok {
+		return err
+	}
+	e.config.Observer.SendReconnect(connIndex)
+	connLog.Info().Msgf("Retrying connection in up to %s", duration)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-e.gracefulShutdownC:
+		return nil
+	case <-protocolFallback.BackoffTimer():
+	}
+	return err
+}
+
 // protocolFallback is a wrapper around backoffHandler that will try fallback option when backoff reaches
 // max retries
 type protocolFallback struct {
@@ -429,6 +608,12 @@ func (e *EdgeTunnelServer) serveTunnel(
 			connLog.ConnAwareLogger().Err(err).Msg("Unable to establish connection.")
 			// don't retry this connection anymore, let supervisor pick a new address
 			return err, false
+		case connection.RegistrationTimeoutError:
+			connLog.ConnAwareLogger().Err(err).Msg("Timed out registering connection, trying another address")
+			return err, false
+		case MaxConnectionAgeError:
+			connLog.Logger().Info().Msgf("Connection reached its maximum age of %s, re-establishing it on another address", err.Age)
+			return err, false
 		case connection.ServerRegisterTunnelError:
 			connLog.ConnAwareLogger().Err(err).Msg("Register tunnel error from server side")
 			// Don't send registration error return from server to Sentry. They are
@@ -482,6 +667,8 @@ func (e *EdgeTunnelServer) serveConnection(
 		e.gracefulShutdownC,
 		e.config.GracePeriod,
 		protocol,
+		e.config.RegistrationTimeout,
+		e.config.LogSuccessfulConnections,
 	)
 
 	switch protocol {
@@ -495,7 +682,7 @@ func (e *EdgeTunnelServer) serveConnection(
 			connIndex)
 
 	case connection.HTTP2:
-		edgeConn, err := edgediscovery.DialEdge(ctx, dialTimeout, e.config.EdgeTLSConfigs[protocol], addr.TCP, e.edgeBindAddr)
+		edgeConn, err := e.dialEdgeHTTP2(ctx, connLog, addr, connIndex)
 		if err != nil {
 			connLog.ConnAwareLogger().Err(err).Msg("Unable to establish connection with Cloudflare edge")
 			return err, true
@@ -519,6 +706,78 @@ func (e *EdgeTunnelServer) serveConnection(
 	return
 }
 
+// dialEdgeHTTP2 dials the edge address of the connection. If both IP versions are used, an address of the
+// other IP version is raced against it, preferring IPv6, and the connection moves to the address that won.
+// There's no race when dialing through a proxy, which reaches the edge over whichever IP version it has.
+func (e *EdgeTunnelServer) dialEdgeHTTP2(
+	ctx context.Context,
+	connLog *ConnAwareLogger,
+	addr *allregions.EdgeAddr,
+	connIndex uint8,
+) (net.Conn, error) {
+	tlsConfig := e.config.EdgeTLSConfigs[connection.HTTP2]
+	timeouts := e.config.edgeDialTimeouts()
+	proxyURL, err := e.config.edgeProxyURL(addr.TCP)
+	if err != nil {
+		return nil, err
+	}
+	if e.config.PQMode == PQModePrefer {
+		edgeConn, err := edgediscovery.DialEdge(ctx, timeouts, pqTLSConfig(tlsConfig, e.config.PQKexIdx), addr.TCP, e.edgeBindAddr, e.config.EdgeDSCP, proxyURL)
+		recordPQHandshake(err)
+		if err == nil {
+			return edgeConn, nil
+		}
+		connLog.Logger().Warn().Err(err).Msg("Failed to connect with post-quantum key agreement, falling back to classical key agreement")
+	}
+	fallback := e.edgeAddrs.GetFallbackAddr(int(connIndex))
+	if fallback == nil || e.edgeBindAddr != nil || e.config.EdgeProxy != nil {
+		return edgediscovery.DialEdge(ctx, timeouts, tlsConfig, addr.TCP, e.edgeBindAddr, e.config.EdgeDSCP, proxyURL)
+	}
+
+	preferred := addr
+	if addr.IPVersion == allregions.V4 {
+		preferred, fallback = fallback, addr
+	}
+	edgeConn, dialedAddr, err := edgediscovery.DialEdgeHappyEyeballs(ctx, timeouts, tlsConfig, preferred.TCP, fallback.TCP, nil, e.config.EdgeDSCP)
+	if err != nil {
+		return nil, err
+	}
+	winner := preferred
+	if dialedAddr == fallback.TCP {
+		winner = fallback
+	}
+	if winner != addr && !e.edgeAddrs.SwitchAddr(int(connIndex), winner) {
+		// The address was given to another connection while racing
+		_ = edgeConn.Close()
+		return edgediscovery.DialEdge(ctx, timeouts, tlsConfig, addr.TCP, e.edgeBindAddr, e.config.EdgeDSCP, nil)
+	}
+	if winner != addr {
+		connLog.Logger().Debug().
+			IPAddr(connection.LogFieldIPAddress, winner.UDP.IP).
+			Msgf("Connected over IPv%s edge address, which won the race against the IPv%s one", winner.IPVersion, addr.IPVersion)
+	}
+	return edgeConn, nil
+}
+
+// orchestratorFor returns the orchestrator serving the streams of the connection with the given index.
+func (e *EdgeTunnelServer) orchestratorFor(connIndex uint8) connection.Orchestrator {
+	if originProxy, ok := e.config.OriginOverrides[connIndex]; ok {
+		return &originOverride{Orchestrator: e.orchestrator, originProxy: originProxy}
+	}
+	return e.orchestrator
+}
+
+// originOverride proxies the streams of a connection to its own origin proxy, while everything
+// else, like remote configuration updates, is still handled by the orchestrator.
+type originOverride struct {
+	connection.Orchestrator
+	originProxy connection.OriginProxy
+}
+
+func (o *originOverride) GetOriginProxy() (connection.OriginProxy, error) {
+	return o.originProxy, nil
+}
+
 type unrecoverableError struct {
 	err error
 }
@@ -542,10 +801,11 @@ func (e *EdgeTunnelServer) serveHTTP2(
 	connLog.Logger().Debug().Msgf("Connecting via http2")
 	h2conn := connection.NewHTTP2Connection(
 		tlsServerConn,
-		e.orchestrator,
+		e.orchestratorFor(connIndex),
 		connOptions,
 		e.config.Observer,
 		connIndex,
+		&e.config.MuxerConfig,
 		controlStreamHandler,
 		e.config.Log,
 	)
@@ -555,6 +815,12 @@ func (e *EdgeTunnelServer) serveHTTP2(
 		return h2conn.Serve(serveCtx)
 	})
 
+	if e.config.MaxConnectionAge > 0 {
+		errGroup.Go(func() error {
+			return waitMaxConnectionAge(serveCtx, maxConnectionAge(e.config.MaxConnectionAge, connIndex, int(e.haConnections.Load())))
+		})
+	}
+
 	errGroup.Go(func() error {
 		err := listenReconnect(serveCtx, e.reconnectCh, e.gracefulShutdownC)
 		if err != nil {
@@ -581,16 +847,7 @@ func (e *EdgeTunnelServer) serveQUIC(
 	if e.config.NeedPQ {
 		// If the user passes the -post-quantum flag, we override
 		// CurvePreferences to only support hybrid post-quantum key agreements.
-		cs := make([]tls.CurveID, len(PQKexes))
-		copy(cs, PQKexes[:])
-
-		// It is unclear whether Kyber512 or Kyber768 will become the standard.
-		// Kyber768 is a bit bigger (and doesn't fit in one initial
-		// datagram anymore). We're enabling both, but pick randomly which
-		// one to put first. (TLS will use the first one in the list
-		// and allows a fallback to the second.)
-		cs[0], cs[e.config.PQKexIdx] = cs[e.config.PQKexIdx], cs[0]
-		tlsConfig.CurvePreferences = cs
+		tlsConfig.CurvePreferences = pqCurvePreferences(e.config.PQKexIdx)
 	}
 
 	quicConfig := &quic.Config{
@@ -603,19 +860,41 @@ func (e *EdgeTunnelServer) serveQUIC(
 		MaxDatagramFrameSize:  quicpogs.MaxDatagramFrameSize,
 		Tracer:                quicpogs.NewClientTracer(connLogger.Logger(), connIndex),
 	}
+	if e.config.EdgeDialTimeouts.TLSHandshake > 0 {
+		quicConfig.HandshakeIdleTimeout = e.config.EdgeDialTimeouts.TLSHandshake
+	}
 
-	quicConn, err := connection.NewQUICConnection(
-		ctx,
-		quicConfig,
-		edgeAddr,
-		e.edgeBindAddr,
-		connIndex,
-		tlsConfig,
-		e.orchestrator,
-		connOptions,
-		controlStreamHandler,
-		connLogger.Logger(),
-		e.config.PacketConfig)
+	newQUICConnection := func(tlsConfig *tls.Config) (*connection.QUICConnection, error) {
+		return connection.NewQUICConnection(
+			ctx,
+			quicConfig,
+			edgeAddr,
+			e.edgeBindAddr,
+			e.config.EdgeDSCP,
+			connIndex,
+			tlsConfig,
+			e.orchestratorFor(connIndex),
+			connOptions,
+			e.config.Observer,
+			controlStreamHandler,
+			connLogger.Logger(),
+			e.config.PacketConfig)
+	}
+
+	var quicConn *connection.QUICConnection
+	if e.config.PQMode == PQModePrefer {
+		quicConn, err = newQUICConnection(pqTLSConfig(tlsConfig, e.config.PQKexIdx))
+		recordPQHandshake(err)
+		if err != nil {
+			connLogger.Logger().Warn().Err(err).Msg("Failed to create new quic connection with post-quantum key agreement, falling back to classical key agreement")
+			quicConn, err = newQUICConnection(tlsConfig)
+		}
+	} else {
+		quicConn, err = newQUICConnection(tlsConfig)
+		if e.config.NeedPQ {
+			recordPQHandshake(err)
+		}
+	}
 	if err != nil {
 		if e.config.NeedPQ {
 			handlePQTunnelError(err, e.config)
@@ -634,6 +913,12 @@ func (e *EdgeTunnelServer) serveQUIC(
 		return err
 	})
 
+	if e.config.MaxConnectionAge > 0 {
+		errGroup.Go(func() error {
+			return waitMaxConnectionAge(serveCtx, maxConnectionAge(e.config.MaxConnectionAge, connIndex, int(e.haConnections.Load())))
+		})
+	}
+
 	errGroup.Go(func() error {
 		err := listenReconnect(serveCtx, e.reconnectCh, e.gracefulShutdownC)
 		if err != nil {
@@ -1,16 +1,27 @@
 package supervisor
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/quic-go/quic-go"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/edgediscovery"
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/orchestration"
 	"github.com/cloudflare/cloudflared/retry"
+	"github.com/cloudflare/cloudflared/signal"
+	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/tunnelstate"
 )
 
 type dynamicMockFetcher struct {
@@ -42,6 +53,7 @@ func TestWaitForBackoffFallback(t *testing.T) {
 		false,
 		mockFetcher.fetch(),
 		resolveTTL,
+		0,
 		&log,
 	)
 	assert.NoError(t, err)
@@ -103,6 +115,7 @@ func TestWaitForBackoffFallback(t *testing.T) {
 		false,
 		mockFetcher.fetch(),
 		resolveTTL,
+		0,
 		&log,
 	)
 	assert.NoError(t, err)
@@ -118,3 +131,158 @@ func TestWaitForBackoffFallback(t *testing.T) {
 	ok = selectNextProtocol(&log, protoFallback, protocolSelector, &quic.IdleTimeoutError{})
 	assert.False(t, ok)
 }
+
+func TestPreflightDNSCheckUnavailable(t *testing.T) {
+	originalLookupSRV := preflightLookupSRV
+	defer func() { preflightLookupSRV = originalLookupSRV }()
+	edgeResolver := &net.Resolver{PreferGo: true}
+	var lookups []string
+	preflightLookupSRV = func(ctx context.Context, resolver *net.Resolver, service, proto, name string) ([]*net.SRV, error) {
+		// The record edge discovery looks up, with its resolver
+		assert.Same(t, edgeResolver, resolver)
+		lookups = append(lookups, allregions.SRVRecord{Service: service, Proto: proto, Name: name}.String())
+		return nil, errors.New("no such host")
+	}
+
+	var logOutput bytes.Buffer
+	log := zerolog.New(&logOutput)
+	observer := connection.NewObserver(&log, &log)
+	tracker := tunnelstate.NewConnTracker(&log)
+	edgeAddrs, err := edgediscovery.StaticEdge(&log, []string{"127.0.0.1:7844"})
+	require.NoError(t, err)
+
+	server := EdgeTunnelServer{
+		config: &TunnelConfig{
+			Log:               &log,
+			Observer:          observer,
+			PreflightDNSCheck: true,
+			Region:            "us",
+			EdgeSRVRecord:     allregions.SRVRecord{Name: "argotunnel.example.com"},
+			EdgeResolver:      edgeResolver,
+		},
+		edgeAddrs:       edgeAddrs,
+		tracker:         tracker,
+		connAwareLogger: NewConnAwareLogger(&log, tracker, observer),
+	}
+	protoFallback := &protocolFallback{
+		retry.BackoffHandler{MaxRetries: 1, BaseTime: time.Millisecond},
+		connection.HTTP2,
+		false,
+	}
+
+	err = server.Serve(context.Background(), 0, protoFallback, signal.New(make(chan struct{})))
+	var dnsErr DNSUnavailableError
+	require.True(t, errors.As(err, &dnsErr))
+	assert.Equal(t, []string{"_us-v2-origintunneld._tcp.argotunnel.example.com"}, lookups)
+	assert.Contains(t, logOutput.String(), "DNS unavailable")
+	// The edge address must not have been handed out since no dial was attempted
+	assert.Equal(t, 1, edgeAddrs.AvailableAddrs())
+	// The attempt should have backed off
+	assert.Equal(t, 1, protoFallback.Retries())
+}
+
+func TestRegistrationTimeoutRotatesAddress(t *testing.T) {
+	fallback := NewIPAddrFallback(3)
+	needsNewAddress, cErr := fallback.ShouldGetNewAddress(0, connection.RegistrationTimeoutError{Timeout: time.Second})
+	assert.True(t, needsNewAddress)
+	assert.NoError(t, cErr)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	backoff := (&TunnelConfig{Retries: 3}).retryBackoff()
+	assert.Equal(t, tunnelRetryDuration, backoff.BaseTime)
+	assert.Equal(t, time.Duration(0), backoff.MaxTime)
+	assert.Equal(t, 1.0, backoff.MinWait)
+
+	backoff = (&TunnelConfig{
+		Retries:        3,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  time.Minute,
+		RetryJitter:    0.25,
+	}).retryBackoff()
+	assert.Equal(t, uint(3), backoff.MaxRetries)
+	assert.Equal(t, time.Second, backoff.BaseTime)
+	assert.Equal(t, time.Minute, backoff.MaxTime)
+	assert.Equal(t, 0.75, backoff.MinWait)
+	assert.True(t, backoff.RetryForever)
+
+	assert.Equal(t, registrationInterval, (&TunnelConfig{}).registrationInterval())
+	assert.Equal(t, time.Millisecond, (&TunnelConfig{RegistrationInterval: time.Millisecond}).registrationInterval())
+}
+
+func TestMaxConnectionAgeIsStaggered(t *testing.T) {
+	const haConnections = 4
+	ages := make([]time.Duration, haConnections)
+	for i := range ages {
+		ages[i] = maxConnectionAge(time.Hour, uint8(i), haConnections)
+	}
+	assert.Equal(t, []time.Duration{time.Hour, 75 * time.Minute, 90 * time.Minute, 105 * time.Minute}, ages)
+	assert.Equal(t, time.Hour, maxConnectionAge(time.Hour, 0, 1))
+}
+
+func TestMaxConnectionAgeRecyclesConnection(t *testing.T) {
+	log := zerolog.Nop()
+	observer := connection.NewObserver(&log, &log)
+	tracker := tunnelstate.NewConnTracker(&log)
+	server := EdgeTunnelServer{
+		config: &TunnelConfig{
+			Log:              &log,
+			Observer:         observer,
+			HAConnections:    1,
+			MaxConnectionAge: 10 * time.Millisecond,
+		},
+		haConnections:   newHAConnections(1),
+		tracker:         tracker,
+		connAwareLogger: NewConnAwareLogger(&log, tracker, observer),
+	}
+
+	edgeConn, originConn := net.Pipe()
+	defer edgeConn.Close()
+	connOptions := &tunnelpogs.ConnectionOptions{}
+	controlStream := connection.NewControlStream(observer, nil, nil, 0, nil, nil, nil, 0, connection.HTTP2, 0, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := server.serveHTTP2(ctx, server.connAwareLogger, originConn, connOptions, controlStream, 0)
+	var ageErr MaxConnectionAgeError
+	require.True(t, errors.As(err, &ageErr), "unexpected error %v", err)
+	assert.NoError(t, ctx.Err(), "connection should be recycled before the test times out")
+
+	needsNewAddress, cErr := NewIPAddrFallback(3).ShouldGetNewAddress(0, err)
+	assert.True(t, needsNewAddress)
+	assert.NoError(t, cErr)
+}
+
+type mockOriginProxy struct {
+	connection.OriginProxy
+}
+
+func TestOriginOverrides(t *testing.T) {
+	log := zerolog.Nop()
+	orchestrator, err := orchestration.NewOrchestrator(context.Background(), &orchestration.Config{
+		Ingress: &ingress.Ingress{},
+	}, nil, []ingress.Rule{}, &log)
+	require.NoError(t, err)
+	defaultProxy, err := orchestrator.GetOriginProxy()
+	require.NoError(t, err)
+
+	canary := &mockOriginProxy{}
+	server := EdgeTunnelServer{
+		config: &TunnelConfig{
+			OriginOverrides: map[uint8]connection.OriginProxy{1: canary},
+		},
+		orchestrator: orchestrator,
+	}
+
+	for connIndex := uint8(0); connIndex < 4; connIndex++ {
+		originProxy, err := server.orchestratorFor(connIndex).GetOriginProxy()
+		require.NoError(t, err)
+		if connIndex == 1 {
+			assert.Same(t, canary, originProxy, "connection %d", connIndex)
+		} else {
+			assert.Equal(t, defaultProxy, originProxy, "connection %d", connIndex)
+		}
+		// Everything but the streams is still handled by the orchestrator
+		assert.Equal(t, orchestrator.WarpRoutingEnabled(), server.orchestratorFor(connIndex).WarpRoutingEnabled())
+	}
+}
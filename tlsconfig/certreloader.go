@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"runtime"
+	"strings"
 	"sync"
 
 	"github.com/getsentry/sentry-go"
@@ -15,8 +16,10 @@ import (
 )
 
 const (
-	OriginCAPoolFlag = "origin-ca-pool"
-	CaCertFlag       = "cacert"
+	OriginCAPoolFlag     = "origin-ca-pool"
+	OriginClientCertFlag = "origin-client-cert"
+	OriginClientKeyFlag  = "origin-client-key"
+	CaCertFlag           = "cacert"
 )
 
 // CertReloader can load and reload a TLS certificate from a particular filepath.
@@ -96,6 +99,35 @@ func LoadOriginCA(originCAPoolFilename string, log *zerolog.Logger) (*x509.CertP
 	return originCertPool, nil
 }
 
+// LoadOriginClientCertificate loads the certificate cloudflared presents to origins that require mutual TLS.
+// cert and key are either paths to PEM files or inline PEM blocks.
+func LoadOriginClientCertificate(cert, key string) (tls.Certificate, error) {
+	if cert == "" || key == "" {
+		return tls.Certificate{}, fmt.Errorf("both a client certificate and a client key are required for mutual TLS with the origin")
+	}
+	certPEM, err := readPEM(cert)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "unable to read the origin client certificate")
+	}
+	keyPEM, err := readPEM(key)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "unable to read the origin client key")
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "Error parsing the origin client X509 key pair")
+	}
+	return certificate, nil
+}
+
+// readPEM returns pemOrPath if it's a PEM block, and the content of the file at pemOrPath otherwise.
+func readPEM(pemOrPath string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(pemOrPath), "-----BEGIN") {
+		return []byte(pemOrPath), nil
+	}
+	return ioutil.ReadFile(pemOrPath)
+}
+
 func LoadCustomOriginCA(originCAFilename string) (*x509.CertPool, error) {
 	// First, obtain the system certificate pool
 	certPool, err := x509.SystemCertPool()
@@ -0,0 +1,94 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+)
+
+var (
+	// FIPSCipherSuites are the FIPS-approved cipher suites. TLS 1.3 cipher suites can't be configured, so
+	// connections negotiating TLS_CHACHA20_POLY1305_SHA256 are rejected once the handshake completes instead.
+	FIPSCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_AES_128_GCM_SHA256,
+		tls.TLS_AES_256_GCM_SHA384,
+	}
+	// FIPSCurves are the FIPS-approved key agreement curves.
+	FIPSCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+)
+
+var fipsEnabled atomic.Bool
+
+// EnableFIPS restricts the TLS configs passed to EnforceFIPS to the FIPS-approved settings from then on.
+func EnableFIPS() {
+	fipsEnabled.Store(true)
+}
+
+// FIPSEnabled reports whether EnableFIPS was called.
+func FIPSEnabled() bool {
+	return fipsEnabled.Load()
+}
+
+// EnforceFIPS restricts config to TLS 1.2 or later, FIPSCipherSuites and FIPSCurves if FIPS mode is enabled, and
+// does nothing otherwise. It returns an error rather than overriding the settings of config that aren't approved.
+func EnforceFIPS(config *tls.Config) error {
+	if !FIPSEnabled() {
+		return nil
+	}
+	if config.MaxVersion != 0 && config.MaxVersion < tls.VersionTLS12 {
+		return fmt.Errorf("TLS versions before 1.2 aren't allowed in FIPS mode")
+	}
+	for _, suite := range config.CipherSuites {
+		if !isFIPSCipherSuite(suite) {
+			return fmt.Errorf("cipher suite %s isn't allowed in FIPS mode", tls.CipherSuiteName(suite))
+		}
+	}
+	for _, curve := range config.CurvePreferences {
+		if !isFIPSCurve(curve) {
+			return fmt.Errorf("curve %s isn't allowed in FIPS mode", curve)
+		}
+	}
+
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if len(config.CipherSuites) == 0 {
+		config.CipherSuites = FIPSCipherSuites
+	}
+	if len(config.CurvePreferences) == 0 {
+		config.CurvePreferences = FIPSCurves
+	}
+	verifyConnection := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if !isFIPSCipherSuite(cs.CipherSuite) {
+			return fmt.Errorf("%s negotiated cipher suite %s, which isn't allowed in FIPS mode", cs.ServerName, tls.CipherSuiteName(cs.CipherSuite))
+		}
+		if verifyConnection != nil {
+			return verifyConnection(cs)
+		}
+		return nil
+	}
+	return nil
+}
+
+func isFIPSCipherSuite(suite uint16) bool {
+	for _, s := range FIPSCipherSuites {
+		if s == suite {
+			return true
+		}
+	}
+	return false
+}
+
+func isFIPSCurve(curve tls.CurveID) bool {
+	for _, c := range FIPSCurves {
+		if c == curve {
+			return true
+		}
+	}
+	return false
+}
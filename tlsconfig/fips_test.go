@@ -0,0 +1,81 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enableTestFIPS(t *testing.T) {
+	EnableFIPS()
+	t.Cleanup(func() { fipsEnabled.Store(false) })
+}
+
+func TestEnforceFIPSDisabled(t *testing.T) {
+	config := &tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}}
+	require.NoError(t, EnforceFIPS(config))
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}, config.CipherSuites)
+	assert.Nil(t, config.VerifyConnection)
+}
+
+func TestEnforceFIPS(t *testing.T) {
+	enableTestFIPS(t)
+
+	verified := false
+	config := &tls.Config{
+		CurvePreferences: []tls.CurveID{tls.CurveP384},
+		VerifyConnection: func(tls.ConnectionState) error {
+			verified = true
+			return nil
+		},
+	}
+	require.NoError(t, EnforceFIPS(config))
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Equal(t, FIPSCipherSuites, config.CipherSuites)
+	assert.Equal(t, []tls.CurveID{tls.CurveP384}, config.CurvePreferences)
+
+	assert.Error(t, config.VerifyConnection(tls.ConnectionState{CipherSuite: tls.TLS_CHACHA20_POLY1305_SHA256}))
+	assert.False(t, verified)
+	assert.NoError(t, config.VerifyConnection(tls.ConnectionState{CipherSuite: tls.TLS_AES_128_GCM_SHA256}))
+	assert.True(t, verified)
+}
+
+func TestEnforceFIPSRejectsSettings(t *testing.T) {
+	enableTestFIPS(t)
+
+	for _, config := range []*tls.Config{
+		{MaxVersion: tls.VersionTLS11},
+		{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}},
+		{CurvePreferences: []tls.CurveID{tls.X25519}},
+	} {
+		assert.Error(t, EnforceFIPS(config))
+	}
+}
+
+func TestEnforceFIPSHandshake(t *testing.T) {
+	enableTestFIPS(t)
+
+	cert, err := tls.LoadX509KeyPair("testcert.pem", "testkey.pem")
+	require.NoError(t, err)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305},
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	config := &tls.Config{InsecureSkipVerify: true}
+	require.NoError(t, EnforceFIPS(config))
+	_, err = tls.Dial("tcp", listener.Addr().String(), config)
+	assert.Error(t, err)
+}
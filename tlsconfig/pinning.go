@@ -0,0 +1,73 @@
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const spkiPinPrefix = "sha256/"
+
+// SPKIPin is the SHA-256 hash of the DER-encoded SubjectPublicKeyInfo of a certificate, as in HPKP (RFC 7469).
+type SPKIPin [sha256.Size]byte
+
+// ParseSPKIPins parses pins given as the base64 encoded SHA-256 hash of a SubjectPublicKeyInfo, optionally
+// prefixed with sha256/, e.g. the output of
+// `openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64`.
+func ParseSPKIPins(pins []string) ([]SPKIPin, error) {
+	parsed := make([]SPKIPin, 0, len(pins))
+	for _, pin := range pins {
+		hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, spkiPinPrefix))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate pin %s, expected the base64 encoded SHA-256 hash of a SubjectPublicKeyInfo", pin)
+		}
+		var p SPKIPin
+		copy(p[:], hash)
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// NewSPKIPin returns the pin of cert.
+func NewSPKIPin(cert *x509.Certificate) SPKIPin {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+func (p SPKIPin) String() string {
+	return spkiPinPrefix + base64.StdEncoding.EncodeToString(p[:])
+}
+
+// PinSPKI makes config reject the connections whose verified certificate chain doesn't contain a certificate
+// with one of pins, on top of the regular verification. That way a CA trusted by config, but compromised,
+// can't issue a certificate that's accepted. It does nothing if pins is empty.
+func PinSPKI(config *tls.Config, pins []SPKIPin) {
+	if len(pins) == 0 {
+		return
+	}
+	pinned := make(map[SPKIPin]struct{}, len(pins))
+	for _, pin := range pins {
+		pinned[pin] = struct{}{}
+	}
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		chains := cs.VerifiedChains
+		if len(chains) == 0 {
+			// The chain isn't verified with InsecureSkipVerify, pin the certificates the peer sent
+			chains = [][]*x509.Certificate{cs.PeerCertificates}
+		}
+		for _, chain := range chains {
+			for _, cert := range chain {
+				if _, ok := pinned[NewSPKIPin(cert)]; ok {
+					return nil
+				}
+			}
+		}
+		var leaf SPKIPin
+		if len(cs.PeerCertificates) > 0 {
+			leaf = NewSPKIPin(cs.PeerCertificates[0])
+		}
+		return fmt.Errorf("certificate of %s doesn't match any pinned public key, its pin is %s", cs.ServerName, leaf)
+	}
+}
@@ -0,0 +1,53 @@
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSPKIPins(t *testing.T) {
+	hash := sha256.Sum256([]byte("spki"))
+	encoded := base64.StdEncoding.EncodeToString(hash[:])
+
+	pins, err := ParseSPKIPins([]string{encoded, "sha256/" + encoded})
+	require.NoError(t, err)
+	assert.Equal(t, []SPKIPin{hash, hash}, pins)
+	assert.Equal(t, "sha256/"+encoded, pins[0].String())
+
+	for _, pin := range []string{"", "not base64", "sha256/" + base64.StdEncoding.EncodeToString(hash[:16])} {
+		_, err := ParseSPKIPins([]string{pin})
+		assert.Error(t, err, pin)
+	}
+}
+
+func TestPinSPKI(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	serverPin := NewSPKIPin(server.Certificate())
+	otherPin := SPKIPin(sha256.Sum256([]byte("other")))
+
+	dial := func(pins []SPKIPin) error {
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Certificate())
+		config := &tls.Config{RootCAs: roots, ServerName: "example.com"}
+		PinSPKI(config, pins)
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), config)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	assert.NoError(t, dial(nil))
+	assert.NoError(t, dial([]SPKIPin{otherPin, serverPin}))
+	err := dial([]SPKIPin{otherPin})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), serverPin.String())
+}
@@ -2,6 +2,7 @@ package tlsconfig
 
 import (
 	"crypto/tls"
+	"io/ioutil"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -80,3 +81,27 @@ func TestCertReloader(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedCert, *cert)
 }
+
+func TestLoadOriginClientCertificate(t *testing.T) {
+	expectedCert, err := tls.LoadX509KeyPair("testcert.pem", "testkey.pem")
+	assert.NoError(t, err)
+
+	cert, err := LoadOriginClientCertificate("testcert.pem", "testkey.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedCert, cert)
+
+	certPEM, err := ioutil.ReadFile("testcert.pem")
+	assert.NoError(t, err)
+	keyPEM, err := ioutil.ReadFile("testkey.pem")
+	assert.NoError(t, err)
+	cert, err = LoadOriginClientCertificate(string(certPEM), string(keyPEM))
+	assert.NoError(t, err)
+	assert.Equal(t, expectedCert, cert)
+
+	_, err = LoadOriginClientCertificate("testcert.pem", "")
+	assert.Error(t, err)
+	_, err = LoadOriginClientCertificate("testcert.pem", "testcert.pem")
+	assert.Error(t, err)
+	_, err = LoadOriginClientCertificate("testcert.pem", "does-not-exist.pem")
+	assert.Error(t, err)
+}
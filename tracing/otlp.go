@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const otlpExportTimeout = 10 * time.Second
+
+var (
+	// otlpProcessor exports the spans of all requests when OTLP export is enabled, it's nil otherwise
+	otlpProcessor tracesdk.SpanProcessor
+	// otlpProvider traces the requests that aren't traced by the edge when OTLP export is enabled
+	otlpProvider *tracesdk.TracerProvider
+)
+
+// OTLPConfig configures the export of the spans of the requests proxied to origins to an OpenTelemetry collector,
+// over OTLP/HTTP.
+type OTLPConfig struct {
+	// Endpoint is the URL spans are posted to, e.g. http://localhost:4318/v1/traces.
+	Endpoint string
+	// Headers are sent with every export, e.g. to authenticate to the collector.
+	Headers map[string]string
+	// SampleRatio is the fraction of the requests that aren't traced by the edge that are traced, between 0 and 1.
+	// Requests traced by the edge always are.
+	SampleRatio float64
+}
+
+// InitOTLP starts exporting spans as configured by cfg. It must be called before requests are proxied. The returned
+// function exports the spans that weren't yet, and stops the export.
+func InitOTLP(cfg OTLPConfig) (func(context.Context) error, error) {
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid OTLP sample ratio %v, expected a value between 0 and 1", cfg.SampleRatio)
+	}
+	exporter, err := otlptrace.New(context.Background(), &otlpHTTPClient{
+		endpoint: cfg.Endpoint,
+		headers:  cfg.Headers,
+		client:   &http.Client{Timeout: otlpExportTimeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+	otlpProcessor = tracesdk.NewBatchSpanProcessor(exporter)
+	otlpProvider = tracesdk.NewTracerProvider(
+		tracesdk.WithSpanProcessor(otlpProcessor),
+		tracesdk.WithSampler(tracesdk.ParentBased(tracesdk.TraceIDRatioBased(cfg.SampleRatio))),
+		tracesdk.WithResource(newResource()),
+	)
+	return otlpProvider.Shutdown, nil
+}
+
+// OTLPEnabled returns true if spans are exported over OTLP.
+func OTLPEnabled() bool {
+	return otlpProvider != nil
+}
+
+// otlpHTTPClient is an otlptrace.Client posting spans to a collector as protobuf, as per the OTLP/HTTP
+// specification.
+type otlpHTTPClient struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func (c *otlpHTTPClient) Start(_ context.Context) error {
+	return nil
+}
+
+func (c *otlpHTTPClient) Stop(_ context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *otlpHTTPClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	body, err := proto.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("OTLP collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// ExtractTraceParent returns a copy of ctx carrying the W3C trace context of header, so that the spans of a request
+// continue the trace of the client that sent it.
+func ExtractTraceParent(ctx context.Context, header http.Header) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectTraceParent sets the W3C traceparent header of a request to an origin to the span in ctx, so that the
+// spans of the origin are part of the same trace. It does nothing if ctx has no span.
+func InjectTraceParent(ctx context.Context, header http.Header) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(header))
+}
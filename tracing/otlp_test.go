@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPExport(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		spans []string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var export coltracepb.ExportTraceServiceRequest
+		require.NoError(t, proto.Unmarshal(body, &export))
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, resourceSpans := range export.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					spans = append(spans, span.Name)
+				}
+			}
+		}
+	}))
+	defer collector.Close()
+
+	stop, err := InitOTLP(OTLPConfig{
+		Endpoint:    collector.URL,
+		Headers:     map[string]string{"Authorization": "secret"},
+		SampleRatio: 1,
+	})
+	require.NoError(t, err)
+	defer func() {
+		otlpProcessor = nil
+		otlpProvider = nil
+	}()
+	assert.True(t, OTLPEnabled())
+
+	log := zerolog.Nop()
+	// Requests that aren't traced by the edge are traced locally
+	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	tr := NewTracedHTTPRequest(req, 0, &log)
+	_, span := tr.Tracer().Start(tr.Context(), "local")
+	span.End()
+	assert.Empty(t, tr.GetSpans())
+
+	// Requests traced by the edge are also exported
+	req = httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.Header.Add(TracerContextName, "14cb070dde8e51fc5ae8514e69ba42ca:b38f1bf5eae406f3:0:1")
+	tr = NewTracedHTTPRequest(req, 0, &log)
+	_, span = tr.Tracer().Start(tr.Context(), "edge")
+	span.End()
+	assert.NotEmpty(t, tr.GetSpans())
+
+	require.NoError(t, stop(context.Background()))
+	lock.Lock()
+	defer lock.Unlock()
+	assert.ElementsMatch(t, []string{"local", "edge"}, spans)
+}
+
+func TestOTLPInvalidSampleRatio(t *testing.T) {
+	_, err := InitOTLP(OTLPConfig{Endpoint: "http://localhost:4318/v1/traces", SampleRatio: 2})
+	assert.Error(t, err)
+	assert.False(t, OTLPEnabled())
+}
+
+func TestTraceParentPropagation(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	header := http.Header{"Traceparent": []string{traceParent}}
+	ctx := ExtractTraceParent(context.Background(), header)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.SpanContextFromContext(ctx).TraceID().String())
+
+	origin := http.Header{}
+	InjectTraceParent(ctx, origin)
+	assert.Equal(t, traceParent, origin.Get("Traceparent"))
+
+	// Nothing is injected without a span
+	origin = http.Header{}
+	InjectTraceParent(context.Background(), origin)
+	assert.Empty(t, origin)
+}
@@ -80,7 +80,7 @@ type TracedHTTPRequest struct {
 func NewTracedHTTPRequest(req *http.Request, connIndex uint8, log *zerolog.Logger) *TracedHTTPRequest {
 	ctx, exists := extractTrace(req)
 	if !exists {
-		return &TracedHTTPRequest{req, &cfdTracer{trace.NewNoopTracerProvider(), &NoopOtlpClient{}, log}, connIndex}
+		return &TracedHTTPRequest{req, newLocalTracer(log), connIndex}
 	}
 	return &TracedHTTPRequest{req.WithContext(ctx), newCfdTracer(ctx, log), connIndex}
 }
@@ -98,7 +98,7 @@ type TracedContext struct {
 func NewTracedContext(ctx context.Context, traceContext string, log *zerolog.Logger) *TracedContext {
 	ctx, exists := extractTraceFromString(ctx, traceContext)
 	if !exists {
-		return &TracedContext{ctx, &cfdTracer{trace.NewNoopTracerProvider(), &NoopOtlpClient{}, log}}
+		return &TracedContext{ctx, newLocalTracer(log)}
 	}
 	return &TracedContext{ctx, newCfdTracer(ctx, log)}
 }
@@ -116,24 +116,41 @@ func newCfdTracer(ctx context.Context, log *zerolog.Logger) *cfdTracer {
 	if err != nil {
 		return &cfdTracer{trace.NewNoopTracerProvider(), &NoopOtlpClient{}, log}
 	}
-	tp := tracesdk.NewTracerProvider(
+	opts := []tracesdk.TracerProviderOption{
 		// We want to dump to in-memory exporter immediately
 		tracesdk.WithSyncer(exp),
 		// Record information about this application in a Resource.
-		tracesdk.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			serviceAttribute,
-			otelVersionAttribute,
-			hostnameAttribute,
-			cloudflaredVersionAttribute,
-			HostOSAttribute,
-			HostArchAttribute,
-		)),
-	)
+		tracesdk.WithResource(newResource()),
+	}
+	if otlpProcessor != nil {
+		opts = append(opts, tracesdk.WithSpanProcessor(otlpProcessor))
+	}
+	tp := tracesdk.NewTracerProvider(opts...)
 
 	return &cfdTracer{tp, mc, log}
 }
 
+// newLocalTracer creates a tracer for a request that isn't traced by the edge. Its spans are only exported over
+// OTLP, if it's enabled.
+func newLocalTracer(log *zerolog.Logger) *cfdTracer {
+	if otlpProvider == nil {
+		return &cfdTracer{trace.NewNoopTracerProvider(), &NoopOtlpClient{}, log}
+	}
+	return &cfdTracer{otlpProvider, &NoopOtlpClient{}, log}
+}
+
+func newResource() *resource.Resource {
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		serviceAttribute,
+		otelVersionAttribute,
+		hostnameAttribute,
+		cloudflaredVersionAttribute,
+		HostOSAttribute,
+		HostArchAttribute,
+	)
+}
+
 func (cft *cfdTracer) Tracer() trace.Tracer {
 	return cft.TracerProvider.Tracer(tracerInstrumentName)
 }
@@ -2,6 +2,7 @@ package tunnelstate
 
 import (
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -13,11 +14,17 @@ type ConnTracker struct {
 	// int is the connection Index
 	connectionInfo map[uint8]ConnectionInfo
 	log            *zerolog.Logger
+	// established counts every time a connection was established, reconnects the subset of those that
+	// re-established a connection index that had been connected before
+	established uint64
+	reconnects  uint64
 }
 
 type ConnectionInfo struct {
 	IsConnected bool
 	Protocol    connection.Protocol
+	// ConnectedAt is when the connection was last established
+	ConnectedAt time.Time
 }
 
 func NewConnTracker(log *zerolog.Logger) *ConnTracker {
@@ -37,9 +44,17 @@ func (ct *ConnTracker) OnTunnelEvent(c connection.Event) {
 	switch c.EventType {
 	case connection.Connected:
 		ct.Lock()
+		// Registration reports the connection as connected more than once, only count the first time
+		if previous := ct.connectionInfo[c.Index]; !previous.IsConnected {
+			ct.established++
+			if !previous.ConnectedAt.IsZero() {
+				ct.reconnects++
+			}
+		}
 		ci := ConnectionInfo{
 			IsConnected: true,
 			Protocol:    c.Protocol,
+			ConnectedAt: time.Now(),
 		}
 		ct.connectionInfo[c.Index] = ci
 		ct.Unlock()
@@ -78,3 +93,41 @@ func (ct *ConnTracker) HasConnectedWith(protocol connection.Protocol) bool {
 	}
 	return false
 }
+
+// OldestConnectedAt returns when the longest-lived active connection was established.
+// Returns false if there are no active connections.
+func (ct *ConnTracker) OldestConnectedAt() (time.Time, bool) {
+	ct.RLock()
+	defer ct.RUnlock()
+	var oldest time.Time
+	found := false
+	for _, ci := range ct.connectionInfo {
+		if !ci.IsConnected {
+			continue
+		}
+		if !found || ci.ConnectedAt.Before(oldest) {
+			oldest = ci.ConnectedAt
+			found = true
+		}
+	}
+	return oldest, found
+}
+
+// Connections returns a snapshot of the known connections, keyed by connection index.
+func (ct *ConnTracker) Connections() map[uint8]ConnectionInfo {
+	ct.RLock()
+	defer ct.RUnlock()
+	snapshot := make(map[uint8]ConnectionInfo, len(ct.connectionInfo))
+	for index, ci := range ct.connectionInfo {
+		snapshot[index] = ci
+	}
+	return snapshot
+}
+
+// ConnectionCounts returns how many times connections were established since the tracker was created, and
+// how many of those were reconnects of a connection index that had been connected before.
+func (ct *ConnTracker) ConnectionCounts() (established, reconnects uint64) {
+	ct.RLock()
+	defer ct.RUnlock()
+	return ct.established, ct.reconnects
+}
@@ -81,6 +81,8 @@ func (c *GorillaConn) SetDeadline(t time.Time) error {
 type Conn struct {
 	rw  io.ReadWriter
 	log *zerolog.Logger
+	// deflater compresses the messages written when permessage-deflate was negotiated, it's nil otherwise
+	deflater *deflater
 	// writeLock makes sure
 	// 1. Only one write at a time. The pinger and Stream function can both call write.
 	// 2. Close only returns after in progress Write is finished, and no more Write will succeed after calling Close.
@@ -93,13 +95,24 @@ func NewConn(ctx context.Context, rw io.ReadWriter, log *zerolog.Logger) *Conn {
 		rw:  rw,
 		log: log,
 	}
+	if perMessageDeflateFromContext(ctx) {
+		c.deflater = newDeflater()
+	}
 	go c.pinger(ctx)
 	return c
 }
 
 // Read will read messages from the websocket connection
 func (c *Conn) Read(reader []byte) (int, error) {
-	data, err := wsutil.ReadClientBinary(c.rw)
+	var (
+		data []byte
+		err  error
+	)
+	if c.deflater != nil {
+		data, err = readDeflateMessage(c.rw)
+	} else {
+		data, err = wsutil.ReadClientBinary(c.rw)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -114,7 +127,13 @@ func (c *Conn) Write(p []byte) (int, error) {
 	if c.done {
 		return 0, errors.New("write to closed websocket connection")
 	}
-	if err := wsutil.WriteServerBinary(c.rw, p); err != nil {
+	var err error
+	if c.deflater != nil {
+		err = c.deflater.writeMessage(c.rw, p)
+	} else {
+		err = wsutil.WriteServerBinary(c.rw, p)
+	}
+	if err != nil {
 		return 0, err
 	}
 
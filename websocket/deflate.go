@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	gobwas "github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	perMessageDeflate = "permessage-deflate"
+	// Messages are compressed independently of each other, so that neither side keeps a window per connection
+	perMessageDeflateResponse = "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+)
+
+var (
+	// Deflated messages are sent without the tail of the empty block ending them, RFC 7692 section 7.2.1
+	deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+	// The tail, followed by a final empty block so that inflating ends cleanly
+	inflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+)
+
+type perMessageDeflateKey struct{}
+
+// ContextWithPerMessageDeflate returns a copy of ctx enabling the permessage-deflate extension on the websocket
+// connections made with it, and in the response headers of the handshake of the requests made with it.
+func ContextWithPerMessageDeflate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, perMessageDeflateKey{}, true)
+}
+
+func perMessageDeflateFromContext(ctx context.Context) bool {
+	enabled, _ := ctx.Value(perMessageDeflateKey{}).(bool)
+	return enabled
+}
+
+// OffersPerMessageDeflate returns true if header offers the permessage-deflate extension with parameters that can
+// be accepted.
+func OffersPerMessageDeflate(header http.Header) bool {
+	for _, value := range header.Values("Sec-Websocket-Extensions") {
+		for _, offer := range strings.Split(value, ",") {
+			if acceptableDeflateOffer(offer) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptableDeflateOffer(offer string) bool {
+	params := strings.Split(offer, ";")
+	if strings.TrimSpace(params[0]) != perMessageDeflate {
+		return false
+	}
+	for _, param := range params[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.TrimSpace(name) {
+		case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+			// compress/flate inflates messages deflated with any window
+		case "server_max_window_bits":
+			// compress/flate always deflates with the largest window
+			if value != "15" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// readDeflateMessage reads the next binary message from rw, inflating it if it was compressed.
+func readDeflateMessage(rw io.ReadWriter) ([]byte, error) {
+	controlHandler := wsutil.ControlFrameHandler(rw, gobwas.StateServerSide)
+	rd := wsutil.Reader{
+		Source:         rw,
+		State:          gobwas.StateServerSide | gobwas.StateExtended,
+		OnIntermediate: controlHandler,
+	}
+	for {
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Rsv2() || hdr.Rsv3() || hdr.Rsv1() && hdr.OpCode.IsControl() {
+			return nil, gobwas.ErrProtocolNonZeroRsv
+		}
+		if hdr.OpCode.IsControl() {
+			if err := controlHandler(hdr, &rd); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if hdr.OpCode&gobwas.OpBinary == 0 {
+			if err := rd.Discard(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		payload, err := io.ReadAll(&rd)
+		if err != nil || !hdr.Rsv1() {
+			return payload, err
+		}
+		return io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(inflateTail))))
+	}
+}
+
+// deflater compresses the messages written to a websocket connection.
+type deflater struct {
+	buf    bytes.Buffer
+	writer *flate.Writer
+}
+
+func newDeflater() *deflater {
+	d := &deflater{}
+	// Only fails on invalid levels
+	d.writer, _ = flate.NewWriter(&d.buf, flate.BestSpeed)
+	return d
+}
+
+// writeMessage writes p to w as a compressed binary message. It isn't safe for concurrent use.
+func (d *deflater) writeMessage(w io.Writer, p []byte) error {
+	d.buf.Reset()
+	d.writer.Reset(&d.buf)
+	if _, err := d.writer.Write(p); err != nil {
+		return err
+	}
+	if err := d.writer.Flush(); err != nil {
+		return err
+	}
+	frame := gobwas.NewBinaryFrame(bytes.TrimSuffix(d.buf.Bytes(), deflateTail))
+	frame.Header.Rsv = gobwas.Rsv(true, false, false)
+	return gobwas.WriteFrame(w, frame)
+}
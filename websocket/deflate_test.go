@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffersPerMessageDeflate(t *testing.T) {
+	tests := []struct {
+		extensions string
+		offered    bool
+	}{
+		{extensions: "", offered: false},
+		{extensions: "permessage-deflate", offered: true},
+		{extensions: "permessage-deflate; client_max_window_bits", offered: true},
+		{extensions: "permessage-deflate; server_no_context_takeover; client_no_context_takeover", offered: true},
+		{extensions: `permessage-deflate; server_max_window_bits="15"`, offered: true},
+		{extensions: "permessage-deflate; server_max_window_bits=10", offered: false},
+		{extensions: "permessage-deflate; server_max_window_bits=10, permessage-deflate", offered: true},
+		{extensions: "x-webkit-deflate-frame", offered: false},
+		{extensions: "permessage-deflate; unknown", offered: false},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.extensions != "" {
+			header.Set("Sec-Websocket-Extensions", test.extensions)
+		}
+		assert.Equal(t, test.offered, OffersPerMessageDeflate(header), test.extensions)
+	}
+}
+
+func TestNewResponseHeaderPerMessageDeflate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Sec-WebSocket-Key", testSecWebsocketKey)
+	assert.Empty(t, NewResponseHeader(req).Get("Sec-Websocket-Extensions"))
+
+	req = req.WithContext(ContextWithPerMessageDeflate(req.Context()))
+	assert.Equal(t, perMessageDeflateResponse, NewResponseHeader(req).Get("Sec-Websocket-Extensions"))
+}
+
+// Messages are compressed both ways with a client negotiating permessage-deflate
+func TestConnPerMessageDeflate(t *testing.T) {
+	log := zerolog.Nop()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, OffersPerMessageDeflate(r.Header))
+		r = r.WithContext(ContextWithPerMessageDeflate(r.Context()))
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, _ = fmt.Fprint(rw, "HTTP/1.1 101 Switching Protocols\r\n")
+		require.NoError(t, NewResponseHeader(r).Write(rw))
+		_, _ = fmt.Fprint(rw, "\r\n")
+		require.NoError(t, rw.Flush())
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		wsConn := NewConn(ctx, conn, &log)
+		defer wsConn.Close()
+		buf := make([]byte, 1024)
+		n, err := wsConn.Read(buf)
+		require.NoError(t, err)
+		_, err = wsConn.Write(append([]byte("echo-"), buf[:n]...))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	dialer := gorilla.Dialer{EnableCompression: true}
+	client, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, perMessageDeflateResponse, resp.Header.Get("Sec-Websocket-Extensions"))
+
+	message := strings.Repeat("compressible ", 20)
+	require.NoError(t, client.WriteMessage(gorilla.BinaryMessage, []byte(message)))
+	_, echo, err := client.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "echo-"+message, string(echo))
+}
@@ -13,12 +13,16 @@ func IsWebSocketUpgrade(req *http.Request) bool {
 	return websocket.IsWebSocketUpgrade(req)
 }
 
-// NewResponseHeader returns headers needed to return to origin for completing handshake
+// NewResponseHeader returns headers needed to return to origin for completing handshake. The permessage-deflate
+// extension is accepted if it's enabled in the context of req.
 func NewResponseHeader(req *http.Request) http.Header {
 	header := http.Header{}
 	header.Add("Connection", "Upgrade")
 	header.Add("Sec-Websocket-Accept", generateAcceptKey(req.Header.Get("Sec-WebSocket-Key")))
 	header.Add("Upgrade", "websocket")
+	if perMessageDeflateFromContext(req.Context()) {
+		header.Add("Sec-Websocket-Extensions", perMessageDeflateResponse)
+	}
 	return header
 }
 